@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type batchContextKey struct{}
+
+// batchFrame marks the start (Args set) and end (OK set) of the output of one command in a batch.
+type batchFrame struct {
+	OK    *bool    `json:"ok,omitempty"`
+	Error string   `json:"error,omitempty"`
+	Args  []string `json:"args,omitempty"`
+	Batch int      `json:"batch"`
+}
+
+func batchCmd(build func() *cobra.Command) *cobra.Command {
+	var (
+		file      string
+		keepGoing bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run several commands from a file with one shared client and cache",
+		Long: "Runs the commands in a file, one per line without the leading \"hn\", in a single process so they\n" +
+			"share the client, its in-memory cache, and the open cache file instead of paying for them on every\n" +
+			"invocation. Blank lines and lines starting with # are skipped, and arguments can be quoted with ' or \".\n" +
+			"Global flags like --cache-path apply to the whole batch and cannot be given on a line.\n" +
+			"Each command's output is framed by JSON lines with its number in \"batch\": one with its \"args\"\n" +
+			"before it, and one with \"ok\" and any \"error\" after it. The batch stops at the first failure\n" +
+			"unless --keep-going is set. Commands are not transactional: the effects of commands before a\n" +
+			"failure remain.",
+		Example: "  hn batch -f commands.txt\n" +
+			"  printf 'top --limit 5\\nitem 43740065\\n' | hn batch -f -",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			if ctx.Value(batchContextKey{}) != nil {
+				return fmt.Errorf("%w: batch cannot be nested", errInvalidArgs)
+			}
+
+			lines, err := readBatchFile(file)
+			if err != nil {
+				return err
+			}
+
+			return runBatch(context.WithValue(ctx, batchContextKey{}, true), build, lines, keepGoing)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file of commands, or - for stdin")
+	cmd.Flags().BoolVar(&keepGoing, "keep-going", false, "run the remaining commands after a failure")
+
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// readBatchFile returns the arguments of each command in the file, skipping blank lines and comments.
+func readBatchFile(path string) (_ [][]string, err error) {
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path) //nolint:gosec // G304 intended
+		if err != nil {
+			return nil, fmt.Errorf("error opening batch file: %w", err)
+		}
+
+		defer func() { _ = f.Close() }()
+
+		r = f
+	}
+
+	var lines [][]string
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+
+	for scanner.Scan() {
+		n++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args, err := splitBatchLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", errInvalidArgs, n, err)
+		}
+
+		lines = append(lines, args)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	return lines, nil
+}
+
+var (
+	errUnterminatedQuote = errors.New("unterminated quote")
+	errBatchFailed       = errors.New("batch commands failed")
+)
+
+// splitBatchLine splits a line into arguments at spaces outside of quotes. Within double quotes and outside of
+// quotes a backslash escapes the next character; within single quotes everything is literal.
+func splitBatchLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errUnterminatedQuote
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+func runBatch(ctx context.Context, build func() *cobra.Command, lines [][]string, keepGoing bool) error {
+	_, writer, _ := getGlobalItems(ctx)
+	encoder := json.NewEncoder(writer)
+
+	failed := 0
+
+	for i, args := range lines {
+		err := encoder.Encode(batchFrame{nil, "", args, i + 1})
+		if err != nil {
+			return fmt.Errorf("failed to write to output: %w", err)
+		}
+
+		cmd := build()
+		cmd.SetArgs(args)
+
+		runErr := cmd.ExecuteContext(ctx)
+
+		ok := runErr == nil
+		frame := batchFrame{&ok, "", nil, i + 1}
+
+		if runErr != nil {
+			frame.Error = runErr.Error()
+			failed++
+		}
+
+		err = encoder.Encode(frame)
+		if err != nil {
+			return fmt.Errorf("failed to write to output: %w", err)
+		}
+
+		if runErr != nil && !keepGoing {
+			return fmt.Errorf("batch command %d failed: %w", i+1, runErr)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", errBatchFailed, failed, len(lines))
+	}
+
+	return nil
+}
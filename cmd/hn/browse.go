@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var errBrowseLimit = errors.New("browse limit reached")
+
+func browseCmd() *cobra.Command {
+	var (
+		from      string
+		query     string
+		by        string
+		pagerMode string
+		types     []string
+		limit     int
+		lines     int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "browse",
+		Short: "Page through the items of an archive in the terminal",
+		Long: "Shows the items of an NDJSON archive or SQLite item database (the cache file by default) one after\n" +
+			"another through $PAGER, like git, so they can be paged and searched with / in less. Nothing is\n" +
+			"retrieved from the network. --query keeps only items whose title, text, URL, or author contain it,\n" +
+			"ignoring case.",
+		Example: "  hn browse -f out.json\n" +
+			"  hn browse -f archive.db --types story --query sqlite\n" +
+			"  hn browse --by dang --lines 10",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			_, writer, outputFile := getGlobalItems(ctx)
+
+			if from == "" {
+				from = getGlobalCachePath(ctx)
+				if from == "" {
+					return fmt.Errorf("%w: --from is required with --no-cache", errInvalidArgs)
+				}
+			}
+
+			if limit < 0 || lines < 0 {
+				return fmt.Errorf("%w: --limit and --lines must not be negative", errInvalidArgs)
+			}
+
+			width, height := terminalSize(outputFile)
+			if width == 0 {
+				width = defaultBrowseWidth
+			}
+
+			var out io.Writer = writer
+			if height > 0 {
+				out = os.Stdout
+			}
+
+			p, err := unl.NewPager(pagerMode, height, os.Getenv, out)
+			if err != nil {
+				return fmt.Errorf("%w: --pager %w", errInvalidArgs, err)
+			}
+
+			b := browser{strings.ToLower(query), by, types, limit, lines, width, 0}
+
+			var buf bytes.Buffer
+
+			err = rangeArchive(ctx, from, func(value []byte) error { return b.write(&buf, value) })
+			if err != nil && !errors.Is(err, errBrowseLimit) {
+				return err
+			}
+
+			return p.Write(ctx, &buf)
+		},
+	}
+
+	cmd.Flags().StringVarP(&from, "from", "f", "", "input NDJSON archive or SQLite database (default the cache file)")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "only items whose title, text, URL, or author contain this")
+	cmd.Flags().StringVar(&by, "by", "", "only items by this user")
+	cmd.Flags().StringSliceVar(&types, "types", nil, "only items of these types (default all)")
+	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "limit number of items shown (0 for no limit)")
+	cmd.Flags().IntVar(&lines, "lines", defaultBrowseLines, "lines of text shown for each item (0 for titles only)")
+	cmd.Flags().StringVar(&pagerMode, "pager", unl.PagerAuto,
+		"page output through $PAGER: auto (when taller than the terminal), always, or never")
+
+	return cmd
+}
+
+const (
+	defaultBrowseLines = 3
+	defaultBrowseWidth = 80
+	browseIndent       = "    "
+)
+
+// terminalSize returns the size of the terminal on stdout, or 0 for both if the output is not a terminal. The width
+// is defaultBrowseWidth with no height if the size is unknown.
+func terminalSize(outputFile *os.File) (int, int) {
+	fd := int(os.Stdout.Fd()) //nolint:gosec // G115 file descriptors fit in an int
+	if outputFile != nil || !term.IsTerminal(fd) {
+		return 0, 0
+	}
+
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		return defaultBrowseWidth, 0
+	}
+
+	return width, height
+}
+
+// browser renders the items of an archive that match its filters as blocks of a heading, the title, and the start of
+// the text.
+type browser struct {
+	query string
+	by    string
+	types []string
+	limit int
+	lines int
+	width int
+	shown int
+}
+
+func (b *browser) write(buf *bytes.Buffer, value []byte) error {
+	if isNullBody(value) {
+		return nil
+	}
+
+	var item hn.Item
+
+	err := json.Unmarshal(value, &item)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+
+	if !b.include(&item) {
+		return nil
+	}
+
+	heading := []string{strconv.Itoa(item.ID) + " " + string(item.Type)}
+	if item.By != "" {
+		heading[0] += " by " + item.By
+	}
+
+	heading = append(heading, time.Unix(item.Time, 0).UTC().Format("2006-01-02 15:04"))
+
+	if item.Parent != nil {
+		heading = append(heading, "reply to "+strconv.Itoa(*item.Parent))
+	} else {
+		heading = append(heading, strconv.Itoa(item.Score)+" points", strconv.Itoa(item.Descendants)+" comments")
+	}
+
+	buf.WriteString(strings.Join(heading, " · "))
+	buf.WriteByte('\n')
+
+	text := ""
+	if item.Title != "" || item.Dead || item.Deleted {
+		writeBrowseLine(buf, unl.PrettyFormatTitle(&item, true))
+
+		text = unl.PrettyCleanText(item.Text)
+	} else {
+		text = unl.PrettyFormatTitle(&item, false)
+	}
+
+	for _, line := range unl.PrettyWrapText(text, b.width-len(browseIndent), b.lines) {
+		writeBrowseLine(buf, line)
+	}
+
+	buf.WriteByte('\n')
+
+	b.shown++
+	if b.limit > 0 && b.shown >= b.limit {
+		return errBrowseLimit
+	}
+
+	return nil
+}
+
+func writeBrowseLine(buf *bytes.Buffer, line string) {
+	buf.WriteString(browseIndent)
+	buf.WriteString(line)
+	buf.WriteByte('\n')
+}
+
+func (b *browser) include(item *hn.Item) bool {
+	if len(b.types) > 0 && !slices.Contains(b.types, string(item.Type)) {
+		return false
+	}
+
+	if b.by != "" && item.By != b.by {
+		return false
+	}
+
+	if b.query == "" {
+		return true
+	}
+
+	for _, field := range []string{item.Title, item.Text, item.URL, item.By} {
+		if strings.Contains(strings.ToLower(field), b.query) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/spf13/cobra"
+)
+
+func cacheCmd(getter core.Getter[string, io.ReadCloser], clock core.Clock, defaultCachePath string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache [command]",
+		Short: "Inspect and maintain the persistent cache",
+		Args:  cobra.NoArgs,
+		RunE:  func(cmd *cobra.Command, _ []string) error { return cmd.Help() },
+	}
+
+	cmd.AddCommand(cacheVerifyCmd(getter, clock))
+	cmd.AddCommand(cacheCompactCmd())
+	cmd.AddCommand(cacheListCmd(defaultCachePath))
+
+	return cmd
+}
+
+func cacheVerifyCmd(getter core.Getter[string, io.ReadCloser], clock core.Clock) *cobra.Command {
+	var sample int
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Compare a random sample of cached items against the live API",
+		Long: "Re-fetches a random sample of cached items directly from the API and reports how many diverge,\n" +
+			"split by whether the stale policy already considers them stale. Divergent items the policy\n" +
+			"considers fresh are served from the cache despite being out of date.",
+		Example: "  hn cache verify --sample 1000",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			_, writer, _ := getGlobalItems(ctx)
+
+			cachePath := getGlobalCachePath(ctx)
+			if cachePath == "" {
+				return fmt.Errorf("%w: cache verify cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			report, err := runCacheVerify(ctx, getter, clock, cachePath, sample)
+			if err != nil {
+				return err
+			}
+
+			err = json.NewEncoder(writer).Encode(report)
+			if err != nil {
+				return fmt.Errorf("failed to write to output: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	const defaultSample = 1000
+
+	cmd.Flags().IntVar(&sample, "sample", defaultSample, "number of cached items to verify")
+
+	return cmd
+}
+
+func cacheCompactCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "Reclaim unused space in the persistent cache",
+		Long: "Writes a compacted copy of the cache with VACUUM INTO and renames it over the original. Other tools\n" +
+			"can keep reading the cache meanwhile, unlike an in-place VACUUM, but items they write before the\n" +
+			"rename are lost, so avoid compacting while another process is filling the cache.",
+		Example: "  hn cache compact",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			_, writer, _ := getGlobalItems(ctx)
+
+			cachePath := getGlobalCachePath(ctx)
+			if cachePath == "" {
+				return fmt.Errorf("%w: cache compact cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			report, err := runCacheCompact(ctx, cachePath)
+			if err != nil {
+				return err
+			}
+
+			err = json.NewEncoder(writer).Encode(report)
+			if err != nil {
+				return fmt.Errorf("failed to write to output: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func cacheListCmd(defaultCachePath string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the default and named caches with their sizes",
+		Long: "Lists the caches in the directory of the default cache file: the default cache, with an empty name,\n" +
+			"and the caches selected with --cache-name.",
+		Example: "  hn cache list",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, writer, _ := getGlobalItems(cmd.Context())
+
+			caches, err := hn.NamedFileCaches(filepath.Dir(defaultCachePath))
+			if err != nil {
+				return fmt.Errorf("failed to list caches: %w", err)
+			}
+
+			encoder := json.NewEncoder(writer)
+
+			for _, cache := range caches {
+				err = encoder.Encode(cache)
+				if err != nil {
+					return fmt.Errorf("failed to write to output: %w", err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+type cacheCompactReport struct {
+	Before int64 `json:"before"`
+	After  int64 `json:"after"`
+}
+
+func runCacheCompact(ctx context.Context, cachePath string) (_ *cacheCompactReport, err error) {
+	before, err := hn.FileCacheSize(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	compacted := cachePath + ".compact"
+
+	err = os.Remove(compacted)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale compacted file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = os.Remove(compacted)
+		}
+	}()
+
+	cache, err := core.NewItemFileCache(ctx, core.NewClock(), cachePath, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+
+	err = errors.Join(cache.VacuumInto(ctx, compacted), cache.Close())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact cache: %w", err)
+	}
+
+	err = syncFile(compacted)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(compacted, cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace cache: %w", err)
+	}
+
+	after, err := hn.FileCacheSize(cachePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cacheCompactReport{before, after}, nil
+}
+
+// syncFile flushes the file at path to disk so it survives a crash once renamed into place.
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0) //nolint:gosec // G304 intended
+	if err != nil {
+		return fmt.Errorf("failed to open compacted file: %w", err)
+	}
+
+	err = errors.Join(f.Sync(), f.Close())
+	if err != nil {
+		return fmt.Errorf("failed to sync compacted file: %w", err)
+	}
+
+	return nil
+}
+
+type cacheVerifyReport struct {
+	Fields           map[string]int `json:"fields"`
+	FreshDivergedIDs []int          `json:"freshDivergedIds"`
+	Sampled          int            `json:"sampled"`
+	Stale            int            `json:"stale"`
+	Fresh            int            `json:"fresh"`
+	Identical        int            `json:"identical"`
+	DivergedStale    int            `json:"divergedStale"`
+	DivergedFresh    int            `json:"divergedFresh"`
+	NullLive         int            `json:"nullLive"`
+}
+
+func runCacheVerify(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	sample int,
+) (_ *cacheVerifyReport, err error) {
+	if clock == nil {
+		clock = core.NewClock()
+	}
+
+	cache, err := core.NewItemFileCache(ctx, clock, cachePath, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	entries, err := cache.Sample(ctx, sample)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample cache: %w", err)
+	}
+
+	live, err := hn.NewClient(
+		ctx,
+		hn.WithFileCachePath(""),
+		hn.WithCacheFor(0),
+		hn.WithSource(getGlobalSource(ctx)),
+		hn.WithGetter(getter),
+		hn.WithClock(clock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, live.Close()) }()
+
+	byID := make(map[int]core.ItemFileCacheEntry, len(entries))
+	ids := make([]int, 0, len(entries))
+
+	for _, e := range entries {
+		byID[e.ID] = e
+		ids = append(ids, e.ID)
+	}
+
+	report := &cacheVerifyReport{map[string]int{}, []int{}, len(entries), 0, 0, 0, 0, 0, 0}
+
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	err = live.Advanced().NewRawItemStream(ctx).SearchUnordered(ids, func(id int, r io.ReadCloser) (bool, []int, error) {
+		defer func() { _ = r.Close() }()
+
+		var buf bytes.Buffer
+
+		_, err := buf.ReadFrom(r)
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to read item %d: %w", id, err)
+		}
+
+		return true, nil, report.add(byID[id], buf.Bytes())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve live items: %w", err)
+	}
+
+	return report, nil
+}
+
+func (r *cacheVerifyReport) add(e core.ItemFileCacheEntry, liveValue []byte) error {
+	if e.Stale {
+		r.Stale++
+	} else {
+		r.Fresh++
+	}
+
+	var cached, live *hn.Item
+
+	err := json.Unmarshal(e.Value, &cached)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal cached item %d: %w", e.ID, err)
+	}
+
+	err = json.Unmarshal(liveValue, &live)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal live item %d: %w", e.ID, err)
+	}
+
+	if live == nil {
+		r.NullLive++
+		return nil
+	}
+
+	changed := cached.ChangedFields(live)
+	if len(changed) == 0 {
+		r.Identical++
+		return nil
+	}
+
+	for _, field := range changed {
+		r.Fields[field]++
+	}
+
+	if e.Stale {
+		r.DivergedStale++
+	} else {
+		r.DivergedFresh++
+		r.FreshDivergedIDs = append(r.FreshDivergedIDs, e.ID)
+	}
+
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"math"
 	"os"
 	"strconv"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
 )
 
 var ErrCannotContinue = errors.New("cannot continue from provided file")
@@ -112,12 +114,17 @@ func lastIDs(f *os.File, needed int) ([]int, error) {
 			continue
 		}
 
-		err = json.Unmarshal(lines[i], &item)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+		id, _, ok := core.ItemIDAndTime(lines[i])
+		if !ok {
+			err = json.Unmarshal(lines[i], &item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+
+			id = item.ID
 		}
 
-		ids = append(ids, item.ID)
+		ids = append(ids, id)
 	}
 
 	return ids, nil
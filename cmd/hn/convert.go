@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/spf13/cobra"
+)
+
+func convertCmd() *cobra.Command {
+	var (
+		from      string
+		to        string
+		ascending bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Convert between NDJSON archives and SQLite item databases",
+		Long: "Loads an NDJSON archive (as written by scan) into a SQLite database using the item cache schema,\n" +
+			"or writes the items in such a database back out as NDJSON. The direction is chosen by checking\n" +
+			"whether --from is a SQLite database. Items loaded from an archive are marked as refreshed at the\n" +
+			"modification time of the archive.",
+		Example: "  hn convert --from out.json --to archive.db\n" +
+			"  hn convert --from archive.db --to out.json --asc",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			isSQLite, err := isSQLiteFile(from)
+			if err != nil {
+				return err
+			}
+
+			var n int
+			if isSQLite {
+				n, err = convertSQLiteToNDJSON(ctx, from, to, ascending)
+			} else {
+				if cmd.Flags().Changed("asc") {
+					return fmt.Errorf("%w: --asc only applies when converting from SQLite", errInvalidArgs)
+				}
+
+				n, err = convertNDJSONToSQLite(ctx, from, to)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(os.Stderr, "converted %d items\n", n)
+			if err != nil {
+				return fmt.Errorf("failed to write summary: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "input NDJSON archive or SQLite database")
+	cmd.Flags().StringVar(&to, "to", "", "output SQLite database or NDJSON archive")
+	cmd.Flags().BoolVar(&ascending, "asc", false, "write NDJSON in ascending order")
+
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func isSQLiteFile(path string) (bool, error) {
+	f, err := os.Open(path) //nolint:gosec // G304 intended
+	if err != nil {
+		return false, fmt.Errorf("error opening input file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	header := []byte("SQLite format 3\x00")
+	buf := make([]byte, len(header))
+
+	_, err = io.ReadFull(f, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("error reading input file: %w", err)
+	}
+
+	return bytes.Equal(buf, header), nil
+}
+
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
+
+func convertNDJSONToSQLite(ctx context.Context, from string, to string) (_ int, err error) {
+	const putBatchSize = 100
+
+	f, err := os.Open(from) //nolint:gosec // G304 intended
+	if err != nil {
+		return 0, fmt.Errorf("error opening input file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	cache, err := core.NewItemFileCache(ctx, fixedClock{stat.ModTime()}, to, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output database: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	const maxLineLength = 16 * 1024 * 1024
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineLength)
+
+	n := 0
+	batch := make([][]byte, 0, putBatchSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.Equal(line, []byte("null")) {
+			// null bodies are not written, so they are not counted either
+			continue
+		}
+
+		batch = append(batch, bytes.Clone(line))
+		if len(batch) < putBatchSize {
+			continue
+		}
+
+		err = cache.Put(ctx, batch)
+		if err != nil {
+			return n, fmt.Errorf("failed to write items: %w", err)
+		}
+
+		n += len(batch)
+		batch = batch[:0]
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return n, fmt.Errorf("failed to scan input file: %w", err)
+	}
+
+	err = cache.Put(ctx, batch)
+	if err != nil {
+		return n, fmt.Errorf("failed to write items: %w", err)
+	}
+
+	return n + len(batch), nil
+}
+
+func convertSQLiteToNDJSON(ctx context.Context, from string, to string, ascending bool) (_ int, err error) {
+	cache, err := core.NewItemFileCache(ctx, core.NewClock(), from, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open input database: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	const outputFilePermissions = 0o644
+
+	//nolint:gosec // G304 intended
+	f, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, outputFilePermissions)
+	if err != nil {
+		return 0, fmt.Errorf("error opening output file: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, f.Close()) }()
+
+	writer := bufio.NewWriter(f)
+	n := 0
+
+	err = cache.Range(ctx, ascending, func(_ int, value []byte) error {
+		_, err := writer.Write(value)
+		if err != nil {
+			return fmt.Errorf("failed to write item: %w", err)
+		}
+
+		err = writer.WriteByte('\n')
+		if err != nil {
+			return fmt.Errorf("failed to write newline: %w", err)
+		}
+
+		n++
+
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return n, fmt.Errorf("failed to flush output file: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// coordination holds the scan flags for leasing ranges from a store shared with other processes.
+type coordination struct {
+	path   string
+	size   int
+	ttl    time.Duration
+	leases int
+}
+
+// scanOwner names this process in the leases it holds.
+func scanOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
+
+// runCoordinatedScan records plan in the store unless another process already did, then calls scan for one leased
+// range at a time until none are left or c.leases are done. Leases are renewed while they are scanned, and a lease
+// taken over by another process stops the scan of its range with an error wrapping core.ErrScanLeaseLost.
+func runCoordinatedScan(
+	ctx context.Context,
+	clock core.Clock,
+	c coordination,
+	owner string,
+	plan core.ScanPlan,
+	scan func(ctx context.Context, from int, to int) error,
+) (err error) {
+	store, err := core.NewScanLeaseStore(ctx, clock, c.path)
+	if err != nil {
+		return fmt.Errorf("failed to open lease store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	plan.Size = c.size
+
+	_, err = store.Plan(ctx, plan)
+	if err != nil {
+		return fmt.Errorf("failed to record scan plan: %w", err)
+	}
+
+	for n := 0; c.leases == 0 || n < c.leases; n++ {
+		lease, err := store.Lease(ctx, owner, c.ttl)
+		if err != nil {
+			return fmt.Errorf("failed to lease range: %w", err)
+		}
+
+		if lease == nil {
+			return nil
+		}
+
+		err = scanLease(ctx, store, lease, c.ttl, scan)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanLease scans the range of lease, renewing it every third of ttl until the scan returns, and then completes it.
+func scanLease(
+	ctx context.Context,
+	store *core.ScanLeaseStore,
+	lease *core.ScanLease,
+	ttl time.Duration,
+	scan func(ctx context.Context, from int, to int) error,
+) error {
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		wg       sync.WaitGroup
+		renewErr error
+	)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		const renewalsPerTTL = 3
+
+		ticker := time.NewTicker(ttl / renewalsPerTTL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+				renewErr = store.Renew(leaseCtx, lease, ttl)
+				if renewErr != nil {
+					cancel(renewErr)
+					return
+				}
+			}
+		}
+	}()
+
+	err := scan(leaseCtx, lease.From, lease.To)
+
+	cancel(nil)
+	wg.Wait()
+
+	if renewErr != nil && !errors.Is(renewErr, context.Canceled) {
+		return fmt.Errorf("failed to renew lease of %d to %d: %w", lease.From, lease.To, errors.Join(renewErr, err))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = store.Complete(ctx, lease)
+	if err != nil {
+		return fmt.Errorf("failed to complete lease of %d to %d: %w", lease.From, lease.To, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+)
+
+// datasetFields are the item fields and links that --fields accepts.
+//
+//nolint:gochecknoglobals // constant
+var datasetFields = append(slices.Clone(hn.ItemFields), hn.ItemLinkFields...)
+
+func datasetCmd() *cobra.Command {
+	var (
+		from   string
+		prefix string
+		splits string
+		fields []string
+		types  []string
+		clean  []string
+		sample float64
+		seed   int64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dataset",
+		Short: "Write seeded train/test splits of archived items as JSONL",
+		Long: "Samples items from an NDJSON archive or SQLite item database (the cache file by default) and writes\n" +
+			"the selected fields of each to one JSONL file per split, named <prefix>.<split>.jsonl. Titles and\n" +
+			"text are cleaned the same way unl displays them unless --clean selects other modes:\n" +
+			"  paragraphs: separate paragraphs with a blank line\n" +
+			"  code:       keep code blocks verbatim between ``` lines\n" +
+			"  strip-urls: remove links and bare URLs\n" +
+			"  lowercase:  lowercase the text outside code blocks\n" +
+			"The permalink and context fields link to each item on news.ycombinator.com, the context link\n" +
+			"showing a comment within its thread. Dead and deleted items are skipped.\n" +
+			"Each item is sampled and assigned to a split by a hash of --seed and its ID alone, so the same seed\n" +
+			"gives the same splits regardless of the order or completeness of the input, and an item stays in its\n" +
+			"split as the archive grows.",
+		Example: "  hn dataset --split train:0.8,test:0.2 --fields title,text,score\n" +
+			"  hn dataset --from archive.db --types story --sample 0.1 --seed 7 --prefix stories\n" +
+			"  hn dataset --fields text --clean paragraphs,code,strip-urls",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			if from == "" {
+				from = getGlobalCachePath(ctx)
+				if from == "" {
+					return fmt.Errorf("%w: --from is required with --no-cache", errInvalidArgs)
+				}
+			}
+
+			parsed, err := parseDatasetSplits(splits)
+			if err != nil {
+				return err
+			}
+
+			for _, field := range fields {
+				if !slices.Contains(datasetFields, field) {
+					return fmt.Errorf("%w: unrecognized field %q, expected one of %s",
+						errInvalidArgs, field, strings.Join(datasetFields, ", "))
+				}
+			}
+
+			if sample <= 0 || sample > 1 {
+				return fmt.Errorf("%w: --sample must be greater than 0 and at most 1", errInvalidArgs)
+			}
+
+			cleanOptions, err := parseCleanModes(clean)
+			if err != nil {
+				return err
+			}
+
+			d := dataset{parsed, fields, types, cleanOptions, sample, uint64(seed)} //nolint:gosec // G115 any seed
+
+			counts, err := d.run(ctx, from, prefix)
+			if err != nil {
+				return err
+			}
+
+			summary := make([]string, len(parsed))
+			for i, split := range parsed {
+				summary[i] = fmt.Sprintf("%d %s", counts[i], split.name)
+			}
+
+			_, err = fmt.Fprintf(os.Stderr, "wrote %s\n", strings.Join(summary, ", "))
+			if err != nil {
+				return fmt.Errorf("failed to write summary: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "input NDJSON archive or SQLite database (default the cache file)")
+	cmd.Flags().StringVar(&prefix, "prefix", "dataset", "output file prefix")
+	cmd.Flags().StringVar(&splits, "split", "train:0.8,test:0.2", "comma-separated name:fraction splits")
+	cmd.Flags().StringSliceVar(&fields, "fields", []string{"title", "text", "score"},
+		"fields to write: "+strings.Join(datasetFields, ", "))
+	cmd.Flags().StringSliceVar(&types, "types", nil, "only items of these types (default all)")
+	cmd.Flags().StringSliceVar(&clean, "clean", nil, "text cleaning modes: paragraphs, code, strip-urls, lowercase")
+	cmd.Flags().Float64Var(&sample, "sample", 1, "fraction of the items to include")
+	cmd.Flags().Int64Var(&seed, "seed", 1, "seed for sampling and split assignment")
+
+	return cmd
+}
+
+type datasetSplit struct {
+	name string
+	// upper is the cumulative fraction of the splits up to and including this one.
+	upper float64
+}
+
+func parseDatasetSplits(v string) ([]datasetSplit, error) {
+	var splits []datasetSplit
+
+	total := 0.0
+
+	for part := range strings.SplitSeq(v, ",") {
+		name, fraction, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: split %q must be name:fraction", errInvalidArgs, part)
+		}
+
+		f, err := strconv.ParseFloat(fraction, 64)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("%w: split %q must have a positive fraction", errInvalidArgs, part)
+		}
+
+		if slices.ContainsFunc(splits, func(s datasetSplit) bool { return s.name == name }) {
+			return nil, fmt.Errorf("%w: split %q is repeated", errInvalidArgs, name)
+		}
+
+		total += f
+		splits = append(splits, datasetSplit{name, total})
+	}
+
+	const tolerance = 1e-6
+	if math.Abs(total-1) > tolerance {
+		return nil, fmt.Errorf("%w: split fractions must sum to 1, got %g", errInvalidArgs, total)
+	}
+
+	splits[len(splits)-1].upper = 1
+
+	return splits, nil
+}
+
+func parseCleanModes(modes []string) (unl.CleanOptions, error) {
+	var opts unl.CleanOptions
+
+	for _, mode := range modes {
+		switch mode {
+		case "paragraphs":
+			opts.ParagraphBreaks = true
+		case "code":
+			opts.CodeFences = true
+		case "strip-urls":
+			opts.StripURLs = true
+		case "lowercase":
+			opts.Lowercase = true
+		default:
+			return opts, fmt.Errorf("%w: unrecognized cleaning mode %q", errInvalidArgs, mode)
+		}
+	}
+
+	return opts, nil
+}
+
+type dataset struct {
+	splits []datasetSplit
+	fields []string
+	types  []string
+	clean  unl.CleanOptions
+	sample float64
+	seed   uint64
+}
+
+// assign returns the index of the split for the item with id, or -1 if it is not sampled.
+func (d *dataset) assign(id int) int {
+	h := splitMix64(d.seed ^ uint64(id)) //nolint:gosec // G115 IDs are positive
+
+	if d.sample < 1 && unitFloat(h) >= d.sample {
+		return -1
+	}
+
+	u := unitFloat(splitMix64(h))
+	for i, split := range d.splits {
+		if u < split.upper {
+			return i
+		}
+	}
+
+	return len(d.splits) - 1
+}
+
+func (d *dataset) include(item *hn.Item) bool {
+	if item.Dead || item.Deleted {
+		return false
+	}
+
+	return len(d.types) == 0 || slices.Contains(d.types, string(item.Type))
+}
+
+// run writes the splits of the items in from and returns the number of items written to each.
+func (d *dataset) run(ctx context.Context, from string, prefix string) (_ []int, err error) {
+	counts := make([]int, len(d.splits))
+	writers := make([]*bufio.Writer, len(d.splits))
+
+	for i, split := range d.splits {
+		const outputFilePermissions = 0o644
+
+		path := prefix + "." + split.name + ".jsonl"
+
+		//nolint:gosec // G304 intended
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, outputFilePermissions)
+		if err != nil {
+			return nil, fmt.Errorf("error opening output file: %w", err)
+		}
+
+		writers[i] = bufio.NewWriter(f)
+
+		defer func() { err = errors.Join(err, writers[i].Flush(), f.Close()) }()
+	}
+
+	err = rangeArchive(ctx, from, func(value []byte) error {
+		var item hn.Item
+
+		err := json.Unmarshal(value, &item)
+		if err != nil {
+			return fmt.Errorf("failed to parse item: %w", err)
+		}
+
+		if !d.include(&item) {
+			return nil
+		}
+
+		i := d.assign(item.ID)
+		if i < 0 {
+			return nil
+		}
+
+		item.Title = unl.CleanText(item.Title, d.clean)
+		item.Text = unl.CleanText(item.Text, d.clean)
+
+		err = item.WriteJSONFields(writers[i], d.fields)
+		if err != nil {
+			return fmt.Errorf("failed to write item: %w", err)
+		}
+
+		err = writers[i].WriteByte('\n')
+		if err != nil {
+			return fmt.Errorf("failed to write newline: %w", err)
+		}
+
+		counts[i]++
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// rangeArchive calls do with each item in an NDJSON archive or SQLite item database.
+func rangeArchive(ctx context.Context, path string, do func(value []byte) error) (err error) {
+	isSQLite, err := isSQLiteFile(path)
+	if err != nil {
+		return err
+	}
+
+	if isSQLite {
+		cache, err := core.NewItemFileCache(ctx, core.NewClock(), path, "")
+		if err != nil {
+			return fmt.Errorf("failed to open input database: %w", err)
+		}
+
+		defer func() { err = errors.Join(err, cache.Close()) }()
+
+		return cache.Range(ctx, true, func(_ int, value []byte) error { return do(value) })
+	}
+
+	f, err := os.Open(path) //nolint:gosec // G304 intended
+	if err != nil {
+		return fmt.Errorf("error opening input file: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	const maxLineLength = 16 * 1024 * 1024
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineLength)
+
+	for scanner.Scan() {
+		err = do(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("failed to scan input file: %w", err)
+	}
+
+	return nil
+}
+
+// splitMix64 is the SplitMix64 finalizer, used as a stable hash so splits do not depend on the Go version.
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+
+	return x ^ (x >> 31)
+}
+
+// unitFloat maps h to [0, 1).
+func unitFloat(h uint64) float64 {
+	const mantissaBits = 53
+	return float64(h>>(64-mantissaBits)) / (1 << mantissaBits)
+}
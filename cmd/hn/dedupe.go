@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// itemDeduper skips items whose serialized bytes are identical to what was already written for the same ID this run.
+type itemDeduper struct {
+	hashes map[int][sha256.Size]byte
+	buf    bytes.Buffer
+}
+
+func newItemDeduper() *itemDeduper {
+	return &itemDeduper{make(map[int][sha256.Size]byte), bytes.Buffer{}}
+}
+
+// write writes item followed by a newline unless it duplicates the last item written for id. A nil itemDeduper
+// writes every item.
+func (d *itemDeduper) write(writer io.Writer, id int, item io.Reader) (bool, error) {
+	if d == nil {
+		if _, err := io.Copy(writer, item); err != nil {
+			return false, fmt.Errorf("failed to write item: %w", err)
+		}
+	} else {
+		d.buf.Reset()
+
+		if _, err := d.buf.ReadFrom(item); err != nil {
+			return false, fmt.Errorf("failed to read item: %w", err)
+		}
+
+		hash := sha256.Sum256(bytes.TrimSpace(d.buf.Bytes()))
+
+		if previous, ok := d.hashes[id]; ok && previous == hash {
+			return false, nil
+		}
+
+		d.hashes[id] = hash
+
+		if _, err := d.buf.WriteTo(writer); err != nil {
+			return false, fmt.Errorf("failed to write item: %w", err)
+		}
+	}
+
+	if _, err := writer.Write([]byte{'\n'}); err != nil {
+		return false, fmt.Errorf("failed to write newline: %w", err)
+	}
+
+	return true, nil
+}
+
+// bodyTee keeps a copy of the body of each item read through it while enabled, for the reports that look at bodies.
+type bodyTee struct {
+	buf     bytes.Buffer
+	enabled bool
+}
+
+func newBodyTee(enabled bool) *bodyTee {
+	return &bodyTee{bytes.Buffer{}, enabled}
+}
+
+// read returns item, or while enabled a reader of item that copies the body for body.
+func (t *bodyTee) read(item io.Reader) io.Reader {
+	if !t.enabled {
+		return item
+	}
+
+	t.buf.Reset()
+
+	return io.TeeReader(item, &t.buf)
+}
+
+// body returns the body of the item last read, or nothing if not enabled.
+func (t *bodyTee) body() []byte {
+	return t.buf.Bytes()
+}
+
+// isNullBody reports whether body is the null the HN API returns for items that do not exist or have not propagated.
+func isNullBody(body []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(body), []byte("null"))
+}
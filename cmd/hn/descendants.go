@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// descendantsReport compares the descendants the API reports for an item, the count behind the comment link on the
+// site, with the replies actually retrieved under it. Replies under a dead one are hidden on the site along with it,
+// so a difference usually points at flagged or killed subtrees.
+type descendantsReport struct {
+	Title       string `json:"title,omitempty"`
+	ID          int    `json:"id"`
+	Descendants int    `json:"descendants"`
+	Retrieved   int    `json:"retrieved"`
+	Visible     int    `json:"visible"`
+	Dead        int    `json:"dead"`
+	Deleted     int    `json:"deleted"`
+	Hidden      int    `json:"hidden"`
+	Difference  int    `json:"difference"`
+	Mismatch    bool   `json:"mismatch"`
+}
+
+// runItemCheckDescendants retrieves every reply under each item and writes a descendantsReport for it as a JSON line,
+// followed by a summary on stderr. Mismatches are reported rather than failing the command.
+func runItemCheckDescendants(ctx context.Context, client *hn.Client, writer *bufio.Writer, ids []int) error {
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve items: %w", err)
+	}
+
+	for _, id := range ids {
+		if items[id] == nil || items[id].Type == hn.NullBody {
+			return fmt.Errorf("item %d: %w", id, hn.ErrItemNotFound)
+		}
+	}
+
+	descendants, err := client.GetDescendants(ctx, items)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+
+	allByParent, _, err := descendants.GroupByParent()
+	if err != nil {
+		return fmt.Errorf("failed to group replies: %w", err)
+	}
+
+	enc := json.NewEncoder(writer)
+	mismatches := 0
+
+	for _, id := range ids {
+		report := newDescendantsReport(items[id], allByParent)
+		if report.Mismatch {
+			mismatches++
+		}
+
+		err = enc.Encode(report)
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	_, err = fmt.Fprintf(os.Stderr, "checked %d items: %d mismatches\n", len(ids), mismatches)
+	if err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	return nil
+}
+
+// newDescendantsReport counts the replies under root. Visible replies are neither dead nor deleted nor under a dead
+// reply; a deleted reply is still shown as a placeholder on the site, so its live replies stay visible.
+func newDescendantsReport(root *hn.Item, allByParent map[int]hn.ItemSet) descendantsReport {
+	report := descendantsReport{
+		root.Title, root.ID, root.Descendants, 0, 0, 0, 0, 0, 0, false,
+	}
+
+	var visit func(parent *hn.Item, underDead bool)
+	visit = func(parent *hn.Item, underDead bool) {
+		for _, item := range allByParent[parent.ID] {
+			report.Retrieved++
+
+			switch {
+			case item.Dead:
+				report.Dead++
+			case item.Deleted:
+				report.Deleted++
+			case underDead:
+				report.Hidden++
+			default:
+				report.Visible++
+			}
+
+			visit(item, underDead || item.Dead)
+		}
+	}
+
+	visit(root, false)
+
+	report.Difference = report.Descendants - report.Visible
+	report.Mismatch = report.Difference != 0
+
+	return report
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+type diffOp struct {
+	text string
+	kind byte
+}
+
+// unifiedDiff returns a unified diff of two line slices with the given number of context lines,
+// or an empty string if the slices are equal.
+func unifiedDiff(a []string, b []string, fromName string, toName string, context int) string {
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+
+	for start := 0; start < len(ops); {
+		first := nextChange(ops, start)
+		if first == len(ops) {
+			break
+		}
+
+		// extend the hunk while the next change is close enough that the context would overlap
+		last := first
+		for {
+			next := nextChange(ops, last+1)
+			if next == len(ops) || next-last > 2*context {
+				break
+			}
+
+			last = next
+		}
+
+		if sb.Len() == 0 {
+			sb.WriteString("--- " + fromName + "\n+++ " + toName + "\n")
+		}
+
+		from, to := max(0, first-context), min(len(ops), last+1+context)
+		writeHunk(&sb, ops, from, to)
+
+		start = to
+	}
+
+	return sb.String()
+}
+
+func nextChange(ops []diffOp, from int) int {
+	for i := from; i < len(ops); i++ {
+		if ops[i].kind != ' ' {
+			return i
+		}
+	}
+
+	return len(ops)
+}
+
+func writeHunk(sb *strings.Builder, ops []diffOp, from int, to int) {
+	aStart, bStart := 1, 1
+
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			aStart++
+		}
+
+		if op.kind != '-' {
+			bStart++
+		}
+	}
+
+	aCount, bCount := 0, 0
+
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			aCount++
+		}
+
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+
+	sb.WriteString("@@ -" + hunkRange(aStart, aCount) + " +" + hunkRange(bStart, bCount) + " @@\n")
+
+	for _, op := range ops[from:to] {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.text)
+		sb.WriteByte('\n')
+	}
+}
+
+func hunkRange(start int, count int) string {
+	switch count {
+	case 0:
+		return strconv.Itoa(start-1) + ",0"
+	case 1:
+		return strconv.Itoa(start)
+	default:
+		return strconv.Itoa(start) + "," + strconv.Itoa(count)
+	}
+}
+
+// diffLines computes a minimal line diff from the longest common subsequence.
+// This is quadratic, which is fine for the size of HN items.
+func diffLines(a []string, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{a[i], ' '})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{a[i], '-'})
+			i++
+		default:
+			ops = append(ops, diffOp{b[j], '+'})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{a[i], '-'})
+	}
+
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{b[j], '+'})
+	}
+
+	return ops
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type itemExplanation struct {
+	Cached  *itemExplanationCache `json:"cached"`
+	Source  string                `json:"source"`
+	Timings itemExplanationTiming `json:"timings"`
+	ID      int                   `json:"id"`
+}
+
+// itemExplanationCache is the file cache row for an item as it was before the lookup.
+type itemExplanationCache struct {
+	Refreshed    string `json:"refreshed"`
+	RefreshedAgo string `json:"refreshedAgo"`
+	ItemAge      string `json:"itemAge"`
+	Stale        bool   `json:"stale"`
+}
+
+type itemExplanationTiming struct {
+	Total      string `json:"total"`
+	FileCache  string `json:"fileCache,omitempty"`
+	WorkerPool string `json:"workerPool,omitempty"`
+	Network    string `json:"network,omitempty"`
+}
+
+const (
+	itemSourceMap     = "map"
+	itemSourceFile    = "file"
+	itemSourceNetwork = "network"
+)
+
+// runItemExplain retrieves each item in turn through a client of its own that records the spans of its layers, so
+// the layer that answered can be told from which spans were started beneath the item's parent span.
+func runItemExplain(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	writer *bufio.Writer,
+	ids []int,
+) (err error) {
+	if clock == nil {
+		clock = core.NewClock()
+	}
+
+	var cache *core.ItemFileCache
+
+	if cachePath != "" {
+		cache, err = core.NewItemFileCache(ctx, clock, cachePath, "")
+		if err != nil {
+			return fmt.Errorf("failed to open cache: %w", err)
+		}
+
+		defer func() { err = errors.Join(err, cache.Close()) }()
+	}
+
+	spans := &spanCollector{nil, sync.Mutex{}}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	defer func() { err = errors.Join(err, tp.Shutdown(context.Background())) }()
+
+	client, err := hn.NewClient(
+		ctx,
+		hn.WithFileCachePath(cachePath),
+		hn.WithSource(getGlobalSource(ctx)),
+		hn.WithGetter(getter),
+		hn.WithClock(clock),
+		hn.WithTracerProvider(tp))
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, client.Close()) }()
+
+	encoder := json.NewEncoder(writer)
+	tracer := tp.Tracer("hn.explain")
+
+	for _, id := range ids {
+		explanation := &itemExplanation{nil, itemSourceMap, itemExplanationTiming{"", "", "", ""}, id}
+
+		if cache != nil {
+			explanation.Cached, err = explainCachedItem(ctx, cache, clock, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		itemCtx, parent := tracer.Start(ctx, "hn.item")
+		start := time.Now()
+
+		_, err = client.GetItems(itemCtx, []int{id})
+
+		explanation.Timings.Total = time.Since(start).String()
+
+		parent.End()
+
+		if err != nil {
+			return fmt.Errorf("failed to retrieve item %d: %w", id, err)
+		}
+
+		explanation.explainSpans(spans.take(parent.SpanContext().TraceID()))
+
+		err = encoder.Encode(explanation)
+		if err != nil {
+			return fmt.Errorf("failed to write to output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func explainCachedItem(
+	ctx context.Context,
+	cache *core.ItemFileCache,
+	clock core.Clock,
+	id int,
+) (*itemExplanationCache, error) {
+	entry, err := cache.Entry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached item: %w", err)
+	}
+
+	if entry == nil {
+		return nil, nil //nolint:nilnil // not cached is not an error
+	}
+
+	now := clock.Now()
+	refreshed := time.Unix(entry.Refreshed, 0)
+
+	return &itemExplanationCache{
+		refreshed.UTC().Format(time.RFC3339),
+		now.Sub(refreshed).String(),
+		now.Sub(time.Unix(entry.Time, 0)).String(),
+		entry.Stale,
+	}, nil
+}
+
+// explainSpans sets the source and layer timings from the spans recorded for the item. A lookup that reaches the
+// file cache records an "hn.file_cache" span, and one that leaves it for the network records an "hn.get" span.
+func (e *itemExplanation) explainSpans(spans []sdktrace.ReadOnlySpan) {
+	for _, span := range spans {
+		duration := span.EndTime().Sub(span.StartTime()).String()
+
+		switch span.Name() {
+		case "hn.file_cache":
+			e.Timings.FileCache = duration
+
+			if e.Source == itemSourceMap {
+				e.Source = itemSourceFile
+			}
+		case "hn.worker_pool":
+			e.Timings.WorkerPool = duration
+		case "hn.get":
+			if spanHasAttribute(span, "hn.path", "item/"+strconv.Itoa(e.ID)+".json") {
+				e.Timings.Network = duration
+				e.Source = itemSourceNetwork
+			}
+		}
+	}
+}
+
+func spanHasAttribute(span sdktrace.ReadOnlySpan, key attribute.Key, value string) bool {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key && kv.Value.AsString() == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// spanCollector is a span processor that keeps ended spans until they are taken.
+type spanCollector struct {
+	ended []sdktrace.ReadOnlySpan
+	mu    sync.Mutex
+}
+
+func (c *spanCollector) take(traceID trace.TraceID) []sdktrace.ReadOnlySpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result, rest []sdktrace.ReadOnlySpan
+
+	for _, span := range c.ended {
+		if span.SpanContext().TraceID() == traceID {
+			result = append(result, span)
+		} else {
+			rest = append(rest, span)
+		}
+	}
+
+	c.ended = rest
+
+	return result
+}
+
+func (c *spanCollector) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (c *spanCollector) OnEnd(span sdktrace.ReadOnlySpan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ended = append(c.ended, span)
+}
+
+func (c *spanCollector) Shutdown(context.Context) error {
+	return nil
+}
+
+func (c *spanCollector) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+const (
+	// gapRecent is a run of null bodies next to items created within gapRecentWithin, or past the newest item
+	// scanned, which the API may not have propagated yet, so scanning it again later may fill it in.
+	gapRecent = "recent"
+	// gapUnassigned is a run of null bodies between older items, IDs that were never assigned or were purged.
+	gapUnassigned = "unassigned"
+
+	gapRecentWithin = 10 * time.Minute
+)
+
+// gap is a run of consecutive IDs with null bodies, with the items on either side of it by ID.
+type gap struct {
+	Kind       string `json:"kind"`
+	FirstID    int    `json:"firstId"`
+	LastID     int    `json:"lastId"`
+	Count      int    `json:"count"`
+	LowerID    int    `json:"lowerId,omitempty"`
+	LowerTime  int64  `json:"lowerTime,omitempty"`
+	HigherID   int    `json:"higherId,omitempty"`
+	HigherTime int64  `json:"higherTime,omitempty"`
+}
+
+type gapNeighbor struct {
+	id   int
+	time int64
+}
+
+// gapReporter writes the runs of null bodies found during a scan to the file of --gaps-report as JSON lines. All
+// methods of a nil gapReporter do nothing.
+type gapReporter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	clock   core.Clock
+	run     *gap
+	before  *gapNeighbor
+	last    *gapNeighbor
+	encoder *json.Encoder
+}
+
+func newGapReporter(path string, clock core.Clock) (*gapReporter, error) {
+	const gapsFilePermissions = 0o644
+
+	//nolint:gosec // G304 intended
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, gapsFilePermissions)
+	if err != nil {
+		return nil, fmt.Errorf("error opening gaps report file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+
+	return &gapReporter{f, w, clock, nil, nil, nil, json.NewEncoder(w)}, nil
+}
+
+// add records the body of the item id of the scan, in scan order.
+func (r *gapReporter) add(id int, body []byte) error {
+	if r == nil {
+		return nil
+	}
+
+	if isNullBody(body) {
+		if r.run == nil {
+			r.run = &gap{"", id, id, 0, 0, 0, 0, 0}
+			r.before = r.last
+		}
+
+		r.run.FirstID = min(r.run.FirstID, id)
+		r.run.LastID = max(r.run.LastID, id)
+		r.run.Count++
+
+		return nil
+	}
+
+	var item struct {
+		Time int64 `json:"time"`
+	}
+
+	err := json.Unmarshal(body, &item)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal item %d: %w", id, err)
+	}
+
+	r.last = &gapNeighbor{id, item.Time}
+
+	return r.end(r.last)
+}
+
+// finish reports the run of null bodies at the end of a scan, which has no item after it. Runs do not continue into
+// the next scan, such as the next range leased with --coordinate.
+func (r *gapReporter) finish() error {
+	if r == nil {
+		return nil
+	}
+
+	err := r.end(nil)
+	r.last = nil
+
+	return err
+}
+
+func (r *gapReporter) end(after *gapNeighbor) error {
+	if r.run == nil {
+		return nil
+	}
+
+	g := r.run
+	r.run = nil
+
+	// the scan may be descending, so the neighbors are ordered by ID rather than by which came first
+	for _, n := range []*gapNeighbor{r.before, after} {
+		switch {
+		case n == nil:
+		case n.id < g.FirstID:
+			g.LowerID, g.LowerTime = n.id, n.time
+		default:
+			g.HigherID, g.HigherTime = n.id, n.time
+		}
+	}
+
+	g.Kind = gapUnassigned
+
+	if g.HigherID == 0 || r.clock.Now().Sub(time.Unix(g.HigherTime, 0)) < gapRecentWithin {
+		g.Kind = gapRecent
+	}
+
+	err := r.encoder.Encode(g)
+	if err != nil {
+		return fmt.Errorf("failed to write gap: %w", err)
+	}
+
+	return nil
+}
+
+func (r *gapReporter) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	err := errors.Join(r.finish(), r.writer.Flush(), r.file.Close())
+	if err != nil {
+		return fmt.Errorf("failed to close gaps report: %w", err)
+	}
+
+	return nil
+}
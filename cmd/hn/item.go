@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"github.com/spf13/cobra"
+)
+
+func itemCmd(getter core.Getter[string, io.ReadCloser], clock core.Clock) *cobra.Command {
+	var (
+		diff        bool
+		explain     bool
+		tree        bool
+		pretty      bool
+		noColor     bool
+		descendants bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "item [id or url...]",
+		Short: "Retrieve items by ID or URL",
+		Long: "Retrieves items by ID or news.ycombinator.com URL, where the #anchor of a comment's context link\n" +
+			"selects the comment. With --diff, shows a unified diff between the text of the previous\n" +
+			"version of the item kept by --cache-history and the current text. With --explain, reports for each\n" +
+			"item where it came from (the in-memory cache, the file cache, or the network), the cached row's\n" +
+			"refresh time and staleness before the lookup, and how long each layer took. With --tree, also\n" +
+			"retrieves all replies in thread order. With --pretty, renders items as the indented tree shown by unl\n" +
+			"instead of JSON. With --check-descendants, retrieves all replies and reports for each item how the\n" +
+			"descendants count from the API compares with the visible, dead, deleted, and hidden replies found,\n" +
+			"since a mismatch usually means flagged or killed subtrees.",
+		Example: "  hn item 43740065 43740647\n" +
+			"  hn item 'https://news.ycombinator.com/item?id=43740065#43740647'\n" +
+			"  hn item 43740647 --diff\n" +
+			"  hn item 43740647 43740647 --explain\n" +
+			"  hn item 43740065 --tree --pretty\n" +
+			"  hn item 43740065 --check-descendants",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			client, writer, outputFile := getGlobalItems(ctx)
+
+			ids, err := parseItemIDs(args)
+			if err != nil {
+				return err
+			}
+
+			if diff && explain {
+				return fmt.Errorf("%w: cannot provide both --diff and --explain", errInvalidArgs)
+			}
+
+			if (diff || explain) && (tree || pretty) {
+				return fmt.Errorf("%w: --tree and --pretty cannot be used with --diff or --explain", errInvalidArgs)
+			}
+
+			if descendants && (diff || explain || tree || pretty) {
+				return fmt.Errorf("%w: --check-descendants cannot be used with other modes", errInvalidArgs)
+			}
+
+			if descendants {
+				return runItemCheckDescendants(ctx, client, writer, ids)
+			}
+
+			if explain {
+				return runItemExplain(ctx, getter, clock, getGlobalCachePath(ctx), writer, ids)
+			}
+
+			if diff {
+				if len(ids) != 1 {
+					return fmt.Errorf("%w: --diff requires exactly one item", errInvalidArgs)
+				}
+
+				return runItemDiff(ctx, client, writer, clock, getGlobalCachePath(ctx), ids[0])
+			}
+
+			if tree || pretty {
+				width, _ := terminalSize(outputFile)
+				o := itemTreeOptions{width, tree, pretty, !noColor && width > 0}
+
+				return runItemTree(ctx, client, writer, ids, o)
+			}
+
+			return runList(ctx, client, writer, 0, func(_ context.Context) ([]int, error) {
+				return ids, nil
+			}, nil)
+		},
+	}
+
+	cmd.Flags().BoolVar(&diff, "diff", false, "show a diff against the previous cached version of the text")
+	cmd.Flags().BoolVar(&explain, "explain", false, "report the cache provenance and timing of each item")
+	cmd.Flags().BoolVar(&tree, "tree", false, "also retrieve all replies of each item, in thread order")
+	cmd.Flags().BoolVar(&pretty, "pretty", false, "render items as an indented tree instead of JSON")
+	cmd.Flags().BoolVar(&descendants, "check-descendants", false,
+		"report how the descendants count of each item compares with the replies actually retrieved")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "disable color with --pretty (always off without a terminal)")
+
+	return cmd
+}
+
+func parseItemIDs(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+
+	for _, arg := range args {
+		id, err := unl.ParseItemRef(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid item id: %w", errInvalidArgs, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+type itemTreeOptions struct {
+	maxWidth int
+	tree     bool
+	pretty   bool
+	color    bool
+}
+
+// runItemTree writes each item, followed by its replies in thread order with tree, as JSON or rendered like unl.
+func runItemTree(ctx context.Context, client *hn.Client, writer *bufio.Writer, ids []int, o itemTreeOptions) error {
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve items: %w", err)
+	}
+
+	for _, id := range ids {
+		if items[id] == nil || items[id].Type == hn.NullBody {
+			return fmt.Errorf("item %d: %w", id, hn.ErrItemNotFound)
+		}
+	}
+
+	var allByParent map[int]hn.ItemSet
+
+	if o.tree {
+		descendants, err := client.GetDescendants(ctx, items)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve replies: %w", err)
+		}
+
+		allByParent, _, err = descendants.GroupByParent()
+		if err != nil {
+			return fmt.Errorf("failed to group replies: %w", err)
+		}
+	}
+
+	if !o.pretty {
+		var flat []int
+
+		for _, id := range ids {
+			for _, item := range unl.FlattenTree(items[id], allByParent) {
+				flat = append(flat, item.ID)
+			}
+		}
+
+		return runList(ctx, client, writer, 0, func(_ context.Context) ([]int, error) {
+			return flat, nil
+		}, nil)
+	}
+
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	// every item counts as active so the text of all replies is shown, as with "unl open"
+	var theme *render.Theme
+	if o.color {
+		theme = &render.DefaultTheme
+	}
+
+	r := render.NewTreeRenderer(render.Options{
+		Now:            now,
+		ActiveAfter:    time.Time{},
+		NewAfter:       time.Time{},
+		Theme:          theme,
+		EffectiveTimes: nil,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          render.UnicodeStyle,
+		MaxWidth:       o.maxWidth,
+		MaxByWidth:     render.DefaultMaxByWidth,
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	threads := make([]render.Thread, 0, len(ids))
+	for _, id := range ids {
+		threads = append(threads, render.Thread{Root: items[id], ByParent: allByParent, Orphaned: false})
+	}
+
+	err = r.Render(writer, threads...)
+	if err != nil {
+		return fmt.Errorf("failed to write items: %w", err)
+	}
+
+	return nil
+}
+
+func runItemDiff(
+	ctx context.Context,
+	client *hn.Client,
+	writer *bufio.Writer,
+	clock core.Clock,
+	cachePath string,
+	id int,
+) (err error) {
+	if cachePath == "" {
+		return fmt.Errorf("%w: --diff cannot be used with --no-cache", errInvalidArgs)
+	}
+
+	if clock == nil {
+		clock = core.NewClock()
+	}
+
+	items, err := client.GetItems(ctx, []int{id})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %w", err)
+	}
+
+	current := items[id]
+	if current == nil || current.Type == hn.NullBody {
+		return fmt.Errorf("item %d: %w", id, hn.ErrItemNotFound)
+	}
+
+	cache, err := core.NewItemFileCache(ctx, clock, cachePath, "")
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	previous, err := findPreviousText(ctx, cache, current)
+	if err != nil {
+		return err
+	}
+
+	if previous == nil {
+		_, err = fmt.Fprintf(os.Stderr, "no previous text for item %d in the cache\n", id)
+		if err != nil {
+			return fmt.Errorf("failed to write warning: %w", err)
+		}
+
+		return nil
+	}
+
+	refreshed := time.Unix(previous.Refreshed, 0).UTC().Format(time.RFC3339)
+	fromName := "item/" + strconv.Itoa(id) + " (retrieved " + refreshed + ")"
+	toName := "item/" + strconv.Itoa(id) + " (current)"
+
+	const diffContextLines = 3
+
+	_, err = writer.WriteString(unifiedDiff(previous.lines, itemTextLines(current), fromName, toName, diffContextLines))
+	if err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+
+	return nil
+}
+
+type previousText struct {
+	lines     []string
+	Refreshed int64
+}
+
+// findPreviousText returns the most recent stored version with text differing from the current item.
+// The cached row itself is a candidate since the current item might have just come from the network.
+func findPreviousText(ctx context.Context, cache *core.ItemFileCache, current *hn.Item) (*previousText, error) {
+	candidates, err := cache.Versions(ctx, current.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read item versions: %w", err)
+	}
+
+	entry, err := cache.Entry(ctx, current.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached item: %w", err)
+	}
+
+	if entry != nil {
+		candidates = append([]core.ItemFileCacheEntry{*entry}, candidates...)
+	}
+
+	currentLines := itemTextLines(current)
+
+	for _, candidate := range candidates {
+		var item hn.Item
+
+		err = json.Unmarshal(candidate.Value, &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal cached item: %w", err)
+		}
+
+		lines := itemTextLines(&item)
+		if !slices.Equal(lines, currentLines) {
+			return &previousText{lines, candidate.Refreshed}, nil
+		}
+	}
+
+	return nil, nil //nolint:nilnil // no previous text is not an error
+}
+
+// itemTextLines renders the user-visible text of an item as lines, one per paragraph.
+func itemTextLines(item *hn.Item) []string {
+	var lines []string
+
+	if item.Title != "" {
+		lines = append(lines, "title: "+html.UnescapeString(item.Title))
+	}
+
+	if item.URL != "" {
+		lines = append(lines, "url: "+item.URL)
+	}
+
+	if item.Text != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		for _, paragraph := range strings.Split(item.Text, "<p>") {
+			lines = append(lines, html.UnescapeString(strings.TrimSuffix(paragraph, "</p>")))
+		}
+	}
+
+	return lines
+}
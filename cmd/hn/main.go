@@ -13,22 +13,28 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jasonthorsness/unlurker/hn"
 	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type globalItemsContextKey struct{}
 
 type globalItems struct {
-	client     *hn.Client
-	writer     *bufio.Writer
-	outputFile *os.File
+	client      *hn.Client
+	writer      *bufio.Writer
+	outputFile  *os.File
+	diagnostics *hn.Diagnostics
+	source      hn.Source
+	cachePath   string
 }
 
 func main() {
@@ -50,9 +56,9 @@ func main() {
 		defaultCachePath = os.TempDir()
 	}
 
-	defaultCachePath = filepath.Join(defaultCachePath, "hn.db")
+	defaultCachePath = filepath.Join(defaultCachePath, hn.DefaultFileCacheName)
 
-	rootCmd := buildCommand(nil, nil, defaultCachePath)
+	rootCmd := buildCommand(nil, nil, nil, defaultCachePath)
 
 	err = executeWithCleanup(ctx, rootCmd)
 	if err != nil {
@@ -72,7 +78,7 @@ func main() {
 }
 
 func executeWithCleanup(ctx context.Context, cmd *cobra.Command) (err error) {
-	g := &globalItems{nil, nil, nil}
+	g := &globalItems{nil, nil, nil, nil, hn.HackerNews, ""}
 	ctx = context.WithValue(ctx, globalItemsContextKey{}, g)
 
 	defer func() {
@@ -84,7 +90,7 @@ func executeWithCleanup(ctx context.Context, cmd *cobra.Command) (err error) {
 		}
 
 		if g.client != nil {
-			errs = append(errs, g.client.Close())
+			errs = append(errs, g.client.Close(), g.diagnostics.Write(os.Stderr, g.client.Stats()))
 		}
 
 		if g.writer != nil {
@@ -114,15 +120,32 @@ func getGlobalItems(ctx context.Context) (*hn.Client, *bufio.Writer, *os.File) {
 	return cw.client, cw.writer, cw.outputFile
 }
 
+// getGlobalDiagnostics returns the diagnostics for --verbose, or nil.
+func getGlobalDiagnostics(ctx context.Context) *hn.Diagnostics {
+	cw := ctx.Value(globalItemsContextKey{}).(*globalItems) //nolint:forcetypeassert // typed context value
+	return cw.diagnostics
+}
+
+// getGlobalSource returns the source selected with --source.
+func getGlobalSource(ctx context.Context) hn.Source {
+	cw := ctx.Value(globalItemsContextKey{}).(*globalItems) //nolint:forcetypeassert // typed context value
+	return cw.source
+}
+
+func getGlobalCachePath(ctx context.Context) string {
+	cw := ctx.Value(globalItemsContextKey{}).(*globalItems) //nolint:forcetypeassert // typed context value
+	return cw.cachePath
+}
+
 var errInvalidArgs = errors.New("invalid args")
 
-func buildCommand(getter core.Getter[string, io.ReadCloser], clock core.Clock, defaultCachePath string) *cobra.Command {
-	var (
-		maxConnections int
-		noCache        bool
-		cachePath      string
-		outputPath     string
-	)
+func buildCommand(
+	getter core.Getter[string, io.ReadCloser],
+	siteGetter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	defaultCachePath string,
+) *cobra.Command {
+	var f globalFlags
 
 	rootCmd := &cobra.Command{
 		Use:           "hn [command]",
@@ -130,62 +153,160 @@ func buildCommand(getter core.Getter[string, io.ReadCloser], clock core.Clock, d
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			return setupGlobalsFunc(cmd, args, noCache, cachePath, maxConnections, outputPath, getter, clock)
+			return setupGlobalsFunc(cmd, args, f, getter, clock)
 		},
 		RunE: func(cmd *cobra.Command, _ []string) error { return cmd.Help() },
 		Long: "hn retrieves data from the HN API (https://github.com/HackerNews/API)",
 		Example: "  hn new --limit 3\n" +
 			"  hn user jasonthorsness --submitted --limit 5\n" +
+			"  hn page newest --pages 5\n" +
 			"  hn scan --limit 10000 --continue-at - -o out.json",
 	}
 
 	const defaultMaxConnections = 100
 
 	rootCmd.PersistentFlags().IntVar(
-		&maxConnections,
+		&f.maxConnections,
 		"max-connections",
 		defaultMaxConnections,
 		"maximum TCP connections to open")
-	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "disable caching")
-	rootCmd.PersistentFlags().StringVar(&cachePath, "cache-path", defaultCachePath, "cache file path")
-	rootCmd.PersistentFlags().StringVarP(&outputPath, "output", "o", "", "output filename")
+	rootCmd.PersistentFlags().Float64Var(&f.rateLimit, "rate-limit", 0,
+		"maximum API requests per second (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&f.rateSchedule, "rate-schedule", "",
+		"shares of --rate-limit by local time of day, like 00:00-08:00=100%,20%")
+	rootCmd.PersistentFlags().DurationVar(&f.requestTimeout, "request-timeout", 0,
+		"fail an API request that takes longer than this, such as a stuck response (0 for no timeout)")
+	rootCmd.PersistentFlags().BoolVar(&f.noCache, "no-cache", false, "disable caching")
+	rootCmd.PersistentFlags().BoolVar(
+		&f.cacheHistory,
+		"cache-history",
+		false,
+		"keep previous versions of changed items in the cache (stays enabled for the cache file)")
+	rootCmd.PersistentFlags().StringVar(&f.cachePath, "cache-path", defaultCachePath, "cache file path")
+	rootCmd.PersistentFlags().StringVar(&f.cacheName, "cache-name", "",
+		"use the named cache hn-<name>.db next to the default cache file")
+	rootCmd.PersistentFlags().StringVarP(&f.outputPath, "output", "o", "", "output filename")
+	rootCmd.PersistentFlags().StringVar(&f.source, "source", hn.HackerNews.Name,
+		"API to retrieve from: "+strings.Join(hn.SourceNames(), ", ")+" (others use the cache hn-<source>.db)")
+	rootCmd.PersistentFlags().CountVarP(&f.verbose, "verbose", "v",
+		"print request counts, cache hit rates, and phase timings to stderr (-vv for more)")
 
 	rootCmd.AddCommand(listCmd("new"))
 	rootCmd.AddCommand(listCmd("top"))
 	rootCmd.AddCommand(listCmd("best"))
+	rootCmd.AddCommand(listCmd("ask"))
+	rootCmd.AddCommand(listCmd("show"))
+	rootCmd.AddCommand(listCmd("jobs"))
+	rootCmd.AddCommand(pageCmd(siteGetter))
+	rootCmd.AddCommand(itemCmd(getter, clock))
 	rootCmd.AddCommand(userCmd())
-	rootCmd.AddCommand(scanCmd())
+	rootCmd.AddCommand(scanCmd(clock))
+	rootCmd.AddCommand(tailCmd(clock))
+	rootCmd.AddCommand(warmCmd(clock))
+	rootCmd.AddCommand(validateCmd())
+	rootCmd.AddCommand(convertCmd())
+	rootCmd.AddCommand(browseCmd())
+	rootCmd.AddCommand(datasetCmd())
+	rootCmd.AddCommand(cacheCmd(getter, clock, defaultCachePath))
+	rootCmd.AddCommand(saveCmd(clock))
+	rootCmd.AddCommand(savedCmd(getter, clock))
+	rootCmd.AddCommand(batchCmd(func() *cobra.Command {
+		return buildCommand(getter, siteGetter, clock, defaultCachePath)
+	}))
 
 	return rootCmd
 }
 
+// globalFlags are the persistent flags of the root command, which configure the client and output of every command.
+type globalFlags struct {
+	cachePath      string
+	cacheName      string
+	outputPath     string
+	source         string
+	rateSchedule   string
+	rateLimit      float64
+	requestTimeout time.Duration
+	maxConnections int
+	verbose        int
+	noCache        bool
+	cacheHistory   bool
+}
+
 func setupGlobalsFunc(
 	cmd *cobra.Command,
 	args []string,
-	noCache bool,
-	cachePath string,
-	maxConnections int,
-	outputPath string,
+	f globalFlags,
 	getter core.Getter[string, io.ReadCloser],
 	clock core.Clock,
 ) error {
 	ctx := cmd.Context()
 	g := ctx.Value(globalItemsContextKey{}).(*globalItems) //nolint:forcetypeassert // typed context value
 
+	if g.client != nil {
+		return checkBatchGlobalFlags(cmd)
+	}
+
 	if cmd.Flags().Changed("no-cache") && cmd.Flags().Changed("cache-path") {
 		return fmt.Errorf("%w: cannot provide both --no-cache and --cache-path", errInvalidArgs)
 	}
 
-	if noCache {
-		cachePath = ""
+	if f.noCache && f.cacheHistory {
+		return fmt.Errorf("%w: cannot provide both --no-cache and --cache-history", errInvalidArgs)
 	}
 
-	var err error
+	src, err := hn.SourceByName(f.source)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+
+	rateLimiter, err := getRateLimiter(clock, f.rateLimit, f.rateSchedule)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("cache-name") {
+		if f.noCache || cmd.Flags().Changed("cache-path") {
+			return fmt.Errorf("%w: cannot provide --cache-name with --no-cache or --cache-path", errInvalidArgs)
+		}
+	} else if src.Name != hn.HackerNews.Name && !cmd.Flags().Changed("cache-path") {
+		// items from another f.source are kept apart since they may differ from those of the HN API
+		f.cacheName = src.Name
+	}
+
+	if f.cacheName != "" {
+		f.cachePath, err = hn.NamedFileCachePath(filepath.Dir(f.cachePath), f.cacheName)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidArgs, err)
+		}
+	}
+
+	if f.noCache {
+		f.cachePath = ""
+	}
+
+	g.cachePath = f.cachePath
+	g.source = src
+
+	if f.verbose > 0 {
+		g.diagnostics = hn.NewDiagnostics(f.verbose)
+	}
+
+	movedTo := ""
 
 	g.client, err = hn.NewClient(
 		ctx,
-		hn.WithMaxConnections(maxConnections),
-		hn.WithFileCachePath(cachePath),
+		hn.WithMaxConnections(f.maxConnections),
+		hn.WithRateLimiter(rateLimiter),
+		hn.WithRequestTimeout(f.requestTimeout),
+		hn.WithFileCachePath(f.cachePath),
+		hn.WithFileCacheHistory(f.cacheHistory),
+		hn.WithFileCacheCorruptHandler(func(moved string, err error) {
+			movedTo = moved
+
+			warnCorruptCache(moved, err)
+		}),
+		hn.WithWorkerPanicHandler(warnWorkerPanic),
+		hn.WithSource(src),
 		hn.WithGetter(getter),
 		hn.WithClock(clock),
 	)
@@ -193,7 +314,11 @@ func setupGlobalsFunc(
 		return fmt.Errorf("failed to create client: %w", err)
 	}
 
-	if outputPath != "" && outputPath != "-" {
+	if movedTo != "" {
+		recoverCorruptCache(ctx, f.cachePath, movedTo)
+	}
+
+	if f.outputPath != "" && f.outputPath != "-" {
 		outputFlags, err := getOutputFlags(cmd, args)
 		if err != nil {
 			return err
@@ -203,7 +328,7 @@ func setupGlobalsFunc(
 
 		//nolint:gosec // G304 intended
 		g.outputFile, err = os.OpenFile(
-			outputPath,
+			f.outputPath,
 			outputFlags,
 			outputFilePermissions)
 		if err != nil {
@@ -218,6 +343,70 @@ func setupGlobalsFunc(
 	return nil
 }
 
+// checkBatchGlobalFlags rejects global flags on a command run by hn batch, which shares the batch's client and output.
+func checkBatchGlobalFlags(cmd *cobra.Command) error {
+	var err error
+
+	cmd.Root().PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if err == nil && cmd.Flags().Changed(f.Name) {
+			err = fmt.Errorf("%w: --%s applies to the whole batch and cannot be given to a command", errInvalidArgs, f.Name)
+		}
+	})
+
+	return err
+}
+
+// getRateLimiter returns the limiter for --rate-limit and --rate-schedule, or nil without a limit.
+func getRateLimiter(clock core.Clock, rateLimit float64, rateSchedule string) (*core.RateLimiter, error) {
+	if rateLimit < 0 {
+		return nil, fmt.Errorf("%w: --rate-limit must not be negative", errInvalidArgs)
+	}
+
+	if rateLimit == 0 {
+		if rateSchedule != "" {
+			return nil, fmt.Errorf("%w: --rate-schedule requires --rate-limit", errInvalidArgs)
+		}
+
+		return nil, nil //nolint:nilnil // no limit is not an error
+	}
+
+	schedule := core.RateSchedule{Windows: nil, Default: 1}
+
+	if rateSchedule != "" {
+		var err error
+
+		schedule, err = core.ParseRateSchedule(rateSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidArgs, err)
+		}
+	}
+
+	if clock == nil {
+		clock = core.NewClock()
+	}
+
+	return core.NewRateLimiter(clock, rateLimit, schedule), nil
+}
+
+// warnCorruptCache tells the user the cache was replaced, since the client otherwise continues silently.
+func warnCorruptCache(movedTo string, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "warning: cache file is corrupt (%v), moved it to %s and started a new one\n", err, movedTo)
+}
+
+// recoverCorruptCache copies the bookmarks, mutes, and other state of the cache moved aside to movedTo into the new
+// one, telling the user where they remain if that fails.
+func recoverCorruptCache(ctx context.Context, cachePath string, movedTo string) {
+	err := unl.RecoverStore(ctx, cachePath, movedTo)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// warnWorkerPanic reports a panic recovered while retrieving an item, which otherwise would end the program.
+func warnWorkerPanic(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "warning: recovered from %v\n", err)
+}
+
 func getOutputFlags(cmd *cobra.Command, args []string) (int, error) {
 	subCmd, _, err := cmd.Find(args)
 	if err != nil {
@@ -226,6 +415,23 @@ func getOutputFlags(cmd *cobra.Command, args []string) (int, error) {
 
 	outputFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
 
+	if subCmd.Use == "scan" && subCmd.Flags().Changed("dry-run") {
+		dryRun, err := subCmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return 0, fmt.Errorf("failed to get dry-run flag: %w", err)
+		}
+
+		if dryRun {
+			// the file is only read, to resolve --continue-at
+			return os.O_RDONLY | os.O_CREATE, nil
+		}
+	}
+
+	if subCmd.Use == "scan" && subCmd.Flags().Changed("coordinate") {
+		// ranges already completed by this process must be kept when it restarts
+		return os.O_WRONLY | os.O_APPEND | os.O_CREATE, nil
+	}
+
 	if subCmd.Use == "scan" && subCmd.Flags().Changed("continue-at") {
 		c, err := subCmd.Flags().GetString("continue-at")
 		if err != nil {
@@ -267,11 +473,17 @@ func listCmd(list string) *cobra.Command {
 				getIDs = client.GetTop
 			case "best":
 				getIDs = client.GetBest
+			case "ask":
+				getIDs = client.GetAsk
+			case "show":
+				getIDs = client.GetShow
+			case "jobs":
+				getIDs = client.GetJobs
 			default:
 				return fmt.Errorf("%w: unrecognized list", errInvalidArgs)
 			}
 
-			return runList(ctx, client, writer, limit, getIDs)
+			return runList(ctx, client, writer, limit, getIDs, nil)
 		},
 	}
 
@@ -307,7 +519,7 @@ func userCmd() *cobra.Command {
 			} else {
 				err = runList(ctx, client, writer, limit, func(_ context.Context) ([]int, error) {
 					return user.Submitted, nil
-				})
+				}, nil)
 				if err != nil {
 					return fmt.Errorf("failed to retrieve user items: %w", err)
 				}
@@ -328,11 +540,22 @@ func userCmd() *cobra.Command {
 
 const continueAtStart = -1
 
-func scanCmd() *cobra.Command {
+// scanWindowMultipliers sizes the window of a scan: MaxInFlight queued, MaxInFlight in flight, MaxInFlight waiting
+// for in-order processing.
+const scanWindowMultipliers = 3
+
+func scanCmd(clock core.Clock) *cobra.Command {
 	var (
-		limit      int
-		continueAt string
-		ascending  bool
+		coordinate  coordination
+		limit       int
+		continueAt  string
+		summaryPath string
+		gapsPath    string
+		retryNulls  time.Duration
+		ascending   bool
+		dedupe      bool
+		dryRun      bool
+		shards      int
 	)
 
 	cmd := &cobra.Command{
@@ -340,18 +563,70 @@ func scanCmd() *cobra.Command {
 		Short: "Retrieve a range of items from the HN API",
 		Long: "For a resumable scan (recommended), use a -o <output file> and specify --continue-at -.\n" +
 			"For best performance, you might want to increase --max-connections to 400 or more.\n" +
-			"If you are scanning a huge range, consider --no-cache or your cache will become very large.",
-		Example: "  hn scan --max-connections 400 --no-cache --limit 100000 -c- -o out.json",
-		RunE: func(cmd *cobra.Command, _ []string) error {
+			"If you are scanning a huge range, consider --no-cache or your cache will become very large.\n" +
+			"--summary-json writes a JSON summary of the run (items, first and last ID, bytes, errors, elapsed\n" +
+			"seconds, and rate) to a file or, with -, to stderr, even if the scan fails or is interrupted.\n" +
+			"--dry-run prints the range the scan would retrieve with estimates of its size and duration to stdout\n" +
+			"instead, leaving the output file untouched. Sizes average the items in the cache.\n" +
+			"--shards splits the range among several item streams that write separate parts next to the output\n" +
+			"and merges them in order at the end, for full-history scans slowed by the single ordered window.\n" +
+			"--coordinate leases ranges of --lease-size from a SQLite file shared with other hn processes, on this\n" +
+			"or other machines, so they scan the range together without overlap. The first process decides the\n" +
+			"range. Each writes the ranges it leased to its own output, which are not contiguous.\n" +
+			"--gaps-report writes each run of consecutive IDs with null bodies as a JSON line with the items on\n" +
+			"either side. Runs next to items from the last ten minutes, or past the newest item, are \"recent\" and\n" +
+			"may be propagation delay worth scanning again; older runs are \"unassigned\" IDs.\n" +
+			"--retry-nulls retrieves the items that returned null again after a delay at the end of the scan, or\n" +
+			"of each leased range, and appends those filled in to the output after the scan, out of order.\n" +
+			"Send SIGUSR1 to pause a scan, which stops requesting items, writes those already requested, and\n" +
+			"flushes the output, and SIGUSR2 to resume it.",
+		Example: "  hn scan --max-connections 400 --no-cache --limit 100000 -c- -o out.json\n" +
+			"  hn scan --limit 1000 -o out.json --summary-json summary.json\n" +
+			"  hn scan --max-connections 400 -c- -o out.json --dry-run\n" +
+			"  hn scan --max-connections 800 --shards 8 --no-cache --asc -c- -o out.json\n" +
+			"  hn scan --no-cache --coordinate /mnt/shared/leases.db -o \"out-$(hostname).json\"\n" +
+			"  hn scan --limit 100000 -c- -o out.json --gaps-report gaps.json\n" +
+			"  hn scan --limit 1000 --retry-nulls=2m -o out.json",
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
 			ctx := cmd.Context()
 			client, writer, outputFile := getGlobalItems(ctx)
+
+			if shards < 1 {
+				return fmt.Errorf("%w: --shards must be at least 1", errInvalidArgs)
+			}
+
+			if coordinate.path != "" && continueAt != "" {
+				return fmt.Errorf("%w: --continue-at cannot be used with --coordinate", errInvalidArgs)
+			}
+
+			if gapsPath != "" && shards > 1 {
+				return fmt.Errorf("%w: --gaps-report cannot be used with --shards", errInvalidArgs)
+			}
+
+			if retryNulls < 0 {
+				return fmt.Errorf("%w: --retry-nulls must not be negative", errInvalidArgs)
+			}
+
+			if coordinate.size <= 0 || coordinate.ttl <= 0 || coordinate.leases < 0 {
+				return fmt.Errorf("%w: --lease-size and --lease-ttl must be positive and --leases not negative",
+					errInvalidArgs)
+			}
+
+			var summary *scanSummary
+			if summaryPath != "" {
+				summary = newScanSummary()
+
+				defer func() {
+					err = errors.Join(err, summary.write(summaryPath, cmd.ErrOrStderr(), client.Stats(), err))
+				}()
+			}
+
 			from := continueAtStart
 			remaining := limit
 			if remaining == 0 {
 				remaining = math.MaxInt
 			}
 
-			var err error
 			if continueAt != "" {
 				from, remaining, err = resolveContinueAt(outputFile, limit, ascending, continueAt)
 				if err != nil {
@@ -359,15 +634,19 @@ func scanCmd() *cobra.Command {
 				}
 			}
 
-			if remaining == 0 {
+			if remaining == 0 && !dryRun {
 				return nil
 			}
 
+			start := time.Now()
+
 			maxItem, err := client.GetMaxItem(ctx)
 			if err != nil {
 				return fmt.Errorf("failed to get max item: %w", err)
 			}
 
+			roundTrip := time.Since(start)
+
 			if from == continueAtStart {
 				if ascending {
 					from = 1
@@ -384,17 +663,85 @@ func scanCmd() *cobra.Command {
 				to = max(1, from-remaining)
 			}
 
+			if dryRun {
+				return writeScanPlan(cmd, from, to, ascending, roundTrip)
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			var gaps *gapReporter
+			if gapsPath != "" {
+				gaps, err = newGapReporter(gapsPath, clock)
+				if err != nil {
+					return err
+				}
+
+				defer func() { err = errors.Join(err, gaps.Close()) }()
+			}
+
+			pause := newScanPause(cmd.ErrOrStderr())
+			defer pause.listen()()
+
+			var retry *nullRetry
+			if retryNulls > 0 {
+				retry = newNullRetry(clock, retryNulls, ascending)
+			}
+
+			scan := func(ctx context.Context, from int, to int) error {
+				var err error
+				o := scanOptions{client, writer, outputFile, summary, gaps, retry, pause, shards, ascending, dedupe}
+				if shards > 1 {
+					err = runShardedScan(ctx, o, from, to)
+				} else {
+					err = runScan(ctx, o, from, to)
+				}
+
+				if err != nil {
+					return err
+				}
+
+				return runRetryNulls(ctx, cmd.ErrOrStderr(), client, writer, retry, summary)
+			}
+
+			if coordinate.path != "" {
+				plan := core.ScanPlan{From: from, To: to, Size: 0, Ascending: ascending}
+
+				return runCoordinatedScan(ctx, clock, coordinate, scanOwner(), plan, scan)
+			}
+
 			if from == to {
 				return nil
 			}
 
-			return runScan(ctx, client, writer, from, to, ascending)
+			return scan(ctx, from, to)
 		},
 	}
 
 	cmd.Flags().BoolVar(&ascending, "asc", false, "Sort results in ascending order")
 	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "Limit the number of results (0 for no limit)")
 	cmd.Flags().StringVarP(&continueAt, "continue-at", "c", "", "Continue from a previous scan and/or item number")
+	cmd.Flags().BoolVar(&dedupe, "dedupe", false, "Skip items identical to one already written for the same ID this run")
+	cmd.Flags().StringVar(&summaryPath, "summary-json", "", "Write a JSON summary of the run to this file (- for stderr)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the range and estimated size and duration without scanning")
+	cmd.Flags().StringVar(&gapsPath, "gaps-report", "", "Write runs of IDs with null bodies to this file as JSON lines")
+	cmd.Flags().DurationVar(&retryNulls, "retry-nulls", 0,
+		"Retrieve items that returned null again after this delay at the end of the scan (0 to disable)")
+	cmd.Flags().Lookup("retry-nulls").NoOptDefVal = defaultRetryNullsDelay.String()
+	cmd.Flags().IntVar(&shards, "shards", 1, "Scan this many ranges concurrently and merge them in order")
+	cmd.Flags().StringVar(&coordinate.path, "coordinate", "",
+		"Lease ranges from this SQLite file shared with other processes scanning the same range")
+
+	const (
+		defaultLeaseSize = 10000
+		defaultLeaseTTL  = 5 * time.Minute
+	)
+
+	cmd.Flags().IntVar(&coordinate.size, "lease-size", defaultLeaseSize, "With --coordinate, items per leased range")
+	cmd.Flags().DurationVar(&coordinate.ttl, "lease-ttl", defaultLeaseTTL,
+		"With --coordinate, how long a lease lasts without renewal before another process takes it over")
+	cmd.Flags().IntVar(&coordinate.leases, "leases", 0, "With --coordinate, stop after this many leases (0 for no limit)")
 
 	return cmd
 }
@@ -405,8 +752,15 @@ func runList(
 	writer *bufio.Writer,
 	limit int,
 	getIDs func(context.Context) ([]int, error),
+	retry *nullRetry,
 ) error {
+	diagnostics := getGlobalDiagnostics(ctx)
+
+	endPhase := diagnostics.Phase("list")
 	ids, err := getIDs(ctx)
+
+	endPhase()
+
 	if err != nil {
 		return fmt.Errorf("failed to get item ids: %w", err)
 	}
@@ -415,12 +769,16 @@ func runList(
 		ids = ids[:limit]
 	}
 
+	defer diagnostics.Phase("items")()
+
+	tee := newBodyTee(retry != nil)
+
 	return client.Advanced().NewRawItemStream(ctx).SearchOrdered(
 		ids,
-		func(_ int, item io.ReadCloser) (bool, []int, error) {
+		func(id int, item io.ReadCloser) (bool, []int, error) {
 			defer func() { _ = item.Close() }()
 
-			if _, err := io.Copy(writer, item); err != nil {
+			if _, err := io.Copy(writer, tee.read(item)); err != nil {
 				return false, nil, fmt.Errorf("failed to write item: %w", err)
 			}
 
@@ -428,51 +786,69 @@ func runList(
 				return false, nil, fmt.Errorf("failed to write newline: %w", err)
 			}
 
+			retry.add(id, tee.body())
+
 			return true, nil, nil
 		})
 }
 
-func runScan(ctx context.Context, client *hn.Client, writer *bufio.Writer, from int, to int, ascending bool) error {
-	rawItemStream := client.Advanced().NewRawItemStream(ctx)
-	remaining := max(from-to, to-from)
+// scanOptions are where a scan writes its items and what it records along the way, shared by its ranges.
+type scanOptions struct {
+	client     *hn.Client
+	writer     *bufio.Writer
+	outputFile *os.File
+	summary    *scanSummary
+	gaps       *gapReporter
+	retry      *nullRetry
+	pause      *scanPause
+	shards     int
+	ascending  bool
+	dedupe     bool
+}
 
-	bar := progressbar.NewOptions(remaining,
-		progressbar.OptionSetDescription("Scanning"),
-		progressbar.OptionShowCount(),
-		progressbar.OptionShowIts(),
-		progressbar.OptionSetPredictTime(true),
-		progressbar.OptionThrottle(1*time.Second),
-		progressbar.OptionSetWriter(os.Stderr),
-	)
+func runScan(ctx context.Context, o scanOptions, from int, to int) error {
+	var deduper *itemDeduper
+	if o.dedupe {
+		deduper = newItemDeduper()
+	}
 
-	defer func() {
-		if remaining == 0 {
-			_ = bar.Close()
-		} else {
-			_ = bar.Exit()
-		}
+	tee := newBodyTee(o.gaps != nil || o.retry != nil)
 
-		_, _ = os.Stderr.Write([]byte{'\n'})
-	}()
+	var w io.Writer = o.writer
+	if o.summary != nil {
+		w = countingWriter{o.writer, &o.summary.Bytes}
+	}
+
+	defer getGlobalDiagnostics(ctx).Phase("items")()
+
+	rawItemStream := o.client.Advanced().NewRawItemStream(ctx)
+	remaining := max(from-to, to-from)
+
+	bar, endBar := startScanBar(remaining)
+	defer func() { endBar(remaining == 0) }()
 
 	var ids []int
-	from, ids = initializeScanIDs(rawItemStream.MaxInFlight(), from, ascending)
+	from, ids = initializeScanIDs(rawItemStream.MaxInFlight(), from, o.ascending)
 
 	next := make([]int, 1)
 
-	return rawItemStream.SearchOrdered(ids, func(_ int, item io.ReadCloser) (bool, []int, error) {
+	err := rawItemStream.SearchOrdered(ids, func(id int, item io.ReadCloser) (bool, []int, error) {
 		defer func() { _ = item.Close() }()
 
-		_, err := io.Copy(writer, item)
+		written, err := deduper.write(w, id, tee.read(item))
 		if err != nil {
-			return false, nil, fmt.Errorf("failed to write item: %w", err)
+			return false, nil, err
 		}
 
-		_, err = writer.Write([]byte{'\n'})
+		err = o.gaps.add(id, tee.body())
 		if err != nil {
-			return false, nil, fmt.Errorf("failed to write newline: %w", err)
+			return false, nil, err
 		}
 
+		o.retry.add(id, tee.body())
+
+		o.summary.add(id, written)
+
 		remaining--
 		_ = bar.Add(1)
 
@@ -480,10 +856,15 @@ func runScan(ctx context.Context, client *hn.Client, writer *bufio.Writer, from
 			return false, nil, nil
 		}
 
-		if (ascending && from <= to) || (from >= to) {
+		err = o.pause.wait(ctx, id, o.writer.Flush)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if (o.ascending && from <= to) || (from >= to) {
 			next[0] = from
 
-			if ascending {
+			if o.ascending {
 				from++
 			} else {
 				from--
@@ -494,11 +875,36 @@ func runScan(ctx context.Context, client *hn.Client, writer *bufio.Writer, from
 
 		return true, nil, nil
 	})
+	if err != nil {
+		return fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return o.gaps.finish()
+}
+
+// startScanBar shows the progress of a scan of n items on stderr. The returned function ends it, as complete if done.
+func startScanBar(n int) (*progressbar.ProgressBar, func(done bool)) {
+	bar := progressbar.NewOptions(n,
+		progressbar.OptionSetDescription("Scanning"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionThrottle(1*time.Second),
+		progressbar.OptionSetWriter(os.Stderr),
+	)
+
+	return bar, func(done bool) {
+		if done {
+			_ = bar.Close()
+		} else {
+			_ = bar.Exit()
+		}
+
+		_, _ = os.Stderr.Write([]byte{'\n'})
+	}
 }
 
 func initializeScanIDs(maxInFlight int, from int, ascending bool) (int, []int) {
-	// MaxInFlight queued, MaxInFlight in flight, MaxInFlight waiting for in-order processing
-	const scanWindowMultipliers = 3
 	scanWindowLength := maxInFlight * scanWindowMultipliers
 
 	ids := make([]int, 0, scanWindowLength)
@@ -4,18 +4,27 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jasonthorsness/unlurker/hn"
-	"github.com/jasonthorsness/unlurker/testdata"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
 	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/goleak"
 )
@@ -168,6 +177,33 @@ func TestNew(t *testing.T) {
 	testList(t, "new", testdata.New)
 }
 
+func TestVerbose(t *testing.T) {
+	stderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	defer func() { os.Stderr = stderr }()
+
+	_, err := exec(t, "new", "--limit", "5", "-vv")
+
+	err = errors.Join(err, w.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := []string{"requests: 6\n", "items: 5 requested", "file cache puts: 5 queued", "phases: list "}
+	for _, expected := range lines {
+		if !strings.Contains(string(buf), expected) {
+			t.Fatalf("expected %q in diagnostics:\n%s", expected, buf)
+		}
+	}
+}
+
 func TestTop(t *testing.T) {
 	testList(t, "top", testdata.Top)
 }
@@ -176,10 +212,22 @@ func TestBest(t *testing.T) {
 	testList(t, "best", testdata.Best)
 }
 
+func TestAsk(t *testing.T) {
+	testList(t, "ask", testdata.Ask)
+}
+
+func TestShow(t *testing.T) {
+	testList(t, "show", testdata.Show)
+}
+
+func TestJobs(t *testing.T) {
+	testList(t, "jobs", testdata.Jobs)
+}
+
 func testList(t *testing.T, list string, expected []int) {
 	t.Helper()
 	testListInner(t, expected, list)
-	testListInner(t, expected[:10], list, "-l10")
+	testListInner(t, expected[:min(len(expected), 10)], list, "-l10")
 }
 
 func testListInner(t *testing.T, expected []int, args ...string) {
@@ -213,12 +261,18 @@ func testListInner(t *testing.T, expected []int, args ...string) {
 func exec(t *testing.T, args ...string) ([]byte, error) {
 	t.Helper()
 
+	return execGetter(t, testdata.Getter, args...)
+}
+
+func execGetter(t *testing.T, getter core.Getter[string, io.ReadCloser], args ...string) ([]byte, error) {
+	t.Helper()
+
 	defaultCachePath := useDefaultCachePath
 	if defaultCachePath == "" {
 		defaultCachePath = filepath.Join(t.TempDir(), "hn.db")
 	}
 
-	cmd := buildCommand(testdata.Getter, testdata.Clock, defaultCachePath)
+	cmd := buildCommand(getter, siteGetter{}, testdata.Clock, defaultCachePath)
 
 	if useNoCache {
 		args = append(args, "--no-cache")
@@ -278,188 +332,1791 @@ func TestScanAsc(t *testing.T) {
 	}
 }
 
-func TestScanContinue(t *testing.T) {
-	o := filepath.Join(t.TempDir(), "test.json")
-
-	// continue-at - from not exist is OK
-	_, err := exec(t, "scan", "--limit", "2", "--continue-at", "-", "-o", o)
+func TestScanDedupe(t *testing.T) {
+	buf, err := exec(t, "scan", "--dedupe", "--asc", "--continue-at", strconv.Itoa(testdata.MinItem))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// direction change fails
-	_, err = exec(t, "scan", "--asc", "--limit", "2", "--continue-at", "-", "-o", o)
-	if err == nil {
-		t.Fatal("expected error on direction change")
+	if !bytes.Equal(buf, testdata.ItemsRaw) {
+		t.Fatalf("scan bytes differed with --dedupe")
 	}
 
-	// from a specific number is OK
-	_, err = exec(t, "scan", "--limit", "3", "--continue-at", strconv.Itoa(testdata.MaxItem-2), "-o", o)
+	var out bytes.Buffer
+
+	d := newItemDeduper()
+
+	for _, v := range []string{`{"id":1}`, `{"id":1}`, `{"id":2}`, `{"id":1,"dead":true}`, `{"id":1}`} {
+		_, err = d.write(&out, 1, strings.NewReader(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only consecutive identical versions of an ID are skipped
+	expected := `{"id":1}` + "\n" + `{"id":2}` + "\n" + `{"id":1,"dead":true}` + "\n" + `{"id":1}` + "\n"
+	if out.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestScanSummaryJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+
+	buf, err := exec(t, "scan", "--asc", "--continue-at", strconv.Itoa(testdata.MinItem), "--summary-json", path)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// continue-at with remainder
-	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	b, err := os.ReadFile(path)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// continue-at repeated
-	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	var summary scanSummary
+
+	err = json.Unmarshal(b, &summary)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// output file should contain expected results
-	f, err := os.Open(o) //nolint:gosec // G304 intended
+	if summary.Items != testdata.ItemCount || summary.FirstID != testdata.MinItem || summary.LastID != testdata.MaxItem {
+		t.Fatalf("expected items %d to %d in the summary, got %s", testdata.MinItem, testdata.MaxItem, b)
+	}
+
+	if summary.Bytes != int64(len(buf)) || !summary.Completed || summary.Errors != 0 {
+		t.Fatalf("expected a completed scan of %d bytes, got %s", len(buf), b)
+	}
+
+	// a failed scan is summarized too
+	_, err = exec(t, "scan", "--continue-at", "-", "--summary-json", path)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --continue-at - without --output, got %v", err)
+	}
+
+	b, err = os.ReadFile(path)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	defer func() {
-		err = f.Close()
-		if err != nil {
-			t.Fatal(err)
-		}
-	}()
+	summary = scanSummary{}
 
-	verifyFullScan(t, f, testdata.MaxItem, testdata.MinItem)
-}
+	err = json.Unmarshal(b, &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestInvalidUserArgs(t *testing.T) {
-	// using --limit without --submitted must error
-	_, err := exec(t, "user", testdata.UserID, "--limit", "5")
-	if err == nil {
-		t.Fatal("expected error when --limit is used without --submitted")
+	if summary.Completed || summary.Errors != 1 || summary.Error == "" {
+		t.Fatalf("expected the error in the summary, got %s", b)
 	}
 }
 
-func TestUnknownCommand(t *testing.T) {
-	// unknown sub‑commands should return an error
-	_, err := exec(t, "foobar")
-	if err == nil {
-		t.Fatal("expected error for unknown command")
+func TestScanDryRun(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "hn.db")
+	o := filepath.Join(dir, "out.json")
+
+	_, err := exec(t, "scan", "--limit", "5", "--cache-path", cachePath, "-o", o)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if !strings.Contains(err.Error(), "unknown command") {
-		t.Fatalf("unexpected error message: %v", err)
+	before, err := os.ReadFile(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "scan", "--limit", "10", "--continue-at", "-", "--cache-path", cachePath, "-o", o, "--dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var plan scanPlan
+
+	err = json.Unmarshal(buf, &plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the output file holds 5 items, so continuing it to a limit of 10 retrieves the next 5
+	if plan.Items != 5 || plan.FirstID != testdata.MaxItem-5 || plan.LastID != testdata.MaxItem-9 {
+		t.Fatalf("expected 5 items continuing after the output file, got %s", buf)
+	}
+
+	if plan.CachedItems == 0 || plan.Bytes != int64(5*plan.AverageItemBytes) || plan.Bytes == 0 {
+		t.Fatalf("expected the size estimated from the cached items, got %s", buf)
+	}
+
+	after, err := os.ReadFile(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(before, after) {
+		t.Fatalf("expected --dry-run to leave the output file untouched")
 	}
 }
 
-func TestScanTruncateOutput(t *testing.T) {
-	// scan without --continue-at should truncate the output file
-	out := filepath.Join(t.TempDir(), "scan.json")
-	// first write two items
-	_, err := exec(t, "scan", "--limit", "2", "-o", out)
+func TestScanShards(t *testing.T) {
+	buf, err := exec(t, "scan", "--shards", "4", "--asc", "--continue-at", strconv.Itoa(testdata.MinItem))
 	if err != nil {
 		t.Fatal(err)
 	}
-	// then write only one; file must be truncated to one line
-	_, err = exec(t, "scan", "--limit", "1", "-o", out)
+
+	if !bytes.Equal(buf, testdata.ItemsRaw) {
+		t.Fatalf("scan bytes differed with --shards")
+	}
+
+	unsharded, err := exec(t, "scan", "--limit", "1000")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	f, err := os.Open(out) //nolint:gosec // G304 intended
+	sharded, err := exec(t, "scan", "--limit", "1000", "--shards", "3", "--dedupe")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	defer func() {
-		err = f.Close()
-		if err != nil {
-			t.Fatal(err)
-		}
+	if !bytes.Equal(unsharded, sharded) {
+		t.Fatalf("scan bytes differed with --shards descending")
+	}
+
+	_, err = exec(t, "scan", "--shards", "0")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --shards 0, got %v", err)
+	}
+}
+
+func TestScanShardsSteal(t *testing.T) {
+	s := &shardedScan{nil, nil, nil, nil, "", []*shardRange{
+		{"", 0, 0, 10, 0, 0, 0, 0, false},
+		{"", 10, 10, 100, 0, 0, 0, 0, false},
+	}, 1000, 10, 10, sync.Mutex{}, false, false}
+
+	id, ok := s.claim(s.ranges[1])
+	if !ok || id != 990 {
+		t.Fatalf("expected to claim the first ID of the second range, got %d", id)
+	}
+
+	// the second range has the most left, so its back half becomes a range following it
+	r := s.steal()
+	if r == nil || r.start != 55 || r.end != 100 || s.ranges[1].end != 55 || s.ranges[2] != r {
+		t.Fatalf("expected to steal the back half of the second range, got %+v", r)
+	}
+
+	for _, r := range s.ranges {
+		r.next = r.end
+	}
+
+	if s.steal() != nil {
+		t.Fatal("expected nothing to steal")
+	}
+}
+
+func TestScanPause(t *testing.T) {
+	var errBuf bytes.Buffer
+
+	p := newScanPause(&errBuf)
+
+	err := p.wait(t.Context(), 1, func() error { t.Fatal("flushed while running"); return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.pause()
+
+	flushed := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- p.wait(t.Context(), 2, func() error { close(flushed); return nil })
 	}()
 
-	lines := 0
+	<-flushed
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lines++
+	select {
+	case err = <-done:
+		t.Fatalf("expected to wait while paused, got %v", err)
+	case <-time.After(10 * time.Millisecond):
 	}
 
-	err = scanner.Err()
+	p.resume()
+
+	err = <-done
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if lines != 1 {
-		t.Fatalf("expected 1 line after truncation, got %d", lines)
+	expected := "\npaused after item 2, send SIGUSR2 to resume\nresumed after item 2\n"
+	if errBuf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, errBuf.String())
+	}
+
+	// the scan stops waiting when canceled
+	p.pause()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err = p.wait(ctx, 3, func() error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled while paused, got %v", err)
 	}
 }
 
-func TestScanContinueAsc(t *testing.T) {
-	o := filepath.Join(t.TempDir(), "test.json")
+func TestScanCoordinate(t *testing.T) {
+	dir := t.TempDir()
+	leases := filepath.Join(dir, "leases.db")
+	a := filepath.Join(dir, "a.json")
+	b := filepath.Join(dir, "b.json")
 
-	// continue-at - from not exist is OK
-	_, err := exec(t, "scan", "--asc", "-l2", "--continue-at", strconv.Itoa(testdata.MinItem), "-o", o)
+	// the first process decides the range and leases one part of it
+	_, err := exec(t, "scan", "--limit", "1000", "--coordinate", leases, "--lease-size", "300", "--leases", "1", "-o", a)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// continue-at with direction change fails
-	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
-	if err == nil {
-		t.Fatal("expected error on direction change")
+	_, err = exec(t, "scan", "--coordinate", leases, "--lease-size", "300", "-o", b)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	// continue-at repeated
-	_, err = exec(t, "scan", "--asc", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	unsharded, err := exec(t, "scan", "--limit", "1000")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// output file should contain expected results
-	f, err := os.Open(o) //nolint:gosec // G304 intended
+	first, err := os.ReadFile(a)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	defer func() {
-		err = f.Close()
+	rest, err := os.ReadFile(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Count(first, []byte("\n")) != 300 || !bytes.Equal(append(first, rest...), unsharded) {
+		t.Fatalf("expected the processes to split the range without overlap")
+	}
+
+	_, err = exec(t, "scan", "--coordinate", leases, "--continue-at", "-", "-o", b)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --continue-at with --coordinate, got %v", err)
+	}
+}
+
+func TestScanGapsReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gaps.json")
+
+	readGaps := func() []gap {
+		b, err := os.ReadFile(path)
 		if err != nil {
 			t.Fatal(err)
 		}
-	}()
 
-	verifyFullScan(t, f, testdata.MinItem, testdata.MaxItem)
+		var gaps []gap
 
-	_, err = f.Seek(0, 0)
+		d := json.NewDecoder(bytes.NewReader(b))
+		for d.More() {
+			var g gap
+
+			err = d.Decode(&g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gaps = append(gaps, g)
+		}
+
+		return gaps
+	}
+
+	// IDs past the newest item may not have propagated yet
+	_, err := exec(t, "scan", "--continue-at", strconv.Itoa(testdata.MaxItem+3), "--limit", "5",
+		"-o", filepath.Join(dir, "recent.json"), "--gaps-report", path)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	buf, err := io.ReadAll(f)
+	gaps := readGaps()
+	expected := gap{gapRecent, testdata.MaxItem + 1, testdata.MaxItem + 3, 3, testdata.MaxItem, gaps[0].LowerTime, 0, 0}
 
-	if !bytes.Equal(buf, testdata.ItemsRaw) {
-		t.Fatalf("scan bytes differed")
+	if len(gaps) != 1 || gaps[0] != expected || gaps[0].LowerTime == 0 {
+		t.Fatalf("expected %+v, got %+v", expected, gaps)
+	}
+
+	// IDs below old items were never assigned
+	_, err = exec(t, "scan", "--asc", "--continue-at", strconv.Itoa(testdata.MinItem-2), "--limit", "4",
+		"-o", filepath.Join(dir, "unassigned.json"), "--gaps-report", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gaps = readGaps()
+	expected = gap{
+		gapUnassigned, testdata.MinItem - 2, testdata.MinItem - 1, 2, 0, 0, testdata.MinItem, gaps[0].HigherTime,
+	}
+
+	if len(gaps) != 1 || gaps[0] != expected || gaps[0].HigherTime == 0 {
+		t.Fatalf("expected %+v, got %+v", expected, gaps)
+	}
+
+	_, err = exec(t, "scan", "--shards", "2", "--gaps-report", path)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --gaps-report with --shards, got %v", err)
 	}
 }
 
-func verifyFullScan(t *testing.T, r io.Reader, first int, last int) {
-	t.Helper()
+func TestScanRetryNulls(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.json")
+	late := testdata.MinItem + 1
+	getter := &propagatingGetter{testdata.Getter, map[int]bool{late: true}, sync.Mutex{}}
 
-	lineCount := 0
+	_, err := execGetter(t, getter, "scan", "--asc", "--continue-at", strconv.Itoa(testdata.MinItem), "--limit", "3",
+		"-o", filepath.Join(dir, "out.json"), "--retry-nulls=1ms", "--summary-json", summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		switch lineCount {
-		case 0:
-			if !strings.Contains(scanner.Text(), strconv.Itoa(first)) {
-				t.Fatalf("wrong first item: %s", scanner.Text())
-			}
-		case testdata.ItemCount:
-			if !strings.Contains(scanner.Text(), strconv.Itoa(last)) {
-				t.Fatalf("wrong last item: %s", scanner.Text())
-			}
-		}
+	buf, err := os.ReadFile(filepath.Join(dir, "out.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		lineCount++
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 4 || lines[1] != "null" {
+		t.Fatalf("expected the null line and the filled in item after the scan, got %q", lines)
 	}
 
-	if lineCount != testdata.ItemCount {
-		t.Fatalf("scan returned %d lines, expected %d", lineCount, testdata.ItemCount)
+	id, _, ok := core.ItemIDAndTime([]byte(lines[3]))
+	if !ok || id != late {
+		t.Fatalf("expected item %d after the scan, got %q", late, lines[3])
+	}
+
+	b, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary scanSummary
+
+	err = json.Unmarshal(b, &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Filled != 1 || summary.Bytes != int64(len(buf)) {
+		t.Fatalf("expected 1 filled in item of %d bytes in the summary, got %s", len(buf), b)
+	}
+
+	_, err = exec(t, "tail", "--events", "--retry-nulls")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --retry-nulls with --events, got %v", err)
+	}
+}
+
+// propagatingGetter returns null for the pending items the first time they are retrieved, like new items the API
+// has not propagated yet.
+type propagatingGetter struct {
+	inner   core.Getter[string, io.ReadCloser]
+	pending map[int]bool
+	mu      sync.Mutex
+}
+
+func (g *propagatingGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "item/"), ".json"))
+	if err == nil {
+		g.mu.Lock()
+		pending := g.pending[id]
+		delete(g.pending, id)
+		g.mu.Unlock()
+
+		if pending {
+			return io.NopCloser(strings.NewReader("null")), nil
+		}
+	}
+
+	return g.inner.Get(ctx, path) //nolint:wrapcheck // passes through to the test data
+}
+
+func TestTail(t *testing.T) {
+	expected := make([]int, 0, 5)
+	for id := testdata.MaxItem - 4; id <= testdata.MaxItem; id++ {
+		expected = append(expected, id)
+	}
+
+	testListInner(t, expected, "tail", "--back", "5", "--polls", "2", "--interval", "1ms")
+}
+
+func TestTailClock(t *testing.T) {
+	clock := &recordingClock{nil}
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(testdata.Getter), hn.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	writer := bufio.NewWriter(io.Discard)
+	tl := tail{client, writer, nil, make(map[int]*hn.Item), clock, nil, io.Discard, 0, false}
+
+	ctx := context.WithValue(
+		t.Context(), globalItemsContextKey{}, &globalItems{client, writer, nil, nil, hn.HackerNews, ""})
+
+	// the waits between polls go through the clock, so an hour between them takes no real time
+	err = tl.run(ctx, 1, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []time.Duration{time.Hour, time.Hour}; !slices.Equal(clock.sleeps, expected) {
+		t.Fatalf("expected waits of %v, got %v", expected, clock.sleeps)
+	}
+}
+
+func TestTailEvents(t *testing.T) {
+	id := testdata.MaxItem - 1
+
+	r, err := testdata.Getter.Get(t.Context(), "item/"+strconv.Itoa(id)+".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var current hn.Item
+
+	err = errors.Join(json.NewDecoder(r).Decode(&current), r.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := current
+	edited.Score++
+
+	value, err := json.Marshal(&edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an hour-old refresh is stale so the tail refreshes it
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	cache, err := core.NewItemFileCache(t.Context(), fixedClock{testdata.MaxTime.Add(-time.Hour)}, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = errors.Join(cache.Put(t.Context(), [][]byte{value}), cache.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "tail", "--events", "--back", "2", "--polls", "2", "--interval", "1ms", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []itemEvent
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		var event itemEvent
+
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events = append(events, event)
+	}
+
+	// the uncached item is new, the edited item differs by score, and nothing changes on the second poll
+	if len(events) != 2 || events[0].ID != id || events[1].ID != testdata.MaxItem {
+		t.Fatalf("unexpected events %q", buf)
+	}
+
+	if diff := cmp.Diff(events[0].Changed, []string{"score"}); diff != "" || events[0].Old.Score != edited.Score {
+		t.Fatalf("unexpected changed fields: %s", diff)
+	}
+
+	if events[1].Old != nil || !slices.Contains(events[1].Changed, "id") {
+		t.Fatalf("expected an event without an old item for the uncached item, got %q", buf)
+	}
+}
+
+func TestScanContinue(t *testing.T) {
+	o := filepath.Join(t.TempDir(), "test.json")
+
+	// continue-at - from not exist is OK
+	_, err := exec(t, "scan", "--limit", "2", "--continue-at", "-", "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// direction change fails
+	_, err = exec(t, "scan", "--asc", "--limit", "2", "--continue-at", "-", "-o", o)
+	if err == nil {
+		t.Fatal("expected error on direction change")
+	}
+
+	// from a specific number is OK
+	_, err = exec(t, "scan", "--limit", "3", "--continue-at", strconv.Itoa(testdata.MaxItem-2), "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// continue-at with remainder
+	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// continue-at repeated
+	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// output file should contain expected results
+	f, err := os.Open(o) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	verifyFullScan(t, f, testdata.MaxItem, testdata.MinItem)
+}
+
+func TestInvalidUserArgs(t *testing.T) {
+	// using --limit without --submitted must error
+	_, err := exec(t, "user", testdata.UserID, "--limit", "5")
+	if err == nil {
+		t.Fatal("expected error when --limit is used without --submitted")
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	// unknown sub‑commands should return an error
+	_, err := exec(t, "foobar")
+	if err == nil {
+		t.Fatal("expected error for unknown command")
+	}
+
+	if !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+func TestScanTruncateOutput(t *testing.T) {
+	// scan without --continue-at should truncate the output file
+	out := filepath.Join(t.TempDir(), "scan.json")
+	// first write two items
+	_, err := exec(t, "scan", "--limit", "2", "-o", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// then write only one; file must be truncated to one line
+	_, err = exec(t, "scan", "--limit", "1", "-o", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	lines := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines != 1 {
+		t.Fatalf("expected 1 line after truncation, got %d", lines)
+	}
+}
+
+func TestScanContinueAsc(t *testing.T) {
+	o := filepath.Join(t.TempDir(), "test.json")
+
+	// continue-at - from not exist is OK
+	_, err := exec(t, "scan", "--asc", "-l2", "--continue-at", strconv.Itoa(testdata.MinItem), "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// continue-at with direction change fails
+	_, err = exec(t, "scan", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	if err == nil {
+		t.Fatal("expected error on direction change")
+	}
+
+	// continue-at repeated
+	_, err = exec(t, "scan", "--asc", "--limit", strconv.Itoa(testdata.ItemCount), "--continue-at", "-", "-o", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// output file should contain expected results
+	f, err := os.Open(o) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	verifyFullScan(t, f, testdata.MinItem, testdata.MaxItem)
+
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := io.ReadAll(f)
+
+	if !bytes.Equal(buf, testdata.ItemsRaw) {
+		t.Fatalf("scan bytes differed")
+	}
+}
+
+func verifyFullScan(t *testing.T, r io.Reader, first int, last int) {
+	t.Helper()
+
+	lineCount := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		switch lineCount {
+		case 0:
+			if !strings.Contains(scanner.Text(), strconv.Itoa(first)) {
+				t.Fatalf("wrong first item: %s", scanner.Text())
+			}
+		case testdata.ItemCount:
+			if !strings.Contains(scanner.Text(), strconv.Itoa(last)) {
+				t.Fatalf("wrong last item: %s", scanner.Text())
+			}
+		}
+
+		lineCount++
+	}
+
+	if lineCount != testdata.ItemCount {
+		t.Fatalf("scan returned %d lines, expected %d", lineCount, testdata.ItemCount)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "out.json")
+
+	err := os.WriteFile(f, testdata.ItemsRaw, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "validate", "-f", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(buf) != 0 {
+		t.Fatalf("expected no anomalies, got %s", buf)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(testdata.ItemsRaw), []byte{'\n'})
+	lines[1], lines[2] = lines[2], lines[1]
+	lines = append(lines, []byte(`{"id":1,"type":"comment","time":1,"unexpected":true}`))
+
+	err = os.WriteFile(f, bytes.Join(lines, []byte{'\n'}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "validate", "-f", f)
+	if !errors.Is(err, ErrArchiveInvalid) {
+		t.Fatalf("expected invalid archive, got %v", err)
+	}
+}
+
+func TestValidateAnomalies(t *testing.T) {
+	archive := strings.Join([]string{
+		`{"by":"a","id":10,"time":1,"title":"t","type":"story"}`,
+		`{"by":"b","id":11,"parent":10,"time":2,"type":"comment"}`,
+		`{"by":"c","id":13,"parent":12,"time":3,"type":"comment"}`,
+		`{"by":"d","id":14,"time":4,"type":"story"}`,
+		`{"id":15,"time":5,"type":"comment"}`,
+		`{"by":"e","id":16,"parent":17,"time":6,"type":"comment"}`,
+		`{"by":"f","id":16,"time":7,"title":"t","type":"story"}`,
+		`{"by":"g","id":18,"time":8,"title":"t","type":"bogus"}`,
+		`null`,
+		`{"id":`,
+	}, "\n")
+
+	var buf bytes.Buffer
+
+	summary, err := runValidate(strings.NewReader(archive), json.NewEncoder(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var a validateAnomaly
+
+		err = json.Unmarshal(scanner.Bytes(), &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kinds = append(kinds, strconv.Itoa(a.Line)+":"+a.Kind+":"+a.Detail)
+	}
+
+	expected := []string{
+		"4:missing-field:title",
+		"5:missing-field:by",
+		"5:missing-field:parent",
+		"6:bad-reference:refers to newer item 17",
+		"7:id-order:duplicate id",
+		"8:unknown-type:bogus",
+		"10:malformed:unexpected EOF",
+		"3:missing-parent:12",
+	}
+
+	diff := cmp.Diff(expected, kinds)
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	if summary.items != 10 || summary.nullBodies != 1 || summary.anomalies != len(expected) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "out.json")
+	db := filepath.Join(dir, "archive.db")
+	roundTrip := filepath.Join(dir, "roundtrip.json")
+
+	err := os.WriteFile(archive, testdata.ItemsRaw, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "convert", "--from", archive, "--to", db, "--asc")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --asc from NDJSON, got %v", err)
+	}
+
+	_, err = exec(t, "convert", "--from", archive, "--to", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "convert", "--from", db, "--to", roundTrip, "--asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := os.ReadFile(roundTrip) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, testdata.ItemsRaw) {
+		t.Fatalf("round trip bytes differed")
+	}
+
+	_, err = exec(t, "convert", "--from", db, "--to", roundTrip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(roundTrip) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	verifyFullScan(t, f, testdata.MaxItem, testdata.MinItem)
+}
+
+// TestConvertCountsNulls checks that null lines in a scan archive are not counted as converted items.
+func TestConvertCountsNulls(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "out.json")
+
+	lines := slices.Collect(bytes.Lines(testdata.ItemsRaw))
+	withNulls := bytes.Join([][]byte{[]byte("null\n"), bytes.Join(lines, nil), []byte("null\n")}, nil)
+
+	err := os.WriteFile(archive, withNulls, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := convertNDJSONToSQLite(context.Background(), archive, filepath.Join(dir, "archive.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(lines) {
+		t.Fatalf("expected %d converted items, got %d", len(lines), n)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "out.json")
+	db := filepath.Join(dir, "archive.db")
+
+	err := os.WriteFile(archive, testdata.ItemsRaw, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "browse", "-f", archive, "--types", "story", "--limit", "3", "--lines", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := strings.Split(strings.TrimSpace(string(buf)), "\n\n")
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(blocks))
+	}
+
+	for _, block := range blocks {
+		heading, _, _ := strings.Cut(block, "\n")
+		if !strings.Contains(heading, " story by ") || !strings.HasSuffix(heading, " comments") {
+			t.Fatalf("unexpected heading %q", heading)
+		}
+	}
+
+	_, err = exec(t, "convert", "--from", archive, "--to", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same items are found by their title in either kind of archive
+	var story struct {
+		Title string `json:"title"`
+		ID    int    `json:"id"`
+	}
+
+	first, _, _ := strings.Cut(blocks[0], " ")
+
+	for line := range bytes.Lines(testdata.ItemsRaw) {
+		err = json.Unmarshal(line, &story)
+		if err == nil && strconv.Itoa(story.ID) == first {
+			break
+		}
+	}
+
+	for _, from := range []string{archive, db} {
+		buf, err = exec(t, "browse", "-f", from, "--query", strings.ToUpper(story.Title))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(buf), strconv.Itoa(story.ID)+" story by ") {
+			t.Fatalf("expected item %d in %s, got %s", story.ID, from, buf)
+		}
+	}
+
+	_, err = exec(t, "browse", "-f", archive, "--pager", "sometimes")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --pager, got %v", err)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	_, err := exec(t, "warm", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	stories := make(map[int]struct{})
+	for _, id := range append(slices.Clone(testdata.Top), testdata.Best...) {
+		stories[id] = struct{}{}
+	}
+
+	warm := func(args ...string) warmReport {
+		t.Helper()
+
+		buf, err := exec(t, append([]string{"warm", "--cache-path", db}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var report warmReport
+
+		err = json.Unmarshal(buf, &report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return report
+	}
+
+	report := warm("--depth", "stories")
+	if report.Items != len(stories) || report.Truncated || report.Lists["top"] != len(testdata.Top) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	report = warm("--budget", "10")
+	if report.Items != 10 || !report.Truncated {
+		t.Fatalf("unexpected budgeted report %+v", report)
+	}
+
+	report = warm()
+	if report.Items <= len(stories) || report.Truncated {
+		t.Fatalf("expected comments to be warmed, got %+v", report)
+	}
+
+	cache, err := core.NewItemFileCache(t.Context(), testdata.Clock, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = cache.Close() }()
+
+	entry, err := cache.Entry(t.Context(), testdata.Top[0])
+	if err != nil || entry == nil {
+		t.Fatalf("expected %d to be cached: %v", testdata.Top[0], err)
+	}
+}
+
+type recordingClock struct {
+	sleeps []time.Duration
+}
+
+func (c *recordingClock) Now() time.Time {
+	return testdata.MaxTime
+}
+
+func (c *recordingClock) Sleep(_ context.Context, d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+}
+
+func TestWarmEvery(t *testing.T) {
+	const (
+		every  = 5 * time.Minute
+		jitter = time.Minute
+	)
+
+	fake, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errInjected := errors.New("injected")
+	failed := false
+
+	warm := func(ctx context.Context) (warmReport, error) {
+		if !failed {
+			failed = true
+			return warmReport{nil, 0, false}, errInjected
+		}
+
+		return runWarm(ctx, fake, []string{"top"}, false, 0)
+	}
+
+	var buf, errBuf bytes.Buffer
+
+	clock := &recordingClock{nil}
+	writer := bufio.NewWriter(&buf)
+
+	err = runWarmEvery(t.Context(), clock, writer, &errBuf, every, jitter, 3, warm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clock.sleeps) != 2 {
+		t.Fatalf("expected 2 waits, got %v", clock.sleeps)
+	}
+
+	for _, d := range clock.sleeps {
+		if d < every || d >= every+jitter {
+			t.Fatalf("wait %s outside [%s, %s)", d, every, every+jitter)
+		}
+	}
+
+	if !strings.Contains(errBuf.String(), errInjected.Error()) {
+		t.Fatalf("expected the failed run to be reported, got %q", errBuf.String())
+	}
+
+	if n := bytes.Count(buf.Bytes(), []byte{'\n'}); n != 2 {
+		t.Fatalf("expected 2 reports, got %d", n)
+	}
+
+	_, err = exec(t, "warm", "--runs", "2")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --runs without --every, got %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "cache.db")
+	first, second := testdata.New[0], testdata.New[1]
+
+	_, err := exec(t, "saved", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "save", strconv.Itoa(first), "--tag", "reading", "--note", "later", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "save", strconv.Itoa(second), "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "saved", "--tag", "reading", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item hn.Item
+
+	err = json.Unmarshal(buf, &item)
+	if err != nil || item.ID != first {
+		t.Fatalf("expected only item %d, got %q: %v", first, buf, err)
+	}
+
+	exported := filepath.Join(dir, "bookmarks.json")
+
+	_, err = exec(t, "saved", "--export", "-o", exported, "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := filepath.Join(dir, "imported.db")
+
+	_, err = exec(t, "saved", "--import", exported, "--cache-path", imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = exec(t, "saved", "--export", "--cache-path", imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(buf), `"note":"later","tags":["reading"],"id":`+strconv.Itoa(first)) ||
+		bytes.Count(buf, []byte{'\n'}) != 2 {
+		t.Fatalf("expected both bookmarks after import, got %q", buf)
+	}
+
+	_, err = exec(t, "save", strconv.Itoa(first), "--push", "evernote", "--cache-path", imported)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for an unknown read-later service, got %v", err)
+	}
+
+	t.Setenv("POCKET_CONSUMER_KEY", "")
+
+	_, err = exec(t, "save", strconv.Itoa(first), "--push", "pocket", "--cache-path", imported)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for missing credentials, got %v", err)
+	}
+
+	_, err = exec(t, "save", strconv.Itoa(first), "--remove", "--cache-path", imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = exec(t, "saved", "--tag", "reading", "--cache-path", imported)
+	if err != nil || len(buf) != 0 {
+		t.Fatalf("expected no bookmarks after removal, got %q: %v", buf, err)
+	}
+}
+
+func TestCacheVerify(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	_, err := exec(t, "cache", "verify", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "scan", "--limit", "100", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "cache", "verify", "--sample", "10", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report cacheVerifyReport
+
+	err = json.Unmarshal(buf, &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Sampled != 10 || report.Identical != 10 || report.Stale+report.Fresh != 10 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestItem(t *testing.T) {
+	testListInner(t, []int{43728149, 43727543}, "item", "43728149", "43727543")
+	testListInner(t, []int{43728149, 43727543}, "item",
+		"https://news.ycombinator.com/item?id=43728149", "news.ycombinator.com/item?id=43700000#43727543")
+
+	_, err := exec(t, "item", "43727543", "43728149", "--diff")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with two items, got %v", err)
+	}
+
+	_, err = exec(t, "item", "abc")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with a bad id, got %v", err)
+	}
+}
+
+func TestItemTree(t *testing.T) {
+	// a story with a chain of three replies
+	thread := []int{43727640, 43727674, 43727811, 43728237}
+
+	testListInner(t, thread, "item", "43727640", "--tree")
+
+	buf, err := exec(t, "item", "43727640", "--tree", "--pretty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if len(lines) != len(thread) || strings.Contains(string(buf), "\x1b[") {
+		t.Fatalf("expected %d uncolored lines, got %q", len(thread), buf)
+	}
+
+	for i, line := range lines {
+		if !strings.HasPrefix(line, hn.ItemURL(thread[i])+" ") {
+			t.Fatalf("expected line %d to be item %d, got %q", i, thread[i], line)
+		}
+
+		// each reply is indented one more level than its parent
+		if i > 0 && !strings.Contains(line, " "+strings.Repeat(" ", i-1)+"\\- ") {
+			t.Fatalf("expected line %d at depth %d, got %q", i, i, line)
+		}
+	}
+
+	buf, err = exec(t, "item", "43727640", "--pretty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Count(string(buf), "\n") != 1 {
+		t.Fatalf("expected only the story without --tree, got %q", buf)
+	}
+
+	_, err = exec(t, "item", "43727640", "--tree", "--diff")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --tree with --diff, got %v", err)
+	}
+}
+
+func TestItemCheckDescendants(t *testing.T) {
+	buf, err := exec(t, "item", "43727640", "--check-descendants")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report descendantsReport
+
+	err = json.Unmarshal(buf, &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the story of TestItemTree has a chain of three live replies
+	if report.ID != 43727640 || report.Retrieved != 3 || report.Visible != 3 ||
+		report.Mismatch != (report.Descendants != 3) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	_, err = exec(t, "item", "43727640", "--check-descendants", "--tree")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --check-descendants with --tree, got %v", err)
+	}
+
+	// a dead reply hides its live replies, while a deleted one shows them under a placeholder
+	var items []*hn.Item
+
+	err = json.Unmarshal([]byte(`[
+		{"id":2,"type":"comment","parent":1},
+		{"id":3,"type":"comment","parent":2,"dead":true},
+		{"id":4,"type":"comment","parent":3},
+		{"id":5,"type":"comment","parent":1,"deleted":true},
+		{"id":6,"type":"comment","parent":5}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root hn.Item
+	root.ID = 1
+	root.Type = hn.Story
+	root.Title = "root"
+	root.Descendants = 2
+
+	expected := descendantsReport{"root", 1, 2, 5, 2, 1, 1, 1, 0, false}
+
+	if actual := newDescendantsReport(&root, allByParent); actual != expected {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestItemDiff(t *testing.T) {
+	const id = 43727543
+
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	buf, err := exec(t, "item", strconv.Itoa(id), "--diff", "--cache-history", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(buf) != 0 {
+		t.Fatalf("expected no diff without a previous version, got %q", buf)
+	}
+
+	r, err := testdata.Getter.Get(t.Context(), "item/"+strconv.Itoa(id)+".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := bytes.Replace(original, []byte("a first read"), []byte("a quick read"), 1)
+
+	cache, err := core.NewItemFileCache(t.Context(), testdata.Clock, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = errors.Join(
+		cache.Put(t.Context(), [][]byte{edited}),
+		cache.Put(t.Context(), [][]byte{original}),
+		cache.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err = exec(t, "item", strconv.Itoa(id), "--diff", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(buf), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "--- item/43727543 (retrieved ") {
+		t.Fatalf("unexpected diff header: %q", buf)
+	}
+
+	if !strings.Contains(string(buf), "\n-Only managed a quick read,") ||
+		!strings.Contains(string(buf), "\n+Only managed a first read,") {
+		t.Fatalf("unexpected diff: %q", buf)
+	}
+}
+
+func TestItemExplain(t *testing.T) {
+	const id = 43727543
+
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	explain := func() []itemExplanation {
+		buf, err := exec(t, "item", strconv.Itoa(id), strconv.Itoa(id), "--explain", "--cache-path", db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result []itemExplanation
+
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		for decoder.More() {
+			var e itemExplanation
+
+			err = decoder.Decode(&e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result = append(result, e)
+		}
+
+		return result
+	}
+
+	first := explain()
+	if len(first) != 2 || first[0].Source != itemSourceNetwork || first[0].Cached != nil ||
+		first[0].Timings.Network == "" || first[1].Source != itemSourceMap {
+		t.Fatalf("expected the network and then the in-memory cache, got %+v", first)
+	}
+
+	second := explain()
+	if len(second) != 2 || second[0].Source != itemSourceFile || second[0].Cached == nil ||
+		second[0].Cached.Stale || second[0].Timings.Network != "" {
+		t.Fatalf("expected the file cache, got %+v", second)
+	}
+
+	_, err := exec(t, "item", strconv.Itoa(id), "--explain", "--diff")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --diff and --explain, got %v", err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	b := []string{"1", "2", "three", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+13\n"
+
+	diff := cmp.Diff(unifiedDiff(a, b, "a", "b", 3), expected)
+	if diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	if unifiedDiff(a, a, "a", "a", 3) != "" {
+		t.Fatal("expected no diff for equal input")
+	}
+}
+
+// siteGetter serves the newest listing as pages of 30 of testdata.New linked like the site.
+type siteGetter struct{}
+
+func (siteGetter) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	const perPage = 30
+
+	page := 0
+
+	if v, ok := strings.CutPrefix(path, "newest?next="); ok {
+		v, _, _ = strings.Cut(v, "&")
+
+		next, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+
+		page = slices.Index(testdata.New, next) / perPage
+	} else if path != "newest" {
+		return nil, &core.GetterError{Path: path, Code: http.StatusNotFound}
+	}
+
+	ids := testdata.New[page*perPage : min(len(testdata.New), (page+1)*perPage)]
+
+	var sb strings.Builder
+
+	for _, id := range ids {
+		fmt.Fprintf(&sb, "<tr class='athing submission' id='%d'></tr>\n", id)
+	}
+
+	if (page+1)*perPage < len(testdata.New) {
+		fmt.Fprintf(&sb, "<a href='newest?next=%d&amp;n=%d' class='morelink'>More</a>\n",
+			testdata.New[(page+1)*perPage], (page+1)*perPage+1)
+	}
+
+	return io.NopCloser(strings.NewReader(sb.String())), nil
+}
+
+func TestPage(t *testing.T) {
+	testListInner(t, testdata.New[:30], "page", "newest")
+	testListInner(
+		t, testdata.New[:75], "page", "newest", "--pages", "3", "--limit", "75", "--delay", "0", "--jitter", "0")
+
+	_, err := exec(t, "page", "item")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for an unknown listing, got %v", err)
+	}
+
+	_, err = exec(t, "page", "newest", "--pages", "0")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for zero pages, got %v", err)
+	}
+}
+
+func TestDataset(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "out.json")
+	prefix := filepath.Join(dir, "ds")
+
+	err := os.WriteFile(archive, testdata.ItemsRaw, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"--split", "train:0.8,test:0.3"},
+		{"--split", "train"},
+		{"--fields", "title,nope"},
+		{"--sample", "0"},
+		{"--clean", "shout"},
+	} {
+		_, err = exec(t, append([]string{"dataset", "--from", archive, "--prefix", prefix}, args...)...)
+		if !errors.Is(err, errInvalidArgs) {
+			t.Fatalf("expected invalid args for %v, got %v", args, err)
+		}
+	}
+
+	read := func() (string, string) {
+		train, err := os.ReadFile(prefix + ".train.jsonl")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test, err := os.ReadFile(prefix + ".test.jsonl")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(train), string(test)
+	}
+
+	_, err = exec(t, "dataset", "--from", archive, "--prefix", prefix, "--fields", "id,title,text,score")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	train, test := read()
+	trainLines := strings.Count(train, "\n")
+	testLines := strings.Count(test, "\n")
+
+	if trainLines == 0 || testLines == 0 || trainLines < testLines {
+		t.Fatalf("unexpected split sizes %d and %d", trainLines, testLines)
+	}
+
+	first, _, _ := strings.Cut(train, "\n")
+	if !strings.HasPrefix(first, `{"id":`) || strings.Contains(train, "&#x27;") {
+		t.Fatalf("unexpected item %s", first)
+	}
+
+	db := filepath.Join(dir, "archive.db")
+
+	_, err = exec(t, "convert", "--from", archive, "--to", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "dataset", "--from", db, "--prefix", prefix, "--fields", "id,title,text,score")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromDBTrain, fromDBTest := read()
+	if fromDBTrain != train || fromDBTest != test {
+		t.Fatalf("expected the same splits from the archive and the database")
+	}
+
+	_, err = exec(t, "dataset", "--from", db, "--prefix", prefix, "--seed", "2", "--sample", "0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampledTrain, sampledTest := read()
+	if n := strings.Count(sampledTrain+sampledTest, "\n"); n == 0 || n >= trainLines+testLines {
+		t.Fatalf("expected about half of %d items, got %d", trainLines+testLines, n)
+	}
+
+	_, err = exec(t, "dataset", "--from", db, "--prefix", prefix, "--fields", "text", "--clean", "paragraphs,lowercase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanedTrain, _ := read()
+	if !strings.Contains(cleanedTrain, `\n\n`) || strings.ToLower(cleanedTrain) != cleanedTrain {
+		t.Fatalf("expected lowercase text with paragraph breaks")
+	}
+}
+
+func TestCacheCompact(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "cache.db")
+
+	_, err := exec(t, "cache", "compact", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "scan", "--limit", "500", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting most items leaves free pages for compaction to reclaim
+	sqlDB, err := sql.Open("sqlite3", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sqlDB.ExecContext(t.Context(), "DELETE FROM item WHERE ID NOT IN (SELECT ID FROM item LIMIT 10)")
+
+	err = errors.Join(err, sqlDB.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := exec(t, "cache", "compact", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report cacheCompactReport
+
+	err = json.Unmarshal(buf, &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.After <= 0 || report.After >= report.Before {
+		t.Fatalf("expected compaction to shrink the cache, got %+v", report)
+	}
+
+	buf, err = exec(t, "cache", "verify", "--sample", "100", "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verify cacheVerifyReport
+
+	err = json.Unmarshal(buf, &verify)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verify.Sampled != 10 {
+		t.Fatalf("expected the remaining items after compaction, got %+v", verify)
+	}
+}
+
+func TestSource(t *testing.T) {
+	dir := t.TempDir()
+
+	useDefaultCachePath = filepath.Join(dir, "hn.db")
+	defer func() { useDefaultCachePath = "" }()
+
+	_, err := exec(t, "new", "--source", "lobsters")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for an unknown source, got %v", err)
+	}
+
+	// the test data only serves the paths of the HN API, so the translated Algolia paths are not found
+	_, err = exec(t, "user", testdata.UserID, "--source", "algolia")
+	if !errors.Is(err, testdata.ErrNotFound) {
+		t.Fatalf("expected the Algolia path to be requested, got %v", err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "hn-algolia.db"))
+	if err != nil {
+		t.Fatalf("expected the Algolia source to use its own cache: %v", err)
+	}
+}
+
+func TestCacheName(t *testing.T) {
+	dir := t.TempDir()
+
+	useDefaultCachePath = filepath.Join(dir, "hn.db")
+	defer func() { useDefaultCachePath = "" }()
+
+	_, err := exec(t, "new", "--limit", "3", "--cache-name", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "new", "--cache-name", "archive", "--cache-path", filepath.Join(dir, "other.db"))
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --cache-path, got %v", err)
+	}
+
+	_, err = exec(t, "new", "--cache-name", "../archive")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with a path as a name, got %v", err)
+	}
+
+	buf, err := exec(t, "cache", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var caches []hn.NamedFileCache
+
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	for decoder.More() {
+		var cache hn.NamedFileCache
+
+		err = decoder.Decode(&cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		caches = append(caches, cache)
+	}
+
+	if len(caches) != 2 || caches[0].Name != "" || caches[1].Name != "archive" ||
+		caches[1].Path != filepath.Join(dir, "hn-archive.db") || caches[1].Size == 0 {
+		t.Fatalf("unexpected caches %+v", caches)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	commands := filepath.Join(dir, "commands.txt")
+	db := filepath.Join(dir, "cache.db")
+
+	err := os.WriteFile(commands, []byte("# lists\nnew --limit 2\n\nitem 43727543 'extra arg'\nitem 43727543\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "batch", "-f", commands, "--cache-path", db)
+	if err == nil {
+		t.Fatal("expected the invalid item id to stop the batch")
+	}
+
+	// the output file is still written when the batch fails
+	out := filepath.Join(dir, "out.json")
+
+	_, err = exec(t, "batch", "-f", commands, "--keep-going", "--cache-path", db, "-o", out)
+	if err == nil {
+		t.Fatal("expected the batch to report the failed command")
+	}
+
+	buf, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []batchFrame
+
+	items := 0
+
+	// items have no batch field
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	for decoder.More() {
+		var frame batchFrame
+
+		err = decoder.Decode(&frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if frame.Batch == 0 {
+			items++
+			continue
+		}
+
+		frames = append(frames, frame)
+	}
+
+	if len(frames) != 6 || items != 3 ||
+		!slices.Equal(frames[0].Args, []string{"new", "--limit", "2"}) || !*frames[1].OK ||
+		!slices.Equal(frames[2].Args, []string{"item", "43727543", "extra arg"}) || *frames[3].OK ||
+		frames[3].Error == "" || !*frames[5].OK {
+		t.Fatalf("unexpected frames %+v with %d items", frames, items)
+	}
+
+	err = os.WriteFile(commands, []byte("new --no-cache\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = exec(t, "batch", "-f", commands, "--cache-path", db)
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with a global flag in the batch, got %v", err)
+	}
+}
+
+func TestSplitBatchLine(t *testing.T) {
+	args, err := splitBatchLine(`item  1 "a b" 'c \d' e\ f ""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"item", "1", "a b", `c \d`, "e f", ""}, args); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	_, err = splitBatchLine(`item "1`)
+	if !errors.Is(err, errUnterminatedQuote) {
+		t.Fatalf("expected an unterminated quote, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	buf, err := exec(t, "scan", "--limit", "50", "--rate-limit", "100000", "--rate-schedule", "00:00-08:00=100%,20%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlimited, err := exec(t, "scan", "--limit", "50")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, unlimited) {
+		t.Fatalf("scan bytes differed with --rate-limit")
+	}
+
+	for _, args := range [][]string{
+		{"--rate-limit", "-1"},
+		{"--rate-schedule", "20%"},
+		{"--rate-limit", "10", "--rate-schedule", "08:00-25:00=20%"},
+	} {
+		_, err = exec(t, append([]string{"new", "--limit", "1"}, args...)...)
+		if !errors.Is(err, errInvalidArgs) {
+			t.Fatalf("expected invalid args for %v, got %v", args, err)
+		}
 	}
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+)
+
+func pageCmd(siteGetter core.Getter[string, io.ReadCloser]) *cobra.Command {
+	const (
+		defaultDelay  = time.Second
+		defaultJitter = time.Second
+	)
+
+	var (
+		pages  int
+		limit  int
+		proxy  string
+		delay  time.Duration
+		jitter time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "page [listing]",
+		Short: "Retrieve items from a news.ycombinator.com listing",
+		Long: "Retrieves items in the order of a site listing, scraping the listing pages to go beyond the\n" +
+			"500 stories of the API lists. Listings: " + strings.Join(unl.Listings, ", "),
+		Example: "  hn page newest --pages 5\n" +
+			"  hn page ask --limit 10",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			client, writer, _ := getGlobalItems(ctx)
+
+			if pages < 1 {
+				return fmt.Errorf("%w: --pages must be at least 1", errInvalidArgs)
+			}
+
+			if !slices.Contains(unl.Listings, args[0]) {
+				return fmt.Errorf("%w: unrecognized listing %q", errInvalidArgs, args[0])
+			}
+
+			getter := siteGetter
+			if getter == nil {
+				httpClient, err := unl.NewSiteHTTPClient(proxy)
+				if err != nil {
+					return fmt.Errorf("%w: %w", errInvalidArgs, err)
+				}
+
+				getter = core.NewBaseGetter(httpClient, unl.SiteURL)
+			}
+
+			listing := unl.NewListingClient(unl.NewPoliteGetter(getter, delay, jitter))
+
+			return runList(ctx, client, writer, limit, func(ctx context.Context) ([]int, error) {
+				return listing.GetIDs(ctx, args[0], pages)
+			}, nil)
+		},
+	}
+
+	cmd.Flags().IntVar(&pages, "pages", 1, "number of listing pages to scrape (30 items each)")
+	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "limit number of items")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "HTTP or SOCKS5 proxy URL for scraping the site")
+	cmd.Flags().DurationVar(&delay, "delay", defaultDelay, "delay between listing page requests")
+	cmd.Flags().DurationVar(&jitter, "jitter", defaultJitter, "maximum random delay added to --delay")
+
+	return cmd
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// scanPause pauses a scan on SIGUSR1 and resumes it on SIGUSR2, so an operator can yield bandwidth for a while without
+// killing the scan and continuing it later. While paused, no more items are requested; those already requested are
+// retrieved and wait to be written. A nil scanPause never pauses.
+type scanPause struct {
+	errWriter io.Writer
+	resumed   chan struct{}
+	mu        sync.Mutex
+	reported  bool
+}
+
+func newScanPause(errWriter io.Writer) *scanPause {
+	return &scanPause{errWriter, nil, sync.Mutex{}, false}
+}
+
+// listen pauses and resumes p on SIGUSR1 and SIGUSR2 until the returned function is called.
+func (p *scanPause) listen() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					p.pause()
+				} else {
+					p.resume()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func (p *scanPause) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+		p.reported = false
+	}
+}
+
+func (p *scanPause) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+// wait returns at once unless the scan is paused. Otherwise it calls flush, reports the position after the item id, and
+// waits until the scan is resumed or ctx is done. Shards wait separately and the first to wait reports its position.
+func (p *scanPause) wait(ctx context.Context, id int, flush func() error) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	resumed := p.resumed
+	report := resumed != nil && !p.reported
+	p.reported = p.reported || report
+	p.mu.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+
+	err := flush()
+	if err != nil {
+		return fmt.Errorf("failed to flush output: %w", err)
+	}
+
+	if report {
+		_, _ = fmt.Fprintf(p.errWriter, "\npaused after item %d, send SIGUSR2 to resume\n", id)
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("scan canceled while paused: %w", ctx.Err())
+	case <-resumed:
+	}
+
+	if report {
+		_, _ = fmt.Fprintf(p.errWriter, "resumed after item %d\n", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/spf13/cobra"
+)
+
+// defaultAverageItemBytes estimates the size of an item when the cache has none to average.
+const defaultAverageItemBytes = 400
+
+// scanPlan is what scan --dry-run reports instead of scanning: the range it would retrieve and how large and how long
+// that would be. The rate assumes each of --max-connections connections completes a request every round trip, timed on
+// the request for the max item, so it is a lower bound on the time of a scan served from the network.
+type scanPlan struct {
+	ETA              string  `json:"eta"`
+	FirstID          int     `json:"firstId,omitempty"`
+	LastID           int     `json:"lastId,omitempty"`
+	Items            int     `json:"items"`
+	CachedItems      int64   `json:"cachedItems"`
+	AverageItemBytes float64 `json:"averageItemBytes"`
+	Bytes            int64   `json:"bytes"`
+	MaxConnections   int     `json:"maxConnections"`
+	RoundTripSeconds float64 `json:"roundTripSeconds"`
+	ItemsPerSecond   float64 `json:"itemsPerSecond"`
+	ETASeconds       float64 `json:"etaSeconds"`
+}
+
+// newScanPlan plans a scan from from toward to, which is excluded, with the average item size from the cache at
+// cachePath, if any.
+func newScanPlan(
+	ctx context.Context,
+	cachePath string,
+	from int,
+	to int,
+	ascending bool,
+	maxConnections int,
+	roundTrip time.Duration,
+) (*scanPlan, error) {
+	plan := &scanPlan{"", 0, 0, max(from-to, to-from), 0, defaultAverageItemBytes, 0, maxConnections, 0, 0, 0}
+
+	if plan.Items > 0 {
+		plan.FirstID = from
+		plan.LastID = to + 1
+
+		if ascending {
+			plan.LastID = to - 1
+		}
+	}
+
+	if cachePath != "" {
+		count, average, err := cachedItemSize(ctx, cachePath)
+		if err != nil {
+			return nil, err
+		}
+
+		plan.CachedItems = count
+
+		if count > 0 {
+			plan.AverageItemBytes = average
+		}
+	}
+
+	plan.Bytes = int64(float64(plan.Items) * plan.AverageItemBytes)
+	plan.RoundTripSeconds = roundTrip.Seconds()
+
+	if roundTrip > 0 {
+		plan.ItemsPerSecond = float64(maxConnections) / roundTrip.Seconds()
+		plan.ETASeconds = float64(plan.Items) / plan.ItemsPerSecond
+	}
+
+	plan.ETA = time.Duration(plan.ETASeconds * float64(time.Second)).Round(time.Second).String()
+
+	return plan, nil
+}
+
+func cachedItemSize(ctx context.Context, cachePath string) (_ int64, _ float64, err error) {
+	cache, err := core.NewItemFileCache(ctx, core.NewClock(), cachePath, "")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open cache: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	count, average, err := cache.ValueSize(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get cached item size: %w", err)
+	}
+
+	return count, average, nil
+}
+
+// writeScanPlan writes the plan of a scan to stdout, since the output file is left for the scan itself.
+func writeScanPlan(cmd *cobra.Command, from int, to int, ascending bool, roundTrip time.Duration) error {
+	ctx := cmd.Context()
+
+	maxConnections, err := cmd.Flags().GetInt("max-connections")
+	if err != nil {
+		return fmt.Errorf("failed to get max-connections flag: %w", err)
+	}
+
+	plan, err := newScanPlan(ctx, getGlobalCachePath(ctx), from, to, ascending, maxConnections, roundTrip)
+	if err != nil {
+		return err
+	}
+
+	err = json.NewEncoder(cmd.OutOrStdout()).Encode(plan)
+	if err != nil {
+		return fmt.Errorf("failed to write to output: %w", err)
+	}
+
+	return nil
+}
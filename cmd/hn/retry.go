@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// defaultRetryNullsDelay is the delay of --retry-nulls given without a value, long enough for most new items to
+// propagate.
+const defaultRetryNullsDelay = time.Minute
+
+// nullRetry collects the IDs that returned a null body during a scan or tail batch for --retry-nulls, so they can be
+// retrieved again after a delay, since items near maxitem often return null until the API propagates them. It is
+// safe for concurrent use, and all methods of a nil nullRetry do nothing.
+type nullRetry struct {
+	clock     core.Clock
+	ids       []int
+	delay     time.Duration
+	mu        sync.Mutex
+	ascending bool
+}
+
+func newNullRetry(clock core.Clock, delay time.Duration, ascending bool) *nullRetry {
+	return &nullRetry{clock, nil, delay, sync.Mutex{}, ascending}
+}
+
+// add records id for retrying if body is null.
+func (r *nullRetry) add(id int, body []byte) {
+	if r == nil || !isNullBody(body) {
+		return
+	}
+
+	r.mu.Lock()
+	r.ids = append(r.ids, id)
+	r.mu.Unlock()
+}
+
+// run waits for the delay and then retrieves the recorded items again in scan order, writing those that are no longer
+// null to writer, which is after the rest of the scan rather than in place of their null lines. It returns how many
+// were filled in of how many were retried, and forgets them either way.
+func (r *nullRetry) run(ctx context.Context, client *hn.Client, writer io.Writer) (int, int, error) {
+	if r == nil {
+		return 0, 0, nil
+	}
+
+	r.mu.Lock()
+	ids := r.ids
+	r.ids = nil
+	r.mu.Unlock()
+
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	// shards record their nulls concurrently
+	slices.Sort(ids)
+
+	if !r.ascending {
+		slices.Reverse(ids)
+	}
+
+	err := core.Sleep(ctx, r.clock, r.delay)
+	if err != nil {
+		return 0, len(ids), err
+	}
+
+	filled := 0
+
+	err = client.Advanced().NewRawItemStream(ctx).SearchOrdered(ids, func(_ int, item io.ReadCloser) (bool, []int, error) {
+		defer func() { _ = item.Close() }()
+
+		body, err := io.ReadAll(item)
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to read item: %w", err)
+		}
+
+		if isNullBody(body) {
+			return true, nil, nil
+		}
+
+		_, err = writer.Write(append(body, '\n'))
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to write item: %w", err)
+		}
+
+		filled++
+
+		return true, nil, nil
+	})
+	if err != nil {
+		return filled, len(ids), fmt.Errorf("failed to retry null items: %w", err)
+	}
+
+	return filled, len(ids), nil
+}
+
+// runRetryNulls runs retry, writing the items filled in to writer and how many to errWriter, and counts them in the
+// summary.
+func runRetryNulls(
+	ctx context.Context,
+	errWriter io.Writer,
+	client *hn.Client,
+	writer io.Writer,
+	retry *nullRetry,
+	summary *scanSummary,
+) error {
+	if summary != nil {
+		writer = countingWriter{writer, &summary.Bytes}
+	}
+
+	filled, retried, err := retry.run(ctx, client, writer)
+
+	summary.fill(filled)
+
+	if retried > 0 {
+		_, _ = fmt.Fprintf(errWriter, "filled %d of %d null items\n", filled, retried)
+	}
+
+	return err
+}
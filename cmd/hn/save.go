@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+)
+
+func saveCmd(clock core.Clock) *cobra.Command {
+	var (
+		tags   []string
+		note   string
+		push   string
+		remove bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "save [id...]",
+		Short: "Bookmark items with optional tags and a note",
+		Long: "Bookmarks items in the cache file. Saving an item again adds the new tags and replaces the note\n" +
+			"if one is given. List bookmarks with \"hn saved\".\n" +
+			"With --push, also sends the links to a read-later service with credentials from the environment:\n" +
+			"  pocket:     POCKET_CONSUMER_KEY and POCKET_ACCESS_TOKEN\n" +
+			"  instapaper: INSTAPAPER_USERNAME and INSTAPAPER_PASSWORD\n" +
+			"  omnivore:   OMNIVORE_API_KEY",
+		Example: "  hn save 43740065 --tag reading --note \"for the weekend\"\n" +
+			"  hn save 43740065 --push pocket\n" +
+			"  hn save 43740065 --remove",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			ids, err := parseItemIDs(args)
+			if err != nil {
+				return err
+			}
+
+			if remove && (len(tags) > 0 || note != "" || push != "") {
+				return fmt.Errorf("%w: cannot provide --tag, --note, or --push with --remove", errInvalidArgs)
+			}
+
+			var readLater unl.ReadLater
+
+			if push != "" {
+				readLater, err = unl.NewReadLater(push, nil, os.Getenv)
+				if err != nil {
+					return fmt.Errorf("%w: %w", errInvalidArgs, err)
+				}
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			return withStore(ctx, func(store *unl.Store) error {
+				for _, id := range ids {
+					var b unl.Bookmark
+					b.Tags = tags
+					b.Note = note
+					b.ID = id
+					b.SavedAt = clock.Now().Unix()
+
+					if remove {
+						err = store.RemoveBookmark(ctx, id)
+					} else {
+						err = store.SaveBookmark(ctx, b)
+					}
+
+					if err != nil {
+						return fmt.Errorf("failed to save bookmark: %w", err)
+					}
+				}
+
+				if readLater == nil {
+					return nil
+				}
+
+				return pushReadLater(ctx, readLater, ids)
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "tags for the bookmark")
+	cmd.Flags().StringVar(&note, "note", "", "note for the bookmark")
+	cmd.Flags().StringVar(&push, "push", "", "also send the links to a read-later service: "+
+		strings.Join(unl.ReadLaterServices, ", "))
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove the bookmarks instead")
+
+	return cmd
+}
+
+// pushReadLater sends the link of each item to readLater, using its discussion for items without a link.
+func pushReadLater(ctx context.Context, readLater unl.ReadLater, ids []int) error {
+	client, _, _ := getGlobalItems(ctx)
+
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+
+	for _, id := range ids {
+		item := items[id]
+
+		link := item.URL
+		if link == "" {
+			link = hn.ItemURL(id)
+		}
+
+		err = readLater.Add(ctx, link, item.Title)
+		if err != nil {
+			return fmt.Errorf("failed to push %d: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+func savedCmd(getter core.Getter[string, io.ReadCloser], clock core.Clock) *cobra.Command {
+	var (
+		tag        string
+		export     bool
+		importPath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "saved",
+		Short: "Retrieve bookmarked items",
+		Long: "Retrieves bookmarked items, most recently saved first. Items are always fetched from the API so\n" +
+			"scores and comment counts are current, and the cache is updated with them. With --export, writes\n" +
+			"the bookmarks themselves instead, in the format read by --import:\n" +
+			"  {\"tags\":[\"reading\"],\"note\":\"...\",\"id\":N,\"saved\":T}",
+		Example: "  hn saved --tag reading\n" +
+			"  hn saved --export -o bookmarks.json\n" +
+			"  hn saved --import bookmarks.json",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			_, writer, _ := getGlobalItems(ctx)
+
+			if export && importPath != "" {
+				return fmt.Errorf("%w: cannot provide both --export and --import", errInvalidArgs)
+			}
+
+			return withStore(ctx, func(store *unl.Store) error {
+				if importPath != "" {
+					return importBookmarks(ctx, store, importPath)
+				}
+
+				bookmarks, err := store.Bookmarks(ctx, tag)
+				if err != nil {
+					return fmt.Errorf("failed to list bookmarks: %w", err)
+				}
+
+				if export {
+					return exportBookmarks(writer, bookmarks)
+				}
+
+				return writeSaved(ctx, getter, clock, getGlobalCachePath(ctx), writer, bookmarks)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&tag, "tag", "", "only bookmarks with this tag")
+	cmd.Flags().BoolVar(&export, "export", false, "write the bookmarks instead of the items")
+	cmd.Flags().StringVar(&importPath, "import", "", "save the bookmarks from a file written by --export")
+
+	return cmd
+}
+
+// withStore opens the unl store in the cache file for do.
+func withStore(ctx context.Context, do func(store *unl.Store) error) (err error) {
+	cachePath := getGlobalCachePath(ctx)
+	if cachePath == "" {
+		return fmt.Errorf("%w: bookmarks cannot be used with --no-cache", errInvalidArgs)
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	return do(store)
+}
+
+func exportBookmarks(writer *bufio.Writer, bookmarks []unl.Bookmark) error {
+	encoder := json.NewEncoder(writer)
+
+	for _, b := range bookmarks {
+		err := encoder.Encode(b)
+		if err != nil {
+			return fmt.Errorf("failed to write bookmark: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func importBookmarks(ctx context.Context, store *unl.Store, path string) (err error) {
+	f, err := os.Open(path) //nolint:gosec // G304 intended
+	if err != nil {
+		return fmt.Errorf("failed to open bookmarks: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, f.Close()) }()
+
+	decoder := json.NewDecoder(f)
+	for decoder.More() {
+		var b unl.Bookmark
+
+		err = decoder.Decode(&b)
+		if err != nil {
+			return fmt.Errorf("failed to read bookmark: %w", err)
+		}
+
+		err = store.SaveBookmark(ctx, b)
+		if err != nil {
+			return fmt.Errorf("failed to save bookmark %d: %w", b.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// writeSaved writes the live versions of the bookmarked items and puts them in the cache.
+func writeSaved(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	writer *bufio.Writer,
+	bookmarks []unl.Bookmark,
+) (err error) {
+	if len(bookmarks) == 0 {
+		return nil
+	}
+
+	if clock == nil {
+		clock = core.NewClock()
+	}
+
+	live, err := hn.NewClient(
+		ctx,
+		hn.WithFileCachePath(""),
+		hn.WithCacheFor(0),
+		hn.WithSource(getGlobalSource(ctx)),
+		hn.WithGetter(getter),
+		hn.WithClock(clock))
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, live.Close()) }()
+
+	cache, err := core.NewItemFileCache(ctx, clock, cachePath, "")
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, cache.Close()) }()
+
+	ids := make([]int, len(bookmarks))
+	for i, b := range bookmarks {
+		ids[i] = b.ID
+	}
+
+	values := make([][]byte, 0, len(ids))
+
+	err = live.Advanced().NewRawItemStream(ctx).SearchOrdered(ids, func(id int, r io.ReadCloser) (bool, []int, error) {
+		defer func() { _ = r.Close() }()
+
+		var buf bytes.Buffer
+
+		_, err := buf.ReadFrom(r)
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to read item %d: %w", id, err)
+		}
+
+		values = append(values, buf.Bytes())
+
+		_, err = writer.Write(buf.Bytes())
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to write item: %w", err)
+		}
+
+		err = writer.WriteByte('\n')
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to write newline: %w", err)
+		}
+
+		return true, nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve saved items: %w", err)
+	}
+
+	err = cache.Put(ctx, values)
+	if err != nil {
+		return fmt.Errorf("failed to update cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+// shardRange is a contiguous part of a sharded scan, written in order to its own part file. Offsets count items from
+// the start of the scan. A shard claims the items of its range one at a time from next, and a shard with nothing left
+// steals the back half of the range with the most left by moving end.
+type shardRange struct {
+	path    string
+	start   int
+	next    int
+	end     int
+	firstID int
+	lastID  int
+	written int
+	skipped int
+	done    bool
+}
+
+// shardedScan scans the items from from toward to, which is excluded, with several item streams at once so that an
+// item slow to retrieve holds back only its own shard rather than the single ordered window of runScan.
+type shardedScan struct {
+	client    *hn.Client
+	bar       *progressbar.ProgressBar
+	retry     *nullRetry
+	pause     *scanPause
+	dir       string
+	ranges    []*shardRange
+	from      int
+	inFlight  int
+	window    int
+	mu        sync.Mutex
+	ascending bool
+	dedupe    bool
+}
+
+// runShardedScan is runScan with shards item streams scanning separate ranges into part files in a temporary directory
+// next to the output file, which are then merged into writer in order. Shards that finish early steal from the others,
+// so the shards stay busy until the end. If the scan fails, the parts that are complete from the start of the range,
+// and the beginning of the first incomplete part, are still merged so a scan with --continue-at - resumes after them.
+func runShardedScan(ctx context.Context, o scanOptions, from int, to int) (err error) {
+	dir := ""
+	if o.outputFile != nil {
+		dir = filepath.Dir(o.outputFile.Name())
+	}
+
+	dir, err = os.MkdirTemp(dir, ".hn-scan-*")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for shards: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, os.RemoveAll(dir)) }()
+
+	endPhase := getGlobalDiagnostics(ctx).Phase("items")
+
+	total := max(from-to, to-from)
+	bar, endBar := startScanBar(total)
+
+	// the shards split the in-flight budget of one stream, since their items all queue on the same worker pool
+	maxInFlight := o.client.Advanced().MaxInFlight()
+	shards := min(o.shards, total, maxInFlight)
+	inFlight := maxInFlight / max(shards, 1)
+
+	s := &shardedScan{
+		o.client,
+		bar,
+		o.retry,
+		o.pause,
+		dir,
+		nil,
+		from,
+		inFlight,
+		inFlight * scanWindowMultipliers,
+		sync.Mutex{},
+		o.ascending,
+		o.dedupe,
+	}
+
+	for i := range shards {
+		start := total * i / shards
+		s.ranges = append(s.ranges, &shardRange{"", start, start, total * (i + 1) / shards, 0, 0, 0, 0, false})
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+
+	for _, r := range s.ranges {
+		g.Go(func() error { return s.work(gctx, r) })
+	}
+
+	scanErr := g.Wait()
+
+	endBar(scanErr == nil)
+	endPhase()
+
+	return errors.Join(scanErr, s.merge(o.writer, o.summary))
+}
+
+// work scans r and then ranges stolen from the other shards until there are none left to steal.
+func (s *shardedScan) work(ctx context.Context, r *shardRange) error {
+	for r != nil {
+		err := s.scanRange(ctx, r)
+		if err != nil {
+			return err
+		}
+
+		r = s.steal()
+	}
+
+	return nil
+}
+
+func (s *shardedScan) scanRange(ctx context.Context, r *shardRange) (err error) {
+	r.path = filepath.Join(s.dir, strconv.Itoa(r.start))
+
+	const shardFilePermissions = 0o600
+
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, shardFilePermissions) //nolint:gosec // G304 intended
+	if err != nil {
+		return fmt.Errorf("failed to create shard file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+
+	defer func() { err = errors.Join(err, w.Flush(), f.Close()) }()
+
+	var deduper *itemDeduper
+	if s.dedupe {
+		deduper = newItemDeduper()
+	}
+
+	tee := newBodyTee(s.retry != nil)
+	ids := make([]int, 0, s.window)
+
+	for range s.window {
+		id, ok := s.claim(r)
+		if !ok {
+			break
+		}
+
+		ids = append(ids, id)
+	}
+
+	stream := s.client.Advanced().NewRawItemStreamLimited(ctx, s.inFlight)
+	next := make([]int, 1)
+
+	err = stream.SearchOrdered(ids, func(id int, item io.ReadCloser) (bool, []int, error) {
+		defer func() { _ = item.Close() }()
+
+		written, err := deduper.write(w, id, tee.read(item))
+		if err != nil {
+			return false, nil, err
+		}
+
+		s.retry.add(id, tee.body())
+
+		r.add(id, written)
+		_ = s.bar.Add(1)
+
+		err = s.pause.wait(ctx, id, w.Flush)
+		if err != nil {
+			return false, nil, err
+		}
+
+		id, ok := s.claim(r)
+		if !ok {
+			return true, nil, nil
+		}
+
+		next[0] = id
+
+		return true, next, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan shard: %w", err)
+	}
+
+	s.mu.Lock()
+	r.done = true
+	s.mu.Unlock()
+
+	return nil
+}
+
+// claim returns the ID of the next item of r, if any are left.
+func (s *shardedScan) claim(r *shardRange) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.next >= r.end {
+		return 0, false
+	}
+
+	offset := r.next
+	r.next++
+
+	if s.ascending {
+		return s.from + offset, true
+	}
+
+	return s.from - offset, true
+}
+
+// steal splits off the back half of the range with the most items left as a new range following it, or returns nil if
+// no range has enough left to be worth splitting.
+func (s *shardedScan) steal() *shardRange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	victim := -1
+	left := s.window
+
+	for i, r := range s.ranges {
+		if r.end-r.next > left {
+			victim = i
+			left = r.end - r.next
+		}
+	}
+
+	if victim < 0 {
+		return nil
+	}
+
+	v := s.ranges[victim]
+	mid := v.next + left/2
+	r := &shardRange{"", mid, mid, v.end, 0, 0, 0, 0, false}
+	v.end = mid
+
+	s.ranges = append(s.ranges[:victim+1], append([]*shardRange{r}, s.ranges[victim+1:]...)...)
+
+	return r
+}
+
+func (r *shardRange) add(id int, written bool) {
+	if r.written+r.skipped == 0 {
+		r.firstID = id
+	}
+
+	r.lastID = id
+
+	if written {
+		r.written++
+	} else {
+		r.skipped++
+	}
+}
+
+// merge copies the part files into writer in order, stopping after the first that is incomplete.
+func (s *shardedScan) merge(writer io.Writer, summary *scanSummary) error {
+	if summary != nil {
+		writer = countingWriter{writer, &summary.Bytes}
+	}
+
+	for _, r := range s.ranges {
+		if r.path == "" {
+			break
+		}
+
+		f, err := os.Open(r.path)
+		if err != nil {
+			return fmt.Errorf("failed to open shard file: %w", err)
+		}
+
+		_, err = io.Copy(writer, f)
+
+		err = errors.Join(err, f.Close())
+		if err != nil {
+			return fmt.Errorf("failed to merge shard file: %w", err)
+		}
+
+		summary.addRange(r.firstID, r.lastID, r.written, r.skipped)
+
+		if !r.done {
+			break
+		}
+	}
+
+	return nil
+}
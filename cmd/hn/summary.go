@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// scanSummary is the summary of a scan written by --summary-json, so a pipeline can check how far a scan got and how
+// fast without parsing the progress bar. It is written when the scan fails or is interrupted as well.
+type scanSummary struct {
+	start          time.Time
+	Error          string  `json:"error,omitempty"`
+	Items          int     `json:"items"`
+	Skipped        int     `json:"skipped"`
+	Filled         int     `json:"filled"`
+	FirstID        int     `json:"firstId,omitempty"`
+	LastID         int     `json:"lastId,omitempty"`
+	Bytes          int64   `json:"bytes"`
+	Errors         int     `json:"errors"`
+	Requests       int64   `json:"requests"`
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	ItemsPerSecond float64 `json:"itemsPerSecond"`
+	Completed      bool    `json:"completed"`
+}
+
+func newScanSummary() *scanSummary {
+	return &scanSummary{time.Now(), "", 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, false}
+}
+
+// add records an item of the scan, which was written unless it was skipped by --dedupe. A nil scanSummary records
+// nothing.
+func (s *scanSummary) add(id int, written bool) {
+	if s == nil {
+		return
+	}
+
+	if s.FirstID == 0 {
+		s.FirstID = id
+	}
+
+	s.LastID = id
+
+	if written {
+		s.Items++
+	} else {
+		s.Skipped++
+	}
+}
+
+// addRange records the items of a range of a sharded scan, which are merged in order. A nil scanSummary records
+// nothing.
+func (s *scanSummary) addRange(firstID int, lastID int, written int, skipped int) {
+	if s == nil || written+skipped == 0 {
+		return
+	}
+
+	if s.FirstID == 0 {
+		s.FirstID = firstID
+	}
+
+	s.LastID = lastID
+	s.Items += written
+	s.Skipped += skipped
+}
+
+// fill records items that returned null in the scan and were written by --retry-nulls. A nil scanSummary records
+// nothing.
+func (s *scanSummary) fill(n int) {
+	if s == nil {
+		return
+	}
+
+	s.Filled += n
+}
+
+// write finishes the summary with the result of the scan and writes it to path, or to errWriter if path is "-".
+func (s *scanSummary) write(path string, errWriter io.Writer, stats hn.Stats, scanErr error) error {
+	elapsed := time.Since(s.start)
+
+	s.Requests = stats.Requests
+	s.ElapsedSeconds = elapsed.Seconds()
+	s.Completed = scanErr == nil
+
+	if scanErr != nil {
+		s.Errors = 1
+		s.Error = scanErr.Error()
+	}
+
+	if elapsed > 0 {
+		s.ItemsPerSecond = float64(s.Items+s.Skipped) / elapsed.Seconds()
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary: %w", err)
+	}
+
+	b = append(b, '\n')
+
+	if path == "-" {
+		_, err = errWriter.Write(b)
+		if err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+
+		return nil
+	}
+
+	const summaryFilePermissions = 0o644
+
+	err = os.WriteFile(path, b, summaryFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	inner io.Writer
+	n     *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.inner.Write(p)
+	*c.n += int64(n)
+
+	return n, err //nolint:wrapcheck // plain wrapper like those with an "inner" field
+}
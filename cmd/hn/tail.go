@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/spf13/cobra"
+)
+
+func tailCmd(clock core.Clock) *cobra.Command {
+	const (
+		defaultInterval = 30 * time.Second
+		defaultWindow   = time.Hour
+	)
+
+	var (
+		back       int
+		polls      int
+		events     bool
+		interval   time.Duration
+		window     time.Duration
+		retryNulls time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Follow new items as they are created",
+		Long: "Polls maxitem and writes new items in ascending order until interrupted.\n" +
+			"With --events, writes change events instead of items, also re-checking items younger than --window:\n" +
+			"  {\"id\":N,\"changed\":[\"descendants\",\"score\"],\"old\":{...},\"new\":{...}}\n" +
+			"The first event for an item is computed against its cached version, or has a null old if not cached.\n" +
+			"--retry-nulls retrieves the new items that returned null again after a delay at the end of each poll,\n" +
+			"and writes those filled in after the rest of the poll.",
+		Example: "  hn tail --back 100\n" +
+			"  hn tail --events --window 2h\n" +
+			"  hn tail --retry-nulls=30s",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			client, writer, _ := getGlobalItems(ctx)
+
+			if back < 0 || polls < 0 || interval <= 0 {
+				return fmt.Errorf("%w: --back and --polls must not be negative and --interval must be positive",
+					errInvalidArgs)
+			}
+
+			if retryNulls < 0 || retryNulls > 0 && events {
+				return fmt.Errorf("%w: --retry-nulls must not be negative or used with --events", errInvalidArgs)
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			t := tail{client, writer, nil, make(map[int]*hn.Item), clock, nil, cmd.ErrOrStderr(), window, events}
+
+			if retryNulls > 0 {
+				t.retry = newNullRetry(clock, retryNulls, true)
+			}
+
+			if events && getGlobalCachePath(ctx) != "" {
+				cache, err := core.NewItemFileCache(ctx, clock, getGlobalCachePath(ctx), "")
+				if err != nil {
+					return fmt.Errorf("failed to open cache: %w", err)
+				}
+
+				t.cache = cache
+
+				defer func() { _ = cache.Close() }()
+			}
+
+			return t.run(ctx, back, polls, interval)
+		},
+	}
+
+	cmd.Flags().IntVar(&back, "back", 0, "start this many items before the current maxitem")
+	cmd.Flags().IntVar(&polls, "polls", 0, "stop after this many polls (0 to run until interrupted)")
+	cmd.Flags().BoolVar(&events, "events", false, "write change events instead of items")
+	cmd.Flags().DurationVar(&interval, "interval", defaultInterval, "time between polls")
+	cmd.Flags().DurationVar(&window, "window", defaultWindow, "with --events, keep checking items this young for changes")
+	cmd.Flags().DurationVar(&retryNulls, "retry-nulls", 0,
+		"retrieve new items that returned null again after this delay at the end of each poll (0 to disable)")
+	cmd.Flags().Lookup("retry-nulls").NoOptDefVal = defaultRetryNullsDelay.String()
+
+	return cmd
+}
+
+type tail struct {
+	client  *hn.Client
+	writer  *bufio.Writer
+	cache   *core.ItemFileCache
+	tracked map[int]*hn.Item
+	clock   core.Clock
+	retry   *nullRetry
+	errOut  io.Writer
+	window  time.Duration
+	events  bool
+}
+
+// itemEvent describes the fields that changed between two versions of an item. Old is nil for an item seen for the
+// first time without a cached version.
+type itemEvent struct {
+	Old     *hn.Item `json:"old"`
+	New     *hn.Item `json:"new"`
+	Changed []string `json:"changed"`
+	ID      int      `json:"id"`
+}
+
+func (t *tail) run(ctx context.Context, back int, polls int, interval time.Duration) error {
+	next := 0
+
+	for poll := 0; polls == 0 || poll < polls; poll++ {
+		if poll > 0 {
+			err := core.Sleep(ctx, t.clock, interval)
+			if err != nil {
+				return fmt.Errorf("tail canceled: %w", err)
+			}
+		}
+
+		maxItem, err := t.client.GetMaxItem(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get max item: %w", err)
+		}
+
+		if next == 0 {
+			next = max(1, maxItem-back+1)
+		}
+
+		var ids []int
+		for ; next <= maxItem; next++ {
+			ids = append(ids, next)
+		}
+
+		if t.events {
+			err = t.writeEvents(ctx, ids)
+		} else {
+			err = runList(ctx, t.client, t.writer, 0, func(context.Context) ([]int, error) { return ids, nil }, t.retry)
+			if err == nil {
+				err = runRetryNulls(ctx, t.errOut, t.client, t.writer, t.retry, nil)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = t.writer.Flush()
+		if err != nil {
+			return fmt.Errorf("failed to flush output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeEvents writes change events for the new ids and the tracked items, then stops tracking items older than the
+// window.
+func (t *tail) writeEvents(ctx context.Context, ids []int) error {
+	previous := make(map[int]*hn.Item, len(t.tracked)+len(ids))
+
+	for id, item := range t.tracked {
+		previous[id] = item
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		if _, ok := previous[id]; ok {
+			continue
+		}
+
+		old, err := t.cachedItem(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		previous[id] = old
+	}
+
+	items, err := t.client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+
+	encoder := json.NewEncoder(t.writer)
+	oldest := t.clock.Now().Add(-t.window).Unix()
+
+	for _, id := range ids {
+		item := items[id]
+		if item == nil || item.Type == hn.NullBody {
+			continue
+		}
+
+		old := previous[id]
+
+		changed := changedItemFields(old, item)
+		if len(changed) > 0 {
+			err = encoder.Encode(itemEvent{old, item, changed, id})
+			if err != nil {
+				return fmt.Errorf("failed to write event: %w", err)
+			}
+		}
+
+		if item.Time >= oldest {
+			t.tracked[id] = item
+		} else {
+			delete(t.tracked, id)
+		}
+	}
+
+	return nil
+}
+
+func (t *tail) cachedItem(ctx context.Context, id int) (*hn.Item, error) {
+	if t.cache == nil {
+		return nil, nil //nolint:nilnil // not cached is not an error
+	}
+
+	entry, err := t.cache.Entry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached item: %w", err)
+	}
+
+	if entry == nil {
+		return nil, nil //nolint:nilnil // not cached is not an error
+	}
+
+	var item hn.Item
+
+	err = json.Unmarshal(entry.Value, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached item: %w", err)
+	}
+
+	return &item, nil
+}
+
+// changedItemFields returns the JSON names of the fields that differ between the items. A nil old item is compared
+// as if all of its fields were empty.
+func changedItemFields(old *hn.Item, item *hn.Item) []string {
+	if old == nil {
+		var empty hn.Item
+
+		old = &empty
+	}
+
+	return old.ChangedFields(item)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/spf13/cobra"
+)
+
+var ErrArchiveInvalid = errors.New("archive failed validation")
+
+func validateCmd() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check an archive file produced by scan for anomalies",
+		Long: "Re-parses each line of the archive and checks ID monotonicity, parent references within the\n" +
+			"archived range, and required fields per item type. Anomalies are written as JSON lines.",
+		Example: "  hn validate -f out.json",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			_, writer, _ := getGlobalItems(ctx)
+
+			f, err := os.Open(file) //nolint:gosec // G304 intended
+			if err != nil {
+				return fmt.Errorf("error opening archive file: %w", err)
+			}
+
+			defer func() { _ = f.Close() }()
+
+			summary, err := runValidate(f, json.NewEncoder(writer))
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(
+				os.Stderr,
+				"validated %d items (%d null bodies): %d anomalies\n",
+				summary.items,
+				summary.nullBodies,
+				summary.anomalies)
+			if err != nil {
+				return fmt.Errorf("failed to write summary: %w", err)
+			}
+
+			if summary.anomalies > 0 {
+				return fmt.Errorf("%w: %d anomalies", ErrArchiveInvalid, summary.anomalies)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "archive file to validate")
+
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+const (
+	anomalyMalformed     = "malformed"
+	anomalyIDOrder       = "id-order"
+	anomalyMissingField  = "missing-field"
+	anomalyUnknownType   = "unknown-type"
+	anomalyBadReference  = "bad-reference"
+	anomalyMissingParent = "missing-parent"
+)
+
+type validateAnomaly struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+	Line   int    `json:"line"`
+	ID     int    `json:"id,omitempty"`
+}
+
+type validateSummary struct {
+	items      int
+	nullBodies int
+	anomalies  int
+}
+
+type validateReference struct {
+	line   int
+	id     int
+	parent int
+}
+
+type validator struct {
+	report     func(validateAnomaly) error
+	seen       map[int]struct{}
+	references []validateReference
+	summary    validateSummary
+	lastID     int
+	minID      int
+	maxID      int
+	direction  int
+}
+
+func runValidate(r io.Reader, enc *json.Encoder) (validateSummary, error) {
+	v := &validator{
+		report:     func(a validateAnomaly) error { return enc.Encode(a) },
+		seen:       make(map[int]struct{}),
+		references: nil,
+		summary:    validateSummary{0, 0, 0},
+		lastID:     0,
+		minID:      0,
+		maxID:      0,
+		direction:  0,
+	}
+
+	const maxLineLength = 16 * 1024 * 1024
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		err := v.validateLine(line, scanner.Bytes())
+		if err != nil {
+			return v.summary, fmt.Errorf("failed to write anomaly: %w", err)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return v.summary, fmt.Errorf("failed to scan archive file: %w", err)
+	}
+
+	err = v.validateReferences()
+	if err != nil {
+		return v.summary, fmt.Errorf("failed to write anomaly: %w", err)
+	}
+
+	return v.summary, nil
+}
+
+func (v *validator) add(line int, id int, kind string, detail string) error {
+	v.summary.anomalies++
+	return v.report(validateAnomaly{kind, detail, line, id})
+}
+
+func (v *validator) validateLine(line int, data []byte) error {
+	v.summary.items++
+
+	if bytes.Equal(data, []byte("null")) {
+		// null bodies carry no ID so they are only counted
+		v.summary.nullBodies++
+		return nil
+	}
+
+	var item hn.Item
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&item)
+	if err != nil {
+		return v.add(line, 0, anomalyMalformed, err.Error())
+	}
+
+	if item.ID <= 0 {
+		return v.add(line, 0, anomalyMissingField, "id")
+	}
+
+	err = v.validateOrder(line, item.ID)
+	if err != nil {
+		return err
+	}
+
+	err = v.validateFields(line, &item)
+	if err != nil {
+		return err
+	}
+
+	return v.validateItemReferences(line, &item)
+}
+
+func (v *validator) validateOrder(line int, id int) error {
+	_, duplicate := v.seen[id]
+	v.seen[id] = struct{}{}
+
+	lastID := v.lastID
+	v.lastID = id
+
+	if lastID == 0 {
+		v.minID, v.maxID = id, id
+		return nil
+	}
+
+	v.minID, v.maxID = min(v.minID, id), max(v.maxID, id)
+
+	if duplicate {
+		return v.add(line, id, anomalyIDOrder, "duplicate id")
+	}
+
+	direction := 1
+	if id < lastID {
+		direction = -1
+	}
+
+	if v.direction == 0 {
+		v.direction = direction
+		return nil
+	}
+
+	if direction != v.direction {
+		return v.add(line, id, anomalyIDOrder, "follows "+strconv.Itoa(lastID)+" out of order")
+	}
+
+	return nil
+}
+
+func (v *validator) validateFields(line int, item *hn.Item) error {
+	var required []string
+
+	if item.Type == hn.NullBody {
+		required = append(required, "type")
+	}
+
+	if item.Time <= 0 {
+		required = append(required, "time")
+	}
+
+	if !item.Deleted {
+		switch item.Type {
+		case hn.Story, hn.Job, hn.Poll:
+			required = appendMissing(required, "by", item.By != "")
+			// the API strips titles from some dead items
+			required = appendMissing(required, "title", item.Dead || item.Title != "")
+		case hn.Comment, hn.PollOption:
+			required = appendMissing(required, "by", item.By != "")
+		case hn.NullBody:
+		default:
+			err := v.add(line, item.ID, anomalyUnknownType, string(item.Type))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch item.Type {
+	case hn.Comment:
+		required = appendMissing(required, "parent", item.Parent != nil)
+	case hn.PollOption:
+		required = appendMissing(required, "poll", item.Poll != nil)
+	case hn.Poll:
+		required = appendMissing(required, "parts", item.Deleted || len(item.Parts) > 0)
+	case hn.NullBody, hn.Story, hn.Job:
+	}
+
+	for _, field := range required {
+		err := v.add(line, item.ID, anomalyMissingField, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appendMissing(missing []string, field string, present bool) []string {
+	if present {
+		return missing
+	}
+
+	return append(missing, field)
+}
+
+func (v *validator) validateItemReferences(line int, item *hn.Item) error {
+	for _, ref := range []*int{item.Parent, item.Poll} {
+		if ref == nil {
+			continue
+		}
+
+		if *ref >= item.ID {
+			err := v.add(line, item.ID, anomalyBadReference, "refers to newer item "+strconv.Itoa(*ref))
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		v.references = append(v.references, validateReference{line, item.ID, *ref})
+	}
+
+	return nil
+}
+
+// validateReferences reports references into the archived ID range that are not present in the archive.
+// References to items older than the archive can't be checked.
+func (v *validator) validateReferences() error {
+	for _, ref := range v.references {
+		if ref.parent < v.minID || ref.parent > v.maxID {
+			continue
+		}
+
+		_, ok := v.seen[ref.parent]
+		if ok {
+			continue
+		}
+
+		err := v.add(ref.line, ref.id, anomalyMissingParent, strconv.Itoa(ref.parent))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/spf13/cobra"
+)
+
+const (
+	warmDepthStories  = "stories"
+	warmDepthComments = "comments"
+)
+
+func warmCmd(clock core.Clock) *cobra.Command {
+	var (
+		lists  []string
+		depth  string
+		budget int
+		every  time.Duration
+		jitter time.Duration
+		runs   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "warm",
+		Short: "Pre-fetch lists and their comment trees into the cache",
+		Long: "Fetches the current lists and, with --depth comments, the full comment tree of every story so later\n" +
+			"sessions are served from the cache. --budget bounds the number of items fetched; lists are visited in\n" +
+			"order, so earlier lists are warmed first. Writes a summary to the output.\n" +
+			"With --every, keeps warming until interrupted, waiting --every plus up to --jitter between runs.\n" +
+			"Failed runs are reported to stderr and retried at the next interval.",
+		Example: "  hn warm --lists top,best --depth comments\n" +
+			"  hn warm --lists new --depth stories --budget 500\n" +
+			"  hn warm --every 5m --jitter 1m",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			client, writer, _ := getGlobalItems(ctx)
+
+			if getGlobalCachePath(ctx) == "" {
+				return fmt.Errorf("%w: warm cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			if depth != warmDepthStories && depth != warmDepthComments {
+				return fmt.Errorf("%w: --depth must be %s or %s", errInvalidArgs, warmDepthStories, warmDepthComments)
+			}
+
+			if budget < 0 || every < 0 || jitter < 0 || runs < 0 {
+				return fmt.Errorf("%w: --budget, --every, --jitter, and --runs must not be negative", errInvalidArgs)
+			}
+
+			if every == 0 && (jitter != 0 || runs != 0) {
+				return fmt.Errorf("%w: --jitter and --runs require --every", errInvalidArgs)
+			}
+
+			if every == 0 {
+				runs = 1
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			warm := func(ctx context.Context) (warmReport, error) {
+				return runWarm(ctx, client, lists, depth == warmDepthComments, budget)
+			}
+
+			return runWarmEvery(ctx, clock, writer, cmd.ErrOrStderr(), every, jitter, runs, warm)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&lists, "lists", []string{"top", "best"}, "lists to warm: top, best, new, ask, show, jobs")
+	cmd.Flags().StringVar(&depth, "depth", warmDepthComments, "what to fetch: stories or comments")
+	cmd.Flags().IntVar(&budget, "budget", 0, "maximum number of items to fetch (0 for no limit)")
+	cmd.Flags().DurationVar(&every, "every", 0, "warm repeatedly at this interval (0 to warm once)")
+	cmd.Flags().DurationVar(&jitter, "jitter", 0, "with --every, add a random delay up to this long between runs")
+	cmd.Flags().IntVar(&runs, "runs", 0, "with --every, stop after this many runs (0 to run until interrupted)")
+
+	return cmd
+}
+
+type warmReport struct {
+	Lists     map[string]int `json:"lists"`
+	Items     int            `json:"items"`
+	Truncated bool           `json:"truncated"`
+}
+
+func getListIDs(ctx context.Context, client hn.API, list string) ([]int, error) {
+	var getIDs func(context.Context) ([]int, error)
+
+	switch list {
+	case "top":
+		getIDs = client.GetTop
+	case "best":
+		getIDs = client.GetBest
+	case "new":
+		getIDs = client.GetNew
+	case "ask":
+		getIDs = client.GetAsk
+	case "show":
+		getIDs = client.GetShow
+	case "jobs":
+		getIDs = client.GetJobs
+	default:
+		return nil, fmt.Errorf("%w: unrecognized list %q", errInvalidArgs, list)
+	}
+
+	ids, err := getIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s list: %w", list, err)
+	}
+
+	return ids, nil
+}
+
+// runWarm fetches the stories on the lists and, if comments is set, their descendants, visiting at most budget items
+// when budget is positive. Stories on more than one list are fetched once.
+func runWarm(ctx context.Context, client hn.API, lists []string, comments bool, budget int) (warmReport, error) {
+	report := warmReport{make(map[string]int, len(lists)), 0, false}
+
+	var ids []int
+
+	seen := make(map[int]struct{})
+
+	for _, list := range lists {
+		listIDs, err := getListIDs(ctx, client, list)
+		if err != nil {
+			return report, err
+		}
+
+		report.Lists[list] = len(listIDs)
+
+		for _, id := range listIDs {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if budget > 0 && len(ids) > budget {
+		ids = ids[:budget]
+		report.Truncated = true
+	}
+
+	queued := len(ids)
+
+	err := client.SearchUnordered(ctx, ids, func(_ int, item *hn.Item) (bool, []int, error) {
+		report.Items++
+
+		if !comments || len(item.Kids) == 0 {
+			return true, nil, nil
+		}
+
+		kids := item.Kids
+		if budget > 0 && queued+len(kids) > budget {
+			kids = kids[:budget-queued]
+			report.Truncated = true
+		}
+
+		queued += len(kids)
+
+		return true, kids, nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to warm items: %w", err)
+	}
+
+	return report, nil
+}
+
+// runWarmEvery calls warm runs times, or until ctx is done if runs is 0, waiting every plus a random duration up to
+// jitter between calls. A failed run is reported to errWriter and does not stop later runs.
+func runWarmEvery(
+	ctx context.Context,
+	clock core.Clock,
+	writer *bufio.Writer,
+	errWriter io.Writer,
+	every time.Duration,
+	jitter time.Duration,
+	runs int,
+	warm func(context.Context) (warmReport, error),
+) error {
+	encoder := json.NewEncoder(writer)
+
+	for run := 0; runs == 0 || run < runs; run++ {
+		if run > 0 {
+			wait := every
+			if jitter > 0 {
+				wait += rand.N(jitter) //nolint:gosec // G404 jitter needs no cryptographic randomness
+			}
+
+			err := core.Sleep(ctx, clock, wait)
+			if err != nil {
+				return err
+			}
+		}
+
+		report, err := warm(ctx)
+		if err != nil {
+			if ctx.Err() != nil || runs == 1 {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(errWriter, "warm failed: %v\n", err)
+
+			continue
+		}
+
+		err = encoder.Encode(report)
+		if err != nil {
+			return fmt.Errorf("failed to write to output: %w", err)
+		}
+
+		err = writer.Flush()
+		if err != nil {
+			return fmt.Errorf("failed to flush output: %w", err)
+		}
+	}
+
+	return nil
+}
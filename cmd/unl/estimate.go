@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+)
+
+// validateStrategy checks the flags that --strategy descendants cannot honor, since it never retrieves the replies.
+func validateStrategy(strategy unl.ActivityStrategy, noCache bool, orphans bool, minActivity float64) error {
+	if _, ok := strategy.(unl.ListDelta); !ok {
+		return nil
+	}
+
+	if noCache {
+		return fmt.Errorf("%w: --strategy descendants cannot be used with --no-cache", errInvalidArgs)
+	}
+
+	if orphans || minActivity > 0 {
+		return fmt.Errorf("%w: --strategy descendants cannot be used with --orphans or --min-activity", errInvalidArgs)
+	}
+
+	return nil
+}
+
+// runEstimate writes the recent stories found active by a strategy that estimates their growth rather than
+// retrieving their replies, keeping its state in the cache file.
+func runEstimate(
+	ctx context.Context,
+	retrieveCtx context.Context,
+	client *hn.Client,
+	cachePath string,
+	strategy unl.ActivityStrategy,
+	req unl.ActivityRequest,
+	limit int,
+	noColor bool,
+	maxWidth int,
+) (err error) {
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	req.Store = store
+
+	items, activity, err := unl.FindActive(retrieveCtx, client, strategy, req, limit)
+	if err != nil {
+		return fmt.Errorf("failed to estimate active stories: %w", err)
+	}
+
+	r := render.NewTreeRenderer(render.Options{
+		Now:            req.Now,
+		ActiveAfter:    req.ActiveAfter,
+		NewAfter:       time.Time{},
+		Theme:          getTheme(noColor),
+		EffectiveTimes: req.EffectiveTimes,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          getStyle(ctx),
+		MaxWidth:       maxWidth,
+		MaxByWidth:     getMaxByWidth(ctx),
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	lines := make([]render.Line, 0, len(items))
+
+	for _, item := range items {
+		e := activity.Growth[item.ID]
+		text := fmt.Sprintf("(+%d comments, %+d points in %s) %s",
+			e.Comments, e.Points, unl.PrettyFormatDuration(req.Now.Sub(e.Since)), unl.PrettyFormatTitle(item, true))
+
+		lines = append(lines, render.Line{
+			Time: time.Unix(req.EffectiveTimes.Get(item), 0), By: item.By, Text: text, ID: item.ID,
+		})
+	}
+
+	var buf bytes.Buffer
+
+	err = r.RenderLines(&buf, lines...)
+	if err != nil {
+		return fmt.Errorf("failed to render estimates: %w", err)
+	}
+
+	return getPager(ctx).Write(ctx, &buf)
+}
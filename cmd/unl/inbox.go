@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"github.com/spf13/cobra"
+)
+
+func inboxCmd(
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	maxWidth int,
+	cachePath *string,
+	noCache *bool,
+	noColor *bool,
+) *cobra.Command {
+	const defaultSubmitted = 30
+
+	var (
+		submitted int
+		markRead  []int
+		markAll   bool
+		format    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inbox [username...]",
+		Short: "List unseen replies to one or more users",
+		Long: "Lists unread replies by others to the latest items submitted by each user, newest first across all\n" +
+			"users. Items are fetched through the cache. Replies stay unread until marked with --mark-read or\n" +
+			"--mark-all-read; read state is kept per user in the cache file. With --format json, writes one\n" +
+			"object per reply, with the effective current time of the run as now:\n" +
+			"  {\"now\":\"...\",\"user\":\"...\",\"url\":\"...\",\"context\":\"...\",\"item\":{...}}",
+		Example: "  unl inbox jasonthorsness\n" +
+			"  unl inbox me my-alt colleague --submitted 10\n" +
+			"  unl inbox me --mark-read 43728595,43729778\n" +
+			"  unl inbox me --format json --mark-all-read",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *noCache {
+				return fmt.Errorf("%w: inbox cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			if submitted <= 0 {
+				return fmt.Errorf("%w: --submitted must be positive", errInvalidArgs)
+			}
+
+			if format != inboxFormatPretty && format != inboxFormatJSON {
+				return fmt.Errorf("%w: --format must be %s or %s", errInvalidArgs, inboxFormatPretty, inboxFormatJSON)
+			}
+
+			var o inboxOptions
+			o.users = args
+			o.submitted = submitted
+			o.markRead = markRead
+			o.markAll = markAll
+			o.json = format == inboxFormatJSON
+			o.noColor = *noColor
+			o.maxWidth = maxWidth
+			o.style = getStyle(cmd.Context())
+
+			return runInbox(cmd.Context(), getter, clock, *cachePath, o)
+		},
+	}
+
+	cmd.Flags().IntVar(&submitted, "submitted", defaultSubmitted, "number of latest submitted items to check per user")
+	cmd.Flags().IntSliceVar(&markRead, "mark-read", nil, "mark these replies read and list the rest")
+	cmd.Flags().BoolVar(&markAll, "mark-all-read", false, "mark every listed reply read")
+	cmd.Flags().StringVar(&format, "format", inboxFormatPretty, "output format: pretty or json")
+
+	return cmd
+}
+
+const (
+	inboxFormatPretty = "pretty"
+	inboxFormatJSON   = "json"
+)
+
+type inboxOptions struct {
+	style     render.Style
+	users     []string
+	markRead  []int
+	submitted int
+	maxWidth  int
+	markAll   bool
+	json      bool
+	noColor   bool
+}
+
+// inboxReply is written for each reply with --format json. URL is the permalink of the reply and Context links to it
+// within the thread, below the item it replies to. Now is the effective current time of the run, against which the age
+// of the reply is measured.
+type inboxReply struct {
+	Now     time.Time `json:"now"`
+	Item    *hn.Item  `json:"item"`
+	User    string    `json:"user"`
+	URL     string    `json:"url"`
+	Context string    `json:"context"`
+}
+
+func runInbox(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	o inboxOptions,
+) (err error) {
+	client, err := createClient(ctx, cachePath, getter, clock, false)
+	if err != nil {
+		return err
+	}
+	defer closeClient(ctx, client)
+
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	replies, err := unl.GetReplies(ctx, client, o.users, o.submitted)
+	if err != nil {
+		return fmt.Errorf("failed to get replies: %w", err)
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	unseen, err := store.UnseenReplies(ctx, replies)
+	if err != nil {
+		return fmt.Errorf("failed to check seen replies: %w", err)
+	}
+
+	unread, read := partitionInbox(unseen, o.markRead, o.markAll)
+
+	if o.json {
+		err = writeInboxJSON(unread, now)
+	} else {
+		err = writeInboxPretty(ctx, unread, now, o)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = store.MarkRepliesSeen(ctx, read)
+	if err != nil {
+		return fmt.Errorf("failed to mark replies read: %w", err)
+	}
+
+	return nil
+}
+
+// partitionInbox returns the replies to list and the replies to mark read. Replies marked read by ID are not listed,
+// while with markAll every reply is listed and then marked read.
+func partitionInbox(unseen []unl.Reply, markRead []int, markAll bool) ([]unl.Reply, []unl.Reply) {
+	var unread, read []unl.Reply
+
+	for _, reply := range unseen {
+		marked := slices.Contains(markRead, reply.Item.ID)
+
+		if markAll || marked {
+			read = append(read, reply)
+		}
+
+		if !marked {
+			unread = append(unread, reply)
+		}
+	}
+
+	return unread, read
+}
+
+func writeInboxJSON(replies []unl.Reply, now time.Time) error {
+	encoder := json.NewEncoder(os.Stdout)
+
+	for _, reply := range replies {
+		err := encoder.Encode(inboxReply{now, reply.Item, reply.User, hn.ItemURL(reply.Item.ID), hn.ContextURL(reply.Item)})
+		if err != nil {
+			return fmt.Errorf("failed to write reply: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func writeInboxPretty(ctx context.Context, replies []unl.Reply, now time.Time, o inboxOptions) error {
+	r := render.NewTreeRenderer(render.Options{
+		Now:            now,
+		ActiveAfter:    now,
+		NewAfter:       time.Time{},
+		Theme:          getTheme(o.noColor),
+		EffectiveTimes: nil,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          o.style,
+		MaxWidth:       o.maxWidth,
+		MaxByWidth:     getMaxByWidth(ctx),
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	lines := make([]render.Line, 0, len(replies))
+
+	for _, reply := range replies {
+		item := reply.Item
+		text := "(to " + reply.User + ") " + unl.PrettyFormatTitle(item, false)
+		lines = append(lines, render.Line{Time: time.Unix(item.Time, 0), By: item.By, Text: text, ID: item.ID})
+	}
+
+	var buf bytes.Buffer
+
+	err := r.RenderLines(&buf, lines...)
+	if err != nil {
+		return fmt.Errorf("failed to render replies: %w", err)
+	}
+
+	return getPager(ctx).Write(ctx, &buf)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"golang.org/x/term"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// liveView redraws the active threads of each poll of "unl watch --live" in place. The threads of the last poll are
+// retained so a resized terminal is redrawn at its new width right away rather than at the next poll.
+type liveView struct {
+	out     io.Writer
+	width   func() int
+	threads []render.Thread
+	opts    render.Options
+	mu      sync.Mutex
+}
+
+func newLiveView(out io.Writer, width func() int, opts render.Options) *liveView {
+	return &liveView{out, width, nil, opts, sync.Mutex{}}
+}
+
+// terminalWidth returns a function for the current width of the terminal on stdout, or fallback if stdout is not a
+// terminal or its size is unknown.
+func terminalWidth(fallback int) func() int {
+	return func() int {
+		fd := int(os.Stdout.Fd()) //nolint:gosec // G115 file descriptors fit in an int
+		if !term.IsTerminal(fd) {
+			return fallback
+		}
+
+		width, _, err := term.GetSize(fd)
+		if err != nil {
+			return fallback
+		}
+
+		return width
+	}
+}
+
+// update retains the active threads of a poll and draws them, marking items after newAfter as new.
+func (v *liveView) update(
+	now time.Time,
+	activeAfter time.Time,
+	newAfter time.Time,
+	items []*hn.Item,
+	byParent map[int]hn.ItemSet,
+) error {
+	threads := make([]render.Thread, 0, len(items))
+	for _, item := range items {
+		threads = append(threads, render.Thread{Root: item, ByParent: byParent, Orphaned: item.Parent != nil})
+	}
+
+	v.mu.Lock()
+	v.threads = threads
+	v.opts.Now = now
+	v.opts.ActiveAfter = activeAfter
+	v.opts.NewAfter = newAfter
+	v.mu.Unlock()
+
+	return v.draw()
+}
+
+// draw clears the screen and renders the retained threads at the current width.
+func (v *liveView) draw() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	opts := v.opts
+	opts.MaxWidth = v.width()
+
+	var buf bytes.Buffer
+
+	buf.WriteString(clearScreen)
+
+	err := render.NewTreeRenderer(opts).Render(&buf, v.threads...)
+	if err != nil {
+		return fmt.Errorf("failed to render threads: %w", err)
+	}
+
+	_, err = buf.WriteTo(v.out)
+	if err != nil {
+		return fmt.Errorf("failed to write threads: %w", err)
+	}
+
+	return nil
+}
+
+// listen redraws v on SIGWINCH until the returned function is called.
+func (v *liveView) listen() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				// a failed write fails the next poll too, which reports it
+				_ = v.draw()
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,11 +10,13 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/jasonthorsness/unlurker/hn"
 	"github.com/jasonthorsness/unlurker/hn/core"
 	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -21,17 +24,33 @@ import (
 
 var errInvalidArgs = errors.New("invalid args")
 
+const (
+	defaultMaxAge      = 8 * time.Hour
+	defaultWindow      = 30 * time.Minute
+	defaultMinBy       = 3
+	defaultReuseWithin = time.Minute
+	defaultMaxTreeSize = 1000
+)
+
+// The defaults for --ask and --show are looser since those sections see fewer, slower conversations.
+const (
+	focusMaxAge = 24 * time.Hour
+	focusWindow = 2 * time.Hour
+	focusMinBy  = 2
+)
+
 func main() {
 	const defaultWidthOnTerminalSizeFailure = 80
 
 	var err error
 	maxWidth := 0
+	maxHeight := 0
 	defaultNoColor := false
 
 	if term.IsTerminal(int(os.Stdout.Fd())) {
-		maxWidth, _, err = term.GetSize(int(os.Stdout.Fd()))
+		maxWidth, maxHeight, err = term.GetSize(int(os.Stdout.Fd()))
 		if err != nil {
-			maxWidth = defaultWidthOnTerminalSizeFailure
+			maxWidth, maxHeight = defaultWidthOnTerminalSizeFailure, 0
 		}
 	} else {
 		defaultNoColor = true
@@ -42,9 +61,9 @@ func main() {
 		defaultCachePath = os.TempDir()
 	}
 
-	defaultCachePath = filepath.Join(defaultCachePath, "hn.db")
+	defaultCachePath = filepath.Join(defaultCachePath, hn.DefaultFileCacheName)
 
-	cmd := buildCommand(nil, nil, maxWidth, defaultNoColor, defaultCachePath)
+	cmd := buildCommand(nil, nil, maxWidth, maxHeight, defaultNoColor, defaultCachePath)
 
 	err = cmd.Execute()
 	if err != nil {
@@ -56,94 +75,325 @@ func buildCommand(
 	getter core.Getter[string, io.ReadCloser],
 	clock core.Clock,
 	maxWidth int,
+	maxHeight int,
 	defaultNoColor bool,
 	defaultCachePath string,
 ) *cobra.Command {
 	var (
-		noCache   bool
-		noColor   bool
-		cachePath string
-		maxAge    time.Duration
-		window    time.Duration
-		minBy     int
-		limit     int
+		f          runFlags
+		ascii      bool
+		ask        bool
+		show       bool
+		cacheName  string
+		source     string
+		pagerMode  string
+		maxByWidth int
+		verbose    int
 	)
 
 	cmd := &cobra.Command{
 		Use:   "unl",
 		Short: "unl finds active discussions on news.ycombinator.com",
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if verbose > 0 {
+				cmd.SetContext(context.WithValue(cmd.Context(), diagnosticsContextKey{}, hn.NewDiagnostics(verbose)))
+			}
+
+			src, err := hn.SourceByName(source)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidArgs, err)
+			}
+
+			cmd.SetContext(context.WithValue(cmd.Context(), sourceContextKey{}, src))
+
+			p, err := newPager(pagerMode, maxHeight, os.Getenv, os.Stdout)
+			if err != nil {
+				return err
+			}
+
+			cmd.SetContext(context.WithValue(cmd.Context(), pagerContextKey{}, p))
+
+			if ascii {
+				cmd.SetContext(context.WithValue(cmd.Context(), styleContextKey{}, render.ASCIIStyle))
+			}
+
+			if maxByWidth < 0 {
+				return fmt.Errorf("%w: --max-by-width must not be negative", errInvalidArgs)
+			}
+
+			cmd.SetContext(context.WithValue(cmd.Context(), maxByWidthContextKey{}, maxByWidth))
+
+			return resolveCacheName(cmd, &f.cachePath, cacheName, src)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCommand(cmd, args, getter, clock, noCache, cachePath, maxWidth, window, maxAge, minBy, limit, noColor)
+			focus, err := getFocus(cmd, ask, show, &f.maxAge, &f.window, &f.minBy)
+			if err != nil {
+				return err
+			}
+
+			return runCommand(cmd, args, getter, clock, maxWidth, focus, f)
 		},
 		Long:    "unl finds active discussions on news.ycombinator.com",
 		Example: "  unl --max-age 8h --window 30m --min-by 3 --limit 3",
 	}
 
-	const (
-		defaultMaxAge = 8 * time.Hour
-		defaultWindow = 30 * time.Minute
-		defaultMinBy  = 3
-	)
-
-	cmd.Flags().DurationVar(&maxAge, "max-age", defaultMaxAge, "maximum age for items")
-	cmd.Flags().DurationVar(&window, "window", defaultWindow, "time window for activity")
-	cmd.Flags().IntVar(&minBy, "min-by", defaultMinBy, "minimum count of unique contributors to activity")
-	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "limit the number of results")
-	cmd.Flags().StringVar(&cachePath, "cache-path", defaultCachePath, "cache file path")
-	cmd.Flags().BoolVar(&noCache, "no-cache", false, "disable cache")
-	cmd.Flags().BoolVar(&noColor, "no-color", defaultNoColor, "disable color")
+	cmd.Flags().DurationVar(&f.maxAge, "max-age", defaultMaxAge, "maximum age for items")
+	cmd.Flags().DurationVar(&f.window, "window", defaultWindow, "time window for activity")
+	cmd.Flags().IntVar(&f.minBy, "min-by", defaultMinBy, "minimum count of unique contributors to activity")
+	cmd.Flags().Float64Var(&f.minActivity, "min-activity", 0,
+		"minimum activity score, counting each contributor as 1/depth of their reply (0 to disable)")
+	cmd.Flags().IntVarP(&f.limit, "limit", "l", 0, "limit the number of results")
+	cmd.Flags().IntVar(&f.maxTreeSize, "max-tree-size", defaultMaxTreeSize,
+		"retrieve about this many items per discussion, omitting older replies of larger ones (0 to disable)")
+	cmd.Flags().IntVar(&f.preview, "preview", 0, "lines of self-post text to show under each root (0 to disable)")
+	cmd.Flags().BoolVar(&f.showRank, "rank", false, "show the front page rank of stories on the front page")
+	cmd.Flags().StringVar(&f.childOrder, "child-order", "time", "order of replies: time, kids (as on the site), or score")
+	cmd.Flags().StringSliceVar(&f.types, "types", nil, "only include roots of these types: story, ask, show, job, poll")
+	cmd.Flags().BoolVar(&ask, "ask", false,
+		"only include Ask HN roots, defaulting to --max-age 24h --window 2h --min-by 2")
+	cmd.Flags().BoolVar(&show, "show", false, "only include Show HN roots, with the same defaults as --ask")
+	cmd.Flags().BoolVar(&f.orphans, "orphans", false,
+		"show active subthreads of dead or deleted roots, rooted at their highest live reply")
+	cmd.PersistentFlags().StringVar(&f.cachePath, "cache-path", defaultCachePath, "cache file path")
+	cmd.PersistentFlags().StringVar(&cacheName, "cache-name", "",
+		"use the named cache hn-<name>.db next to the default cache file")
+	cmd.PersistentFlags().StringVar(&source, "source", hn.HackerNews.Name,
+		"API to retrieve from: "+strings.Join(hn.SourceNames(), ", ")+" (others use the cache hn-<source>.db)")
+	cmd.PersistentFlags().BoolVar(&f.noCache, "no-cache", false, "disable cache")
+	cmd.PersistentFlags().BoolVar(&f.noColor, "no-color", defaultNoColor, "disable color")
+	cmd.PersistentFlags().StringVar(&pagerMode, "pager", unl.PagerAuto,
+		"pipe output through $PAGER (default less): auto when taller than the terminal, always, or never")
+	cmd.PersistentFlags().BoolVar(&ascii, "ascii", false,
+		"use ASCII glyphs and mark active items with [ACTIVE], for screen readers and limited terminals")
+	cmd.PersistentFlags().IntVar(&maxByWidth, "max-by-width", render.DefaultMaxByWidth,
+		"truncate longer usernames in the middle to keep the columns narrow (0 to disable)")
+	cmd.Flags().BoolVar(&f.normalize, "normalize-now", false, "correct local clock skew using the time of the latest item")
+	cmd.Flags().BoolVar(&f.checkClock, "check-clock", false,
+		"warn when the local clock is off from the time of the latest item")
+	cmd.Flags().DurationVar(&f.reuseWithin, "reuse-within", defaultReuseWithin,
+		"reuse the result of an identical run this recent unless its items changed (0 to disable)")
+	cmd.Flags().StringVar(&f.strategy, "strategy", unl.StrategyNames()[0],
+		"how to find activity: "+strings.Join(unl.StrategyNames(), ", ")+" (scan is exact, the rest are cheaper estimates)")
+	cmd.Flags().StringVar(&f.proxy, "proxy", "", "HTTP or SOCKS5 proxy URL for scraping the front page")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 0,
+		"stop retrieving after this long and show what was found so far (0 to disable)")
+	cmd.PersistentFlags().CountVarP(&verbose, "verbose", "v",
+		"print request counts, cache hit rates, and phase timings to stderr (-vv for more)")
+
+	cmd.AddCommand(secondChanceCmd(getter, clock, maxWidth, &f.cachePath, &f.noCache, &f.noColor))
+	cmd.AddCommand(watchCmd(getter, clock, maxWidth, &f.cachePath, &f.noCache, &f.noColor))
+	cmd.AddCommand(muteCmd(clock, &f.cachePath, &f.noCache))
+	cmd.AddCommand(inboxCmd(getter, clock, maxWidth, &f.cachePath, &f.noCache, &f.noColor))
+	cmd.AddCommand(openCmd(getter, clock, maxWidth, &f.cachePath, &f.noCache, &f.noColor))
+	cmd.AddCommand(tuneCmd(getter, clock, &f.cachePath, &f.noCache))
 
 	return cmd
 }
 
+// runFlags are the flags of the root command that select and show the active discussions.
+type runFlags struct {
+	cachePath   string
+	childOrder  string
+	strategy    string
+	proxy       string
+	types       []string
+	minActivity float64
+	maxAge      time.Duration
+	window      time.Duration
+	reuseWithin time.Duration
+	timeout     time.Duration
+	minBy       int
+	limit       int
+	maxTreeSize int
+	preview     int
+	noCache     bool
+	noColor     bool
+	normalize   bool
+	checkClock  bool
+	showRank    bool
+	orphans     bool
+}
+
 func runCommand(
 	cmd *cobra.Command,
 	args []string,
 	getter core.Getter[string, io.ReadCloser],
 	clock core.Clock,
-	noCache bool,
-	cachePath string,
 	maxWidth int,
-	window time.Duration,
-	maxAge time.Duration,
-	minBy int,
-	limit int,
-	noColor bool,
+	focus []unl.RootType,
+	f runFlags,
 ) error {
 	ctx := cmd.Context()
 
-	if err := validateArgs(cmd, args, noCache); err != nil {
+	if err := validateArgs(cmd, args, f.noCache); err != nil {
+		return err
+	}
+
+	order, err := unl.ParseChildOrder(f.childOrder)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+
+	strategy, err := unl.StrategyByName(f.strategy)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+
+	err = validateStrategy(strategy, f.noCache, f.orphans, f.minActivity)
+	if err != nil {
+		return err
+	}
+
+	options, err := getActiveOptions(f.minActivity, f.types)
+	if err != nil {
+		return err
+	}
+
+	if f.orphans {
+		options = append(options, unl.WithOrphans())
+	}
+
+	if f.maxTreeSize < 0 {
+		return fmt.Errorf("%w: --max-tree-size must not be negative", errInvalidArgs)
+	}
+
+	truncated := make(hn.Truncated)
+	options = append(options, unl.WithMaxTreeSize(f.maxTreeSize, truncated))
+
+	if f.timeout < 0 {
+		return fmt.Errorf("%w: --timeout must not be negative", errInvalidArgs)
+	}
+
+	// retrieval is bounded by the timeout, while stores and output use ctx so a partial result can still be shown
+	retrieveCtx, cancel := ctx, context.CancelFunc(func() {})
+	if f.timeout > 0 {
+		retrieveCtx, cancel = context.WithTimeout(ctx, f.timeout)
+	}
+	defer cancel()
+
+	fetchFrontPage, err := getFrontPageFetcher(f.proxy)
+	if err != nil {
 		return err
 	}
 
-	if noCache {
+	cachePath := f.cachePath
+	if f.noCache {
 		cachePath = ""
 	}
 
-	client, err := createClient(ctx, cachePath, getter, clock)
+	// the client is created first so it can replace a corrupt cache file before the store opens it
+	client, err := createClient(ctx, cachePath, getter, clock, f.normalize)
 	if err != nil {
 		return err
 	}
-	defer closeClient(client)
+	defer closeClient(ctx, client)
 
-	now := getCurrentTime(clock)
-	activeAfter := now.Add(-window)
-	agedAfter := now.Add(-maxAge)
+	muted, err := getMutedOption(ctx, cachePath)
+	if err != nil {
+		return err
+	}
+
+	options = append(options, muted...)
+
+	focused, err := getFocusOptions(retrieveCtx, client, focus)
+	if err != nil {
+		return err
+	}
+
+	options = append(options, focused...)
+
+	diagnostics := getDiagnostics(ctx)
+
+	now, err := getNow(retrieveCtx, client, f.checkClock && !f.normalize)
+	if err != nil {
+		return err
+	}
+
+	activeAfter := now.Add(-f.window)
+	agedAfter := now.Add(-f.maxAge)
+
+	var frontPageTimes unl.EffectiveTimes
+	var ranks map[int]int
+
+	endPhase := diagnostics.Phase("front page")
+	frontPage, err := fetchFrontPage(retrieveCtx, now)
+
+	endPhase()
 
-	frontPageTimes, err := unl.FetchFrontPageTimes(ctx, now)
 	if err != nil {
 		_, err = fmt.Fprintf(os.Stderr, "\nWarning: Failed to adjust times for second-chance articles: %v\n", err)
 		if err != nil {
 			return fmt.Errorf("failed to write warning: %w", err)
 		}
+	} else {
+		frontPageTimes = frontPage.Times()
+
+		if f.showRank {
+			ranks = make(map[int]int, len(frontPage.Entries))
+			for _, e := range frontPage.Entries {
+				ranks[e.ID] = e.Rank
+			}
+		}
+	}
+
+	if frontPageTimes != nil && cachePath != "" {
+		// the record only feeds "unl second-chance", so failing to update it does not fail the run
+		err = recordSecondChances(ctx, client, cachePath, frontPage, now)
+		if err != nil {
+			_, err = fmt.Fprintf(os.Stderr, "\nWarning: Failed to record second-chance articles: %v\n", err)
+			if err != nil {
+				return fmt.Errorf("failed to write warning: %w", err)
+			}
+		}
+	}
+
+	req := unl.ActivityRequest{
+		Now: now, ActiveAfter: activeAfter, AgedAfter: agedAfter, EffectiveTimes: frontPageTimes, Store: nil,
+		Options: options, MinBy: f.minBy,
+	}
+
+	if _, ok := strategy.(unl.ListDelta); ok {
+		endPhase = diagnostics.Phase("stories")
+		err = runEstimate(ctx, retrieveCtx, client, cachePath, strategy, req, f.limit, f.noColor, maxWidth)
+
+		endPhase()
+
+		return err
+	}
+
+	key := snapshotKey(
+		getSource(ctx), strategy, f.window, f.maxAge, f.minBy, f.minActivity, f.limit, f.types, focus, f.orphans,
+		f.maxTreeSize, frontPageTimes)
+
+	endPhase = diagnostics.Phase("items")
+	items, allByParent, err := getActiveReusingSnapshot(
+		retrieveCtx, client, cachePath, key, now, f.reuseWithin, truncated,
+		func() ([]*hn.Item, map[int]hn.ItemSet, error) {
+			items, activity, err := unl.FindActive(retrieveCtx, client, strategy, req, f.limit)
+
+			return items, activity.ByParent, err
+		})
+
+	endPhase()
+
+	// the retrieval may also fail outright at the deadline, such as opening the store, leaving nothing to show
+	footer := ""
+	if errors.Is(err, hn.ErrPartialResult) || (err != nil && retrieveCtx.Err() != nil) {
+		footer = fmt.Sprintf("Warning: timed out after %s; showing what was retrieved so far", f.timeout)
+		err = nil
 	}
 
-	items, allByParent, err := unl.GetActive(ctx, client, frontPageTimes, activeAfter, agedAfter, minBy, limit)
 	if err != nil {
 		return err
 	}
 
-	err = writeActiveToStdout(items, allByParent, frontPageTimes, now, activeAfter, noColor, maxWidth)
+	endPhase = diagnostics.Phase("render")
+	err = writeActiveToStdout(
+		ctx, items, allByParent, frontPageTimes, ranks, truncated, now, activeAfter, getStyle(ctx), f.noColor, maxWidth,
+		order, f.preview, footer)
+
+	endPhase()
+
 	if err != nil {
 		return err
 	}
@@ -151,6 +401,114 @@ func runCommand(
 	return nil
 }
 
+func getActiveOptions(minActivity float64, types []string) ([]unl.Option, error) {
+	var options []unl.Option
+
+	if minActivity > 0 {
+		options = append(options, unl.WithMinActivity(minActivity, unl.DepthWeightedActivity))
+	}
+
+	if len(types) > 0 {
+		rootTypes := make([]unl.RootType, len(types))
+
+		for i, v := range types {
+			t, err := unl.ParseRootType(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errInvalidArgs, err)
+			}
+
+			rootTypes[i] = t
+		}
+
+		options = append(options, unl.WithRootTypes(rootTypes...))
+	}
+
+	return options, nil
+}
+
+// getFocus returns the root types selected by --ask and --show, applying their defaults to the thresholds that were
+// not set explicitly.
+func getFocus(
+	cmd *cobra.Command,
+	ask bool,
+	show bool,
+	maxAge *time.Duration,
+	window *time.Duration,
+	minBy *int,
+) ([]unl.RootType, error) {
+	var focus []unl.RootType
+
+	if ask {
+		focus = append(focus, unl.RootAsk)
+	}
+
+	if show {
+		focus = append(focus, unl.RootShow)
+	}
+
+	if len(focus) == 0 {
+		return nil, nil
+	}
+
+	if cmd.Flags().Changed("types") {
+		return nil, fmt.Errorf("%w: --types cannot be used with --ask or --show", errInvalidArgs)
+	}
+
+	if !cmd.Flags().Changed("max-age") {
+		*maxAge = focusMaxAge
+	}
+
+	if !cmd.Flags().Changed("window") {
+		*window = focusWindow
+	}
+
+	if !cmd.Flags().Changed("min-by") {
+		*minBy = focusMinBy
+	}
+
+	return focus, nil
+}
+
+// getFocusOptions restricts the roots to the focused types, also accepting the roots on the matching lists so stories
+// like "Tell HN" posts on the Ask list are included even though their titles lack the prefix.
+func getFocusOptions(ctx context.Context, client hn.API, focus []unl.RootType) ([]unl.Option, error) {
+	if len(focus) == 0 {
+		return nil, nil
+	}
+
+	var listed []int
+
+	for _, t := range focus {
+		getIDs := client.GetAsk
+		if t == unl.RootShow {
+			getIDs = client.GetShow
+		}
+
+		ids, err := getIDs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s list: %w", t, err)
+		}
+
+		listed = append(listed, ids...)
+	}
+
+	return []unl.Option{unl.WithRootTypes(focus...), unl.WithListedRoots(listed...)}, nil
+}
+
+// getFrontPageFetcher returns the shared front page fetcher, or one using proxy if provided.
+func getFrontPageFetcher(proxy string) (func(context.Context, time.Time) (*unl.FrontPage, error), error) {
+	if proxy == "" {
+		return unl.FetchFrontPage, nil
+	}
+
+	httpClient, err := unl.NewSiteHTTPClient(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+
+	return unl.NewFrontPageClient(httpClient, unl.FrontPageURL, time.Minute).Get, nil
+}
+
 func validateArgs(cmd *cobra.Command, args []string, _ bool) error {
 	if len(args) != 0 {
 		return fmt.Errorf("%w: unexpected positional arguments: %v", errInvalidArgs, args)
@@ -163,58 +521,202 @@ func validateArgs(cmd *cobra.Command, args []string, _ bool) error {
 	return nil
 }
 
+// resolveCacheName points cachePath at the named cache selected with --cache-name, if any, or at the cache named for
+// a source other than the HN API unless --cache-path is provided.
+func resolveCacheName(cmd *cobra.Command, cachePath *string, cacheName string, source hn.Source) error {
+	if cmd.Flags().Changed("cache-name") {
+		if cmd.Flags().Changed("no-cache") || cmd.Flags().Changed("cache-path") {
+			return fmt.Errorf("%w: cannot provide --cache-name with --no-cache or --cache-path", errInvalidArgs)
+		}
+	} else if source.Name != hn.HackerNews.Name && !cmd.Flags().Changed("cache-path") {
+		cacheName = source.Name
+	}
+
+	if cacheName == "" {
+		return nil
+	}
+
+	path, err := hn.NamedFileCachePath(filepath.Dir(*cachePath), cacheName)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+
+	*cachePath = path
+
+	return nil
+}
+
 func createClient(
-	ctx context.Context, cachePath string, getter core.Getter[string, io.ReadCloser], clock core.Clock,
+	ctx context.Context,
+	cachePath string,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	normalizeNow bool,
 ) (*hn.Client, error) {
-	client, err := hn.NewClient(ctx, hn.WithFileCachePath(cachePath), hn.WithGetter(getter), hn.WithClock(clock))
+	movedTo := ""
+
+	client, err := hn.NewClient(
+		ctx,
+		hn.WithFileCachePath(cachePath),
+		hn.WithFileCacheCorruptHandler(func(moved string, err error) {
+			movedTo = moved
+
+			warnCorruptCache(moved, err)
+		}),
+		hn.WithWorkerPanicHandler(warnWorkerPanic),
+		hn.WithSource(getSource(ctx)),
+		hn.WithGetter(getter),
+		hn.WithClock(clock),
+		hn.WithNormalizedNow(normalizeNow))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
+	if movedTo != "" {
+		recoverCorruptCache(ctx, cachePath, movedTo)
+	}
+
 	return client, nil
 }
 
-func closeClient(client *hn.Client) {
+// warnCorruptCache tells the user the cache was replaced, since the client otherwise continues silently.
+func warnCorruptCache(movedTo string, err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "\nWarning: cache file is corrupt (%v), moved it to %s and started a new one\n", err, movedTo)
+}
+
+// recoverCorruptCache copies the bookmarks, mutes, and other state of the cache moved aside to movedTo into the new
+// one, telling the user where they remain if that fails.
+func recoverCorruptCache(ctx context.Context, cachePath string, movedTo string) {
+	err := unl.RecoverStore(ctx, cachePath, movedTo)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "\nWarning: %v\n", err)
+	}
+}
+
+// warnWorkerPanic reports a panic recovered while retrieving an item, which otherwise would end the program.
+func warnWorkerPanic(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "\nWarning: recovered from %v\n", err)
+}
+
+type diagnosticsContextKey struct{}
+
+type sourceContextKey struct{}
+
+// getSource returns the source selected with --source.
+func getSource(ctx context.Context) hn.Source {
+	source, ok := ctx.Value(sourceContextKey{}).(hn.Source)
+	if !ok {
+		return hn.HackerNews
+	}
+
+	return source
+}
+
+// getDiagnostics returns the diagnostics for --verbose, or nil.
+func getDiagnostics(ctx context.Context) *hn.Diagnostics {
+	d, _ := ctx.Value(diagnosticsContextKey{}).(*hn.Diagnostics)
+	return d
+}
+
+// closeClient closes client and then writes the diagnostics for --verbose, which include its final stats.
+func closeClient(ctx context.Context, client *hn.Client) {
 	err := client.Close()
 	if err != nil {
 		log.Fatalf("failed to close client: %v", err)
 	}
+
+	err = getDiagnostics(ctx).Write(os.Stderr, client.Stats())
+	if err != nil {
+		log.Fatalf("failed to write diagnostics: %v", err)
+	}
 }
 
-func getCurrentTime(clock core.Clock) time.Time {
-	if clock != nil {
-		return clock.Now()
+// getNow returns the effective current time, warning on stderr when it was normalized or, with checkClock, when the
+// local clock appears skewed.
+func getNow(ctx context.Context, client *hn.Client, checkClock bool) (time.Time, error) {
+	now, skew, err := client.Now(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get current time: %w", err)
 	}
 
-	return time.Now()
+	message := ""
+
+	switch {
+	case skew != 0:
+		message = fmt.Sprintf("local clock is off by %s, using the time of the latest item", skew.Round(time.Second))
+	case checkClock:
+		skew, err = client.ClockSkew(ctx)
+		if err != nil && ctx.Err() != nil {
+			// the check is only advice, so running out of time for it is reported with the result instead
+			break
+		}
+
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to check clock skew: %w", err)
+		}
+
+		if skew.Abs() > hn.ClockSkewTolerance {
+			message = fmt.Sprintf("local clock is off by %s, consider --normalize-now", skew.Round(time.Second))
+		}
+	}
+
+	if message != "" {
+		_, err = fmt.Fprintf(os.Stderr, "\nWarning: %s\n", message)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to write warning: %w", err)
+		}
+	}
+
+	return now, nil
 }
 
 func writeActiveToStdout(
+	ctx context.Context,
 	items []*hn.Item,
 	allByParent map[int]hn.ItemSet,
-	adjustedTimes map[int]int64,
+	effectiveTimes unl.EffectiveTimes,
+	ranks map[int]int,
+	truncated hn.Truncated,
 	now time.Time,
 	activeAfter time.Time,
+	style render.Style,
 	noColor bool,
 	maxWidth int,
+	childOrder unl.ChildOrder,
+	previewLines int,
+	footer string,
 ) error {
-	pw := prettyWriter{
-		now:           now,
-		activeAfter:   activeAfter,
-		adjustedTimes: adjustedTimes,
-		lines:         nil,
-		maxWidth:      maxWidth,
-		showColor:     !noColor,
-	}
-
+	r := render.NewTreeRenderer(render.Options{
+		Now:            now,
+		ActiveAfter:    activeAfter,
+		NewAfter:       time.Time{},
+		Theme:          getTheme(noColor),
+		EffectiveTimes: effectiveTimes,
+		Ranks:          ranks,
+		Truncated:      truncated,
+		Style:          style,
+		MaxWidth:       maxWidth,
+		MaxByWidth:     getMaxByWidth(ctx),
+		ChildOrder:     childOrder,
+		PreviewLines:   previewLines,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	threads := make([]render.Thread, 0, len(items))
 	for _, item := range items {
-		pw.writeTree(item, allByParent)
+		threads = append(threads, render.Thread{Root: item, ByParent: allByParent, Orphaned: item.Parent != nil})
 	}
 
-	_, err := pw.WriteTo(os.Stdout)
+	var buf bytes.Buffer
+
+	err := r.Render(&buf, threads...)
 	if err != nil {
-		return fmt.Errorf("failed to write to writer: %w", err)
+		return fmt.Errorf("failed to render items: %w", err)
 	}
 
-	return nil
+	if footer != "" {
+		buf.WriteString("\n" + footer + "\n")
+	}
+
+	return getPager(ctx).Write(ctx, &buf)
 }
@@ -4,18 +4,24 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/jasonthorsness/unlurker/testdata"
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
 )
 
 func TestConflictingFlags(t *testing.T) {
@@ -124,7 +130,7 @@ func TestWindow(t *testing.T) {
 			continue
 		}
 
-		if strings.Contains(scanner.Text(), colorLightBlue) {
+		if strings.Contains(scanner.Text(), colorActive) {
 			if duration > expectedDefault {
 				t.Fatalf("expected default to be %v", expectedDefault)
 			}
@@ -158,7 +164,7 @@ func TestWindow(t *testing.T) {
 			continue
 		}
 
-		if strings.Contains(scanner.Text(), colorLightBlue) {
+		if strings.Contains(scanner.Text(), colorActive) {
 			if duration > testWindowMinutes*time.Minute {
 				t.Fatalf("marked active outside window")
 			}
@@ -186,6 +192,24 @@ func TestNoColorDisablesANSI(t *testing.T) {
 	}
 }
 
+func TestASCII(t *testing.T) {
+	out, err := exec(t, "--no-color", "--ascii")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out, []byte("[ACTIVE] ")) || bytes.Contains(out, []byte("…")) {
+		t.Fatalf("expected active markers and only ASCII glyphs, got %s", out)
+	}
+}
+
+func TestPager(t *testing.T) {
+	_, err := exec(t, "--pager", "sometimes")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for unknown --pager, got %v", err)
+	}
+}
+
 func TestLimitFlag(t *testing.T) {
 	out, err := exec(t, "-l", "2")
 	if err != nil {
@@ -222,6 +246,31 @@ func TestDefaultFlags(t *testing.T) {
 	}
 }
 
+func TestVerbose(t *testing.T) {
+	stderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	defer func() { os.Stderr = stderr }()
+
+	_, err := exec(t, "-v")
+
+	err = errors.Join(err, w.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(buf), "items: ") || !strings.Contains(string(buf), "active scans: 1, ") ||
+		!strings.Contains(string(buf), ", render ") {
+		t.Fatalf("expected diagnostics, got:\n%s", buf)
+	}
+}
+
 func TestDurationFlagParsing(t *testing.T) {
 	_, err := exec(t, "--max-age", "notaduration")
 	if err == nil {
@@ -234,6 +283,722 @@ func TestDurationFlagParsing(t *testing.T) {
 	}
 }
 
+func TestChildOrder(t *testing.T) {
+	_, err := exec(t, "--child-order", "random")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for unknown --child-order, got %v", err)
+	}
+
+	byTime, err := exec(t, "--no-color", "--child-order", "time")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byKids, err := exec(t, "--no-color", "--child-order", "kids")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byTime) != len(byKids) || bytes.Equal(byTime, byKids) {
+		t.Fatal("expected the same lines in a different order")
+	}
+}
+
+func TestMinActivity(t *testing.T) {
+	all, err := exec(t, "--no-color", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	some, err := exec(t, "--no-color", "--min-by", "1", "--min-activity", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none, err := exec(t, "--no-color", "--min-by", "1", "--min-activity", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(none) != 0 || len(some) == 0 || len(some) >= len(all) {
+		t.Fatalf("expected fewer results with a higher --min-activity, got %d, %d, %d", len(all), len(some), len(none))
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	without, err := exec(t, "--no-color", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with, err := exec(t, "--no-color", "--min-by", "1", "--orphans")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marker := render.UnicodeStyle.Orphaned
+	if bytes.Contains(without, []byte(marker)) || !bytes.Contains(with, []byte(marker)) || len(with) <= len(without) {
+		t.Fatalf("expected --orphans to add a marked subthread:\n%s", with)
+	}
+}
+
+func TestMaxTreeSize(t *testing.T) {
+	_, err := exec(t, "--max-tree-size", "-1")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for negative --max-tree-size, got %v", err)
+	}
+
+	uncapped, err := exec(t, "--no-color", "--ascii", "--min-by", "1", "--max-tree-size", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capped, err := exec(t, "--no-color", "--ascii", "--min-by", "1", "--max-tree-size", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(uncapped, []byte("[TRUNCATED]")) || !bytes.Contains(capped, []byte("[TRUNCATED]")) {
+		t.Fatalf("expected a truncation marker only with a cap:\n%s", capped)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	_, err := exec(t, "--timeout", "-1s")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for negative --timeout, got %v", err)
+	}
+
+	// the deadline passes before anything is retrieved, which still shows the warning rather than failing
+	out, err := exec(t, "--no-color", "--timeout", "1ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out, []byte("Warning: timed out after 1ns")) {
+		t.Fatalf("expected a timeout warning, got:\n%s", out)
+	}
+
+	out, err = exec(t, "--no-color", "--timeout", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) == 0 || bytes.Contains(out, []byte("Warning: timed out")) {
+		t.Fatalf("expected a complete result within the timeout, got:\n%s", out)
+	}
+}
+
+func TestStrategy(t *testing.T) {
+	for _, args := range [][]string{
+		{"--strategy", "guess"},
+		{"--strategy", "descendants", "--no-cache"},
+		{"--strategy", "descendants", "--orphans"},
+	} {
+		_, err := exec(t, args...)
+		if !errors.Is(err, errInvalidArgs) {
+			t.Fatalf("expected invalid args for %v, got %v", args, err)
+		}
+	}
+
+	// the first run has no recorded metrics, so only stories created within the window can be estimated
+	cachePath := filepath.Join(t.TempDir(), "hn.db")
+
+	out, err := exec(t, "--no-color", "--cache-path", cachePath, "--strategy", "descendants", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out, []byte(" comments, +")) {
+		t.Fatalf("expected estimated stories, got:\n%s", out)
+	}
+
+	// the corpus doesn't change, so the recorded metrics leave nothing but stories created within the window
+	again, err := exec(t, "--no-color", "--cache-path", cachePath, "--strategy", "descendants", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again) > len(out) {
+		t.Fatalf("expected no more estimates on the second run, got:\n%s", again)
+	}
+
+	// the corpus has no changed items, so the updates feed finds nothing where the scan finds something
+	updates, err := exec(t, "--no-color", "--strategy", "updates", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan, err := exec(t, "--no-color", "--strategy", "scan", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) >= len(scan) {
+		t.Fatalf("expected the updates feed to find less than the scan, got:\n%s", updates)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	_, err := exec(t, "--types", "story,comment")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for unknown --types, got %v", err)
+	}
+
+	out, err := exec(t, "--types", "ask,show", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		_, title, ok := strings.Cut(scanner.Text(), "\033[92m")
+		if !ok {
+			continue
+		}
+
+		count++
+
+		if !strings.HasPrefix(title, "Ask HN") && !strings.HasPrefix(title, "Show HN") {
+			t.Fatalf("expected only Ask HN and Show HN roots, got %q", title)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count == 0 {
+		t.Fatal("expected at least one Ask HN or Show HN root")
+	}
+}
+
+func TestFocus(t *testing.T) {
+	_, err := exec(t, "--ask", "--types", "story")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --ask with --types, got %v", err)
+	}
+
+	for _, test := range []struct {
+		flag     string
+		prefixes []string
+	}{
+		{"--ask", []string{"Ask HN", "Tell HN"}},
+		{"--show", []string{"Show HN"}},
+	} {
+		out, err := exec(t, test.flag)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			_, title, ok := strings.Cut(scanner.Text(), "\033[92m")
+			if !ok {
+				continue
+			}
+
+			count++
+
+			if !slices.ContainsFunc(test.prefixes, func(p string) bool { return strings.HasPrefix(title, p) }) {
+				t.Fatalf("%s: unexpected root %q", test.flag, title)
+			}
+		}
+
+		err = scanner.Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count == 0 {
+			t.Fatalf("%s: expected at least one root", test.flag)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	without, err := exec(t, "--no-color", "--types", "ask", "--min-by", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with, err := exec(t, "--no-color", "--types", "ask", "--min-by", "1", "--preview", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withoutLines := strings.Split(string(without), "\n")
+	withLines := strings.Split(string(with), "\n")
+
+	if len(withLines) <= len(withoutLines) {
+		t.Fatalf("expected preview lines, got %d lines without and %d with", len(withoutLines), len(withLines))
+	}
+
+	for _, line := range withLines {
+		if len([]rune(line)) > 120 {
+			t.Fatalf("expected preview to fit the width, got %q", line)
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	_, err := exec(t, "--proxy", "localhost:8080")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for a proxy without a scheme, got %v", err)
+	}
+}
+
+func TestNormalizeNow(t *testing.T) {
+	without, err := exec(t, "--no-color")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with, err := exec(t, "--no-color", "--normalize-now")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test clock has no skew so normalizing changes nothing
+	if len(with) != len(without) {
+		t.Fatalf("expected the same output, got %d and %d bytes", len(without), len(with))
+	}
+}
+
+func TestSecondChance(t *testing.T) {
+	_, err := exec(t, "second-chance", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "second-chance", "--since", "a week")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for a bad --since, got %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "hn.db")
+
+	store, err := unl.OpenStore(t.Context(), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item hn.Item
+	item.ID = 43729739
+	item.Time = testdata.MaxTime.Add(-20 * time.Hour).Unix()
+
+	now := testdata.Clock.Now()
+	times := unl.EffectiveTimes{item.ID: now.Add(-time.Hour).Unix()}
+
+	err = errors.Join(
+		store.RecordSecondChances(t.Context(), hn.ItemSet{item.ID: &item}, times, now.Add(-2*24*time.Hour)),
+		store.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec(t, "second-chance", "--no-color", "--cache-path", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(out), "item?id=43729739") || !strings.Contains(string(out), "(reset 19h  0m)") {
+		t.Fatalf("expected the recorded article, got %q", out)
+	}
+
+	out, err = exec(t, "second-chance", "--no-color", "--cache-path", dbPath, "--since", "1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 0 {
+		t.Fatalf("expected no articles in the last day, got %q", out)
+	}
+
+	// a front page without adjusted articles retrieves nothing and leaves the store alone
+	var entry unl.FrontPageEntry
+	entry.ID = item.ID
+	entry.Time = item.Time
+	entry.ApparentTime = item.Time
+
+	missing := filepath.Join(t.TempDir(), "missing", "hn.db")
+
+	frontPage := &unl.FrontPage{Entries: []unl.FrontPageEntry{entry}, Partial: false}
+
+	err = recordSecondChances(t.Context(), nil, missing, frontPage, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	_, err := exec(t, "watch", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "hn.db")
+
+	watch := func(args ...string) []watchEvent {
+		t.Helper()
+
+		out, err := exec(t, append([]string{"watch", "--polls", "1", "--cache-path", dbPath, "--window", "1h"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var events []watchEvent
+
+		decoder := json.NewDecoder(bytes.NewReader(out))
+		for decoder.More() {
+			var event watchEvent
+
+			err = decoder.Decode(&event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events = append(events, event)
+		}
+
+		return events
+	}
+
+	events := watch()
+	if len(events) == 0 || events[0].Event != "added" || events[0].Active == 0 {
+		t.Fatalf("expected added threads, got %v", events)
+	}
+
+	if events[0].URL != hn.ItemURL(events[0].ID) {
+		t.Fatalf("expected the permalink of %d, got %s", events[0].ID, events[0].URL)
+	}
+
+	if events[0].Now.Before(testdata.MaxTime) {
+		t.Fatalf("expected the effective now of the poll, got %s", events[0].Now)
+	}
+
+	// the state persists across runs, so nothing is reported again
+	if again := watch(); len(again) != 0 {
+		t.Fatalf("expected no events after a restart, got %v", again)
+	}
+
+	if reset := watch("--reset-state"); len(reset) != len(events) {
+		t.Fatalf("expected %d added threads after a reset, got %v", len(events), reset)
+	}
+
+	if throttled := watch("--reset-state", "--max-per-hour", "1"); len(throttled) != 1 {
+		t.Fatalf("expected 1 event with --max-per-hour 1, got %v", throttled)
+	}
+
+	_, err = exec(t, "watch", "--quiet-hours", "late")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for bad --quiet-hours, got %v", err)
+	}
+
+	// a narrower window ends some discussions and changes the counts of others
+	narrower := watch("--window", "10m")
+	if len(narrower) == 0 {
+		t.Fatal("expected events for a narrower window")
+	}
+}
+
+func TestWatchLive(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "hn.db")
+
+	out, err := exec(t, "watch", "--live", "--polls", "2", "--interval", "1ms", "--cache-path", dbPath, "--window", "1h",
+		"--no-color")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each poll redraws the whole screen with the threads rather than writing events
+	frames := strings.Split(string(out), clearScreen)
+	if len(frames) != 3 || frames[0] != "" {
+		t.Fatalf("expected 2 frames, got %d", len(frames)-1)
+	}
+
+	for _, frame := range frames[1:] {
+		if !strings.HasPrefix(frame, "https://news.ycombinator.com/item?id=") {
+			t.Fatalf("expected threads in each frame, got %q", frame)
+		}
+	}
+
+	if strings.Contains(string(out), "[NEW] ") {
+		t.Fatal("expected nothing new without an earlier refresh")
+	}
+
+	// items after the refresh time saved by a previous watch are marked as new
+	store, err := unl.OpenStore(t.Context(), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = errors.Join(store.SaveWatchRefreshed(t.Context(), testdata.MaxTime.Add(-10*time.Minute)), store.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = exec(t, "watch", "--live", "--polls", "1", "--cache-path", dbPath, "--window", "1h", "--no-color",
+		"--ascii")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(out), "[NEW] ") {
+		t.Fatalf("expected items marked as new, got %q", out)
+	}
+
+	_, err = exec(t, "watch", "--live", "--cache-path", dbPath, "--max-per-hour", "1")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --live with --max-per-hour, got %v", err)
+	}
+
+	// a resize redraws the retained threads at the new width
+	var item hn.Item
+	item.ID = 1
+	item.By = "a"
+	item.Title = strings.Repeat("title ", 20)
+
+	width := 100
+
+	var buf bytes.Buffer
+
+	view := newLiveView(&buf, func() int { return width }, render.Options{
+		Now:            time.Unix(60, 0),
+		ActiveAfter:    time.Time{},
+		NewAfter:       time.Time{},
+		Theme:          nil,
+		EffectiveTimes: nil,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          render.ASCIIStyle,
+		MaxWidth:       0,
+		MaxByWidth:     0,
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	err = view.update(time.Unix(60, 0), time.Time{}, time.Time{}, []*hn.Item{&item}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	width = 60
+
+	err = view.draw()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames = strings.Split(buf.String(), clearScreen)
+	if len(frames) != 3 || len(strings.TrimSuffix(frames[1], "\n")) != 100 ||
+		len(strings.TrimSuffix(frames[2], "\n")) != 60 {
+		t.Fatalf("expected a 100-column frame and then a 60-column frame, got %q", buf.String())
+	}
+}
+
+func TestInbox(t *testing.T) {
+	_, err := exec(t, "inbox", testdata.UserID, "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "inbox", testdata.UserID, "--submitted", "0")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --submitted 0, got %v", err)
+	}
+
+	_, err = exec(t, "inbox", testdata.UserID, "--format", "xml")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for --format xml, got %v", err)
+	}
+
+	replies := make([]unl.Reply, 3)
+	for i := range replies {
+		var item hn.Item
+		item.ID = i + 1
+		replies[i] = unl.Reply{User: "a", Item: &item}
+	}
+
+	ids := func(replies []unl.Reply) []int {
+		result := make([]int, len(replies))
+		for i, reply := range replies {
+			result[i] = reply.Item.ID
+		}
+
+		return result
+	}
+
+	unread, read := partitionInbox(replies, []int{2}, false)
+	if !slices.Equal(ids(unread), []int{1, 3}) || !slices.Equal(ids(read), []int{2}) {
+		t.Fatalf("unexpected unread %v and read %v", ids(unread), ids(read))
+	}
+
+	unread, read = partitionInbox(replies, nil, true)
+	if len(unread) != 3 || len(read) != 3 {
+		t.Fatalf("expected all replies listed and marked read, got %v and %v", ids(unread), ids(read))
+	}
+
+	// the replies to the testdata user are all deleted
+	out, err := exec(
+		t, "inbox", testdata.UserID, "another", "--format", "json",
+		"--submitted", strconv.Itoa(len(testdata.UserSubmitted)))
+	if err != nil || len(out) != 0 {
+		t.Fatalf("expected no replies, got %q: %v", out, err)
+	}
+}
+
+func TestMute(t *testing.T) {
+	_, err := exec(t, "mute", "--list", "--clear")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --list and --clear, got %v", err)
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "hn.db")
+
+	out, err := exec(t, "--no-color", "--cache-path", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := strings.Fields(string(out))[0]
+	id := strings.TrimPrefix(first, "https://news.ycombinator.com/item?id=")
+
+	_, err = exec(t, "mute", id, "--cache-path", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = exec(t, "--no-color", "--cache-path", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(out), first+" ") {
+		t.Fatalf("expected %s to be muted, got %q", id, out)
+	}
+
+	out, err = exec(t, "mute", "--list", "--cache-path", dbPath)
+	if err != nil || strings.TrimSpace(string(out)) != id {
+		t.Fatalf("expected %s to be listed, got %q: %v", id, out, err)
+	}
+
+	_, err = exec(t, "mute", "--clear", "--cache-path", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = exec(t, "--no-color", "--cache-path", dbPath)
+	if err != nil || !strings.Contains(string(out), first+" ") {
+		t.Fatalf("expected %s after clearing mutes, got %q: %v", id, out, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	byID, err := exec(t, "open", "43727393", "--no-color")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"quantumHazer", "trop", "mindslight"} {
+		if !bytes.Contains(byID, []byte(expected)) {
+			t.Fatalf("expected %s in the thread, got %s", expected, byID)
+		}
+	}
+
+	byURL, err := exec(t, "open", "https://news.ycombinator.com/item?id=43727157#43727393", "--no-color")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(byID, byURL) {
+		t.Fatalf("expected the context link to open the comment, got %s", byURL)
+	}
+
+	_, err = exec(t, "open", "https://example.com/item?id=43727393")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for another site, got %v", err)
+	}
+}
+
+func TestTune(t *testing.T) {
+	out, err := exec(t, "tune", "--windows", "30m,1h", "--min-bys", "3,100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a row per window, got %q", out)
+	}
+
+	if fields := strings.Fields(lines[0]); !slices.Equal(fields, []string{"window\\min-by", "3", "100"}) {
+		t.Fatalf("expected a column per min-by, got %q", lines[0])
+	}
+
+	// the default unl run uses a 30m window and min-by 3, so the first count is its number of roots
+	active, err := exec(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := 0
+
+	for line := range strings.SplitSeq(string(active), "\n") {
+		if strings.Contains(line, "\033[92m") {
+			roots++
+		}
+	}
+
+	fields := strings.Fields(lines[1])
+	if fields[1] != strconv.Itoa(roots) || fields[2] != "0" {
+		t.Fatalf("expected counts matching %d roots from unl, got %q", roots, lines[1])
+	}
+
+	_, err = exec(t, "tune", "--windows", "0s")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for a zero window, got %v", err)
+	}
+}
+
+func TestReuseWithin(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "hn.db")
+
+	first, err := exec(t, "--no-color", "--cache-path", dbPath)
+	if err != nil || len(first) == 0 {
+		t.Fatalf("expected output, got %q: %v", first, err)
+	}
+
+	// emptying the saved snapshot shows whether the next run reuses it
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ExecContext(t.Context(), "UPDATE active_snapshot SET roots = '[]'")
+	if err = errors.Join(err, db.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	reused, err := exec(t, "--no-color", "--cache-path", dbPath)
+	if err != nil || len(reused) != 0 {
+		t.Fatalf("expected the emptied snapshot to be reused, got %q: %v", reused, err)
+	}
+
+	for _, args := range [][]string{{"--reuse-within", "0"}, {"--window", "1h"}} {
+		fresh, err := exec(t, append([]string{"--no-color", "--cache-path", dbPath}, args...)...)
+		if err != nil || len(fresh) == 0 {
+			t.Fatalf("%v: expected the result to be computed again, got %q: %v", args, fresh, err)
+		}
+	}
+}
+
 func TestCombinationOfAll(t *testing.T) {
 	_, err := exec(
 		t,
@@ -243,12 +1008,18 @@ func TestCombinationOfAll(t *testing.T) {
 		"--window", "15m",
 		"--min-by", "2",
 		"--limit", "2",
+		"--child-order", "kids",
+		"--preview", "1",
+		"--rank",
 	)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
+// colorActive is the color of the ages of active items.
+const colorActive = "\033[94m"
+
 var (
 	ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 	timeRe = regexp.MustCompile(`\b(?:(\d+)h\s*)?(\d+)m\b`)
@@ -278,7 +1049,7 @@ func exec(t *testing.T, args ...string) ([]byte, error) {
 
 	defaultCachePath := filepath.Join(t.TempDir(), "hn.db")
 
-	cmd := buildCommand(testdata.Getter, testdata.Clock, 120, false, defaultCachePath)
+	cmd := buildCommand(testdata.Getter, testdata.Clock, 120, 0, false, defaultCachePath)
 
 	if args == nil {
 		args = []string{}
@@ -307,3 +1078,142 @@ func exec(t *testing.T, args ...string) ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+func TestCorruptCache(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "hn.db")
+
+	err := os.WriteFile(db, bytes.Repeat([]byte("not a database"), 1000), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec(t, "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) == 0 {
+		t.Fatal("expected output with a replaced cache")
+	}
+
+	moved, err := filepath.Glob(db + ".corrupt-*")
+	if err != nil || len(moved) != 1 {
+		t.Fatalf("expected the corrupt cache to be moved aside, got %v: %v", moved, err)
+	}
+}
+
+func TestCorruptCacheRecoversState(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "hn.db")
+
+	_, err := exec(t, "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := unl.OpenStore(t.Context(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = errors.Join(
+		store.SaveBookmark(t.Context(), unl.Bookmark{Note: "later", Tags: []string{"reading"}, ID: 1, SavedAt: 100}),
+		store.Mute(t.Context(), []int{2}, testdata.MaxTime),
+		store.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corruptItemTable(t, db)
+
+	_, err = exec(t, "--cache-path", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := filepath.Glob(db + ".corrupt-*")
+	if err != nil || len(moved) != 1 {
+		t.Fatalf("expected the corrupt cache to be moved aside, got %v: %v", moved, err)
+	}
+
+	store, err = unl.OpenStore(t.Context(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	bookmarks, err := store.Bookmarks(t.Context(), "")
+	if err != nil || len(bookmarks) != 1 || bookmarks[0].Note != "later" {
+		t.Fatalf("expected the bookmark recovered from the moved cache, got %v: %v", bookmarks, err)
+	}
+
+	muted, err := store.Muted(t.Context())
+	if err != nil || !slices.Equal(muted, []int{2}) {
+		t.Fatalf("expected the mute recovered from the moved cache, got %v: %v", muted, err)
+	}
+}
+
+// corruptItemTable overwrites the root page of the item table in the SQLite file at path, leaving the other tables.
+func corruptItemTable(t *testing.T, path string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pageSize, rootPage int64
+
+	err = errors.Join(
+		db.QueryRowContext(t.Context(), "PRAGMA page_size").Scan(&pageSize),
+		db.QueryRowContext(t.Context(), "SELECT rootpage FROM sqlite_master WHERE name = 'item'").Scan(&rootPage),
+		db.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, int(pageSize)), (rootPage-1)*pageSize)
+	if err != nil {
+		t.Fatal(errors.Join(err, f.Close()))
+	}
+
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheName(t *testing.T) {
+	_, err := exec(t, "--cache-name", "interactive", "--no-cache")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with --no-cache, got %v", err)
+	}
+
+	_, err = exec(t, "--cache-name", "a/b")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args with a path as a name, got %v", err)
+	}
+
+	out, err := exec(t, "--cache-name", "interactive")
+	if err != nil || len(out) == 0 {
+		t.Fatalf("expected output with a named cache: %v", err)
+	}
+}
+
+func TestSource(t *testing.T) {
+	_, err := exec(t, "--source", "lobsters")
+	if !errors.Is(err, errInvalidArgs) {
+		t.Fatalf("expected invalid args for an unknown source, got %v", err)
+	}
+
+	// the test data only serves the paths of the HN API, so the translated Algolia paths are not found
+	_, err = exec(t, "--source", "algolia", "--no-cache")
+	if !errors.Is(err, testdata.ErrNotFound) {
+		t.Fatalf("expected the Algolia paths to be requested, got %v", err)
+	}
+}
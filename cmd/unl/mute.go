@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+)
+
+func muteCmd(clock core.Clock, cachePath *string, noCache *bool) *cobra.Command {
+	var (
+		list  bool
+		clear bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "mute [id...]",
+		Short: "Hide threads from unl output and watch events",
+		Long: "Mutes items so they no longer appear in unl output or watch events. Muting a story hides the whole\n" +
+			"discussion; muting a comment hides it and its replies, which then stop counting toward the activity\n" +
+			"of the thread. Mutes are kept in the cache file.",
+		Example: "  unl mute 43740065\n" +
+			"  unl mute --list\n" +
+			"  unl mute --clear",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *noCache {
+				return fmt.Errorf("%w: mute cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			if list && clear || (list || clear) == (len(args) > 0) {
+				return fmt.Errorf("%w: provide item IDs, --list, or --clear", errInvalidArgs)
+			}
+
+			ids := make([]int, len(args))
+
+			for i, arg := range args {
+				id, err := strconv.Atoi(arg)
+				if err != nil || id <= 0 {
+					return fmt.Errorf("%w: invalid item id: %s", errInvalidArgs, arg)
+				}
+
+				ids[i] = id
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			return runMute(cmd.Context(), *cachePath, ids, list, clear, clock)
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", false, "list the muted item IDs, most recently muted first")
+	cmd.Flags().BoolVar(&clear, "clear", false, "unmute all items")
+
+	return cmd
+}
+
+func runMute(ctx context.Context, cachePath string, ids []int, list bool, clear bool, clock core.Clock) (err error) {
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	switch {
+	case clear:
+		err = store.ClearMuted(ctx)
+	case list:
+		ids, err = store.Muted(ctx)
+		for _, id := range ids {
+			if err == nil {
+				_, err = fmt.Fprintln(os.Stdout, id)
+			}
+		}
+	default:
+		err = store.Mute(ctx, ids, clock.Now())
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to update mutes: %w", err)
+	}
+
+	return nil
+}
+
+// getMutedOption returns an option hiding the items muted in the store, or nil without a cache.
+func getMutedOption(ctx context.Context, cachePath string) (_ []unl.Option, err error) {
+	if cachePath == "" {
+		return nil, nil
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	muted, err := store.Muted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list muted items: %w", err)
+	}
+
+	if len(muted) == 0 {
+		return nil, nil
+	}
+
+	return []unl.Option{unl.WithMuted(muted...)}, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"github.com/spf13/cobra"
+)
+
+func openCmd(
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	maxWidth int,
+	cachePath *string,
+	noCache *bool,
+	noColor *bool,
+) *cobra.Command {
+	var childOrder string
+
+	cmd := &cobra.Command{
+		Use:   "open <id or url>",
+		Short: "Show the thread under an item or news.ycombinator.com URL",
+		Long: "Shows an item and all of its replies. Accepts an ID or a news.ycombinator.com URL as pasted from a\n" +
+			"browser; the #anchor of a comment's context link selects that comment, so only its subthread is shown.",
+		Example: "  unl open 43727393\n" +
+			"  unl open \"https://news.ycombinator.com/item?id=43727157#43727393\"",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := unl.ParseItemRef(args[0])
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidArgs, err)
+			}
+
+			order, err := unl.ParseChildOrder(childOrder)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errInvalidArgs, err)
+			}
+
+			return runOpen(cmd.Context(), getter, clock, *cachePath, *noCache, id, order, *noColor, maxWidth)
+		},
+	}
+
+	cmd.Flags().StringVar(&childOrder, "child-order", "time", "order of replies: time, kids (as on the site), or score")
+
+	return cmd
+}
+
+func runOpen(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	noCache bool,
+	id int,
+	order unl.ChildOrder,
+	noColor bool,
+	maxWidth int,
+) error {
+	if noCache {
+		cachePath = ""
+	}
+
+	client, err := createClient(ctx, cachePath, getter, clock, false)
+	if err != nil {
+		return err
+	}
+	defer closeClient(ctx, client)
+
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	items, err := client.GetItems(ctx, []int{id})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve item: %w", err)
+	}
+
+	root := items[id]
+	if root.Type == hn.NullBody {
+		return fmt.Errorf("%w: item %d not found", errInvalidArgs, id)
+	}
+
+	descendants, err := client.GetDescendants(ctx, items)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve replies: %w", err)
+	}
+
+	allByParent, _, err := descendants.GroupByParent()
+	if err != nil {
+		return fmt.Errorf("failed to group replies: %w", err)
+	}
+
+	// every item counts as active so the text of all replies is shown, not only the recent ones
+	r := render.NewTreeRenderer(render.Options{
+		Now:            now,
+		ActiveAfter:    time.Time{},
+		NewAfter:       time.Time{},
+		Theme:          getTheme(noColor),
+		EffectiveTimes: nil,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          getStyle(ctx),
+		MaxWidth:       maxWidth,
+		MaxByWidth:     getMaxByWidth(ctx),
+		ChildOrder:     order,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	var buf bytes.Buffer
+
+	err = r.Render(&buf, render.Thread{Root: root, ByParent: allByParent, Orphaned: false})
+	if err != nil {
+		return fmt.Errorf("failed to render thread: %w", err)
+	}
+
+	return getPager(ctx).Write(ctx, &buf)
+}
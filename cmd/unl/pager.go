@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jasonthorsness/unlurker/unl"
+)
+
+func newPager(mode string, height int, getenv func(string) string, out io.Writer) (*unl.Pager, error) {
+	p, err := unl.NewPager(mode, height, getenv, out)
+	if err != nil {
+		return nil, fmt.Errorf("%w: --pager %w", errInvalidArgs, err)
+	}
+
+	return p, nil
+}
+
+type pagerContextKey struct{}
+
+// getPager returns the pager of the command, or one writing directly to stdout if there is none.
+func getPager(ctx context.Context) *unl.Pager {
+	p, ok := ctx.Value(pagerContextKey{}).(*unl.Pager)
+	if !ok {
+		p, _ = unl.NewPager(unl.PagerNever, 0, os.Getenv, os.Stdout)
+	}
+
+	return p
+}
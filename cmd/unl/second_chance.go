@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"github.com/spf13/cobra"
+)
+
+func secondChanceCmd(
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	maxWidth int,
+	cachePath *string,
+	noCache *bool,
+	noColor *bool,
+) *cobra.Command {
+	var since string
+
+	cmd := &cobra.Command{
+		Use:   "second-chance",
+		Short: "List articles recently picked from the second-chance pool",
+		Long: "Lists articles that unl saw on the front page with a reset time, meaning they were picked from the\n" +
+			"second-chance pool. Detections are recorded in the cache file each time unl runs.",
+		Example: "  unl second-chance --since 7d",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if *noCache {
+				return fmt.Errorf("%w: second-chance cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			d, err := parseSince(since)
+			if err != nil {
+				return err
+			}
+
+			return runSecondChance(cmd.Context(), getter, clock, *cachePath, d, *noColor, maxWidth)
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "7d", "how far back to list, as a duration or a number of days like 7d")
+
+	return cmd
+}
+
+// parseSince parses a duration, also accepting a whole number of days like "7d".
+func parseSince(v string) (time.Duration, error) {
+	const day = 24 * time.Hour
+
+	days, ok := strings.CutSuffix(v, "d")
+	if ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return time.Duration(n) * day, nil
+		}
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: invalid --since: %s", errInvalidArgs, v)
+	}
+
+	return d, nil
+}
+
+func runSecondChance(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	since time.Duration,
+	noColor bool,
+	maxWidth int,
+) (err error) {
+	client, err := createClient(ctx, cachePath, getter, clock, false)
+	if err != nil {
+		return err
+	}
+	defer closeClient(ctx, client)
+
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	picks, err := store.SecondChances(ctx, now.Add(-since))
+	if err != nil {
+		return fmt.Errorf("failed to list second-chance articles: %w", err)
+	}
+
+	ids := make([]int, len(picks))
+	for i, pick := range picks {
+		ids[i] = pick.ID
+	}
+
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve items: %w", err)
+	}
+
+	r := render.NewTreeRenderer(render.Options{
+		Now:            now,
+		ActiveAfter:    now,
+		NewAfter:       time.Time{},
+		Theme:          getTheme(noColor),
+		EffectiveTimes: nil,
+		Ranks:          nil,
+		Truncated:      nil,
+		Style:          getStyle(ctx),
+		MaxWidth:       maxWidth,
+		MaxByWidth:     getMaxByWidth(ctx),
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   0,
+		Boundary:       hn.BoundaryInclusive,
+	})
+
+	lines := make([]render.Line, 0, len(picks))
+
+	for _, pick := range picks {
+		reset := unl.PrettyFormatDuration(time.Unix(pick.ApparentTime, 0).Sub(time.Unix(pick.Time, 0)))
+		text := "(reset " + reset + ") " + unl.PrettyFormatTitle(items[pick.ID], true)
+
+		lines = append(lines, render.Line{
+			Time: time.Unix(pick.DetectedAt, 0), By: items[pick.ID].By, Text: text, ID: pick.ID,
+		})
+	}
+
+	var buf bytes.Buffer
+
+	err = r.RenderLines(&buf, lines...)
+	if err != nil {
+		return fmt.Errorf("failed to render second chances: %w", err)
+	}
+
+	return getPager(ctx).Write(ctx, &buf)
+}
+
+// recordSecondChances stores the front page articles with adjusted times for "unl second-chance". Only the articles
+// whose apparent time differs from their time are retrieved, which is usually none of them.
+func recordSecondChances(
+	ctx context.Context,
+	client *hn.Client,
+	cachePath string,
+	frontPage *unl.FrontPage,
+	now time.Time,
+) (err error) {
+	var ids []int
+
+	for _, e := range frontPage.Entries {
+		if e.ApparentTime != e.Time {
+			ids = append(ids, e.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve front page items: %w", err)
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	err = store.RecordSecondChances(ctx, items, frontPage.Times(), now)
+	if err != nil {
+		return fmt.Errorf("failed to record second-chance articles: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl"
+)
+
+// snapshotKey describes the parameters that select the result of unl.FindActive, so only identical runs can share a
+// snapshot. Front page times are hashed since they move roots in and out of --max-age.
+func snapshotKey(
+	source hn.Source,
+	strategy unl.ActivityStrategy,
+	window time.Duration,
+	maxAge time.Duration,
+	minBy int,
+	minActivity float64,
+	limit int,
+	types []string,
+	focus []unl.RootType,
+	orphans bool,
+	maxTreeSize int,
+	effectiveTimes unl.EffectiveTimes,
+) string {
+	ids := make([]int, 0, len(effectiveTimes))
+	for id := range effectiveTimes {
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+
+	h := fnv.New64a()
+	for _, id := range ids {
+		_, _ = fmt.Fprintf(h, "%d:%d,", id, effectiveTimes[id])
+	}
+
+	return fmt.Sprintf(
+		"source=%s strategy=%s window=%s max-age=%s min-by=%d min-activity=%g limit=%d types=%s focus=%v orphans=%t "+
+			"max-tree-size=%d front-page=%x",
+		source.Name, strategy.Name(), window, maxAge, minBy, minActivity, limit, strings.Join(types, ","), focus, orphans,
+		maxTreeSize, h.Sum64())
+}
+
+// getActiveReusingSnapshot returns the snapshot of an identical run within reuseWithin of now if none of its items
+// have changed since, and otherwise calls getActive and saves its result as the snapshot for the next run. Results
+// with trees added to truncated by getActive are not saved, since a snapshot would lose their markers, and neither are
+// partial results, which are returned along with their error.
+func getActiveReusingSnapshot(
+	ctx context.Context,
+	client hn.API,
+	cachePath string,
+	key string,
+	now time.Time,
+	reuseWithin time.Duration,
+	truncated hn.Truncated,
+	getActive func() ([]*hn.Item, map[int]hn.ItemSet, error),
+) (_ []*hn.Item, _ map[int]hn.ItemSet, err error) {
+	if cachePath == "" || reuseWithin <= 0 {
+		return getActive()
+	}
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	snapshot, err := store.ActiveSnapshot(ctx, key, now.Add(-reuseWithin))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+
+	if snapshot != nil {
+		changed, err := snapshot.Changed(ctx, client)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check snapshot: %w", err)
+		}
+
+		if !changed {
+			items, allByParent, err := snapshot.Result()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read snapshot: %w", err)
+			}
+
+			return items, allByParent, nil
+		}
+	}
+
+	items, allByParent, err := getActive()
+	if errors.Is(err, hn.ErrPartialResult) {
+		return items, allByParent, err
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(truncated) > 0 {
+		return items, allByParent, nil
+	}
+
+	err = store.SaveActiveSnapshot(ctx, key, unl.NewActiveSnapshot(items, allByParent, now), now.Add(-reuseWithin))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return items, allByParent, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jasonthorsness/unlurker/unl/render"
+)
+
+type styleContextKey struct{}
+
+func getStyle(ctx context.Context) render.Style {
+	style, ok := ctx.Value(styleContextKey{}).(render.Style)
+	if !ok {
+		return render.UnicodeStyle
+	}
+
+	return style
+}
+
+// getTheme returns the theme of the pretty output, or nil for no color.
+func getTheme(noColor bool) *render.Theme {
+	if noColor {
+		return nil
+	}
+
+	return &render.DefaultTheme
+}
+
+type maxByWidthContextKey struct{}
+
+// getMaxByWidth returns the --max-by-width of the pretty output.
+func getMaxByWidth(ctx context.Context) int {
+	width, ok := ctx.Value(maxByWidthContextKey{}).(int)
+	if !ok {
+		return render.DefaultMaxByWidth
+	}
+
+	return width
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/spf13/cobra"
+)
+
+func tuneCmd(
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath *string,
+	noCache *bool,
+) *cobra.Command {
+	var (
+		windows     []time.Duration
+		minBys      []int
+		types       []string
+		maxAge      time.Duration
+		minActivity float64
+		proxy       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "tune",
+		Short: "Count active discussions for combinations of --window and --min-by",
+		Long: "Prints a matrix of how many discussions unl would list for each window (rows) and minimum count of\n" +
+			"contributors (columns), to help pick thresholds. Items are retrieved once for the longest window and\n" +
+			"every combination is counted from them, rather than running unl for each.",
+		Example: "  unl tune --windows 15m,30m,1h --min-bys 2,3,5\n" +
+			"  unl tune --max-age 24h --types ask,show --min-activity 1.5",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if len(windows) == 0 || len(minBys) == 0 {
+				return fmt.Errorf("%w: --windows and --min-bys must not be empty", errInvalidArgs)
+			}
+
+			for _, window := range windows {
+				if window <= 0 {
+					return fmt.Errorf("%w: --windows must be positive", errInvalidArgs)
+				}
+			}
+
+			options, err := getActiveOptions(minActivity, types)
+			if err != nil {
+				return err
+			}
+
+			fetchFrontPage, err := getFrontPageFetcher(proxy)
+			if err != nil {
+				return err
+			}
+
+			path := *cachePath
+			if *noCache {
+				path = ""
+			}
+
+			t := tune{windows, minBys, options, maxAge}
+
+			return t.run(cmd.Context(), getter, clock, path, fetchFrontPage)
+		},
+	}
+
+	cmd.Flags().DurationSliceVar(&windows, "windows", []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour},
+		"time windows for activity to compare")
+	cmd.Flags().IntSliceVar(&minBys, "min-bys", []int{2, 3, 5}, "minimum counts of unique contributors to compare")
+	cmd.Flags().DurationVar(&maxAge, "max-age", defaultMaxAge, "maximum age for items")
+	cmd.Flags().Float64Var(&minActivity, "min-activity", 0, "minimum activity score, as for unl (0 to disable)")
+	cmd.Flags().StringSliceVar(&types, "types", nil, "only include roots of these types: story, ask, show, job, poll")
+	cmd.Flags().StringVar(&proxy, "proxy", "", "HTTP or SOCKS5 proxy URL for scraping the front page")
+
+	return cmd
+}
+
+type tune struct {
+	windows []time.Duration
+	minBys  []int
+	options []unl.Option
+	maxAge  time.Duration
+}
+
+func (t *tune) run(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	fetchFrontPage func(context.Context, time.Time) (*unl.FrontPage, error),
+) error {
+	client, err := createClient(ctx, cachePath, getter, clock, false)
+	if err != nil {
+		return err
+	}
+	defer closeClient(ctx, client)
+
+	muted, err := getMutedOption(ctx, cachePath)
+	if err != nil {
+		return err
+	}
+
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	var frontPageTimes unl.EffectiveTimes
+
+	frontPage, err := fetchFrontPage(ctx, now)
+	if err != nil {
+		_, err = fmt.Fprintf(os.Stderr, "\nWarning: Failed to adjust times for second-chance articles: %v\n", err)
+		if err != nil {
+			return fmt.Errorf("failed to write warning: %w", err)
+		}
+	} else {
+		frontPageTimes = frontPage.Times()
+	}
+
+	counts, err := unl.CountActive(
+		ctx, client, frontPageTimes, now, now.Add(-t.maxAge), t.windows, t.minBys, append(t.options, muted...)...)
+	if err != nil {
+		return fmt.Errorf("failed to count active discussions: %w", err)
+	}
+
+	return getPager(ctx).Write(ctx, t.matrix(counts))
+}
+
+// matrix lays out the counts with a row per window and a column per minimum count of contributors.
+func (t *tune) matrix(counts [][]int) *bytes.Buffer {
+	const corner = "window\\min-by"
+
+	rows := make([][]string, 1+len(t.windows))
+
+	rows[0] = []string{corner}
+	for _, minBy := range t.minBys {
+		rows[0] = append(rows[0], strconv.Itoa(minBy))
+	}
+
+	for i, window := range t.windows {
+		rows[i+1] = []string{unl.PrettyFormatDuration(window)}
+		for _, count := range counts[i] {
+			rows[i+1] = append(rows[i+1], strconv.Itoa(count))
+		}
+	}
+
+	widths := make([]int, len(rows[0]))
+
+	for _, row := range rows {
+		for j, cell := range row {
+			widths[j] = max(widths[j], len(cell))
+		}
+	}
+
+	var buf bytes.Buffer
+
+	for _, row := range rows {
+		for j, cell := range row {
+			if j > 0 {
+				buf.WriteByte(' ')
+			}
+
+			for range widths[j] - len(cell) {
+				buf.WriteByte(' ')
+			}
+
+			buf.WriteString(cell)
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	return &buf
+}
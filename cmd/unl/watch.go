@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/unl"
+	"github.com/jasonthorsness/unlurker/unl/render"
+	"github.com/spf13/cobra"
+)
+
+func watchCmd(
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	maxWidth int,
+	cachePath *string,
+	noCache *bool,
+	noColor *bool,
+) *cobra.Command {
+	const defaultInterval = time.Minute
+
+	var (
+		w          watch
+		interval   time.Duration
+		polls      int
+		resetState bool
+		quietHours string
+		maxPerHour int
+		live       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Report active discussions as they start, change, and end",
+		Long: "Polls for active discussions and writes an event for each thread that was added, removed, or\n" +
+			"changed since the previous poll:\n" +
+			"  {\"now\":\"...\",\"event\":\"changed\",\"id\":N,\"title\":\"...\",\"metrics\":[\"comments\"],...}\n" +
+			"The last-seen threads are kept in the cache file, so a restarted watch reports only what changed\n" +
+			"while it was stopped. Use --reset-state to report every active thread again.\n" +
+			"Events during --quiet-hours or beyond --max-per-hour are dropped, and the next event written\n" +
+			"has a \"suppressed\" count of the events dropped before it.\n" +
+			"With --live, the active discussions are instead redrawn in place after each poll as unl shows them,\n" +
+			"fitting the current width of the terminal as it is resized.",
+		Example: "  unl watch --window 1h --min-by 5\n" +
+			"  unl watch --reset-state --polls 1\n" +
+			"  unl watch --quiet-hours 22:00-07:00 --max-per-hour 10\n" +
+			"  unl watch --live --interval 30s",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if *noCache {
+				return fmt.Errorf("%w: watch cannot be used with --no-cache", errInvalidArgs)
+			}
+
+			if polls < 0 || interval <= 0 {
+				return fmt.Errorf("%w: --polls must not be negative and --interval must be positive", errInvalidArgs)
+			}
+
+			if maxPerHour < 0 {
+				return fmt.Errorf("%w: --max-per-hour must not be negative", errInvalidArgs)
+			}
+
+			var quiet *unl.QuietHours
+
+			if quietHours != "" {
+				q, err := unl.ParseQuietHours(quietHours)
+				if err != nil {
+					return fmt.Errorf("%w: %w", errInvalidArgs, err)
+				}
+
+				quiet = &q
+			}
+
+			w.throttle = unl.NewThrottle(quiet, maxPerHour)
+
+			if live {
+				if quiet != nil || maxPerHour > 0 || resetState {
+					return fmt.Errorf("%w: --live cannot be used with --quiet-hours, --max-per-hour, or --reset-state",
+						errInvalidArgs)
+				}
+
+				ctx := cmd.Context()
+				w.live = newLiveView(os.Stdout, terminalWidth(maxWidth), render.Options{
+					Now:            time.Time{},
+					ActiveAfter:    time.Time{},
+					NewAfter:       time.Time{},
+					Theme:          getTheme(*noColor),
+					EffectiveTimes: nil,
+					Ranks:          nil,
+					Truncated:      nil,
+					Style:          getStyle(ctx),
+					MaxWidth:       0,
+					MaxByWidth:     getMaxByWidth(ctx),
+					ChildOrder:     unl.ChildOrderTime,
+					PreviewLines:   0,
+					Boundary:       hn.BoundaryInclusive,
+				})
+			}
+
+			if clock == nil {
+				clock = core.NewClock()
+			}
+
+			return w.run(cmd.Context(), getter, clock, *cachePath, interval, polls, resetState)
+		},
+	}
+
+	cmd.Flags().DurationVar(&w.maxAge, "max-age", defaultMaxAge, "maximum age for items")
+	cmd.Flags().DurationVar(&w.window, "window", defaultWindow, "time window for activity")
+	cmd.Flags().IntVar(&w.minBy, "min-by", defaultMinBy, "minimum count of unique contributors to activity")
+	cmd.Flags().DurationVar(&interval, "interval", defaultInterval, "time between polls")
+	cmd.Flags().IntVar(&polls, "polls", 0, "stop after this many polls (0 to run until interrupted)")
+	cmd.Flags().BoolVar(&resetState, "reset-state", false, "forget the threads seen by previous runs")
+	cmd.Flags().StringVar(&quietHours, "quiet-hours", "", "local time span without events, like 22:00-07:00")
+	cmd.Flags().IntVar(&maxPerHour, "max-per-hour", 0, "maximum events per hour (0 for no limit)")
+	cmd.Flags().BoolVar(&live, "live", false, "redraw the active discussions in place after each poll instead of events")
+
+	return cmd
+}
+
+type watch struct {
+	throttle *unl.Throttle
+	live     *liveView
+	maxAge   time.Duration
+	window   time.Duration
+	minBy    int
+}
+
+// watchEvent is written for each added, removed, or changed thread, with the URL of its discussion. The counts are
+// from the latest poll, or from the last poll that saw the thread if it was removed. Suppressed counts the events
+// throttled since the last event written. Now is the effective current time of the poll, against which the thread was
+// found active.
+type watchEvent struct {
+	Now        time.Time          `json:"now"`
+	Event      string             `json:"event"`
+	Title      string             `json:"title"`
+	URL        string             `json:"url"`
+	Metrics    []unl.ThreadMetric `json:"metrics,omitempty"`
+	ID         int                `json:"id"`
+	Comments   int                `json:"comments"`
+	Active     int                `json:"active"`
+	Users      int                `json:"users"`
+	Suppressed int                `json:"suppressed,omitempty"`
+}
+
+func newWatchEvent(now time.Time, event string, thread *unl.ActiveThread, metrics []unl.ThreadMetric) watchEvent {
+	return watchEvent{
+		now, event, thread.Root.Title, hn.ItemURL(thread.ID()), metrics, thread.ID(), thread.Comments, thread.Active,
+		thread.Users, 0,
+	}
+}
+
+func (w *watch) run(
+	ctx context.Context,
+	getter core.Getter[string, io.ReadCloser],
+	clock core.Clock,
+	cachePath string,
+	interval time.Duration,
+	polls int,
+	resetState bool,
+) (err error) {
+	client, err := createClient(ctx, cachePath, getter, clock, false)
+	if err != nil {
+		return err
+	}
+	defer closeClient(ctx, client)
+
+	store, err := unl.OpenStore(ctx, cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	if resetState {
+		err = store.ResetWatchState(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to reset watch state: %w", err)
+		}
+	}
+
+	prev, err := store.WatchState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load watch state: %w", err)
+	}
+
+	if w.live != nil {
+		defer w.live.listen()()
+	}
+
+	for poll := 0; polls == 0 || poll < polls; poll++ {
+		if poll > 0 {
+			err = core.Sleep(ctx, clock, interval)
+			if err != nil {
+				return err
+			}
+		}
+
+		prev, err = w.poll(ctx, client, store, prev)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// poll writes the events for the difference between prev and the current active threads and saves the current
+// threads as the new state.
+func (w *watch) poll(
+	ctx context.Context,
+	client *hn.Client,
+	store *unl.Store,
+	prev []*unl.ActiveThread,
+) ([]*unl.ActiveThread, error) {
+	now, _, err := client.Now(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current time: %w", err)
+	}
+
+	// mutes are reloaded each poll so muting takes effect without restarting the watch
+	muted, err := store.Muted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list muted items: %w", err)
+	}
+
+	activeAfter := now.Add(-w.window)
+
+	items, allByParent, err := unl.GetActive(
+		ctx, client, nil, activeAfter, now.Add(-w.maxAge), w.minBy, 0, unl.WithMuted(muted...))
+	if err != nil {
+		return nil, err
+	}
+
+	// the live view shows the threads themselves, so the state of events is left for the next watch without --live
+	if w.live != nil {
+		return prev, w.refreshLive(ctx, store, now, activeAfter, items, allByParent)
+	}
+
+	next := unl.NewActiveThreads(items, allByParent, activeAfter, hn.BoundaryInclusive)
+	d := unl.Diff(prev, next)
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	var events []watchEvent
+	for _, thread := range d.Added {
+		events = append(events, newWatchEvent(now, "added", thread, nil))
+	}
+
+	for _, change := range d.Changed {
+		events = append(events, newWatchEvent(now, "changed", change.Next, change.Metrics))
+	}
+
+	for _, thread := range d.Removed {
+		events = append(events, newWatchEvent(now, "removed", thread, nil))
+	}
+
+	for _, event := range events {
+		var allowed bool
+
+		allowed, event.Suppressed = w.throttle.Allow(now)
+		if !allowed {
+			continue
+		}
+
+		err = encoder.Encode(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+
+	err = store.SaveWatchState(ctx, next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save watch state: %w", err)
+	}
+
+	return next, nil
+}
+
+// refreshLive draws the active threads with the items that appeared since the previous refresh, even one by an
+// earlier watch, marked as new.
+func (w *watch) refreshLive(
+	ctx context.Context,
+	store *unl.Store,
+	now time.Time,
+	activeAfter time.Time,
+	items []*hn.Item,
+	allByParent map[int]hn.ItemSet,
+) error {
+	refreshed, err := store.WatchRefreshed(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load watch refresh time: %w", err)
+	}
+
+	err = w.live.update(now, activeAfter, refreshed, items, allByParent)
+	if err != nil {
+		return err
+	}
+
+	err = store.SaveWatchRefreshed(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to save watch refresh time: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package hn
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// activeProbeCount is the number of evenly spaced items retrieved in each round of bisecting the active boundary.
+const activeProbeCount = 8
+
+// probeActiveBoundary looks for the oldest item active by time within span items of maxID, so a sparse window can be
+// scanned without the read-ahead of a full scan retrieving up to span inactive items below it. Items at exponentially
+// growing distances from maxID are retrieved together, then the gap between the nearest inactive one and the active
+// one before it is bisected, activeProbeCount items at a time. It returns the distances from maxID of the oldest item
+// found active and of the newest found inactive, which is -1 and 0 if maxID itself is inactive, or ok false if every
+// probe was active, as when the window extends beyond span and a full scan is needed anyway. Items with a null body,
+// typical for the newest ones, can't be dated, so they bound neither side; ok is also false if they leave the gap
+// impossible to narrow.
+func (c *Client) probeActiveBoundary(
+	ctx context.Context,
+	scan *activeScan,
+	maxID int,
+	activeAfter time.Time,
+	span int,
+) (int, int, bool, error) {
+	span = min(span, maxID-1)
+
+	var offsets []int
+	for offset := 0; offset < span; offset = 2*offset + 1 {
+		offsets = append(offsets, offset)
+	}
+
+	offsets = append(offsets, span)
+
+	lo, hi, err := c.probeActiveOffsets(ctx, scan, maxID, activeAfter, offsets, -1, span+1)
+	if err != nil || hi > span {
+		return 0, 0, false, err
+	}
+
+	for hi-lo > 1 {
+		offsets = offsets[:0]
+
+		for i := 1; i <= activeProbeCount; i++ {
+			offset := lo + i*(hi-lo)/(activeProbeCount+1)
+			if offset > lo && offset < hi && !slices.Contains(offsets, offset) {
+				offsets = append(offsets, offset)
+			}
+		}
+
+		if len(offsets) == 0 {
+			offsets = append(offsets, lo+1)
+		}
+
+		prevLo, prevHi := lo, hi
+
+		lo, hi, err = c.probeActiveOffsets(ctx, scan, maxID, activeAfter, offsets, lo, hi)
+		if err != nil || (lo == prevLo && hi == prevHi) {
+			return 0, 0, false, err
+		}
+	}
+
+	return lo, hi, true, nil
+}
+
+// probeActiveOffsets retrieves the items at the ascending offsets below maxID and narrows lo, the offset of an item
+// active by time, and hi, the offset of an inactive one, to the gap around the first inactive item. Items that can't
+// be dated are skipped.
+func (c *Client) probeActiveOffsets(
+	ctx context.Context,
+	scan *activeScan,
+	maxID int,
+	activeAfter time.Time,
+	offsets []int,
+	lo int,
+	hi int,
+) (int, int, error) {
+	ids := make([]int, len(offsets))
+	for i, offset := range offsets {
+		ids[i] = maxID - offset
+	}
+
+	items, err := c.GetItems(ctx, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, id := range ids {
+		scan.examine(id)
+	}
+
+	for _, offset := range offsets {
+		item := items[maxID-offset]
+		if item.Type == NullBody || item.Time == 0 {
+			continue
+		}
+
+		if !BoundaryInclusive.After(item.Time, activeAfter) {
+			return lo, offset, nil
+		}
+
+		lo = offset
+	}
+
+	return lo, hi, nil
+}
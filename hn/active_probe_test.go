@@ -0,0 +1,227 @@
+package hn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// corpusGetter serves a synthetic corpus of items by ID. Missing items are null, as from the API.
+type corpusGetter struct {
+	items ItemSet
+}
+
+func (g *corpusGetter) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, itemPathPrefix), ".json"))
+	if err != nil || !strings.HasPrefix(path, itemPathPrefix) {
+		return nil, testdata.ErrNotFound
+	}
+
+	item, ok := g.items[id]
+	if !ok {
+		return io.NopCloser(strings.NewReader("null")), nil
+	}
+
+	raw, err := item.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// newCorpus returns n items created gap apart on average, in ID order as on HN, where most are replies to an earlier
+// item that can be far older and some are dead or deleted.
+func newCorpus(seed uint64, n int, gap time.Duration) ItemSet {
+	r := rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // G404 deterministic corpus
+	items := make(ItemSet, n)
+	created := int64(1_000_000)
+
+	for id := 1; id <= n; id++ {
+		created += r.Int64N(2*int64(gap/time.Second) + 1)
+
+		item := &Item{
+			Parent:      nil,
+			Poll:        nil,
+			By:          "user" + strconv.Itoa(r.IntN(50)),
+			Text:        "",
+			Title:       "",
+			URL:         "",
+			Type:        Story,
+			Kids:        nil,
+			Parts:       nil,
+			Time:        created,
+			Descendants: 0,
+			ID:          id,
+			Score:       0,
+			Dead:        r.IntN(20) == 0,
+			Deleted:     r.IntN(30) == 0,
+		}
+
+		const replyShare = 4
+		if id > 1 && r.IntN(replyShare+1) != 0 {
+			parent := 1 + r.IntN(id-1)
+			item.Parent = &parent
+			item.Type = Comment
+		}
+
+		items[id] = item
+	}
+
+	return items
+}
+
+// expectedActive returns the IDs of the items GetActive should return: those active after activeAfter with their
+// ancestors.
+func expectedActive(items ItemSet, activeAfter time.Time) []int {
+	result := make(ItemSet)
+
+	for _, item := range items {
+		if item.Dead || item.Deleted || !BoundaryInclusive.After(item.Time, activeAfter) {
+			continue
+		}
+
+		for current := item; ; current = items[*current.Parent] {
+			result[current.ID] = current
+
+			if current.Parent == nil {
+				break
+			}
+		}
+	}
+
+	return result.IDs()
+}
+
+func TestGetActiveProbing(t *testing.T) {
+	t.Parallel()
+
+	const n = 5000
+
+	for _, corpus := range []struct {
+		name string
+		gap  time.Duration
+	}{
+		{"quiet", time.Minute},
+		{"busy", time.Second},
+	} {
+		items := newCorpus(uint64(corpus.gap), n, corpus.gap)
+		latest := time.Unix(items[n].Time, 0)
+
+		for _, window := range []time.Duration{0, time.Second, time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour} {
+			client, err := NewClient(
+				t.Context(),
+				WithFileCachePath(""),
+				WithMaxConnections(50),
+				WithGetter(&corpusGetter{items}),
+				WithClock(testdata.Clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			activeAfter := latest.Add(-window)
+
+			all, _, err := client.GetActiveCapped(t.Context(), n, activeAfter, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_ = client.Close()
+
+			expected := expectedActive(items, activeAfter)
+			if actual := all.IDs(); !slices.Equal(actual, expected) {
+				t.Fatalf("%s %s: expected %d items, got %d", corpus.name, window, len(expected), len(actual))
+			}
+
+			// a sparse window costs about its own items plus the probes, rather than a read-ahead of 100
+			inWindow := 0
+			for _, item := range items {
+				if BoundaryInclusive.After(item.Time, activeAfter) {
+					inWindow++
+				}
+			}
+
+			// everything the client retrieved was for the scan
+			stats := client.Stats()
+			if scans := stats.Scans; scans.Scans != 1 || scans.Requests != stats.Requests ||
+				scans.Items != stats.Items || scans.Examined < int64(len(all)) ||
+				(len(all) > 0 && scans.OldestID > all.IDs()[len(all)-1]) {
+				t.Errorf("%s %s: unexpected scan stats %+v for %+v", corpus.name, window, scans, stats)
+			}
+
+			const probeAllowance = 40
+			if requests := stats.Requests; inWindow < 50 && requests > int64(len(all)+probeAllowance) {
+				t.Errorf("%s %s: expected about %d requests, got %d", corpus.name, window, len(all), requests)
+			}
+		}
+	}
+}
+
+func TestGetActiveNullAtMaxID(t *testing.T) {
+	t.Parallel()
+
+	const n = 5000
+
+	items := newCorpus(uint64(time.Minute), n, time.Minute)
+	latest := time.Unix(items[n].Time, 0)
+
+	for _, window := range []time.Duration{time.Minute, time.Hour} {
+		expected := expectedActive(items, latest.Add(-window))
+
+		// the newest items are often null for a moment after maxitem moves past them
+		for _, nulls := range []int{1, 3} {
+			client, err := NewClient(
+				t.Context(),
+				WithFileCachePath(""),
+				WithGetter(&corpusGetter{items}),
+				WithClock(testdata.Clock))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			all, err := client.GetActive(t.Context(), n+nulls, latest.Add(-window))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_ = client.Close()
+
+			if actual := all.IDs(); !slices.Equal(actual, expected) {
+				t.Fatalf("%s with %d nulls: expected %d items, got %d", window, nulls, len(expected), len(actual))
+			}
+		}
+	}
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	expected, err := client.GetActive(t.Context(), testdata.MaxItem, testdata.MaxTime.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := client.GetActive(t.Context(), testdata.MaxItem+1, testdata.MaxTime.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expected) == 0 || len(actual) != len(expected) {
+		t.Fatalf("expected %d items with a null body at maxID, got %d", len(expected), len(actual))
+	}
+}
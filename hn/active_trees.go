@@ -0,0 +1,116 @@
+package hn
+
+import "errors"
+
+// ErrPartialResult is wrapped by the error of a retrieval that ended early, such as at the deadline of its context,
+// returned along with what was retrieved so far.
+var ErrPartialResult = errors.New("partial result")
+
+// Truncated counts the active items omitted from each tree by GetActiveCapped, by the ID of its root.
+type Truncated map[int]int
+
+// activeTrees groups the items retrieved by GetActiveCapped into trees before their roots are known, as a union-find
+// over item IDs joined to the IDs of their parents, so the size of the tree of each item can be capped.
+type activeTrees struct {
+	parent      map[int]int
+	size        map[int]int
+	omitted     map[int]int
+	omittedIDs  map[int]struct{}
+	maxTreeSize int
+}
+
+func newActiveTrees(maxTreeSize int) *activeTrees {
+	return &activeTrees{make(map[int]int), make(map[int]int), make(map[int]int), make(map[int]struct{}), maxTreeSize}
+}
+
+func (t *activeTrees) find(id int) int {
+	for {
+		parent, ok := t.parent[id]
+		if !ok || parent == id {
+			return id
+		}
+
+		// halve the path on the way up
+		grandparent, ok := t.parent[parent]
+		if ok {
+			t.parent[id] = grandparent
+		}
+
+		id = parent
+	}
+}
+
+// full reports whether the tree of item already holds maxTreeSize items, counting item as omitted if so.
+func (t *activeTrees) full(item *Item) bool {
+	if t.maxTreeSize <= 0 || item.Parent == nil {
+		return false
+	}
+
+	tree := t.find(*item.Parent)
+	if t.size[tree] < t.maxTreeSize {
+		return false
+	}
+
+	t.omitted[tree]++
+	t.omittedIDs[item.ID] = struct{}{}
+
+	return true
+}
+
+// add counts item in its tree, joining it to the tree of its parent.
+func (t *activeTrees) add(item *Item) {
+	if t.maxTreeSize <= 0 {
+		return
+	}
+
+	// an omitted item is retrieved after all when a reply to it was kept before its tree was known to be full
+	if _, ok := t.omittedIDs[item.ID]; ok {
+		delete(t.omittedIDs, item.ID)
+		t.omitted[t.find(*item.Parent)]--
+	}
+
+	tree := t.find(item.ID)
+	t.size[tree]++
+
+	if item.Parent == nil {
+		return
+	}
+
+	parentTree := t.find(*item.Parent)
+	if parentTree == tree {
+		return
+	}
+
+	if t.size[tree] > t.size[parentTree] {
+		tree, parentTree = parentTree, tree
+	}
+
+	t.parent[tree] = parentTree
+	t.size[parentTree] += t.size[tree]
+	t.omitted[parentTree] += t.omitted[tree]
+
+	delete(t.size, tree)
+	delete(t.omitted, tree)
+}
+
+// truncated returns the omitted counts by the ID of the root of each tree. Trees without their root in all, as in a
+// partial result, are left out.
+func (t *activeTrees) truncated(all ItemSet) Truncated {
+	result := make(Truncated)
+
+	for tree, count := range t.omitted {
+		item, ok := all[tree]
+		if count == 0 || !ok {
+			continue
+		}
+
+		root, err := item.FindRoot(all)
+		if err != nil {
+			continue
+		}
+
+		result[root.ID] += count
+	}
+
+	return result
+}
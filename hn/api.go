@@ -0,0 +1,38 @@
+package hn
+
+import (
+	"context"
+	"time"
+)
+
+// API is the set of Client methods that applications typically depend on, so they can substitute a fake client in
+// their tests. Client satisfies API. Advanced is excluded since it exposes the internals of a Client.
+type API interface {
+	GetTop(ctx context.Context) ([]int, error)
+	GetBest(ctx context.Context) ([]int, error)
+	GetNew(ctx context.Context) ([]int, error)
+	GetAsk(ctx context.Context) ([]int, error)
+	GetShow(ctx context.Context) ([]int, error)
+	GetJobs(ctx context.Context) ([]int, error)
+	GetMaxItem(ctx context.Context) (int, error)
+	GetUpdates(ctx context.Context) (*Updates, error)
+	ClockSkew(ctx context.Context) (time.Duration, error)
+	Now(ctx context.Context) (time.Time, time.Duration, error)
+	GetUser(ctx context.Context, username string) (*User, error)
+	GetUsers(ctx context.Context, usernames []string) (map[string]*User, error)
+	GetItem(ctx context.Context, id int) (*Item, error)
+	GetItems(ctx context.Context, ids []int) (ItemSet, error)
+	GetActive(ctx context.Context, maxID int, activeAfter time.Time) (ItemSet, error)
+	GetActiveCapped(ctx context.Context, maxID int, activeAfter time.Time, maxTreeSize int) (ItemSet, Truncated, error)
+	SearchOrdered(ctx context.Context, ids []int, acc func(id int, item *Item) (bool, []int, error)) error
+	SearchUnordered(ctx context.Context, ids []int, acc func(id int, item *Item) (bool, []int, error)) error
+	GetParents(ctx context.Context, items ItemSet) (ItemSet, error)
+	GetAncestors(ctx context.Context, items ItemSet) (ItemSet, error)
+	GetKids(ctx context.Context, items ItemSet) (ItemSet, error)
+	GetDescendants(ctx context.Context, items ItemSet) (ItemSet, error)
+	GetTree(ctx context.Context, rootID int) (ItemSet, error)
+	GetTreeCapped(ctx context.Context, rootID int, maxDepth int, maxItems int) (ItemSet, bool, error)
+	Close() error
+}
+
+var _ API = (*Client)(nil)
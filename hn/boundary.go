@@ -0,0 +1,35 @@
+package hn
+
+import "time"
+
+// Boundary selects whether an item created exactly at a time limit counts as after it. Item times are whole seconds,
+// so a limit is compared at the granularity of the second it falls in.
+type Boundary uint8
+
+const (
+	// BoundaryInclusive counts items created in the same second as the limit, so an item at the limit is after it.
+	BoundaryInclusive Boundary = iota
+	// BoundaryExclusive counts only items created in a later second than the limit.
+	BoundaryExclusive
+)
+
+// After reports whether created, in Unix seconds like Item.Time, is after limit with boundary b. It is the single
+// activity check shared by GetActive and the unl package, so an item at the edge of a window is either active
+// everywhere or nowhere.
+func (b Boundary) After(created int64, limit time.Time) bool {
+	if b == BoundaryExclusive {
+		return created > limit.Unix()
+	}
+
+	return created >= limit.Unix()
+}
+
+// Inclusive returns the limit that selects the same items with BoundaryInclusive as limit does with b, such as for
+// GetActive, which is always inclusive.
+func (b Boundary) Inclusive(limit time.Time) time.Time {
+	if b == BoundaryExclusive {
+		return time.Unix(limit.Unix()+1, 0)
+	}
+
+	return limit
+}
@@ -0,0 +1,38 @@
+package hn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoundary(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		limit     time.Time
+		created   int64
+		inclusive bool
+		exclusive bool
+	}{
+		{time.Unix(100, 0), 99, false, false},
+		{time.Unix(100, 0), 100, true, false},
+		{time.Unix(100, 0), 101, true, true},
+		// a fraction of a second still compares within the second
+		{time.Unix(100, 500), 100, true, false},
+		{time.Unix(100, 500), 101, true, true},
+	} {
+		if actual := BoundaryInclusive.After(test.created, test.limit); actual != test.inclusive {
+			t.Errorf("inclusive %d after %v: expected %v", test.created, test.limit, test.inclusive)
+		}
+
+		if actual := BoundaryExclusive.After(test.created, test.limit); actual != test.exclusive {
+			t.Errorf("exclusive %d after %v: expected %v", test.created, test.limit, test.exclusive)
+		}
+
+		for _, b := range []Boundary{BoundaryInclusive, BoundaryExclusive} {
+			if BoundaryInclusive.After(test.created, b.Inclusive(test.limit)) != b.After(test.created, test.limit) {
+				t.Errorf("boundary %d: inclusive limit disagrees for %d after %v", b, test.created, test.limit)
+			}
+		}
+	}
+}
@@ -5,8 +5,12 @@ package hn
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"sync/atomic"
 	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
 )
 
 // Client is the primary interface to the HN API.
@@ -17,12 +21,25 @@ import (
 // Items, err := client.GetItems(IDs)
 //
 // Remember to Close() the client when done.
+//
+// A Client is safe for concurrent use and is meant to be long-lived: it holds the connection pool, the in-memory and
+// file caches, and the retrievals in flight, which are shared by every caller. Server workloads should create one
+// Client and use it for every request rather than one per request. Each search starts a goroutine for its stream
+// unless it uses a stream from AcquireStream.
 type Client struct {
 	resourceGetter        ResourceGetter
 	bulkItemGetter        BulkStreamGetter[*Item]
 	bulkRawItemGetter     BulkStreamGetter[io.ReadCloser]
+	clock                 core.Clock
+	fileCache             *core.BulkItemFileCacheGetter
+	counters              *clientCounters
+	streams               *itemStreamPool[*Item]
+	getter                core.Getter[string, io.ReadCloser]
+	bulkUserGetter        core.BulkGetter[string, io.ReadCloser]
 	closers               []io.Closer
 	itemStreamMaxInFlight int
+	nullRetry             nullRetry
+	normalizeNow          bool
 }
 
 func (c *Client) GetTop(ctx context.Context) ([]int, error) {
@@ -46,13 +63,97 @@ func (c *Client) GetShow(ctx context.Context) ([]int, error) {
 }
 
 func (c *Client) GetJobs(ctx context.Context) ([]int, error) {
-	return getResource[[]int](ctx, c.resourceGetter, "jobsstories.json")
+	return getResource[[]int](ctx, c.resourceGetter, "jobstories.json")
 }
 
 func (c *Client) GetMaxItem(ctx context.Context) (int, error) {
 	return getResource[int](ctx, c.resourceGetter, "maxitem.json")
 }
 
+// Updates are the items and profiles that changed recently, as listed by the API. The API keeps a rolling list, so an
+// item may be listed for some time after its change.
+type Updates struct {
+	Items    []int    `json:"items"`
+	Profiles []string `json:"profiles"`
+}
+
+// GetUpdates returns the recently changed items and profiles. Sources other than the HN API may not provide them, in
+// which case the error wraps ErrUnsupportedBySource.
+func (c *Client) GetUpdates(ctx context.Context) (*Updates, error) {
+	return getResource[*Updates](ctx, c.resourceGetter, "updates.json")
+}
+
+// ClockSkewTolerance is how far the local clock may differ from the time of the latest item before Now normalizes it.
+// The latest item is usually only seconds old, so anything beyond this is almost certainly local clock skew.
+const ClockSkewTolerance = 1 * time.Minute
+
+// ClockSkew returns how far the local clock is ahead of the time of the latest item (negative if behind).
+func (c *Client) ClockSkew(ctx context.Context) (time.Duration, error) {
+	latest, err := c.getLatestItemTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.clock.Now().Sub(latest), nil
+}
+
+// Now returns the current time according to the client's clock.
+// With WithNormalizedNow, if the clock is off from the latest item by more than ClockSkewTolerance,
+// the time of the latest item is returned instead, so item ages are neither negative nor inflated.
+// The returned duration is the skew that was corrected, or zero.
+func (c *Client) Now(ctx context.Context) (time.Time, time.Duration, error) {
+	now := c.clock.Now()
+
+	if !c.normalizeNow {
+		return now, 0, nil
+	}
+
+	skew, err := c.ClockSkew(ctx)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if skew.Abs() <= ClockSkewTolerance {
+		return now, 0, nil
+	}
+
+	return now.Add(-skew), skew, nil
+}
+
+func (c *Client) getLatestItemTime(ctx context.Context) (time.Time, error) {
+	// the most recent items are often still null, so check a few
+	const latestItemCandidates = 10
+
+	maxID, err := c.GetMaxItem(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get max item: %w", err)
+	}
+
+	ids := make([]int, 0, latestItemCandidates)
+	for id := maxID; id > max(0, maxID-latestItemCandidates); id-- {
+		ids = append(ids, id)
+	}
+
+	items, err := c.GetItems(ctx, ids)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest items: %w", err)
+	}
+
+	var latest int64
+
+	for _, item := range items {
+		if item.Type != NullBody {
+			latest = max(latest, item.Time)
+		}
+	}
+
+	if latest == 0 {
+		return time.Time{}, fmt.Errorf("no recent item to compare against: %w", errNullBody)
+	}
+
+	return time.Unix(latest, 0), nil
+}
+
 type User struct {
 	About     string `json:"about"`
 	ID        string `json:"id"`
@@ -98,54 +199,148 @@ func (c *Client) GetItems(ctx context.Context, ids []int) (ItemSet, error) {
 	return newItemStream(ctx, c.bulkItemGetter, c.itemStreamMaxInFlight).Get(ids)
 }
 
-// GetActive returns the active items, defined as items created after the provided time, along with their ancestors.
-// It scans roughly from the most recent item, avoiding checking ids beyond one it knows was too old.
+// GetItem returns the item with id, or an error wrapping ErrItemNotFound when the API returns a null body for it. With
+// WithNullRetry, a null body is first retried with exponential backoff until the retries time out or ctx is done.
+func (c *Client) GetItem(ctx context.Context, id int) (*Item, error) {
+	delay := c.nullRetry.delay
+	deadline := c.clock.Now().Add(c.nullRetry.timeout)
+
+	for {
+		items, err := c.GetItems(ctx, []int{id})
+		if err != nil {
+			return nil, err
+		}
+
+		item := items[id]
+		if item != nil && item.Type != NullBody {
+			return item, nil
+		}
+
+		remaining := deadline.Sub(c.clock.Now())
+		if delay <= 0 || remaining <= 0 {
+			return nil, fmt.Errorf("item %d has null body: %w", id, ErrItemNotFound)
+		}
+
+		err = core.Sleep(ctx, c.clock, min(delay, remaining))
+		if err != nil {
+			return nil, fmt.Errorf("failed to retry item %d: %w", id, err)
+		}
+
+		delay *= 2
+	}
+}
+
+// nullRetry configures the retries of null bodies by GetItem (see WithNullRetry).
+type nullRetry struct {
+	delay   time.Duration
+	timeout time.Duration
+}
+
+// GetActive returns the active items, defined as items created at or after the provided time to the second as with
+// BoundaryInclusive, along with their ancestors; convert an exclusive limit with Boundary.Inclusive.
+// It scans roughly from the most recent item, avoiding checking ids beyond one it knows was too old. When the window
+// is sparse, a few probes at growing offsets first find where it ends so the scan doesn't read ahead past it.
 // This seems overly specialized, but it's the whole reason this package exists, so it gets to remain :P.
 func (c *Client) GetActive(
 	ctx context.Context,
 	maxID int,
 	activeAfter time.Time,
 ) (ItemSet, error) {
-	itemStream := c.Advanced().NewItemStream(ctx)
-	ids := make([]int, 0, itemStream.MaxInFlight())
+	all, _, err := c.GetActiveCapped(ctx, maxID, activeAfter, 0)
+
+	return all, err
+}
 
-	for i := itemStream.MaxInFlight(); i >= 0; i-- {
+// GetActiveCapped is GetActive with the items retrieved for each tree capped at about maxTreeSize, or uncapped if
+// it is 0, so a single enormous thread can't dominate the retrieval. Once a tree is full, its older active items are
+// omitted and their ancestors are not retrieved; the scan still checks every id in the window. The tree an item
+// belongs to is only known once its ancestors are retrieved, so trees can exceed the cap by the ancestors of items
+// retrieved before they were known to be part of it. The omitted active items are counted by the ID of their root.
+//
+// If ctx ends before the scan completes, the items retrieved so far that can be traced to their root are returned
+// along with an error wrapping ErrPartialResult and the error of ctx.
+func (c *Client) GetActiveCapped(
+	ctx context.Context,
+	maxID int,
+	activeAfter time.Time,
+	maxTreeSize int,
+) (ItemSet, Truncated, error) {
+	scan := c.startScan()
+	defer scan.end()
+
+	scanned, largestKnownInactiveID := c.itemStreamMaxInFlight, 0
+
+	// a sparse window, as in quiet hours, is scanned only down to its boundary
+	lo, hi, sparse, err := c.probeActiveBoundary(ctx, scan, maxID, activeAfter, c.itemStreamMaxInFlight)
+	if err != nil && ctx.Err() != nil {
+		return ItemSet{}, Truncated{}, fmt.Errorf("%w: %w", ErrPartialResult, ctx.Err())
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the scan always starts at maxID, so items with a null body above the newest one found inactive are still read
+	if sparse {
+		scanned, largestKnownInactiveID = max(lo, 0), maxID-hi
+	}
+
+	ids := make([]int, 0, scanned+1)
+
+	for i := scanned; i >= 0; i-- {
 		ids = append(ids, maxID-i)
 	}
 
+	if len(ids) == 0 {
+		return ItemSet{}, Truncated{}, nil
+	}
+
+	itemStream := c.Advanced().NewItemStream(ctx)
 	next := ids[0] - 1
-	largestKnownInactiveID := 0
 	queuedAsParent := make(map[int]struct{}, len(ids))
 	all := make(ItemSet, len(ids))
 	moreIDs := make([]int, 0, 2)
+	trees := newActiveTrees(maxTreeSize)
 
-	err := itemStream.SearchUnordered(ids, func(id int, item *Item) (bool, []int, error) {
-		isActiveByTime := time.Unix(item.Time, 0).After(activeAfter)
-		if !isActiveByTime {
+	err = itemStream.SearchUnordered(ids, func(id int, item *Item) (bool, []int, error) {
+		scan.examine(id)
+
+		// a null body, typical for very new items, is not known to be inactive
+		isActiveByTime := BoundaryInclusive.After(item.Time, activeAfter)
+		if !isActiveByTime && item.Type != NullBody {
 			largestKnownInactiveID = max(id, largestKnownInactiveID)
 		}
 
 		isActive := isActiveByTime && !item.Dead && !item.Deleted
-		if !isActive {
-			_, ok := queuedAsParent[id]
-			if !ok {
-				return true, nil, nil
-			}
+		_, isParent := queuedAsParent[id]
+
+		if !isActive && !isParent {
+			return true, nil, nil
 		}
 
-		all[id] = item
 		moreIDs = moreIDs[:0]
 
-		getActiveTryEnqueueParent(item, queuedAsParent, &moreIDs)
+		// ancestors are always kept so every retrieved item can be traced to its root
+		if isParent || !trees.full(item) {
+			all[id] = item
+			trees.add(item)
+			getActiveTryEnqueueParent(item, queuedAsParent, &moreIDs)
+		}
+
 		getActiveTryEnqueueNextID(&next, largestKnownInactiveID, queuedAsParent, &moreIDs)
 
 		return true, moreIDs, nil
 	})
+	if err != nil && ctx.Err() == nil {
+		return nil, nil, err
+	}
+
 	if err != nil {
-		return nil, err
+		all = all.traceable()
+		err = fmt.Errorf("%w: %w", ErrPartialResult, ctx.Err())
 	}
 
-	return all, nil
+	return all, trees.truncated(all), err
 }
 
 func getActiveTryEnqueueParent(item *Item, queuedAsParent map[int]struct{}, moreIDs *[]int) {
@@ -221,7 +416,16 @@ func (c *Client) GetDescendants(ctx context.Context, items ItemSet) (ItemSet, er
 	return items.getDescendants(ctx, c)
 }
 
+// AcquireStream returns a stream for searches with ctx, reusing the goroutine and channels of a released one if there
+// is one. Unlike a stream from NewItemStream, it serves any number of Gets and searches in turn, so workloads running
+// many small searches avoid starting a goroutine for each. Release the stream when done with it.
+func (c *Client) AcquireStream(ctx context.Context) *ItemStream[*Item] {
+	return c.streams.acquire(ctx)
+}
+
 func (c *Client) Close() error {
+	c.streams.close()
+
 	errs := make([]error, 0, len(c.closers))
 
 	for _, closer := range c.closers {
@@ -233,6 +437,78 @@ func (c *Client) Close() error {
 	return errors.Join(errs...)
 }
 
+// Stats counts the activity of a Client. Clients from NewCustomClient count nothing.
+type Stats struct {
+	// FileCache counts the puts to the file cache. It is zero if the client has no file cache.
+	FileCache core.BulkItemFileCacheStats
+	// Items counts the items retrieved and where they came from.
+	Items ItemStats
+	// Scans describes the scans of GetActive and GetActiveCapped.
+	Scans ScanStats
+	// Requests counts the requests made to the API, for lists and users as well as items.
+	Requests int64
+}
+
+// ItemStats counts the items retrieved by a Client by the layer that answered them.
+type ItemStats struct {
+	// Requested counts the items requested from the client, including repeats.
+	Requested int64
+	// Memory counts the items answered by the in-memory cache or by joining a retrieval already in flight.
+	Memory int64
+	// FileCache counts the items answered by the file cache.
+	FileCache int64
+	// Network counts the items requested from the API.
+	Network int64
+}
+
+type clientCounters struct {
+	requests         atomic.Int64
+	items            atomic.Int64
+	fileCacheLookups atomic.Int64
+	network          atomic.Int64
+	scans            scanCounters
+}
+
+func (c *Client) Stats() Stats {
+	var stats Stats
+
+	if c.fileCache != nil {
+		stats.FileCache = c.fileCache.Stats()
+	}
+
+	stats.Items, stats.Requests = c.itemStats()
+
+	if c.counters != nil {
+		c.counters.scans.mu.Lock()
+		stats.Scans = c.counters.scans.stats
+		c.counters.scans.mu.Unlock()
+	}
+
+	return stats
+}
+
+// itemStats returns the items retrieved so far by layer and the requests made to the API.
+func (c *Client) itemStats() (ItemStats, int64) {
+	var items ItemStats
+
+	if c.counters == nil {
+		return items, 0
+	}
+
+	items.Requested = c.counters.items.Load()
+	items.Network = c.counters.network.Load()
+
+	reached := items.Network
+	if c.fileCache != nil {
+		reached = c.counters.fileCacheLookups.Load()
+		items.FileCache = max(reached-items.Network, 0)
+	}
+
+	items.Memory = max(items.Requested-reached, 0)
+
+	return items, c.counters.requests.Load()
+}
+
 func (c *Client) Advanced() AdvancedClient {
 	return AdvancedClient{client: c}
 }
@@ -245,9 +521,9 @@ type ResourceGetter interface {
 	Get(ctx context.Context, path string, result any) error
 }
 
-type BulkStreamGetter[TItem any] interface {
-	Get(ctx context.Context, ids []int, do func(id int, value ItemStreamValue[TItem])) []int
-}
+// BulkStreamGetter is the core.BulkGetter of the item pipelines of the client, reporting the failure of an item in
+// ItemStreamValue.Err.
+type BulkStreamGetter[TItem any] = core.BulkGetter[int, ItemStreamValue[TItem]]
 
 func (c AdvancedClient) BulkItemGetter() BulkStreamGetter[*Item] {
 	return c.client.bulkItemGetter
@@ -268,3 +544,15 @@ func (c AdvancedClient) NewItemStream(ctx context.Context) *ItemStream[*Item] {
 func (c AdvancedClient) NewRawItemStream(ctx context.Context) *ItemStream[io.ReadCloser] {
 	return newItemStream(ctx, c.client.bulkRawItemGetter, c.client.itemStreamMaxInFlight)
 }
+
+// MaxInFlight returns the number of items a stream of the client keeps in flight, which together fill the queue of
+// its worker pool about halfway.
+func (c AdvancedClient) MaxInFlight() int {
+	return c.client.itemStreamMaxInFlight
+}
+
+// NewRawItemStreamLimited is NewRawItemStream with at most maxInFlight items in flight, so several streams searching
+// at once can share MaxInFlight without the worker pool refusing their items.
+func (c AdvancedClient) NewRawItemStreamLimited(ctx context.Context, maxInFlight int) *ItemStream[io.ReadCloser] {
+	return newItemStream(ctx, c.client.bulkRawItemGetter, min(max(maxInFlight, 1), c.client.itemStreamMaxInFlight))
+}
@@ -1,9 +1,27 @@
 package hn
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
 	_ "github.com/mattn/go-sqlite3"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/goleak"
 )
 
@@ -21,3 +39,697 @@ func TestNewClientClose_NoGoroutineLeaks(t *testing.T) {
 		t.Fatalf("Close() returned error: %v", err)
 	}
 }
+
+type skewedClock struct {
+	skew time.Duration
+}
+
+func (c skewedClock) Now() time.Time {
+	return testdata.Clock.Now().Add(c.skew)
+}
+
+func TestClientNow(t *testing.T) {
+	t.Parallel()
+
+	const skew = -10 * time.Minute
+
+	options := []Option{WithFileCachePath(""), WithGetter(testdata.Getter), WithClock(skewedClock{skew})}
+
+	client, err := NewClient(t.Context(), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	detected, err := client.ClockSkew(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (detected - skew).Abs() > ClockSkewTolerance {
+		t.Fatalf("expected skew around %s, got %s", skew, detected)
+	}
+
+	now, corrected, err := client.Now(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if corrected != 0 || now.Sub(testdata.MaxTime.Add(skew)).Abs() > ClockSkewTolerance {
+		t.Fatalf("expected the local clock without normalization, got %s (%s)", now, corrected)
+	}
+
+	normalized, err := NewClient(t.Context(), append(options, WithNormalizedNow(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = normalized.Close() }()
+
+	now, corrected, err = normalized.Now(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if (corrected-skew).Abs() > ClockSkewTolerance || now.Sub(testdata.MaxTime).Abs() > ClockSkewTolerance {
+		t.Fatalf("expected the time of the latest item, got %s (%s)", now, corrected)
+	}
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(filepath.Join(t.TempDir(), "hn.db")),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock),
+		WithTracerProvider(tp))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(t.Context(), "parent")
+
+	_, err = client.GetItems(ctx, testdata.New[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent.End()
+
+	err = errors.Join(client.Close(), tp.Shutdown(t.Context()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+
+	for _, span := range recorder.Ended() {
+		if span.Name() != "parent" && span.Parent().TraceID() != parent.SpanContext().TraceID() {
+			t.Fatalf("span %s is not part of the parent trace", span.Name())
+		}
+
+		counts[span.Name()]++
+	}
+
+	if counts["hn.get"] != 3 || counts["hn.file_cache"] == 0 || counts["hn.worker_pool"] == 0 {
+		t.Fatalf("unexpected spans %v", counts)
+	}
+}
+
+type gatedGetter struct {
+	release chan struct{}
+	items   atomic.Int64
+}
+
+func (g *gatedGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if strings.HasPrefix(path, itemPathPrefix) {
+		g.items.Add(1)
+		<-g.release
+	}
+
+	return testdata.Getter.Get(ctx, path)
+}
+
+func TestTypedAndRawShareRequests(t *testing.T) {
+	t.Parallel()
+
+	getter := &gatedGetter{make(chan struct{}), atomic.Int64{}}
+
+	client, err := NewClient(t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := testdata.New[:3]
+	errs := make(chan error, 2)
+
+	go func() {
+		_, err := client.GetItems(t.Context(), ids)
+		errs <- err
+	}()
+
+	for getter.items.Load() < int64(len(ids)) {
+		time.Sleep(time.Millisecond)
+	}
+
+	go func() {
+		_, err := client.Advanced().NewRawItemStream(t.Context()).Get(ids)
+		errs <- err
+	}()
+
+	// give the raw stream time to join the in-flight requests before they complete
+	time.Sleep(50 * time.Millisecond)
+	close(getter.release)
+
+	err = errors.Join(<-errs, <-errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := getter.items.Load(); n != int64(len(ids)) {
+		t.Fatalf("expected %d item requests, got %d", len(ids), n)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	t.Parallel()
+
+	db := filepath.Join(t.TempDir(), "hn.db")
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(db),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock),
+		WithFileCachePutBatchSize(7),
+		WithFileCachePutChannelDepth(1),
+		WithFileCacheFlushInterval(time.Millisecond),
+		WithFileCacheBlockingPuts(true),
+		WithFileCacheCheckpointWALSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int{testdata.MaxItem, testdata.MaxItem - 1, testdata.MaxItem - 2}
+
+	// the second retrieval is answered from memory
+	for range 2 {
+		_, err = client.GetItems(t.Context(), ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := client.Stats()
+	if stats.FileCache.Checkpoints == 0 {
+		t.Fatal("expected a checkpoint once the write-ahead log reached 1 byte")
+	}
+
+	n := int64(len(ids))
+
+	var expected Stats
+	expected.FileCache.Queued = n
+	expected.FileCache.Written = n
+	expected.FileCache.Checkpoints = stats.FileCache.Checkpoints
+	expected.Items = ItemStats{2 * n, n, 0, n}
+	expected.Requests = n
+
+	if diff := cmp.Diff(expected, stats); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	client, err = NewClient(t.Context(), WithFileCachePath(db), WithGetter(testdata.Getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.GetItems(t.Context(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromFile Stats
+	fromFile.Items = ItemStats{n, 0, n, 0}
+
+	if diff := cmp.Diff(fromFile, client.Stats()); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+}
+
+func TestGetActiveCapped(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(filepath.Join(t.TempDir(), "hn.db")),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	activeAfter := testdata.MaxTime.Add(-time.Hour)
+
+	all, truncated, err := client.GetActiveCapped(t.Context(), testdata.MaxItem, activeAfter, 0)
+	if err != nil || len(truncated) != 0 {
+		t.Fatalf("expected no truncation without a cap, got %v: %v", truncated, err)
+	}
+
+	const maxTreeSize = 10
+
+	capped, truncated, err := client.GetActiveCapped(t.Context(), testdata.MaxItem, activeAfter, maxTreeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(truncated) == 0 || len(capped) >= len(all) {
+		t.Fatalf("expected fewer items with a cap, got %d of %d", len(capped), len(all))
+	}
+
+	// every kept item can still be traced to its root, and truncated roots were kept with full trees
+	byRoot, err := capped.GroupByRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := make(map[int]int, len(byRoot))
+	for root, tree := range byRoot {
+		sizes[root.ID] = len(tree)
+	}
+
+	for id, omitted := range truncated {
+		if omitted <= 0 || sizes[id] < maxTreeSize {
+			t.Errorf("expected root %d with %d omitted to have at least %d items, got %d",
+				id, omitted, maxTreeSize, sizes[id])
+		}
+	}
+}
+
+// cancelingGetter cancels the context of a retrieval once it has answered a number of item requests.
+type cancelingGetter struct {
+	cancel    context.CancelFunc
+	remaining atomic.Int64
+}
+
+func (g *cancelingGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if strings.HasPrefix(path, itemPathPrefix) && g.remaining.Add(-1) < 0 {
+		g.cancel()
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	return testdata.Getter.Get(ctx, path)
+}
+
+func TestGetActivePartial(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	getter := &cancelingGetter{cancel, atomic.Int64{}}
+	getter.remaining.Store(200)
+
+	client, err := NewClient(ctx, WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	all, _, err := client.GetActiveCapped(ctx, testdata.MaxItem, testdata.MaxTime.Add(-time.Hour), 0)
+	if !errors.Is(err, ErrPartialResult) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a partial result, got %v", err)
+	}
+
+	if len(all) == 0 {
+		t.Fatal("expected the items retrieved before the cancellation")
+	}
+
+	// every returned item can be traced to its root
+	_, err = all.GroupByRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientCorruptFileCache(t *testing.T) {
+	t.Parallel()
+
+	db := filepath.Join(t.TempDir(), "hn.db")
+
+	err := os.WriteFile(db, bytes.Repeat([]byte("not a database"), 1000), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var movedTo string
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(db),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock),
+		WithFileCacheCorruptHandler(func(moved string, err error) {
+			if !core.IsItemFileCacheCorrupt(err) {
+				t.Errorf("expected a corrupt cache error, got %v", err)
+			}
+
+			movedTo = moved
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.GetItems(t.Context(), testdata.New[:3])
+
+	err = errors.Join(err, client.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(movedTo, db+".corrupt-") {
+		t.Fatalf("expected the corrupt file to be moved aside, got %q", movedTo)
+	}
+
+	b, err := os.ReadFile(movedTo)
+	if err != nil || !bytes.HasPrefix(b, []byte("not a database")) {
+		t.Fatalf("expected the corrupt file to be kept: %v", err)
+	}
+}
+
+// updatesGetter serves a fixed list of changes, counting the requests for it.
+type updatesGetter struct {
+	requests atomic.Int64
+}
+
+func (g *updatesGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "updates.json" {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	g.requests.Add(1)
+
+	return io.NopCloser(strings.NewReader(`{"items":[8863,8864],"profiles":["pg","dang"]}`)), nil
+}
+
+func TestGetUpdates(t *testing.T) {
+	t.Parallel()
+
+	getter := &updatesGetter{atomic.Int64{}}
+
+	client, err := NewClient(t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	expected := &Updates{[]int{8863, 8864}, []string{"pg", "dang"}}
+
+	for range 2 {
+		updates, err := client.GetUpdates(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(expected, updates); diff != "" {
+			t.Fatalf("unexpected updates (-want +got):\n%s", diff)
+		}
+	}
+
+	// the second call is served by the map cache
+	if n := getter.requests.Load(); n != 1 {
+		t.Fatalf("expected 1 request for updates, got %d", n)
+	}
+}
+
+// nullGetter serves a null body for the first nulls requests of the latest item, as the API does for very new items.
+type nullGetter struct {
+	requests atomic.Int64
+	nulls    int64
+}
+
+func (g *nullGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path == "item/"+strconv.Itoa(testdata.MaxItem)+".json" && g.requests.Add(1) <= g.nulls {
+		return io.NopCloser(strings.NewReader("null")), nil
+	}
+
+	return testdata.Getter.Get(ctx, path)
+}
+
+func TestGetItem(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		options  []Option
+		nulls    int64
+		requests int64
+		found    bool
+	}{
+		{"found", nil, 0, 1, true},
+		{"null without retry", nil, 1, 1, false},
+		{"null retried", []Option{WithNullRetry(time.Millisecond, time.Minute)}, 3, 4, true},
+		{"retries time out", []Option{WithNullRetry(time.Millisecond, 20*time.Millisecond)}, 1000, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			getter := &nullGetter{atomic.Int64{}, tt.nulls}
+			options := append([]Option{WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock)}, tt.options...)
+
+			client, err := NewClient(t.Context(), options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() { _ = client.Close() }()
+
+			item, err := client.GetItem(t.Context(), testdata.MaxItem)
+
+			if !tt.found {
+				if !errors.Is(err, ErrItemNotFound) {
+					t.Fatalf("expected ErrItemNotFound, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if item.ID != testdata.MaxItem || item.Type == NullBody {
+				t.Fatalf("unexpected item %d of type %s", item.ID, item.Type)
+			}
+
+			if n := getter.requests.Load(); n != tt.requests {
+				t.Fatalf("expected %d requests, got %d", tt.requests, n)
+			}
+		})
+	}
+}
+
+func TestGetItemCanceled(t *testing.T) {
+	t.Parallel()
+
+	getter := &nullGetter{atomic.Int64{}, math.MaxInt64}
+
+	client, err := NewClient(
+		t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock),
+		WithNullRetry(time.Hour, time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = client.GetItem(ctx, testdata.MaxItem)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// sleepClock stands still except in Sleep, which advances it by the duration at once and records it.
+type sleepClock struct {
+	now    time.Time
+	sleeps []time.Duration
+	mu     sync.Mutex
+}
+
+func (c *sleepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *sleepClock) Sleep(_ context.Context, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+}
+
+func TestGetItemRetryClock(t *testing.T) {
+	t.Parallel()
+
+	getter := &nullGetter{atomic.Int64{}, math.MaxInt64}
+	clock := &sleepClock{testdata.MaxTime, nil, sync.Mutex{}}
+
+	client, err := NewClient(
+		t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(clock),
+		WithNullRetry(time.Second, 10*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	// the retries wait through the clock, so they take no real time
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.GetItem(ctx, testdata.MaxItem)
+	if !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("expected ErrItemNotFound, got %v", err)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second}
+	if !slices.Equal(clock.sleeps, expected) {
+		t.Fatalf("expected waits of %v, got %v", expected, clock.sleeps)
+	}
+}
+
+func TestRecoverUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	var panics []error
+
+	unmarshal := recoverUnmarshal(func(int, ItemStreamValue[[]byte]) ItemStreamValue[*Item] {
+		panic("malformed")
+	}, func(err error) { panics = append(panics, err) })
+
+	result := unmarshal(1, ItemStreamValue[[]byte]{ID: 1, Item: []byte("{}"), Err: nil})
+
+	var streamErr *StreamError
+	if !errors.As(result.Err, &streamErr) || streamErr.Op != "decode" || !errors.Is(result.Err, core.ErrWorkerPanic) {
+		t.Fatalf("expected the item to fail to decode, got %v", result.Err)
+	}
+
+	if len(panics) != 1 {
+		t.Fatalf("expected 1 panic reported, got %d", len(panics))
+	}
+}
+
+// panickyGetter serves the latest item with a body that panics when read, so a stage above the getter panics.
+type panickyGetter struct{}
+
+func (panickyGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "item/"+strconv.Itoa(testdata.MaxItem)+".json" {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	return panickyBody{}, nil
+}
+
+type panickyBody struct{}
+
+func (panickyBody) Read([]byte) (int, error) { panic("malformed") }
+
+func (panickyBody) Close() error { return nil }
+
+func TestWorkerPanicAboveGetter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		panics []error
+	)
+
+	client, err := NewClient(
+		t.Context(), WithFileCachePath(""), WithGetter(panickyGetter{}), WithClock(testdata.Clock),
+		WithWorkerPanicHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			panics = append(panics, err)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	// the search ends with the item failed rather than waiting on it forever
+	_, err = client.GetItems(t.Context(), []int{testdata.MaxItem - 1, testdata.MaxItem})
+	if !errors.Is(err, core.ErrWorkerPanic) {
+		t.Fatalf("expected core.ErrWorkerPanic, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(panics) != 1 {
+		t.Fatalf("expected 1 panic reported, got %d", len(panics))
+	}
+}
+
+// stuckGetter never returns the body of the latest item until the request is canceled.
+type stuckGetter struct{}
+
+func (stuckGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "item/"+strconv.Itoa(testdata.MaxItem)+".json" {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestWithRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(), WithFileCachePath(""), WithGetter(stuckGetter{}), WithClock(testdata.Clock),
+		WithRequestTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := []int{testdata.MaxItem, testdata.MaxItem - 1, testdata.MaxItem - 2}
+
+	// the stuck item fails on its own while the search goes on
+	var found []int
+
+	_, err = client.GetItems(t.Context(), ids)
+
+	var searchErr *SearchError
+	if !errors.As(err, &searchErr) || len(searchErr.Items) != 1 || searchErr.Items[0].ID != testdata.MaxItem ||
+		!errors.Is(err, core.ErrRequestTimeout) {
+		t.Fatalf("expected only the stuck item to time out, got %v", err)
+	}
+
+	err = client.SearchUnordered(t.Context(), ids[1:], func(id int, _ *Item) (bool, []int, error) {
+		found = append(found, id)
+		return true, nil, nil
+	})
+	if err != nil || len(found) != 2 {
+		t.Fatalf("expected the other items, got %v: %v", found, err)
+	}
+}
@@ -0,0 +1,154 @@
+package hn
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"unique"
+)
+
+// Intern returns the canonical copy of s, so that equal strings held by many items share their memory.
+func Intern(s string) string {
+	return unique.Make(s).Value()
+}
+
+// Intern replaces the By and Type of item with their canonical copies. They repeat across millions of items in the
+// full history, where a few hundred thousand users account for every item, though the text of an item usually takes
+// far more memory than both.
+func (item *Item) Intern() {
+	item.By = Intern(item.By)
+	item.Type = ItemType(Intern(string(item.Type)))
+}
+
+// Intern interns every item in the set (see Item.Intern).
+func (items ItemSet) Intern() {
+	for _, item := range items {
+		item.Intern()
+	}
+}
+
+// ErrNotCompactable is returned by Item.Compact for items with values that don't fit a CompactItem.
+var ErrNotCompactable = errors.New("item not compactable")
+
+//nolint:gochecknoglobals // constant
+var compactTypes = []ItemType{NullBody, Job, Story, Comment, Poll, PollOption}
+
+const (
+	compactDead uint8 = 1 << iota
+	compactDeleted
+)
+
+// CompactItem holds the metadata of an Item without its text, for analytics over the full history. It takes 32 bytes
+// with no allocations but the interned By, against the 184 of an Item plus its strings and slices: BenchmarkItemMemory
+// retains about 35 bytes per item of the test corpus as CompactItems and about 610 as Items, whether interned or not.
+// Title, Text, URL, Kids, Parts, and Poll are dropped.
+type CompactItem struct {
+	By          unique.Handle[string]
+	ID          int32
+	Parent      int32
+	Time        uint32
+	Score       int32
+	Descendants int32
+	typ         uint8
+	flags       uint8
+}
+
+// Compact returns the compact form of item, or an error wrapping ErrNotCompactable if an ID, count, or time is out
+// of the range of a CompactItem or the type is unknown.
+func (item *Item) Compact() (CompactItem, error) {
+	typ := slices.Index(compactTypes, item.Type)
+	if typ < 0 {
+		return CompactItem{}, fmt.Errorf("%w: item %d has type %q", ErrNotCompactable, item.ID, item.Type)
+	}
+
+	parent := 0
+	if item.Parent != nil {
+		parent = *item.Parent
+	}
+
+	if !fitsInt32(item.ID, parent, item.Score, item.Descendants) || item.Time < 0 || item.Time > math.MaxUint32 {
+		return CompactItem{}, fmt.Errorf("%w: item %d is out of range", ErrNotCompactable, item.ID)
+	}
+
+	var flags uint8
+	if item.Dead {
+		flags |= compactDead
+	}
+
+	if item.Deleted {
+		flags |= compactDeleted
+	}
+
+	return CompactItem{
+		unique.Make(item.By),
+		int32(item.ID),          //nolint:gosec // G115 checked by fitsInt32
+		int32(parent),           //nolint:gosec // G115 checked by fitsInt32
+		uint32(item.Time),       //nolint:gosec // G115 checked above
+		int32(item.Score),       //nolint:gosec // G115 checked by fitsInt32
+		int32(item.Descendants), //nolint:gosec // G115 checked by fitsInt32
+		uint8(typ),              //nolint:gosec // G115 index of compactTypes
+		flags,
+	}, nil
+}
+
+func fitsInt32(values ...int) bool {
+	for _, v := range values {
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Type returns the type of the item.
+func (c CompactItem) Type() ItemType {
+	return compactTypes[c.typ]
+}
+
+// Dead reports whether the item is dead.
+func (c CompactItem) Dead() bool {
+	return c.flags&compactDead != 0
+}
+
+// Deleted reports whether the item is deleted.
+func (c CompactItem) Deleted() bool {
+	return c.flags&compactDeleted != 0
+}
+
+// Item expands c into an Item, with the fields dropped by Item.Compact left empty and By interned.
+func (c CompactItem) Item() *Item {
+	var parent *int
+
+	if c.Parent != 0 {
+		p := int(c.Parent)
+		parent = &p
+	}
+
+	return &Item{
+		parent, nil, c.By.Value(), "", "", "", c.Type(), nil, nil, int64(c.Time), int(c.Descendants), int(c.ID),
+		int(c.Score), c.Dead(), c.Deleted(),
+	}
+}
+
+// Compact returns the compact form of every item in the set ordered by ID, or the first error from Item.Compact.
+func (items ItemSet) Compact() ([]CompactItem, error) {
+	result := make([]CompactItem, 0, len(items))
+
+	for _, item := range items {
+		c, err := item.Compact()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, c)
+	}
+
+	slices.SortFunc(result, func(a, b CompactItem) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,170 @@
+package hn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(), WithFileCachePath(""), WithGetter(testdata.Getter), WithClock(testdata.Clock), WithInterning(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	items, err := client.GetItems(t.Context(), testdata.UserSubmitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every item of the user shares one copy of the name
+	for _, item := range items {
+		if item.Type != NullBody && unsafe.StringData(item.By) != unsafe.StringData(Intern(testdata.UserID)) {
+			t.Fatalf("expected item %d to have an interned By", item.ID)
+		}
+	}
+
+	compact, err := items.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(compact) != len(items) {
+		t.Fatalf("expected %d compact items, got %d", len(items), len(compact))
+	}
+
+	for i, c := range compact {
+		if i > 0 && compact[i-1].ID >= c.ID {
+			t.Fatalf("expected compact items ordered by ID, got %d after %d", c.ID, compact[i-1].ID)
+		}
+
+		// the round trip keeps everything but the dropped fields
+		expected := *items[int(c.ID)]
+		expected.Text, expected.Title, expected.URL, expected.Kids, expected.Parts, expected.Poll = "", "", "", nil, nil, nil
+
+		if diff := cmp.Diff(&expected, c.Item()); diff != "" {
+			t.Fatalf("unexpected round trip of item %d (-want +got):\n%s", c.ID, diff)
+		}
+	}
+
+	var item Item
+	item.ID = 1
+	item.Type = "unknown"
+
+	_, err = item.Compact()
+	if !errors.Is(err, ErrNotCompactable) {
+		t.Fatalf("expected an unknown type to be rejected, got %v", err)
+	}
+
+	item.Type = Comment
+	item.Score = math.MaxInt32 + 1
+
+	_, err = item.Compact()
+	if !errors.Is(err, ErrNotCompactable) {
+		t.Fatalf("expected a score beyond int32 to be rejected, got %v", err)
+	}
+
+	if size := unsafe.Sizeof(CompactItem{}); size != 32 {
+		t.Fatalf("expected a CompactItem to take 32 bytes, got %d", size)
+	}
+}
+
+// BenchmarkItemMemory reports the heap retained per item when decoding the test corpus as Items, as interned Items,
+// and as CompactItems. The corpus is decoded several times over with shifted IDs, since each user has only a couple
+// of items in it against dozens in the full history.
+func BenchmarkItemMemory(b *testing.B) {
+	const copies = 10
+
+	lines := make([][]byte, 0, copies*testdata.ItemCount)
+	for range copies {
+		lines = append(lines, bytes.Split(bytes.TrimSpace(testdata.ItemsRaw), []byte("\n"))...)
+	}
+
+	decode := func(b *testing.B, i int) *Item {
+		b.Helper()
+
+		var item Item
+
+		err := json.Unmarshal(lines[i], &item)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		item.ID += i / testdata.ItemCount * (testdata.MaxItem + 1)
+
+		return &item
+	}
+
+	benchmarks := []struct {
+		build func(b *testing.B) any
+		name  string
+	}{
+		{func(b *testing.B) any {
+			items := make(ItemSet, len(lines))
+			for i := range lines {
+				item := decode(b, i)
+				items[item.ID] = item
+			}
+
+			return items
+		}, "items"},
+		{func(b *testing.B) any {
+			items := make(ItemSet, len(lines))
+			for i := range lines {
+				item := decode(b, i)
+				item.Intern()
+				items[item.ID] = item
+			}
+
+			return items
+		}, "interned"},
+		{func(b *testing.B) any {
+			items := make([]CompactItem, 0, len(lines))
+			for i := range lines {
+				c, err := decode(b, i).Compact()
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				items = append(items, c)
+			}
+
+			return items
+		}, "compact"},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			var retained uint64
+
+			for b.Loop() {
+				var before, after runtime.MemStats
+
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				result := bm.build(b)
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				runtime.KeepAlive(result)
+
+				retained = after.HeapAlloc - min(before.HeapAlloc, after.HeapAlloc)
+			}
+
+			b.ReportMetric(float64(retained)/float64(len(lines)), "retained-B/item")
+		})
+	}
+}
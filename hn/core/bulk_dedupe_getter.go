@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+)
+
+// BulkDedupeGetter passes each distinct key of a call to the inner getter once and fans its value out to do for
+// every time the key was passed, so the inner getter sees no duplicates while callers still get the BulkGetter
+// contract for them.
+type BulkDedupeGetter[TKey comparable, TValue any] struct {
+	inner BulkGetter[TKey, TValue]
+}
+
+func NewBulkDedupeGetter[TKey comparable, TValue any](
+	inner BulkGetter[TKey, TValue],
+) *BulkDedupeGetter[TKey, TValue] {
+	return &BulkDedupeGetter[TKey, TValue]{inner}
+}
+
+func (g *BulkDedupeGetter[TKey, TValue]) Get(
+	ctx context.Context,
+	keys []TKey,
+	do func(key TKey, value TValue),
+) []TKey {
+	counts := make(map[TKey]int, len(keys))
+	distinct := make([]TKey, 0, len(keys))
+
+	for _, key := range keys {
+		if counts[key] == 0 {
+			distinct = append(distinct, key)
+		}
+
+		counts[key]++
+	}
+
+	if len(distinct) == len(keys) {
+		return g.inner.Get(ctx, keys, do)
+	}
+
+	// counts is only read from here on, so do may run concurrently with the rest of the call
+	refused := g.inner.Get(ctx, distinct, func(key TKey, value TValue) {
+		dos := make([]func(key TKey, value TValue), counts[key])
+		for i := range dos {
+			dos[i] = do
+		}
+
+		runDos(dos, key, value)
+	})
+
+	var result []TKey
+
+	for _, key := range refused {
+		for range counts[key] {
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
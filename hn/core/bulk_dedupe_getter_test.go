@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBulkDedupeGetter(t *testing.T) {
+	t.Parallel()
+
+	var innerCalls [][]int
+
+	inner := BulkGetterFunc[int, int](func(_ context.Context, keys []int, do func(int, int)) []int {
+		innerCalls = append(innerCalls, slices.Clone(keys))
+
+		// the pool is full after two keys
+		n := min(len(keys), 2)
+		for _, k := range keys[:n] {
+			go do(k, k*10)
+		}
+
+		return keys[n:]
+	})
+
+	g := NewBulkDedupeGetter(inner)
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		done []int
+	)
+
+	keys := []int{1, 2, 1, 3, 3, 1}
+	wg.Add(len(keys))
+
+	refused := g.Get(t.Context(), keys, func(k int, v int) {
+		defer wg.Done()
+
+		if v != k*10 {
+			t.Errorf("unexpected value %d for %d", v, k)
+		}
+
+		mu.Lock()
+		done = append(done, k)
+		mu.Unlock()
+	})
+
+	wg.Add(-len(refused))
+	wg.Wait()
+
+	if !slices.Equal(refused, []int{3, 3}) {
+		t.Fatalf("expected 3 refused for each duplicate, got %v", refused)
+	}
+
+	slices.Sort(done)
+
+	if !slices.Equal(done, []int{1, 1, 1, 2}) {
+		t.Fatalf("expected do for each duplicate, got %v", done)
+	}
+
+	if diff := cmp.Diff([][]int{{1, 2, 3}}, innerCalls); diff != "" {
+		t.Fatalf("unexpected inner calls (-want +got):\n%s", diff)
+	}
+}
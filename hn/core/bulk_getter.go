@@ -5,12 +5,17 @@ import (
 	"io"
 )
 
+// BulkGetter is the one interface of every stage of the bulk item pipelines. There is no separate error channel: the
+// failure to retrieve a key is reported through the value passed to do for it, as a reader returning the error for
+// io.ReadCloser values (see WrapErrorInReadCloser) and as ItemStreamValue.Err in the hn package, so each key still gets
+// exactly one call. Only keys that could not be queued at all are reported otherwise, by returning them.
 type BulkGetter[TKey any, TValue any] interface {
 	// Get asynchronously retrieves bulk values using keys.
 	// 1. Get returns immediately often but not necessarily before do() is called for any key.
 	// 2. Keys that cannot be processed because the underlying system is full are returned.
 	// 3. The do callback will be called exactly once for each key that is queued and not returned.
-	// 4. If duplicates are passed the do function is called for each (ex: [1,1,1] -> 3 calls).
+	// 4. If duplicates are passed the do function is called for each (ex: [1,1,1] -> 3 calls), and each is returned if
+	//    it cannot be processed, even by getters that retrieve the value once for all of them.
 	// 5. The do callback should not panic, but even if it does the #3 invariant must hold.
 	// 6. Generally the do function should be written to not block as the underlying system might have a fixed capacity.
 	Get(ctx context.Context, keys []TKey, do func(key TKey, value TValue)) []TKey
@@ -20,6 +25,11 @@ func NewBulkItemGetter(workerPool *WorkerPool, getter Getter[string, io.ReadClos
 	return NewBulkWorkerPoolGetter(workerPool, NewItemGetter(getter), WrapErrorInReadCloser)
 }
 
+// NewBulkUserGetter is the BulkGetter of users by username, analogous to NewBulkItemGetter.
+func NewBulkUserGetter(workerPool *WorkerPool, getter Getter[string, io.ReadCloser]) BulkGetter[string, io.ReadCloser] {
+	return NewBulkWorkerPoolGetter(workerPool, NewUserGetter(getter), WrapErrorInReadCloser)
+}
+
 type BulkMapCacheGetter[TKey comparable, TValue any] struct {
 	inner       BulkGetter[TKey, TValue]
 	cache       *MapCache[TKey, TValue]
@@ -5,26 +5,65 @@ import (
 	"context"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-const putChannelBatchDepth = 10
+// BulkItemFileCachePutOptions controls how a BulkItemFileCacheGetter batches its asynchronous puts.
+type BulkItemFileCachePutOptions struct {
+	// BatchSize is the most items written to the cache in one statement.
+	BatchSize int
+	// ChannelDepth is the number of batches that can be queued before puts are dropped, or block if Block is set.
+	ChannelDepth int
+	// FlushInterval is how long to wait for a batch to fill before writing it. With 0, whatever is queued is written
+	// at once.
+	FlushInterval time.Duration
+	// Block waits for room in the queue instead of dropping the put when the queue is full.
+	Block bool
+	// CheckpointInterval is the least time between truncating checkpoints of the write-ahead log after puts. With 0,
+	// the log is only truncated for CheckpointWALSize and on close.
+	CheckpointInterval time.Duration
+	// CheckpointWALSize checkpoints after a put once the write-ahead log reaches this many bytes. With 0, the size is
+	// not checked.
+	CheckpointWALSize int64
+}
+
+// BulkItemFileCacheStats counts the puts of a BulkItemFileCacheGetter.
+type BulkItemFileCacheStats struct {
+	// Queued counts the items queued to be put.
+	Queued int64
+	// Dropped counts the items not put because the queue was full or, when blocking, the context ended.
+	Dropped int64
+	// Written counts the items put successfully.
+	Written int64
+	// Failed counts the items in batches the cache failed to put.
+	Failed int64
+	// Checkpoints counts the completed truncating checkpoints of the write-ahead log after puts.
+	Checkpoints int64
+	// WALSize is the size in bytes of the write-ahead log when last checked.
+	WALSize int64
+}
 
 func NewBulkItemFileCacheGetter(
 	ctx context.Context,
 	inner BulkGetter[int, io.ReadCloser],
 	cache *ItemFileCache,
-	putBatchSize int,
+	putOptions BulkItemFileCachePutOptions,
 	putChannelFull func(),
 	putError func(error),
 ) *BulkItemFileCacheGetter {
+	putOptions.BatchSize = max(putOptions.BatchSize, 1)
+	putOptions.ChannelDepth = max(putOptions.ChannelDepth, 1)
+
 	result := &BulkItemFileCacheGetter{
 		inner:          inner,
-		ch:             make(chan *bytes.Buffer, putBatchSize*putChannelBatchDepth),
+		ch:             make(chan *bytes.Buffer, putOptions.BatchSize*putOptions.ChannelDepth),
 		pool:           &sync.Pool{New: func() any { return &bytes.Buffer{} }},
 		wg:             &sync.WaitGroup{},
 		cache:          cache,
-		putBatchSize:   putBatchSize,
 		putChannelFull: putChannelFull,
+		stats:          &bulkItemFileCacheCounters{},
+		putOptions:     putOptions,
 	}
 
 	result.wg.Add(1)
@@ -43,7 +82,29 @@ type BulkItemFileCacheGetter struct {
 	wg             *sync.WaitGroup
 	cache          *ItemFileCache
 	putChannelFull func()
-	putBatchSize   int
+	stats          *bulkItemFileCacheCounters
+	putOptions     BulkItemFileCachePutOptions
+}
+
+type bulkItemFileCacheCounters struct {
+	queued      atomic.Int64
+	dropped     atomic.Int64
+	written     atomic.Int64
+	failed      atomic.Int64
+	checkpoints atomic.Int64
+	walSize     atomic.Int64
+}
+
+// Stats returns the put counts so far.
+func (g *BulkItemFileCacheGetter) Stats() BulkItemFileCacheStats {
+	return BulkItemFileCacheStats{
+		g.stats.queued.Load(),
+		g.stats.dropped.Load(),
+		g.stats.written.Load(),
+		g.stats.failed.Load(),
+		g.stats.checkpoints.Load(),
+		g.stats.walSize.Load(),
+	}
 }
 
 func (g *BulkItemFileCacheGetter) Close() error {
@@ -83,8 +144,11 @@ func (g *BulkItemFileCacheGetter) Get(
 		b.Reset()
 		b.Write(a.Bytes())
 
-		if !trySend[*bytes.Buffer](g.ch, a) {
+		if g.send(ctx, a) {
+			g.stats.queued.Add(1)
+		} else {
 			g.pool.Put(a)
+			g.stats.dropped.Add(1)
 
 			g.putChannelFull()
 		}
@@ -93,11 +157,26 @@ func (g *BulkItemFileCacheGetter) Get(
 	})
 }
 
+func (g *BulkItemFileCacheGetter) send(ctx context.Context, v *bytes.Buffer) bool {
+	if !g.putOptions.Block {
+		return trySend(g.ch, v)
+	}
+
+	select {
+	case g.ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func (g *BulkItemFileCacheGetter) put(ctx context.Context, putError func(error)) {
 	defer g.wg.Done()
 
+	lastCheckpoint := g.cache.clock.Now()
+
 	for {
-		v, ok := greedyRead(g.ch, g.putBatchSize)
+		v, ok := greedyRead(g.ch, g.putOptions.BatchSize, g.putOptions.FlushInterval)
 		if !ok {
 			break
 		}
@@ -116,13 +195,49 @@ func (g *BulkItemFileCacheGetter) put(ctx context.Context, putError func(error))
 
 			err := g.cache.Put(ctx, b)
 			if err != nil {
+				g.stats.failed.Add(int64(len(b)))
 				putError(err)
+
+				return
 			}
+
+			g.stats.written.Add(int64(len(b)))
 		}()
+
+		size := g.walSize(putError)
+
+		interval := g.putOptions.CheckpointInterval
+		limit := g.putOptions.CheckpointWALSize
+
+		if (interval > 0 && g.cache.clock.Now().Sub(lastCheckpoint) >= interval) || (limit > 0 && size >= limit) {
+			busy, err := g.cache.Checkpoint(ctx)
+			if err != nil {
+				putError(err)
+			} else if !busy {
+				g.stats.checkpoints.Add(1)
+				g.walSize(putError)
+			}
+
+			lastCheckpoint = g.cache.clock.Now()
+		}
 	}
 }
 
-func greedyRead[T any](from <-chan T, maxRead int) ([]T, bool) {
+// walSize returns the size of the write-ahead log and records it in the stats.
+func (g *BulkItemFileCacheGetter) walSize(putError func(error)) int64 {
+	size, err := g.cache.WALSize()
+	if err != nil {
+		putError(err)
+		return 0
+	}
+
+	g.stats.walSize.Store(size)
+
+	return size
+}
+
+// greedyRead blocks for one value and then reads up to maxRead values in total, waiting up to wait for them to arrive.
+func greedyRead[T any](from <-chan T, maxRead int, wait time.Duration) ([]T, bool) {
 	var id T
 	var ok bool
 
@@ -134,19 +249,35 @@ func greedyRead[T any](from <-chan T, maxRead int) ([]T, bool) {
 	var result []T
 	result = append(result, id)
 
-	more := true
-	for more && len(result) < maxRead {
-		select {
-		case id, ok = <-from:
-			if !ok {
-				more = false
-				break
+	var timeout <-chan time.Time
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	for len(result) < maxRead {
+		if timeout == nil {
+			select {
+			case id, ok = <-from:
+			default:
+				return result, true
+			}
+		} else {
+			select {
+			case id, ok = <-from:
+			case <-timeout:
+				return result, true
 			}
+		}
 
-			result = append(result, id)
-		default:
-			more = false
+		if !ok {
+			break
 		}
+
+		result = append(result, id)
 	}
 
 	return result, true
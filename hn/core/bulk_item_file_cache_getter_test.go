@@ -0,0 +1,176 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBulkItemFileCacheGetter_Stats(t *testing.T) {
+	t.Parallel()
+
+	const n = 500
+
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+
+	for _, block := range []bool{false, true} {
+		clock := &testClock{time.Unix(0, 0)}
+
+		cache, err := NewItemFileCache(t.Context(), clock, filepath.Join(t.TempDir(), "hn.db"), "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inner := BulkGetterFunc[int, io.ReadCloser](func(
+			_ context.Context,
+			keys []int,
+			do func(int, io.ReadCloser),
+		) []int {
+			for _, k := range keys {
+				do(k, io.NopCloser(bytes.NewReader(newTestItemEntry(t, k, int64(k)))))
+			}
+
+			return nil
+		})
+
+		full := 0
+		options := BulkItemFileCachePutOptions{1, 1, time.Millisecond, block, 0, 0}
+		g := NewBulkItemFileCacheGetter(t.Context(), inner, cache, options, func() { full++ }, func(error) {})
+
+		g.Get(t.Context(), ids, func(_ int, r io.ReadCloser) { _ = r.Close() })
+
+		err = g.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := g.Stats()
+
+		if stats.Queued+stats.Dropped != n || stats.Written != stats.Queued || stats.Failed != 0 {
+			t.Fatalf("block %v: unexpected %+v", block, stats)
+		}
+
+		if int(stats.Dropped) != full || (block && stats.Dropped != 0) {
+			t.Fatalf("block %v: expected %d channel full calls, got %d", block, stats.Dropped, full)
+		}
+
+		var got []int
+
+		remaining := cache.Get(t.Context(), ids, makeLogAndCheckCallback(t, &got))
+		if len(got) != int(stats.Written) || len(remaining) != int(stats.Dropped) {
+			t.Fatalf("block %v: expected %d cached, got %d", block, stats.Written, len(got))
+		}
+
+		err = cache.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBulkItemFileCacheGetter_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		options    BulkItemFileCachePutOptions
+		checkpoint bool
+	}{
+		{"wal size", BulkItemFileCachePutOptions{10, 1, 0, true, 0, 1}, true},
+		// the interval is measured by the clock of the cache, which stands still
+		{"interval", BulkItemFileCachePutOptions{10, 1, 0, true, time.Nanosecond, 0}, false},
+	}
+
+	for _, test := range tests {
+		clock := &testClock{time.Unix(0, 0)}
+
+		cache, err := NewItemFileCache(t.Context(), clock, filepath.Join(t.TempDir(), "hn.db"), "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inner := BulkGetterFunc[int, io.ReadCloser](func(_ context.Context, keys []int, do func(int, io.ReadCloser)) []int {
+			for _, k := range keys {
+				do(k, io.NopCloser(bytes.NewReader(newTestItemEntry(t, k, int64(k)))))
+			}
+
+			return nil
+		})
+
+		g := NewBulkItemFileCacheGetter(t.Context(), inner, cache, test.options, func() {}, func(err error) { t.Error(err) })
+
+		ids := make([]int, 100)
+		for i := range ids {
+			ids[i] = i + 1
+		}
+
+		g.Get(t.Context(), ids, func(_ int, r io.ReadCloser) { _ = r.Close() })
+
+		err = g.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stats := g.Stats()
+		if stats.Written != int64(len(ids)) || (stats.Checkpoints != 0) != test.checkpoint {
+			t.Fatalf("%s: expected checkpoints %t, got %+v", test.name, test.checkpoint, stats)
+		}
+
+		if test.checkpoint && stats.WALSize != 0 {
+			t.Fatalf("%s: expected the log truncated after every put, got %+v", test.name, stats)
+		}
+
+		err = cache.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGreedyRead(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan int, 10)
+	ch <- 1
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ch <- 2
+	}()
+
+	v, ok := greedyRead(ch, 3, 0)
+	if !ok || len(v) != 1 {
+		t.Fatalf("expected only the queued value without a wait, got %v", v)
+	}
+
+	v, ok = greedyRead(ch, 3, 20*time.Millisecond)
+	if !ok || len(v) != 1 || v[0] != 2 {
+		t.Fatalf("expected the late value alone after the wait, got %v", v)
+	}
+
+	ch <- 3
+	ch <- 4
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ch <- 5
+	}()
+
+	v, ok = greedyRead(ch, 3, time.Minute)
+	if !ok || len(v) != 3 {
+		t.Fatalf("expected to wait for a full batch, got %v", v)
+	}
+
+	close(ch)
+
+	_, ok = greedyRead(ch, 3, time.Minute)
+	if ok {
+		t.Fatal("expected closed channel")
+	}
+}
@@ -49,33 +49,66 @@ func (g *BulkSingleFlightGetter[TKey, TValue]) Get(
 		return remaining
 	}
 
+	requested := remaining
 	remaining = g.addPending(remaining, do)
 
 	if len(remaining) == 0 {
 		return remaining
 	}
 
-	return g.inner.Get(ctx, remaining, func(key TKey, value TValue) {
+	refused := g.inner.Get(ctx, remaining, func(key TKey, value TValue) {
 		if g.cache != nil && g.shouldCache(key, value) {
 			g.cache.Put(key, value)
 		}
 
-		dos := g.removePending(key)
+		runDos(g.removePending(key), key, value)
+	})
 
-		var err error
-		for _, do := range dos {
-			err = errors.Join(g.safeRunDo(do, key, value))
-		}
+	if len(refused) == 0 {
+		return refused
+	}
+
+	return g.refuse(requested, refused)
+}
+
+// refuse drops the pending calls for the keys the inner getter refused, which this call started, and returns each
+// key once for every time it was requested so duplicates are returned as the BulkGetter contract requires. A call
+// that joined one of them in the meantime is dropped with it rather than waiting forever.
+func (g *BulkSingleFlightGetter[TKey, TValue]) refuse(requested []TKey, refused []TKey) []TKey {
+	isRefused := make(map[TKey]struct{}, len(refused))
+
+	for _, key := range refused {
+		isRefused[key] = struct{}{}
+
+		g.removePending(key)
+	}
 
-		if err != nil {
-			panic(err)
+	result := make([]TKey, 0, len(refused))
+
+	for _, key := range requested {
+		if _, ok := isRefused[key]; ok {
+			result = append(result, key)
 		}
-	})
+	}
+
+	return result
 }
 
 var ErrDoPanic = errors.New("do panic")
 
-func (g *BulkSingleFlightGetter[TKey, TValue]) safeRunDo(
+// runDos calls every do with the value, even if some panic, and then panics with the joined panics if any did.
+func runDos[TKey any, TValue any](dos []func(key TKey, value TValue), key TKey, value TValue) {
+	var err error
+	for _, do := range dos {
+		err = errors.Join(err, safeRunDo(do, key, value))
+	}
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func safeRunDo[TKey any, TValue any](
 	do func(key TKey, value TValue),
 	key TKey,
 	value TValue,
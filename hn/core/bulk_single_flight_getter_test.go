@@ -2,10 +2,13 @@ package core
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 type BulkGetterFunc[TKey comparable, TValue any] func(
@@ -106,3 +109,58 @@ func TestSingleFlightDedup(t *testing.T) {
 	default:
 	}
 }
+
+func TestSingleFlightDuplicates(t *testing.T) {
+	t.Parallel()
+
+	var innerCalls [][]int
+
+	refuse := true
+
+	inner := BulkGetterFunc[int, int](func(_ context.Context, keys []int, do func(int, int)) []int {
+		innerCalls = append(innerCalls, slices.Clone(keys))
+
+		if refuse {
+			return keys
+		}
+
+		for _, k := range keys {
+			do(k, k*10)
+		}
+
+		return nil
+	})
+
+	g := NewBulkSingleFlightGetter(inner, nil, nil)
+
+	// a refused key is returned for each duplicate and its flight is dropped
+	refused := g.Get(t.Context(), []int{1, 2, 1, 1}, func(int, int) { t.Fatal("unexpected do") })
+	if !slices.Equal(refused, []int{1, 2, 1, 1}) {
+		t.Fatalf("expected every duplicate refused, got %v", refused)
+	}
+
+	refuse = false
+
+	var done []int
+
+	refused = g.Get(t.Context(), []int{1, 2, 1, 1}, func(k int, v int) {
+		if v != k*10 {
+			t.Errorf("unexpected value %d for %d", v, k)
+		}
+
+		done = append(done, k)
+	})
+	if len(refused) != 0 {
+		t.Fatalf("unexpected refused %v", refused)
+	}
+
+	slices.Sort(done)
+
+	if !slices.Equal(done, []int{1, 1, 1, 2}) {
+		t.Fatalf("expected do for each duplicate, got %v", done)
+	}
+
+	if diff := cmp.Diff([][]int{{1, 2}, {1, 2}}, innerCalls); diff != "" {
+		t.Fatalf("unexpected inner calls (-want +got):\n%s", diff)
+	}
+}
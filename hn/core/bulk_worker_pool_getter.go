@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
 )
 
 // ErrGetterPanic is passed through to the do method when the getter panics.
@@ -38,10 +39,31 @@ func (g *BulkWorkerPoolGetter[TKey, TValue]) Get(
 			result = g.wrapError(err)
 		}
 
-		do(key, result)
+		if g.workerPool.panicHandler == nil {
+			do(key, result)
+			return
+		}
+
+		g.deliver(key, result, do)
 	})
 }
 
+// deliver passes result to do, and if a stage of do panics, as a transform or cache above the getter might, reports the
+// panic to the panic handler of the pool and passes do the panic as an error instead, so whatever waits on key still
+// gets a value for it.
+func (g *BulkWorkerPoolGetter[TKey, TValue]) deliver(key TKey, result TValue, do func(TKey, TValue)) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err := fmt.Errorf("%v: %w: %v\n%s", key, ErrWorkerPanic, r, debug.Stack())
+			g.workerPool.panicHandler(err)
+			do(key, g.wrapError(err))
+		}
+	}()
+
+	do(key, result)
+}
+
 func safeRunGetter[TKey any, TValue any](ctx context.Context, g Getter[TKey, TValue], key TKey) (_ TValue, err error) {
 	defer func() {
 		r := recover()
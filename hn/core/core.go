@@ -3,6 +3,8 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"sync"
 	"time"
@@ -10,6 +12,7 @@ import (
 
 const (
 	itemPathPrefix = "item/"
+	userPathPrefix = "user/"
 	jsonSuffix     = ".json"
 )
 
@@ -17,6 +20,27 @@ type Clock interface {
 	Now() time.Time
 }
 
+// sleeper is implemented by clocks that control how long waits take, such as in tests.
+type sleeper interface {
+	Sleep(ctx context.Context, d time.Duration)
+}
+
+// Sleep waits for d or until ctx is done, returning an error in the latter case. The wait is made through clock if it
+// implements Sleep(ctx, d), as clocks in tests do.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if s, ok := clock.(sleeper); ok {
+		s.Sleep(ctx, d)
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted: %w", ctx.Err())
+		}
+
+		return nil
+	}
+
+	return sleep(ctx, d)
+}
+
 type readCloserWithError struct {
 	err error
 }
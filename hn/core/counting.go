@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// NewCountingGetter wraps inner so each request is counted in count.
+func NewCountingGetter[TKey any, TValue any](inner Getter[TKey, TValue], count *atomic.Int64) Getter[TKey, TValue] {
+	return &countingGetter[TKey, TValue]{inner, count}
+}
+
+type countingGetter[TKey any, TValue any] struct {
+	inner Getter[TKey, TValue]
+	count *atomic.Int64
+}
+
+func (g *countingGetter[TKey, TValue]) Get(ctx context.Context, key TKey) (TValue, error) {
+	g.count.Add(1)
+	return g.inner.Get(ctx, key)
+}
+
+// NewBulkCountingGetter wraps inner so each key it accepts is counted in count. Keys returned as not queued are not
+// counted, since callers retry them.
+func NewBulkCountingGetter[TKey any, TValue any](
+	inner BulkGetter[TKey, TValue],
+	count *atomic.Int64,
+) BulkGetter[TKey, TValue] {
+	return &bulkCountingGetter[TKey, TValue]{inner, count}
+}
+
+type bulkCountingGetter[TKey any, TValue any] struct {
+	inner BulkGetter[TKey, TValue]
+	count *atomic.Int64
+}
+
+func (g *bulkCountingGetter[TKey, TValue]) Get(
+	ctx context.Context,
+	keys []TKey,
+	do func(key TKey, value TValue),
+) []TKey {
+	remaining := g.inner.Get(ctx, keys, do)
+	g.count.Add(int64(len(keys) - len(remaining)))
+
+	return remaining
+}
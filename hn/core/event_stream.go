@@ -0,0 +1,89 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+type eventStreamContextKey struct{}
+
+// WithEventStream marks requests made with ctx to be served as server-sent event streams, which the Firebase REST API
+// behind the HN API provides for any path. The getter from NewBaseGetter then asks for "text/event-stream" and
+// returns the body as it arrives, which lasts until ctx is canceled or the server closes it.
+func WithEventStream(ctx context.Context) context.Context {
+	return context.WithValue(ctx, eventStreamContextKey{}, true)
+}
+
+// IsEventStream reports whether ctx is marked by WithEventStream.
+func IsEventStream(ctx context.Context) bool {
+	v, _ := ctx.Value(eventStreamContextKey{}).(bool)
+	return v
+}
+
+// Event is a server-sent event. Type is "message" unless the stream names it.
+type Event struct {
+	Type string
+	Data []byte
+}
+
+// maxEventLineSize bounds a line of an event stream; the data of a whole list fits comfortably.
+const maxEventLineSize = 4 * 1024 * 1024
+
+// ReadEvents parses the server-sent events from r, calling yield for each until it returns false or r ends. Comments,
+// retry hints, and event IDs are ignored.
+func ReadEvents(r io.Reader, yield func(Event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEventLineSize)
+
+	var (
+		typ     string
+		data    []byte
+		hasData bool
+	)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if len(line) == 0 {
+			if hasData {
+				event := Event{typ, data}
+				if event.Type == "" {
+					event.Type = "message"
+				}
+
+				if !yield(event) {
+					return nil
+				}
+			}
+
+			typ, data, hasData = "", nil, false
+
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+
+		switch string(field) {
+		case "event":
+			typ = string(value)
+		case "data":
+			if hasData {
+				data = append(data, '\n')
+			}
+
+			data = append(data, value...)
+			hasData = true
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return fmt.Errorf("failed to read event stream: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadEvents(t *testing.T) {
+	t.Parallel()
+
+	stream := ": a comment\r\n" +
+		"event: put\r\n" +
+		"data: {\"path\":\"/\",\"data\":1}\r\n" +
+		"\r\n" +
+		"event: keep-alive\n" +
+		"data: null\n" +
+		"\n" +
+		"data: first\n" +
+		"data:second\n" +
+		"id: 7\n" +
+		"\n" +
+		"event: ignored-without-data\n" +
+		"\n" +
+		"event: unterminated\n" +
+		"data: dropped\n"
+
+	var events []Event
+
+	err := ReadEvents(strings.NewReader(stream), func(event Event) bool {
+		events = append(events, event)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{"put", []byte(`{"path":"/","data":1}`)},
+		{"keep-alive", []byte("null")},
+		{"message", []byte("first\nsecond")},
+	}
+
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	n := 0
+
+	err = ReadEvents(strings.NewReader(stream), func(Event) bool {
+		n++
+		return false
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("expected to stop after the first event, got %d and %v", n, err)
+	}
+}
+
+func TestBaseGetterEventStream(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Accept"))
+	}))
+	defer server.Close()
+
+	getter := NewBaseGetter(server.Client(), server.URL+"/")
+
+	for _, test := range []struct {
+		expected string
+		stream   bool
+	}{
+		{"", false},
+		{"text/event-stream", true},
+	} {
+		ctx := t.Context()
+		if test.stream {
+			ctx = WithEventStream(ctx)
+		}
+
+		reader, err := getter.Get(ctx, "maxitem.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(reader)
+		_ = reader.Close()
+
+		if err != nil || string(body) != test.expected {
+			t.Fatalf("expected Accept %q, got %q and %v", test.expected, body, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInjectedTimeout is returned by a flaky getter for an injected timeout.
+var ErrInjectedTimeout = errors.New("injected timeout")
+
+// FailureSpec configures the failures injected by NewFlakyGetter. Rates are probabilities from 0 to 1 checked in
+// field order, so at most one failure is injected per request.
+type FailureSpec struct {
+	// TimeoutRate requests wait Timeout, or until the context is done, then fail with ErrInjectedTimeout.
+	TimeoutRate float64
+	// ServerErrorRate requests fail with a GetterError with code 500.
+	ServerErrorRate float64
+	// MalformedRate requests succeed with the first half of the inner body, which is malformed for JSON.
+	MalformedRate float64
+	// SlowRate requests succeed after waiting SlowDelay.
+	SlowRate  float64
+	Timeout   time.Duration
+	SlowDelay time.Duration
+	// Seed makes the injected failures deterministic for a given sequence of requests.
+	Seed uint64
+}
+
+// NewFlakyGetter wraps inner to inject the failures described by spec, for exercising error handling in tests and
+// load experiments.
+func NewFlakyGetter(inner Getter[string, io.ReadCloser], spec FailureSpec) Getter[string, io.ReadCloser] {
+	return &flakyGetter{inner, rand.New(rand.NewPCG(spec.Seed, spec.Seed)), spec, sync.Mutex{}} //nolint:gosec // G404
+}
+
+type flakyGetter struct {
+	inner Getter[string, io.ReadCloser]
+	rng   *rand.Rand
+	spec  FailureSpec
+	mu    sync.Mutex
+}
+
+func (g *flakyGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	g.mu.Lock()
+	roll := g.rng.Float64()
+	g.mu.Unlock()
+
+	spec := g.spec
+
+	if roll -= spec.TimeoutRate; roll < 0 {
+		err := sleep(ctx, spec.Timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrInjectedTimeout, path)
+	}
+
+	if roll -= spec.ServerErrorRate; roll < 0 {
+		return nil, &GetterError{path, http.StatusInternalServerError}
+	}
+
+	if roll -= spec.MalformedRate; roll < 0 {
+		return g.getMalformed(ctx, path)
+	}
+
+	if roll -= spec.SlowRate; roll < 0 {
+		err := sleep(ctx, spec.SlowDelay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return g.inner.Get(ctx, path)
+}
+
+func (g *flakyGetter) getMalformed(ctx context.Context, path string) (io.ReadCloser, error) {
+	r, err := g.inner.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = r.Close() }()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(body[:len(body)/2])), nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFlakyGetter(t *testing.T) {
+	t.Parallel()
+
+	inner := &fakeGetter{map[string]string{"item/1.json": `{"id":1,"type":"story"}`}}
+
+	var spec FailureSpec
+	spec.TimeoutRate = 0.25
+	spec.ServerErrorRate = 0.25
+	spec.MalformedRate = 0.25
+	spec.SlowRate = 0.25
+	spec.Timeout = time.Millisecond
+	spec.SlowDelay = time.Millisecond
+	spec.Seed = 1
+
+	getter := NewFlakyGetter(inner, spec)
+
+	counts := make(map[string]int)
+
+	for range 200 {
+		r, err := getter.Get(t.Context(), "item/1.json")
+
+		var getterErr *GetterError
+
+		switch {
+		case errors.Is(err, ErrInjectedTimeout):
+			counts["timeout"]++
+		case errors.As(err, &getterErr) && getterErr.Code == http.StatusInternalServerError:
+			counts["500"]++
+		case err != nil:
+			t.Fatal(err)
+		default:
+			var v map[string]any
+
+			err = json.NewDecoder(r).Decode(&v)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				counts["malformed"]++
+			} else if err == nil {
+				counts["ok"]++
+			}
+
+			_ = r.Close()
+		}
+	}
+
+	for _, kind := range []string{"timeout", "500", "malformed", "ok"} {
+		if counts[kind] < 25 {
+			t.Fatalf("expected roughly a quarter %s, got %v", kind, counts)
+		}
+	}
+
+	var none FailureSpec
+
+	r, err := NewFlakyGetter(inner, none).Get(t.Context(), "item/1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r.Close()
+}
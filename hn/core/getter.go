@@ -28,12 +28,17 @@ func (g *baseGetter) Get(ctx context.Context, path string) (io.ReadCloser, error
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	if IsEventStream(ctx) {
+		request.Header.Set("Accept", "text/event-stream")
+	}
+
 	response, err := g.httpClient.Do(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 
 	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
 		return nil, &GetterError{path, response.StatusCode}
 	}
 
@@ -46,6 +51,13 @@ func NewItemGetter(inner Getter[string, io.ReadCloser]) Getter[int, io.ReadClose
 	}}
 }
 
+// NewUserGetter returns a getter of users by username.
+func NewUserGetter(inner Getter[string, io.ReadCloser]) Getter[string, io.ReadCloser] {
+	return &rekeyGetter[string, string, io.ReadCloser]{inner, func(username string) string {
+		return userPathPrefix + username + jsonSuffix
+	}}
+}
+
 type rekeyGetter[TOuter any, TInner any, TValue any] struct {
 	inner Getter[TInner, TValue]
 	rekey func(TOuter) TInner
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bytes"
+)
+
+// ItemIDAndTime extracts the top-level "id" and "time" fields of a raw item without decoding the rest of it. Other
+// values are skipped by searching for their delimiters, and scanning stops once both fields are found, so the rest of
+// the value is not validated. Missing fields are returned as 0. ok is false for anything the scanner does not
+// handle, such as escaped keys or non-integer values, in which case callers should fall back to encoding/json.
+func ItemIDAndTime(value []byte) (int, int64, bool) {
+	var (
+		id       int64
+		t        int64
+		haveID   bool
+		haveTime bool
+	)
+
+	i := skipJSONSpace(value, 0)
+	if i >= len(value) || value[i] != '{' {
+		return 0, 0, false
+	}
+
+	i = skipJSONSpace(value, i+1)
+	if i < len(value) && value[i] == '}' {
+		return 0, 0, true
+	}
+
+	for i < len(value) {
+		if value[i] != '"' {
+			return 0, 0, false
+		}
+
+		end := bytes.IndexByte(value[i+1:], '"')
+		if end < 0 {
+			return 0, 0, false
+		}
+
+		key := value[i+1 : i+1+end]
+		if bytes.IndexByte(key, '\\') >= 0 {
+			return 0, 0, false
+		}
+
+		i = skipJSONSpace(value, i+end+2)
+		if i >= len(value) || value[i] != ':' {
+			return 0, 0, false
+		}
+
+		i = skipJSONSpace(value, i+1)
+
+		var ok bool
+
+		switch string(key) {
+		case "id":
+			id, i, ok = scanJSONInt(value, i)
+			haveID = true
+		case "time":
+			t, i, ok = scanJSONInt(value, i)
+			haveTime = true
+		default:
+			i, ok = skipJSONValue(value, i)
+		}
+
+		if !ok {
+			return 0, 0, false
+		}
+
+		if haveID && haveTime {
+			return int(id), t, true
+		}
+
+		i = skipJSONSpace(value, i)
+		if i >= len(value) {
+			return 0, 0, false
+		}
+
+		switch value[i] {
+		case ',':
+			i = skipJSONSpace(value, i+1)
+		case '}':
+			return int(id), t, true
+		default:
+			return 0, 0, false
+		}
+	}
+
+	return 0, 0, false
+}
+
+func skipJSONSpace(value []byte, i int) int {
+	for i < len(value) && (value[i] == ' ' || value[i] == '\t' || value[i] == '\n' || value[i] == '\r') {
+		i++
+	}
+
+	return i
+}
+
+// scanJSONInt parses the integer at value[i:], rejecting fractions, exponents, and values that may overflow.
+func scanJSONInt(value []byte, i int) (int64, int, bool) {
+	const (
+		base10    = 10
+		maxDigits = 18
+	)
+
+	negative := i < len(value) && value[i] == '-'
+	if negative {
+		i++
+	}
+
+	start := i
+
+	var v int64
+
+	for i < len(value) && '0' <= value[i] && value[i] <= '9' {
+		v = v*base10 + int64(value[i]-'0')
+		i++
+	}
+
+	if i == start || i-start > maxDigits {
+		return 0, i, false
+	}
+
+	if i < len(value) && (value[i] == '.' || value[i] == 'e' || value[i] == 'E') {
+		return 0, i, false
+	}
+
+	if negative {
+		v = -v
+	}
+
+	return v, i, true
+}
+
+// skipJSONValue returns the index just past the value at value[i:]. Strings are skipped by searching for quotes, and
+// objects and arrays by counting brackets outside of strings.
+func skipJSONValue(value []byte, i int) (int, bool) {
+	depth := 0
+
+	for i < len(value) {
+		switch value[i] {
+		case '"':
+			end, ok := skipJSONString(value, i)
+			if !ok {
+				return i, false
+			}
+
+			i = end
+		case '{', '[':
+			depth++
+			i++
+		case '}', ']':
+			if depth == 0 {
+				return i, true
+			}
+
+			depth--
+			i++
+		case ',':
+			if depth == 0 {
+				return i, true
+			}
+
+			i++
+		default:
+			i++
+		}
+
+		if depth == 0 && i > 0 && (value[i-1] == '"' || value[i-1] == '}' || value[i-1] == ']') {
+			return i, true
+		}
+	}
+
+	return i, depth == 0
+}
+
+// skipJSONString returns the index just past the string starting with the quote at value[i].
+func skipJSONString(value []byte, i int) (int, bool) {
+	i++
+
+	for {
+		end := bytes.IndexByte(value[i:], '"')
+		if end < 0 {
+			return i, false
+		}
+
+		i += end
+
+		backslashes := 0
+		for j := i - 1; j >= 0 && value[j] == '\\'; j-- {
+			backslashes++
+		}
+
+		i++
+
+		if backslashes%2 == 0 {
+			return i, true
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestItemIDAndTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		id    int
+		time  int64
+		ok    bool
+	}{
+		{`{"by":"a","id":5,"kids":[1,[2],{"x":"}"}],"text":"say \"time\":9 \\","time":100,"type":"story"}`, 5, 100, true},
+		{` { "time" : 7 , "id" : 8 } `, 8, 7, true},
+		{`{"id":-3,"dead":true}`, -3, 0, true},
+		{`{}`, 0, 0, true},
+		{`{"title":"x"}`, 0, 0, true},
+		{`{"id":1.5,"time":1}`, 0, 0, false},
+		{`{"id":null,"time":1}`, 0, 0, false},
+		{`{"\u0069d":1,"time":1}`, 0, 0, false},
+		{`{"id":1234567890123456789012,"time":1}`, 0, 0, false},
+		{`[1]`, 0, 0, false},
+		{`null`, 0, 0, false},
+		{`{"id":1`, 0, 0, false},
+		{`{"text":"unterminated`, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		id, tm, ok := ItemIDAndTime([]byte(test.value))
+		if id != test.id || tm != test.time || ok != test.ok {
+			t.Errorf("%s: expected %d %d %v, got %d %d %v", test.value, test.id, test.time, test.ok, id, tm, ok)
+		}
+	}
+}
+
+func loadRawItems(tb testing.TB) [][]byte {
+	tb.Helper()
+
+	var values [][]byte
+
+	for i := testdata.MaxItem; i > testdata.MinItem; i-- {
+		reader, err := testdata.Getter.Get(tb.Context(), "item/"+strconv.Itoa(i))
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		value, err := io.ReadAll(reader)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		values = append(values, value)
+	}
+
+	return values
+}
+
+type idAndTime struct {
+	ID   int   `json:"id"`
+	Time int64 `json:"time"`
+}
+
+func TestItemIDAndTimeCorpus(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range loadRawItems(t) {
+		var expected idAndTime
+
+		err := json.Unmarshal(value, &expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, tm, ok := ItemIDAndTime(value)
+		if !ok || id != expected.ID || tm != expected.Time {
+			t.Fatalf("%s: expected %d %d, got %d %d %v", value, expected.ID, expected.Time, id, tm, ok)
+		}
+	}
+}
+
+func BenchmarkItemIDAndTime(b *testing.B) {
+	values := loadRawItems(b)
+
+	b.Run("scanner", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			for _, value := range values {
+				_, _, _ = ItemIDAndTime(value)
+			}
+		}
+	})
+
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			for _, value := range values {
+				var result idAndTime
+
+				_ = json.Unmarshal(value, &result)
+			}
+		}
+	})
+}
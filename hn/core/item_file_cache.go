@@ -8,7 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 // DefaultStaleIf marks stale at 60 seconds after creation, then frequently for the first few days after an item is
@@ -19,7 +25,9 @@ const DefaultStaleIf = "(:now-refreshed)>" +
 type ItemFileCache struct {
 	db      *sql.DB
 	clock   Clock
+	path    string
 	staleIf string
+	history atomic.Bool
 }
 
 func NewItemFileCache(
@@ -43,7 +51,7 @@ func NewItemFileCache(
 		staleIf = DefaultStaleIf
 	}
 
-	c := &ItemFileCache{db, clock, staleIf}
+	c := &ItemFileCache{db, clock, path, staleIf, atomic.Bool{}}
 
 	err = c.execContext(ctx, "PRAGMA journal_mode = WAL")
 	if err != nil {
@@ -66,6 +74,14 @@ func NewItemFileCache(
 		return nil, err
 	}
 
+	// reading the newest item finds a damaged item table now, when it can be moved aside, rather than mid-run
+	var newest int
+
+	err = db.QueryRowContext(ctx, "SELECT ID FROM item ORDER BY ID DESC LIMIT 1").Scan(&newest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read item table: %w", err)
+	}
+
 	err = c.execContext(
 		ctx,
 		"EXPLAIN SELECT ID, refreshed, Time, value FROM item WHERE "+staleIf,
@@ -74,9 +90,122 @@ func NewItemFileCache(
 		return nil, err
 	}
 
+	err = c.loadHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
+// IsItemFileCacheCorrupt reports whether err from an ItemFileCache means its file is damaged or is not a SQLite
+// database at all.
+func IsItemFileCacheCorrupt(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrCorrupt || sqliteErr.Code == sqlite3.ErrNotADB)
+}
+
+// MoveItemFileCacheAside renames the database at path, along with its write-ahead log and shared memory files, to
+// <path>.corrupt-<timestamp> so a new cache can be created in its place. It returns the new path of the database.
+func MoveItemFileCacheAside(path string, now time.Time) (string, error) {
+	moved := path + ".corrupt-" + now.UTC().Format("20060102T150405Z")
+
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Rename(path+suffix, moved+suffix)
+		if err != nil && (suffix == "" || !errors.Is(err, fs.ErrNotExist)) {
+			return "", fmt.Errorf("failed to move aside corrupt cache: %w", err)
+		}
+	}
+
+	return moved, nil
+}
+
+func (c *ItemFileCache) loadHistory(ctx context.Context) (err error) {
+	rows, err := c.queryContext(ctx, "SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'item_version'")
+	if err != nil {
+		return err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	c.history.Store(rows.Next())
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("file cache history rows err: %w", err)
+	}
+
+	return nil
+}
+
+// EnableHistory makes Put keep the previous version of any item it replaces with a different value.
+// History is recorded in the database itself, so once enabled it stays enabled for every later user of the file.
+func (c *ItemFileCache) EnableHistory(ctx context.Context) error {
+	err := c.execContext(ctx, `
+		CREATE TABLE IF NOT EXISTS item_version(
+		  ID INTEGER NOT NULL,
+		  refreshed INTEGER NOT NULL,
+		  Time INTEGER NOT NULL,
+		  value BLOB NOT NULL
+    )`)
+	if err != nil {
+		return err
+	}
+
+	err = c.execContext(ctx, "CREATE INDEX IF NOT EXISTS item_version_ID ON item_version (ID, refreshed)")
+	if err != nil {
+		return err
+	}
+
+	c.history.Store(true)
+
+	return nil
+}
+
+// HistoryEnabled reports whether Put keeps previous versions of replaced items.
+func (c *ItemFileCache) HistoryEnabled() bool {
+	return c.history.Load()
+}
+
+// Versions returns the previous versions of an item kept by history mode, most recently replaced first.
+// Refreshed is the last time each version was retrieved. The current version is not included.
+func (c *ItemFileCache) Versions(ctx context.Context, id int) (_ []ItemFileCacheEntry, err error) {
+	if !c.history.Load() {
+		return nil, nil
+	}
+
+	rows, err := c.queryContext(
+		ctx,
+		"SELECT ID, refreshed, Time, value FROM item_version WHERE ID = ? ORDER BY refreshed DESC",
+		id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	var result []ItemFileCacheEntry
+
+	for rows.Next() {
+		var e ItemFileCacheEntry
+
+		err = rows.Scan(&e.ID, &e.Refreshed, &e.Time, &e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("file cache versions scan: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("file cache versions rows err: %w", err)
+	}
+
+	return result, nil
+}
+
 func (c *ItemFileCache) Get(ctx context.Context, ids []int, do func(id int, reader io.ReadCloser)) []int {
 	did := make([]bool, len(ids))
 	err := c.get(ctx, ids, did, do)
@@ -157,13 +286,177 @@ func getRows(rows *sql.Rows, indices map[int][]int, did []bool, do func(id int,
 
 var errUnexpectedResultFromDatabase = errors.New("unexpected result from database")
 
+type ItemFileCacheEntry struct {
+	Value     []byte
+	ID        int
+	Refreshed int64
+	Time      int64
+	Stale     bool
+}
+
+// Entry returns the cached row for an item regardless of staleness, or nil if the item is not cached.
+func (c *ItemFileCache) Entry(ctx context.Context, id int) (_ *ItemFileCacheEntry, err error) {
+	query := "SELECT ID, refreshed, Time, value, (" + c.staleIf + ") FROM item WHERE ID = :id"
+
+	rows, err := c.queryContext(ctx, query, sql.Named("now", c.clock.Now().Unix()), sql.Named("id", id))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	if !rows.Next() {
+		err = rows.Err()
+		if err != nil {
+			return nil, fmt.Errorf("file cache entry rows err: %w", err)
+		}
+
+		return nil, nil
+	}
+
+	var e ItemFileCacheEntry
+
+	err = rows.Scan(&e.ID, &e.Refreshed, &e.Time, &e.Value, &e.Stale)
+	if err != nil {
+		return nil, fmt.Errorf("file cache entry scan: %w", err)
+	}
+
+	return &e, nil
+}
+
+// Sample returns up to n random cached items along with whether the stale policy currently considers them stale.
+func (c *ItemFileCache) Sample(ctx context.Context, n int) (_ []ItemFileCacheEntry, err error) {
+	query := "SELECT ID, refreshed, Time, value, (" + c.staleIf + ") FROM item ORDER BY random() LIMIT :n"
+
+	rows, err := c.queryContext(ctx, query, sql.Named("now", c.clock.Now().Unix()), sql.Named("n", n))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	result := make([]ItemFileCacheEntry, 0, n)
+
+	for rows.Next() {
+		var e ItemFileCacheEntry
+
+		err = rows.Scan(&e.ID, &e.Refreshed, &e.Time, &e.Value, &e.Stale)
+		if err != nil {
+			return nil, fmt.Errorf("file cache sample scan: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("file cache sample rows err: %w", err)
+	}
+
+	return result, nil
+}
+
+// ValueSize returns the number of cached items and the average size of their values in bytes, which estimates the
+// size of items not yet retrieved.
+func (c *ItemFileCache) ValueSize(ctx context.Context) (int64, float64, error) {
+	var (
+		count   int64
+		average float64
+	)
+
+	err := c.db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(AVG(LENGTH(value)), 0) FROM item").Scan(&count, &average)
+	if err != nil {
+		return 0, 0, fmt.Errorf("file cache value size scan: %w", err)
+	}
+
+	return count, average, nil
+}
+
+// Range calls do for every cached item ordered by ID regardless of staleness, stopping at the first error.
+// The value passed to do is only valid until do returns.
+func (c *ItemFileCache) Range(ctx context.Context, ascending bool, do func(id int, value []byte) error) (err error) {
+	query := "SELECT ID, value FROM item ORDER BY ID DESC"
+	if ascending {
+		query = "SELECT ID, value FROM item ORDER BY ID ASC"
+	}
+
+	rows, err := c.queryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	for rows.Next() {
+		var id int
+		var data sql.RawBytes
+
+		err = rows.Scan(&id, &data)
+		if err != nil {
+			return fmt.Errorf("file cache range scan: %w", err)
+		}
+
+		err = do(id, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("file cache range rows err: %w", err)
+	}
+
+	return nil
+}
+
+// Close checkpoints and truncates the write-ahead log so the database file is complete on its own, then closes the
+// database. A checkpoint blocked by other connections to the file is not an error; the last connection to close
+// completes it.
 func (c *ItemFileCache) Close() error {
+	_, checkpointErr := c.Checkpoint(context.Background())
+
 	err := c.db.Close()
 	if err != nil {
-		return fmt.Errorf("failed to close db: %w", err)
+		return errors.Join(checkpointErr, fmt.Errorf("failed to close db: %w", err))
 	}
 
-	return nil
+	return checkpointErr
+}
+
+// Checkpoint copies the write-ahead log into the database and truncates it to zero bytes. SQLite checkpoints
+// automatically as the log grows but never shrinks it, so long-running writers should checkpoint periodically.
+// It returns true if the checkpoint could not complete because of other connections to the file.
+func (c *ItemFileCache) Checkpoint(ctx context.Context) (bool, error) {
+	var busy, logFrames, checkpointedFrames int
+
+	err := c.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logFrames, &checkpointedFrames)
+	if err != nil {
+		return false, fmt.Errorf("failed to checkpoint db: %w", err)
+	}
+
+	return busy != 0, nil
+}
+
+// VacuumInto writes a compacted copy of the database to path, which must not exist. Unlike VACUUM, it only needs a
+// read transaction, so other connections can keep reading and writing while it runs, although their later writes
+// are not in the copy.
+func (c *ItemFileCache) VacuumInto(ctx context.Context, path string) error {
+	return c.execContext(ctx, "VACUUM INTO ?", path)
+}
+
+// WALSize returns the size in bytes of the write-ahead log file, or 0 if there is none.
+func (c *ItemFileCache) WALSize() (int64, error) {
+	stat, err := os.Stat(c.path + "-wal")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat write-ahead log: %w", err)
+	}
+
+	return stat.Size(), nil
 }
 
 type ItemCacheEntry interface {
@@ -185,17 +478,22 @@ func (c *ItemFileCache) Put(ctx context.Context, items [][]byte) error {
 			continue
 		}
 
-		var result struct {
-			ID   int   `json:"id"`
-			Time int64 `json:"time"`
-		}
+		id, t, ok := ItemIDAndTime(e)
+		if !ok {
+			var result struct {
+				ID   int   `json:"id"`
+				Time int64 `json:"time"`
+			}
 
-		err := json.Unmarshal(e, &result)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal item: %w", err)
+			err := json.Unmarshal(e, &result)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+
+			id, t = result.ID, result.Time
 		}
 
-		params = append(params, result.ID, c.clock.Now().Unix(), result.Time, e)
+		params = append(params, id, c.clock.Now().Unix(), t, e)
 	}
 
 	if len(params) == 0 {
@@ -204,6 +502,10 @@ func (c *ItemFileCache) Put(ctx context.Context, items [][]byte) error {
 
 	query := c.putQuery(params)
 
+	if c.history.Load() {
+		return c.putWithHistory(ctx, query, params)
+	}
+
 	err := c.execContext(ctx, query, params...)
 	if err != nil {
 		return err
@@ -212,6 +514,58 @@ func (c *ItemFileCache) Put(ctx context.Context, items [][]byte) error {
 	return nil
 }
 
+// putWithHistory copies rows whose value is about to change into item_version before replacing them.
+func (c *ItemFileCache) putWithHistory(ctx context.Context, query string, params []interface{}) (err error) {
+	n := len(params) / numPutParams
+	historyParams := make([]interface{}, 0, n*2)
+
+	for i := range n {
+		historyParams = append(historyParams, params[i*numPutParams], params[i*numPutParams+numPutParams-1])
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("WITH new(ID,value) AS (VALUES (?,?)")
+
+	for range n - 1 {
+		sb.WriteString(",(?,?)")
+	}
+
+	sb.WriteString(") INSERT INTO item_version (ID,refreshed,Time,value) ")
+	sb.WriteString("SELECT DISTINCT item.ID,item.refreshed,item.Time,item.value FROM item ")
+	sb.WriteString("JOIN new ON item.ID = new.ID WHERE item.value <> new.value")
+
+	historyQuery := sb.String()
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, historyQuery, historyParams...)
+	if err != nil {
+		return fmt.Errorf("exec failed: %s %w", historyQuery, err)
+	}
+
+	_, err = tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("exec failed: %s %w", query, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 func (c *ItemFileCache) putQuery(params []interface{}) string {
 	var sb strings.Builder
 
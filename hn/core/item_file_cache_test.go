@@ -1,10 +1,12 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
 	"math"
+	"os"
 	"path/filepath"
 	"sort"
 	"testing"
@@ -287,3 +289,405 @@ func (c *testClock) Now() time.Time {
 
 func (c *testClock) Sleep(_ context.Context, _ time.Duration) {
 }
+
+func TestFileCache_Range(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	file := filepath.Join(t.TempDir(), "hn.db")
+
+	fc, err := NewItemFileCache(t.Context(), clock, file, "1")
+	if err != nil {
+		t.Fatalf("NewItemFileCache failed: %v", err)
+	}
+
+	err = fc.Put(t.Context(), [][]byte{
+		newTestItemEntry(t, 2, 2),
+		newTestItemEntry(t, 3, 3),
+		newTestItemEntry(t, 1, 1),
+	})
+	if err != nil {
+		t.Fatalf("putToCache failed: %v", err)
+	}
+
+	for _, ascending := range []bool{true, false} {
+		var ids []int
+
+		err = fc.Range(t.Context(), ascending, func(id int, value []byte) error {
+			var item testItemCacheEntry
+
+			err := json.Unmarshal(value, &item)
+			if err != nil {
+				return err
+			}
+
+			if item.ID != id {
+				t.Fatalf("ID and value.ID mismatch: %d != %d", item.ID, id)
+			}
+
+			ids = append(ids, id)
+
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Range failed: %v", err)
+		}
+
+		expected := []int{3, 2, 1}
+		if ascending {
+			expected = []int{1, 2, 3}
+		}
+
+		diff := cmp.Diff(expected, ids)
+		if diff != "" {
+			t.Fatalf("(-want +got):\n%s", diff)
+		}
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
+
+func TestFileCache_Sample(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	staleIf := "refreshed < (:now - 150)"
+	file := filepath.Join(t.TempDir(), "hn.db")
+
+	fc, err := NewItemFileCache(t.Context(), clock, file, staleIf)
+	if err != nil {
+		t.Fatalf("NewItemFileCache failed: %v", err)
+	}
+
+	err = fc.Put(t.Context(), [][]byte{newTestItemEntry(t, 1, 0), newTestItemEntry(t, 2, 0)})
+	if err != nil {
+		t.Fatalf("putToCache failed: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	err = fc.Put(t.Context(), [][]byte{newTestItemEntry(t, 3, 0)})
+	if err != nil {
+		t.Fatalf("putToCache failed: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+
+	sample, err := fc.Sample(t.Context(), 10)
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+
+	stale := make(map[int]bool, len(sample))
+
+	for _, e := range sample {
+		var item testItemCacheEntry
+
+		err = json.Unmarshal(e.Value, &item)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if item.ID != e.ID {
+			t.Fatalf("ID and value.ID mismatch: %d != %d", item.ID, e.ID)
+		}
+
+		stale[e.ID] = e.Stale
+	}
+
+	diff := cmp.Diff(map[int]bool{1: true, 2: true, 3: false}, stale)
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	sample, err = fc.Sample(t.Context(), 2)
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+
+	if len(sample) != 2 {
+		t.Fatalf("expected 2 sampled, got %d", len(sample))
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
+
+func TestFileCache_ValueSize(t *testing.T) {
+	t.Parallel()
+
+	fc, err := NewItemFileCache(t.Context(), &testClock{time.Unix(0, 0)}, filepath.Join(t.TempDir(), "hn.db"), "")
+	if err != nil {
+		t.Fatalf("NewItemFileCache failed: %v", err)
+	}
+
+	count, average, err := fc.ValueSize(t.Context())
+	if err != nil || count != 0 || average != 0 {
+		t.Fatalf("expected an empty cache, got %d items of %g bytes: %v", count, average, err)
+	}
+
+	err = fc.Put(t.Context(), [][]byte{newTestItemEntry(t, 1, 0), newTestItemEntry(t, 22, 0)})
+	if err != nil {
+		t.Fatalf("putToCache failed: %v", err)
+	}
+
+	expected := 0.0
+
+	for _, id := range []int{1, 22} {
+		e, err := fc.Entry(t.Context(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected += float64(len(e.Value)) / 2
+	}
+
+	count, average, err = fc.ValueSize(t.Context())
+	if err != nil || count != 2 || average != expected {
+		t.Fatalf("expected 2 items of %g bytes, got %d items of %g bytes: %v", expected, count, average, err)
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
+
+func TestFileCache_History(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	file := filepath.Join(t.TempDir(), "hn.db")
+
+	fc, err := NewItemFileCache(t.Context(), clock, file, "1")
+	if err != nil {
+		t.Fatalf("NewItemFileCache failed: %v", err)
+	}
+
+	if fc.HistoryEnabled() {
+		t.Fatal("expected history disabled by default")
+	}
+
+	err = fc.EnableHistory(t.Context())
+	if err != nil {
+		t.Fatalf("EnableHistory failed: %v", err)
+	}
+
+	put := func(fc *ItemFileCache, values ...[]byte) {
+		t.Helper()
+
+		err := fc.Put(t.Context(), values)
+		if err != nil {
+			t.Fatalf("putToCache failed: %v", err)
+		}
+
+		clock.Advance(time.Minute)
+	}
+
+	put(fc, newTestItemEntry(t, 1, 1), newTestItemEntry(t, 2, 1))
+	put(fc, newTestItemEntry(t, 1, 1))
+	put(fc, newTestItemEntry(t, 1, 2), newTestItemEntry(t, 1, 2))
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	fc, err = NewItemFileCache(t.Context(), clock, file, "1")
+	if err != nil {
+		t.Fatalf("NewItemFileCache failed: %v", err)
+	}
+
+	if !fc.HistoryEnabled() {
+		t.Fatal("expected history to remain enabled for the file")
+	}
+
+	put(fc, newTestItemEntry(t, 1, 3))
+
+	versions, err := fc.Versions(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("Versions failed: %v", err)
+	}
+
+	type version struct {
+		refreshed int64
+		time      int64
+	}
+
+	got := make([]version, 0, len(versions))
+	for _, v := range versions {
+		got = append(got, version{v.Refreshed, v.Time})
+	}
+
+	diff := cmp.Diff([]version{{120, 2}, {60, 1}}, got, cmp.AllowUnexported(version{}))
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	versions, err = fc.Versions(t.Context(), 2)
+	if err != nil {
+		t.Fatalf("Versions failed: %v", err)
+	}
+
+	if len(versions) != 0 {
+		t.Fatalf("expected no versions for unchanged item, got %d", len(versions))
+	}
+
+	entry, err := fc.Entry(t.Context(), 1)
+	if err != nil {
+		t.Fatalf("Entry failed: %v", err)
+	}
+
+	if entry == nil || entry.Refreshed != 180 || entry.Time != 3 || !entry.Stale {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	entry, err = fc.Entry(t.Context(), 3)
+	if err != nil {
+		t.Fatalf("Entry failed: %v", err)
+	}
+
+	if entry != nil {
+		t.Fatalf("expected no entry, got %+v", entry)
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
+
+func TestFileCache_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	file := filepath.Join(t.TempDir(), "hn.db")
+
+	fc, err := NewItemFileCache(t.Context(), clock, file, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = newTestItemEntry(t, i+1, int64(i))
+	}
+
+	err = fc.Put(t.Context(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := fc.WALSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size == 0 {
+		t.Fatal("expected the put to grow the write-ahead log")
+	}
+
+	busy, err := fc.Checkpoint(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if busy {
+		t.Fatal("expected the checkpoint to complete")
+	}
+
+	size, err = fc.WALSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 0 {
+		t.Fatalf("expected the checkpoint to truncate the write-ahead log, got %d bytes", size)
+	}
+
+	err = fc.Put(t.Context(), items[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err = NewItemFileCache(t.Context(), clock, file, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err = fc.WALSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 0 {
+		t.Fatalf("expected close to truncate the write-ahead log, got %d bytes", size)
+	}
+
+	var got []int
+
+	remaining := fc.Get(t.Context(), []int{1, 1000}, makeLogAndCheckCallback(t, &got))
+	if len(remaining) != 0 || len(got) != 2 {
+		t.Fatalf("expected both items after reopening, got %v", got)
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileCache_Corrupt(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	file := filepath.Join(t.TempDir(), "hn.db")
+
+	err := os.WriteFile(file, bytes.Repeat([]byte("not a database"), 1000), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewItemFileCache(t.Context(), clock, file, "")
+	if !IsItemFileCacheCorrupt(err) {
+		t.Fatalf("expected a corrupt cache error, got %v", err)
+	}
+
+	moved, err := MoveItemFileCacheAside(file, time.Date(2025, 4, 20, 1, 2, 3, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved != file+".corrupt-20250420T010203Z" {
+		t.Fatalf("unexpected moved path %s", moved)
+	}
+
+	_, err = os.Stat(moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := NewItemFileCache(t.Context(), clock, file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if IsItemFileCacheCorrupt(os.ErrNotExist) {
+		t.Fatal("expected other errors not to be corrupt")
+	}
+}
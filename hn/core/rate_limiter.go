@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidRateSchedule is returned when parsing a malformed rate schedule.
+var ErrInvalidRateSchedule = errors.New("invalid rate schedule")
+
+// RateWindow is a share of a rate limit in effect every day from Start to End, which are times of day as durations
+// since midnight. A window ending before it starts runs past midnight.
+type RateWindow struct {
+	Start time.Duration
+	End   time.Duration
+	Share float64
+}
+
+// RateSchedule is the share of a rate limit in effect at each time of day: that of the first window containing the
+// time, or Default outside of them.
+type RateSchedule struct {
+	Windows []RateWindow
+	Default float64
+}
+
+// ParseRateSchedule parses comma-separated windows like "00:00-08:00=100%" in the local time of requests, and
+// optionally a share like "20%" without a window for the rest of the day, which is otherwise 100%.
+func ParseRateSchedule(value string) (RateSchedule, error) {
+	schedule := RateSchedule{nil, 1}
+	hasDefault := false
+
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+
+		window, share, ok := strings.Cut(entry, "=")
+		if !ok {
+			if hasDefault {
+				return RateSchedule{}, fmt.Errorf("%w: more than one share without a window", ErrInvalidRateSchedule)
+			}
+
+			share, hasDefault = window, true
+		}
+
+		s, err := parseRateShare(share)
+		if err != nil {
+			return RateSchedule{}, err
+		}
+
+		if !ok {
+			schedule.Default = s
+			continue
+		}
+
+		start, end, ok := strings.Cut(window, "-")
+		if !ok {
+			return RateSchedule{}, fmt.Errorf("%w: window %q is not start-end", ErrInvalidRateSchedule, window)
+		}
+
+		w := RateWindow{0, 0, s}
+
+		w.Start, err = parseTimeOfDay(start)
+		if err != nil {
+			return RateSchedule{}, err
+		}
+
+		w.End, err = parseTimeOfDay(end)
+		if err != nil {
+			return RateSchedule{}, err
+		}
+
+		schedule.Windows = append(schedule.Windows, w)
+	}
+
+	return schedule, nil
+}
+
+func parseRateShare(value string) (float64, error) {
+	percent, ok := strings.CutSuffix(strings.TrimSpace(value), "%")
+	if !ok {
+		return 0, fmt.Errorf("%w: share %q is not a percentage", ErrInvalidRateSchedule, value)
+	}
+
+	n, err := strconv.ParseFloat(percent, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w: share %q is not a positive percentage", ErrInvalidRateSchedule, value)
+	}
+
+	const percentPerShare = 100
+
+	return n / percentPerShare, nil
+}
+
+// parseTimeOfDay parses HH:MM as a duration since midnight, from 00:00 to 24:00.
+func parseTimeOfDay(value string) (time.Duration, error) {
+	const (
+		hoursPerDay    = 24
+		minutesPerHour = 60
+	)
+
+	h, m, ok := strings.Cut(strings.TrimSpace(value), ":")
+
+	hours, hErr := strconv.Atoi(h)
+	minutes, mErr := strconv.Atoi(m)
+
+	if !ok || hErr != nil || mErr != nil || hours < 0 || minutes < 0 || minutes >= minutesPerHour ||
+		hours > hoursPerDay || hours == hoursPerDay && minutes != 0 {
+		return 0, fmt.Errorf("%w: time %q is not HH:MM", ErrInvalidRateSchedule, value)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Share returns the share of the rate limit in effect at t, by the time of day in the location of t.
+func (s RateSchedule) Share(t time.Time) float64 {
+	h, m, sec := t.Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+
+	for _, w := range s.Windows {
+		if w.Start <= w.End && d >= w.Start && d < w.End || w.Start > w.End && (d >= w.Start || d < w.End) {
+			return w.Share
+		}
+	}
+
+	return s.Default
+}
+
+// RateLimiter spaces requests evenly to at most limit per second times the share of the schedule in effect when each
+// is made. A limit of 0 does not limit requests. It is safe for concurrent use.
+type RateLimiter struct {
+	clock    Clock
+	next     time.Time
+	schedule RateSchedule
+	limit    float64
+	mu       sync.Mutex
+}
+
+func NewRateLimiter(clock Clock, limit float64, schedule RateSchedule) *RateLimiter {
+	return &RateLimiter{clock, time.Time{}, schedule, limit, sync.Mutex{}}
+}
+
+// Reserve reserves the next request and returns how long to wait before making it.
+func (l *RateLimiter) Reserve() time.Duration {
+	now := l.clock.Now()
+	rate := l.limit * l.schedule.Share(now)
+
+	if rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(time.Second) / rate))
+
+	return wait
+}
+
+// Wait reserves the next request and waits until it can be made, or until ctx is done. Waits are made with Sleep
+// through the clock of the limiter.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	wait := l.Reserve()
+	if wait <= 0 {
+		return nil
+	}
+
+	return Sleep(ctx, l.clock, wait)
+}
+
+// NewRateLimitedGetter wraps inner so each request first waits for limiter.
+func NewRateLimitedGetter[TKey any, TValue any](inner Getter[TKey, TValue], limiter *RateLimiter) Getter[TKey, TValue] {
+	return &rateLimitedGetter[TKey, TValue]{inner, limiter}
+}
+
+type rateLimitedGetter[TKey any, TValue any] struct {
+	inner   Getter[TKey, TValue]
+	limiter *RateLimiter
+}
+
+func (g *rateLimitedGetter[TKey, TValue]) Get(ctx context.Context, key TKey) (TValue, error) {
+	err := g.limiter.Wait(ctx)
+	if err != nil {
+		var zero TValue
+		return zero, err
+	}
+
+	return g.inner.Get(ctx, key)
+}
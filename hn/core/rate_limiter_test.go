@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRateSchedule(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := ParseRateSchedule("00:00-08:00=100%, 22:00-02:00=50%, 20%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	shares := map[time.Duration]float64{
+		0:                            1,
+		7*time.Hour + 59*time.Minute: 1,
+		8 * time.Hour:                0.2,
+		12 * time.Hour:               0.2,
+		23 * time.Hour:               0.5,
+	}
+
+	for at, expected := range shares {
+		share := schedule.Share(day.Add(at))
+		if share != expected {
+			t.Fatalf("expected share %v at %v, got %v", expected, at, share)
+		}
+	}
+
+	// the rest of the day is at full rate without a share for it
+	schedule, err = ParseRateSchedule("09:00-17:00=10%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if share := schedule.Share(day.Add(18 * time.Hour)); share != 1 {
+		t.Fatalf("expected full rate outside the window, got %v", share)
+	}
+
+	for _, value := range []string{"", "20", "0%", "10%,20%", "08:00=20%", "08:00-25:00=20%", "8-9=20%", "=20%"} {
+		_, err = ParseRateSchedule(value)
+		if !errors.Is(err, ErrInvalidRateSchedule) {
+			t.Fatalf("expected invalid rate schedule for %q, got %v", value, err)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := ParseRateSchedule("00:00-08:00=100%,20%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &testClock{time.Date(2025, 6, 1, 1, 0, 0, 0, time.Local)}
+	limiter := NewRateLimiter(clock, 10, schedule)
+
+	// at night requests are spaced at the full 10 per second
+	for i := range 3 {
+		wait := limiter.Reserve()
+		if wait != time.Duration(i)*100*time.Millisecond {
+			t.Fatalf("expected request %d to wait %v, got %v", i, time.Duration(i)*100*time.Millisecond, wait)
+		}
+	}
+
+	// during the day they are spaced at 20% of that
+	clock.Set(time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local))
+
+	for i := range 3 {
+		wait := limiter.Reserve()
+		if wait != time.Duration(i)*500*time.Millisecond {
+			t.Fatalf("expected request %d to wait %v, got %v", i, time.Duration(i)*500*time.Millisecond, wait)
+		}
+	}
+
+	if wait := NewRateLimiter(clock, 0, schedule).Reserve(); wait != 0 {
+		t.Fatalf("expected no wait without a limit, got %v", wait)
+	}
+}
+
+func TestRateLimitedGetter(t *testing.T) {
+	t.Parallel()
+
+	inner := &fakeGetter{map[string]string{"item/1.json": `{"id":1}`}}
+	getter := NewRateLimitedGetter(inner, NewRateLimiter(NewClock(), 1, RateSchedule{nil, 1}))
+
+	r, err := getter.Get(t.Context(), "item/1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r.Close()
+
+	// the second request would wait a second, so it gives up when the context is done
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = getter.Get(ctx, "item/1.json")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to be interrupted, got %v", err)
+	}
+}
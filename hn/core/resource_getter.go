@@ -31,6 +31,12 @@ func (r *ResourceGetter) Get(ctx context.Context, path string, result any) error
 		return nil
 	}
 
+	return r.Refresh(ctx, path, result)
+}
+
+// Refresh is Get without reading the cache, for resources polled more often than the cache expires. The result is
+// still cached for later calls to Get.
+func (r *ResourceGetter) Refresh(ctx context.Context, path string, result any) error {
 	reader, err := r.getter.Get(ctx, path)
 	if err != nil {
 		return fmt.Errorf("getter get failed: %w", err)
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrScanLeaseLost is returned when renewing or completing a lease that expired and was taken by another owner.
+	ErrScanLeaseLost = errors.New("scan lease lost")
+	// ErrInvalidScanPlan is returned when recording a plan with a lease size that is not positive.
+	ErrInvalidScanPlan = errors.New("invalid scan plan")
+)
+
+// ScanPlan is the range of IDs a coordinated scan covers, from From toward To, which is excluded, in leases of Size
+// IDs.
+type ScanPlan struct {
+	From      int
+	To        int
+	Size      int
+	Ascending bool
+}
+
+// ScanLease is a range of a ScanPlan leased to one owner until it expires, from From toward To, which is excluded.
+type ScanLease struct {
+	Owner string
+	Chunk int
+	From  int
+	To    int
+}
+
+// ScanLeaseStore coordinates processes, possibly on different machines, scanning one ScanPlan together. Each leases
+// a range at a time, renewing the lease while it scans, so no range is scanned twice unless its owner stops renewing
+// and the lease expires for another to take over. The store is a SQLite file, so processes on different machines must
+// share it on a filesystem with working locks.
+type ScanLeaseStore struct {
+	db    *sql.DB
+	clock Clock
+}
+
+var scanLeaseSchema = []string{ //nolint:gochecknoglobals // schema
+	`CREATE TABLE IF NOT EXISTS scan_plan(
+	  ID INTEGER PRIMARY KEY CHECK (ID = 0),
+	  from_id INTEGER NOT NULL,
+	  to_id INTEGER NOT NULL,
+	  size INTEGER NOT NULL,
+	  ascending INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS scan_lease(
+	  chunk INTEGER PRIMARY KEY,
+	  owner TEXT NOT NULL,
+	  expires INTEGER NOT NULL,
+	  done INTEGER NOT NULL
+	)`,
+}
+
+// NewScanLeaseStore opens the store at path, creating it if needed. Transactions take the write lock when they begin
+// and wait for other processes holding it, since leasing reads and then writes.
+func NewScanLeaseStore(ctx context.Context, clock Clock, path string) (_ *ScanLeaseStore, err error) {
+	db, err := sql.Open("sqlite3", path+"?_txlock=immediate&_busy_timeout=30000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, db.Close())
+		}
+	}()
+
+	for _, statement := range scanLeaseSchema {
+		_, err = db.ExecContext(ctx, statement)
+		if err != nil {
+			return nil, fmt.Errorf("exec failed: %s %w", statement, err)
+		}
+	}
+
+	return &ScanLeaseStore{db, clock}, nil
+}
+
+func (s *ScanLeaseStore) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close db: %w", err)
+	}
+
+	return nil
+}
+
+// Plan records plan unless the store has one, and returns the recorded plan, so the first process to start decides
+// the range for all of them.
+func (s *ScanLeaseStore) Plan(ctx context.Context, plan ScanPlan) (ScanPlan, error) {
+	if plan.Size <= 0 {
+		return ScanPlan{}, fmt.Errorf("%w: lease size must be positive, got %d", ErrInvalidScanPlan, plan.Size)
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"INSERT OR IGNORE INTO scan_plan(ID, from_id, to_id, size, ascending) VALUES (0, ?, ?, ?, ?)",
+		plan.From, plan.To, plan.Size, plan.Ascending)
+	if err != nil {
+		return ScanPlan{}, fmt.Errorf("failed to insert scan plan: %w", err)
+	}
+
+	var recorded ScanPlan
+
+	err = s.db.QueryRowContext(ctx, "SELECT from_id, to_id, size, ascending FROM scan_plan WHERE ID = 0").Scan(
+		&recorded.From, &recorded.To, &recorded.Size, &recorded.Ascending)
+	if err != nil {
+		return ScanPlan{}, fmt.Errorf("scan plan scan: %w", err)
+	}
+
+	return recorded, nil
+}
+
+// Lease leases the first range of the plan that is neither done nor leased to another owner until after now, for ttl.
+// It returns nil when every range is done or leased.
+func (s *ScanLeaseStore) Lease(ctx context.Context, owner string, ttl time.Duration) (_ *ScanLease, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	var plan ScanPlan
+
+	err = tx.QueryRowContext(ctx, "SELECT from_id, to_id, size, ascending FROM scan_plan WHERE ID = 0").Scan(
+		&plan.From, &plan.To, &plan.Size, &plan.Ascending)
+	if err != nil {
+		return nil, fmt.Errorf("scan plan scan: %w", err)
+	}
+
+	total := max(plan.From-plan.To, plan.To-plan.From)
+	now := s.clock.Now()
+
+	var chunk int
+
+	// an expired lease is taken over before a new range is started, so ranges abandoned by a failed owner are finished
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT chunk FROM scan_lease WHERE done = 0 AND expires < ? ORDER BY chunk LIMIT 1",
+		now.Unix()).Scan(&chunk)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(chunk) + 1, 0) FROM scan_lease").Scan(&chunk)
+		if err != nil {
+			return nil, fmt.Errorf("scan lease next scan: %w", err)
+		}
+
+		if chunk*plan.Size >= total {
+			err = tx.Commit()
+			if err != nil {
+				return nil, fmt.Errorf("failed to commit transaction: %w", err)
+			}
+
+			return nil, nil //nolint:nilnil // no range is left to lease
+		}
+	case err != nil:
+		return nil, fmt.Errorf("scan lease expired scan: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT OR REPLACE INTO scan_lease(chunk, owner, expires, done) VALUES (?, ?, ?, 0)",
+		chunk, owner, now.Add(ttl).Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert scan lease: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	offset := chunk * plan.Size
+	n := min(plan.Size, total-offset)
+
+	if plan.Ascending {
+		return &ScanLease{owner, chunk, plan.From + offset, plan.From + offset + n}, nil
+	}
+
+	return &ScanLease{owner, chunk, plan.From - offset, plan.From - offset - n}, nil
+}
+
+// Renew extends lease to ttl from now, failing with ErrScanLeaseLost if another owner took it over.
+func (s *ScanLeaseStore) Renew(ctx context.Context, lease *ScanLease, ttl time.Duration) error {
+	return s.update(
+		ctx,
+		"UPDATE scan_lease SET expires = ? WHERE chunk = ? AND owner = ? AND done = 0",
+		s.clock.Now().Add(ttl).Unix(), lease.Chunk, lease.Owner)
+}
+
+// Complete marks the range of lease as scanned, failing with ErrScanLeaseLost if another owner took it over.
+func (s *ScanLeaseStore) Complete(ctx context.Context, lease *ScanLease) error {
+	return s.update(ctx, "UPDATE scan_lease SET done = 1 WHERE chunk = ? AND owner = ?", lease.Chunk, lease.Owner)
+}
+
+func (s *ScanLeaseStore) update(ctx context.Context, query string, args ...any) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("exec failed: %s %w", query, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return ErrScanLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScanLeaseStore(t *testing.T) {
+	t.Parallel()
+
+	clock := &testClock{time.Unix(0, 0)}
+	file := filepath.Join(t.TempDir(), "leases.db")
+
+	a, err := NewScanLeaseStore(t.Context(), clock, file)
+	if err != nil {
+		t.Fatalf("NewScanLeaseStore failed: %v", err)
+	}
+
+	b, err := NewScanLeaseStore(t.Context(), clock, file)
+	if err != nil {
+		t.Fatalf("NewScanLeaseStore failed: %v", err)
+	}
+
+	_, err = a.Plan(t.Context(), ScanPlan{100, 75, 0, false})
+	if !errors.Is(err, ErrInvalidScanPlan) {
+		t.Fatalf("expected invalid plan for size 0, got %v", err)
+	}
+
+	plan, err := a.Plan(t.Context(), ScanPlan{100, 75, 10, false})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	// the first plan recorded is the plan of every process
+	other, err := b.Plan(t.Context(), ScanPlan{1, 50, 5, true})
+	if err != nil || other != plan {
+		t.Fatalf("expected the recorded plan %v, got %v: %v", plan, other, err)
+	}
+
+	const ttl = time.Minute
+
+	var leases []ScanLease
+
+	for _, s := range []*ScanLeaseStore{a, b, a} {
+		lease, err := s.Lease(t.Context(), "owner", ttl)
+		if err != nil || lease == nil {
+			t.Fatalf("Lease failed: %v", err)
+		}
+
+		leases = append(leases, *lease)
+	}
+
+	diff := cmp.Diff([]ScanLease{{"owner", 0, 100, 90}, {"owner", 1, 90, 80}, {"owner", 2, 80, 75}}, leases)
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	lease, err := b.Lease(t.Context(), "b", ttl)
+	if err != nil || lease != nil {
+		t.Fatalf("expected every range leased, got %v: %v", lease, err)
+	}
+
+	err = a.Complete(t.Context(), &leases[0])
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	clock.Advance(ttl / 2)
+
+	err = a.Renew(t.Context(), &leases[1], ttl)
+	if err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+
+	// the lease that was not renewed expires and is taken over
+	clock.Advance(ttl)
+
+	lease, err = b.Lease(t.Context(), "b", ttl)
+	if err != nil || lease == nil || lease.Chunk != 2 {
+		t.Fatalf("expected to take over the expired lease, got %v: %v", lease, err)
+	}
+
+	err = a.Complete(t.Context(), &leases[2])
+	if !errors.Is(err, ErrScanLeaseLost) {
+		t.Fatalf("expected the lease to be lost, got %v", err)
+	}
+
+	err = errors.Join(a.Close(), b.Close())
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrRequestTimeout is wrapped by the error of a request that took longer than its timeout (see NewTimeoutGetter).
+var ErrRequestTimeout = errors.New("request timed out")
+
+type requestTimeoutContextKey struct{}
+
+// WithRequestTimeout sets the timeout of each request made with ctx by a getter from NewTimeoutGetter, in place of the
+// timeout of the getter. A timeout of 0 or less disables it.
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutContextKey{}, d)
+}
+
+// NewTimeoutGetter wraps inner so each request, including reading its body, fails with an error wrapping
+// ErrRequestTimeout after timeout, or the timeout set on its context with WithRequestTimeout. A stuck response then
+// fails just the one request rather than holding its worker until the context of the whole operation is done. The body
+// is read before Get returns, so it can be timed. Event streams, which last until canceled, have no timeout.
+func NewTimeoutGetter(inner Getter[string, io.ReadCloser], timeout time.Duration) Getter[string, io.ReadCloser] {
+	return &timeoutGetter{inner, timeout}
+}
+
+type timeoutGetter struct {
+	inner   Getter[string, io.ReadCloser]
+	timeout time.Duration
+}
+
+func (g *timeoutGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	timeout := g.timeout
+	if d, ok := ctx.Value(requestTimeoutContextKey{}).(time.Duration); ok {
+		timeout = d
+	}
+
+	if timeout <= 0 || IsEventStream(ctx) {
+		return g.inner.Get(ctx, path)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := g.read(requestCtx, path)
+	if err != nil && ctx.Err() == nil && errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s: %s", ErrRequestTimeout, timeout, path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (g *timeoutGetter) read(ctx context.Context, path string) (_ []byte, err error) {
+	reader, err := g.inner.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { err = errors.Join(err, reader.Close()) }()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, nil
+}
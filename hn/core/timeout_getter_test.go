@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stuckGetter serves "ok" for every path but "stuck", whose body never arrives until the request is canceled.
+type stuckGetter struct{}
+
+func (stuckGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "stuck" {
+		return io.NopCloser(strings.NewReader("ok")), nil
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func TestTimeoutGetter(t *testing.T) {
+	t.Parallel()
+
+	g := NewTimeoutGetter(stuckGetter{}, 20*time.Millisecond)
+
+	reader, err := g.Get(t.Context(), "fine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("unexpected body %q: %v", body, err)
+	}
+
+	_, err = g.Get(t.Context(), "stuck")
+	if !errors.Is(err, ErrRequestTimeout) {
+		t.Fatalf("expected ErrRequestTimeout, got %v", err)
+	}
+
+	// the timeout of the context replaces that of the getter
+	start := time.Now()
+
+	_, err = g.Get(WithRequestTimeout(t.Context(), time.Millisecond), "stuck")
+	if !errors.Is(err, ErrRequestTimeout) || time.Since(start) >= 20*time.Millisecond {
+		t.Fatalf("expected ErrRequestTimeout within the timeout of the context, got %v", err)
+	}
+
+	// the end of the parent context is not a timeout of the request
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Millisecond)
+	defer cancel()
+
+	_, err = NewTimeoutGetter(stuckGetter{}, time.Hour).Get(ctx, "stuck")
+	if errors.Is(err, ErrRequestTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline of the parent, got %v", err)
+	}
+}
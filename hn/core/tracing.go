@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracingGetter wraps inner so each request is recorded as an "hn.get" span with the requested path.
+// The span is a child of any span in the request context.
+func NewTracingGetter(inner Getter[string, io.ReadCloser], tracer trace.Tracer) Getter[string, io.ReadCloser] {
+	return &tracingGetter{inner, tracer}
+}
+
+type tracingGetter struct {
+	inner  Getter[string, io.ReadCloser]
+	tracer trace.Tracer
+}
+
+func (g *tracingGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, span := g.tracer.Start(ctx, "hn.get", trace.WithAttributes(attribute.String("hn.path", path)))
+	defer span.End()
+
+	result, err := g.inner.Get(ctx, path)
+	if err != nil {
+		var getterErr *GetterError
+		if errors.As(err, &getterErr) {
+			span.SetAttributes(attribute.Int("http.response.status_code", getterErr.Code))
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// NewBulkTracingGetter wraps inner so each bulk request is recorded as a span with the given name and the number of
+// keys requested and returned as not queued. Since bulk getters are asynchronous the span covers only the synchronous
+// part of Get, such as a cache lookup or enqueuing to a worker pool.
+func NewBulkTracingGetter[TKey any, TValue any](
+	inner BulkGetter[TKey, TValue],
+	tracer trace.Tracer,
+	name string,
+) BulkGetter[TKey, TValue] {
+	return &bulkTracingGetter[TKey, TValue]{inner, tracer, name}
+}
+
+type bulkTracingGetter[TKey any, TValue any] struct {
+	inner  BulkGetter[TKey, TValue]
+	tracer trace.Tracer
+	name   string
+}
+
+func (g *bulkTracingGetter[TKey, TValue]) Get(
+	ctx context.Context,
+	keys []TKey,
+	do func(key TKey, value TValue),
+) []TKey {
+	ctx, span := g.tracer.Start(ctx, g.name, trace.WithAttributes(attribute.Int("hn.keys", len(keys))))
+	defer span.End()
+
+	remaining := g.inner.Get(ctx, keys, do)
+	span.SetAttributes(attribute.Int("hn.keys.remaining", len(remaining)))
+
+	return remaining
+}
@@ -2,13 +2,22 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
 	"sync"
 )
 
+// ErrWorkerPanic is wrapped by the errors passed to the panic handler of a WorkerPool.
+var ErrWorkerPanic = errors.New("worker panic")
+
 // NewWorkerPool starts a new worker pool with the specified number of workers and work channel capacity.
-// Arguments must both be positive numbers.
-func NewWorkerPool(numWorkers int, workChannelCapacity int) *WorkerPool {
-	w := &WorkerPool{make(chan workWrapper, workChannelCapacity), sync.WaitGroup{}}
+// The counts must both be positive numbers. If panicHandler is not nil, a do that panics is recovered and the panic,
+// along with its stack, is passed to panicHandler as an error wrapping ErrWorkerPanic, and the worker goes on to the
+// next work; otherwise the panic terminates the program. A BulkWorkerPoolGetter on a pool with a panic handler also
+// passes the panic to its callback as an error for the key.
+func NewWorkerPool(numWorkers int, workChannelCapacity int, panicHandler func(error)) *WorkerPool {
+	w := &WorkerPool{make(chan workWrapper, workChannelCapacity), panicHandler, sync.WaitGroup{}}
 
 	for range numWorkers {
 		w.wg.Add(1)
@@ -21,15 +30,16 @@ func NewWorkerPool(numWorkers int, workChannelCapacity int) *WorkerPool {
 // WorkerPool is a fixed-size pool of workers for arbitrary work. Incoming work is enqueued in a FIFO channel which
 // the individual workers pull from.
 type WorkerPool struct {
-	workCh chan workWrapper
-	wg     sync.WaitGroup
+	workCh       chan workWrapper
+	panicHandler func(error)
+	wg           sync.WaitGroup
 }
 
 // DoWork queues work to the pool for asynchronous execution.
 // 1. DoWork returns immediately often but not necessarily before do() is called for each work item.
 // 2. If the work queue is full, the remaining work that was not queued is returned.
 // 3. The do callback will be called exactly once for each work item that is queued and not returned.
-// 4. The do function must not panic, if it does the panic will escape and the program will terminate.
+// 4. The do function must not panic, if it does the panic escapes the worker unless the pool has a panic handler.
 func DoWork[TWork any](
 	ctx context.Context,
 	w *WorkerPool,
@@ -78,6 +88,19 @@ func (w *WorkerPool) workerLoop() {
 			break
 		}
 
-		r.do(r.ctx, r.work)
+		w.run(r)
 	}
 }
+
+func (w *WorkerPool) run(r workWrapper) {
+	if w.panicHandler != nil {
+		defer func() {
+			v := recover()
+			if v != nil {
+				w.panicHandler(fmt.Errorf("%w: %v\n%s", ErrWorkerPanic, v, debug.Stack()))
+			}
+		}()
+	}
+
+	r.do(r.ctx, r.work)
+}
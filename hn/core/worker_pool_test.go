@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWorkerPoolPanicHandler(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		panics []error
+		done   []int
+		wg     sync.WaitGroup
+	)
+
+	w := NewWorkerPool(1, 4, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		panics = append(panics, err)
+	})
+
+	wg.Add(3)
+
+	r := DoWork(t.Context(), w, []int{1, 2, 3}, func(_ context.Context, work int) {
+		defer wg.Done()
+
+		if work == 2 {
+			panic("malformed")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		done = append(done, work)
+	})
+	if len(r) != 0 {
+		t.Fatalf("unexpected returned work %v", r)
+	}
+
+	wg.Wait()
+
+	_ = w.Close()
+
+	if len(panics) != 1 || !errors.Is(panics[0], ErrWorkerPanic) {
+		t.Fatalf("expected one worker panic, got %v", panics)
+	}
+
+	// the worker went on to the work after the panic
+	if len(done) != 2 || done[0] != 1 || done[1] != 3 {
+		t.Fatalf("expected work 1 and 3 done, got %v", done)
+	}
+}
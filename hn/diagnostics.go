@@ -0,0 +1,135 @@
+package hn
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Diagnostics times the phases of a run, such as fetching a list, fetching its items, and rendering them, and writes
+// them with the Stats of a Client as a human-readable summary. All methods of a nil Diagnostics do nothing, so a run
+// can be instrumented unconditionally.
+type Diagnostics struct {
+	start     time.Time
+	phases    []diagnosticsPhase
+	verbosity int
+	mu        sync.Mutex
+}
+
+type diagnosticsPhase struct {
+	name    string
+	elapsed time.Duration
+	runs    int
+}
+
+// NewDiagnostics starts timing a run. With verbosity 1 the summary has the request count, where the items came from,
+// any active scans, and the phase timings; with 2 or more it also has the file cache puts and the number of runs of
+// each phase.
+func NewDiagnostics(verbosity int) *Diagnostics {
+	return &Diagnostics{time.Now(), nil, verbosity, sync.Mutex{}}
+}
+
+// Phase starts timing the named phase and returns a function that ends it. The time of repeated phases is summed.
+func (d *Diagnostics) Phase(name string) func() {
+	if d == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		elapsed := time.Since(start)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		for i := range d.phases {
+			if d.phases[i].name == name {
+				d.phases[i].elapsed += elapsed
+				d.phases[i].runs++
+
+				return
+			}
+		}
+
+		d.phases = append(d.phases, diagnosticsPhase{name, elapsed, 1})
+	}
+}
+
+// Write writes the summary of stats and the phases so far to w.
+func (d *Diagnostics) Write(w io.Writer, stats Stats) error {
+	if d == nil || d.verbosity <= 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var sb strings.Builder
+
+	items := stats.Items
+
+	fmt.Fprintf(&sb, "requests: %d\n", stats.Requests)
+	fmt.Fprintf(&sb, "items: %d requested, %s memory, %s file cache, %s network\n",
+		items.Requested,
+		diagnosticsShare(items.Memory, items.Requested),
+		diagnosticsShare(items.FileCache, items.Requested),
+		diagnosticsShare(items.Network, items.Requested))
+
+	if scans := stats.Scans; scans.Scans > 0 {
+		const percent = 100
+
+		fmt.Fprintf(&sb, "active scans: %d, %d items examined down to id %d, %d requests, %.1f%% cached, %s\n",
+			scans.Scans, scans.Examined, scans.OldestID, scans.Requests, scans.CacheHitRate()*percent,
+			diagnosticsDuration(scans.Elapsed))
+	}
+
+	const detailed = 2
+
+	if d.verbosity >= detailed {
+		fc := stats.FileCache
+		fmt.Fprintf(&sb, "file cache puts: %d queued, %d dropped, %d written, %d failed\n",
+			fc.Queued, fc.Dropped, fc.Written, fc.Failed)
+		fmt.Fprintf(&sb, "file cache wal: %d bytes, %d checkpoints\n", fc.WALSize, fc.Checkpoints)
+	}
+
+	phases := make([]string, 0, len(d.phases)+1)
+
+	for _, p := range d.phases {
+		phase := p.name + " " + diagnosticsDuration(p.elapsed)
+		if d.verbosity >= detailed && p.runs > 1 {
+			phase += fmt.Sprintf(" (%d runs)", p.runs)
+		}
+
+		phases = append(phases, phase)
+	}
+
+	phases = append(phases, "total "+diagnosticsDuration(time.Since(d.start)))
+
+	fmt.Fprintf(&sb, "phases: %s\n", strings.Join(phases, ", "))
+
+	_, err := io.WriteString(w, sb.String())
+	if err != nil {
+		return fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+
+	return nil
+}
+
+func diagnosticsShare(n int64, total int64) string {
+	if total == 0 {
+		return "0"
+	}
+
+	const percent = 100
+
+	return fmt.Sprintf("%d (%.1f%%)", n, float64(n)*percent/float64(total))
+}
+
+func diagnosticsDuration(d time.Duration) string {
+	const precision = 100 * time.Microsecond
+
+	return d.Round(precision).String()
+}
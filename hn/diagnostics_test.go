@@ -0,0 +1,77 @@
+package hn
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	var stats Stats
+	stats.Requests = 5
+	stats.Items = ItemStats{8, 2, 2, 4}
+	stats.FileCache.Written = 4
+
+	d := NewDiagnostics(1)
+	d.Phase("list")()
+	d.Phase("items")()
+	d.Phase("items")()
+
+	var buf bytes.Buffer
+
+	err := d.Write(&buf, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// without scans there is no scan line
+	if len(lines) != 3 ||
+		lines[0] != "requests: 5" ||
+		lines[1] != "items: 8 requested, 2 (25.0%) memory, 2 (25.0%) file cache, 4 (50.0%) network" ||
+		!strings.HasPrefix(lines[2], "phases: list ") ||
+		!strings.Contains(lines[2], ", items ") ||
+		strings.Contains(lines[2], "runs") {
+		t.Fatalf("unexpected summary:\n%s", buf.String())
+	}
+
+	buf.Reset()
+
+	stats.Scans = ScanStats{ItemStats{4, 1, 1, 2}, 1500 * time.Millisecond, 1, 6, 3, 120}
+
+	err = d.Write(&buf, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(),
+		"active scans: 1, 6 items examined down to id 120, 3 requests, 50.0% cached, 1.5s\n") {
+		t.Fatalf("unexpected scan summary:\n%s", buf.String())
+	}
+
+	buf.Reset()
+
+	d.verbosity = 2
+
+	err = d.Write(&buf, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "file cache puts: 0 queued, 0 dropped, 4 written, 0 failed\n") ||
+		!strings.Contains(buf.String(), "(2 runs)") {
+		t.Fatalf("unexpected detailed summary:\n%s", buf.String())
+	}
+
+	var disabled *Diagnostics
+
+	disabled.Phase("list")()
+
+	err = disabled.Write(&buf, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package hn_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// newExampleClient returns a client reading the testdata corpus through the getter of a FakeClient rather than the
+// HN API, so the examples run offline. Without those options, hn.NewClient(ctx) reads the HN API.
+func newExampleClient(ctx context.Context) *hn.Client {
+	fake, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client, err := hn.NewClient(
+		ctx,
+		hn.WithGetter(fake.Getter()),
+		hn.WithFileCachePath(""),
+		hn.WithClock(testdata.Clock),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return client
+}
+
+func ExampleClient_GetItems() {
+	ctx := context.Background()
+
+	client := newExampleClient(ctx)
+	defer func() { _ = client.Close() }()
+
+	ids, err := client.GetNew(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	items, err := client.GetItems(ctx, ids[:3])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, id := range ids[:3] {
+		fmt.Println(items[id].Type, items[id].Title)
+	}
+
+	// Output:
+	// story Records Related to the Assassination of Senator Robert F. Kennedy
+	// story Attacks on Parachutists
+	// story Are your channels visible enough?
+}
+
+func ExampleClient_GetActive() {
+	ctx := context.Background()
+
+	client := newExampleClient(ctx)
+	defer func() { _ = client.Close() }()
+
+	maxID, err := client.GetMaxItem(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the items of the last 10 minutes of the corpus along with their ancestors
+	items, err := client.GetActive(ctx, maxID, testdata.MaxTime.Add(-10*time.Minute))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	roots, err := items.GroupByRoot()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(len(items), "items in", len(roots), "threads")
+
+	// Output:
+	// 172 items in 38 threads
+}
+
+func ExampleItemStream_SearchOrdered() {
+	ctx := context.Background()
+
+	client := newExampleClient(ctx)
+	defer func() { _ = client.Close() }()
+
+	ids, err := client.GetTop(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stream := client.AcquireStream(ctx)
+	defer stream.Release()
+
+	// the first three top stories with comments, in the order of the list
+	found := 0
+
+	err = stream.SearchOrdered(ids, func(_ int, item *hn.Item) (bool, []int, error) {
+		if len(item.Kids) > 0 {
+			fmt.Printf("%s (%d descendants)\n", item.Title, item.Descendants)
+
+			found++
+		}
+
+		return found < 3, nil, nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Output:
+	// Moon, Mars: China leads to both (1 descendants)
+	// Ask HN: How do you drive adoption when your product requires a behavior shift? (3 descendants)
+	// Hypertext TV (3 descendants)
+}
@@ -0,0 +1,565 @@
+// Package hntest provides an in-memory implementation of hn.API for tests of applications built on the hn package.
+package hntest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// ErrInjected is the default error returned by a FakeClient configured with WithFailures.
+var ErrInjected = errors.New("injected failure")
+
+// FakeClient implements hn.API over fixed items, lists, and users. Like the API, items that do not exist are returned
+// with a null body and users that do not exist are returned as nil. It is safe for concurrent use.
+type FakeClient struct {
+	items       hn.ItemSet
+	lists       map[string][]int
+	users       map[string]*hn.User
+	updates     *hn.Updates
+	clock       core.Clock
+	failureErr  error
+	latency     time.Duration
+	failureRate float64
+	retryDelay  time.Duration
+	retryFor    time.Duration
+	calls       atomic.Int64
+}
+
+var _ hn.API = (*FakeClient)(nil)
+
+type fakeClientOptions struct {
+	lists       map[string][]int
+	users       map[string]*hn.User
+	updates     *hn.Updates
+	clock       core.Clock
+	failureErr  error
+	latency     time.Duration
+	failureRate float64
+	retryDelay  time.Duration
+	retryFor    time.Duration
+}
+
+type Option struct {
+	apply func(*fakeClientOptions)
+}
+
+// WithLists sets the story lists by name: "top", "best", "new", "ask", "show", and "jobs".
+func WithLists(lists map[string][]int) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.lists = lists
+	}}
+}
+
+// WithUsers sets the users returned by GetUser.
+func WithUsers(users ...*hn.User) Option {
+	return Option{func(o *fakeClientOptions) {
+		for _, user := range users {
+			o.users[user.ID] = user
+		}
+	}}
+}
+
+// WithUpdates sets the changed items and profiles returned by GetUpdates, which are empty by default.
+func WithUpdates(updates *hn.Updates) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.updates = updates
+	}}
+}
+
+// WithClock sets the clock returned by Now. Now reports no skew.
+func WithClock(clock core.Clock) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.clock = clock
+	}}
+}
+
+// WithLatency delays every call by latency, or until the context is done.
+func WithLatency(latency time.Duration) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.latency = latency
+	}}
+}
+
+// WithFailures fails calls at random with the probability rate, returning err or ErrInjected if err is nil.
+func WithFailures(rate float64, err error) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.failureRate = rate
+		o.failureErr = err
+	}}
+}
+
+// WithNullRetry makes GetItem retry an item that does not exist as hn.WithNullRetry does, waiting delay before the
+// first retry and twice as long before each next one until timeout has passed. Each retry counts as a call.
+func WithNullRetry(delay time.Duration, timeout time.Duration) Option {
+	return Option{func(o *fakeClientOptions) {
+		o.retryDelay = delay
+		o.retryFor = timeout
+	}}
+}
+
+// NewFakeClient creates a FakeClient serving items.
+func NewFakeClient(items hn.ItemSet, options ...Option) *FakeClient {
+	o := fakeClientOptions{
+		nil, make(map[string]*hn.User), &hn.Updates{Items: nil, Profiles: nil}, core.NewClock(), nil, 0, 0, 0, 0,
+	}
+
+	for _, option := range options {
+		option.apply(&o)
+	}
+
+	if o.failureErr == nil {
+		o.failureErr = ErrInjected
+	}
+
+	return &FakeClient{
+		items, o.lists, o.users, o.updates, o.clock, o.failureErr, o.latency, o.failureRate, o.retryDelay, o.retryFor,
+		atomic.Int64{},
+	}
+}
+
+// NewFakeClientFromTestdata creates a FakeClient serving the testdata corpus with its lists, user, and clock.
+// Options are applied after the testdata defaults.
+func NewFakeClientFromTestdata(options ...Option) (*FakeClient, error) {
+	items := make(hn.ItemSet, testdata.ItemCount)
+
+	scanner := bufio.NewScanner(bytes.NewReader(testdata.ItemsRaw))
+	for scanner.Scan() {
+		var item hn.Item
+
+		err := json.Unmarshal(scanner.Bytes(), &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal testdata item: %w", err)
+		}
+
+		items[item.ID] = &item
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan testdata items: %w", err)
+	}
+
+	var user hn.User
+	user.ID = testdata.UserID
+	user.Submitted = testdata.UserSubmitted
+
+	defaults := []Option{
+		WithLists(map[string][]int{"new": testdata.New, "top": testdata.Top, "best": testdata.Best}),
+		WithUsers(&user),
+		WithClock(testdata.Clock),
+	}
+
+	return NewFakeClient(items, append(defaults, options...)...), nil
+}
+
+// Calls returns the number of API calls made, including failed ones.
+func (c *FakeClient) Calls() int64 {
+	return c.calls.Load()
+}
+
+func (c *FakeClient) inject(ctx context.Context) error {
+	c.calls.Add(1)
+
+	if c.latency > 0 {
+		timer := time.NewTimer(c.latency)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("fake latency: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	if c.failureRate > 0 && rand.Float64() < c.failureRate { //nolint:gosec // G404 no cryptographic randomness needed
+		return c.failureErr
+	}
+
+	return nil
+}
+
+func (c *FakeClient) getList(ctx context.Context, name string) ([]int, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(c.lists[name]), nil
+}
+
+func (c *FakeClient) GetTop(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "top")
+}
+
+func (c *FakeClient) GetBest(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "best")
+}
+
+func (c *FakeClient) GetNew(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "new")
+}
+
+func (c *FakeClient) GetAsk(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "ask")
+}
+
+func (c *FakeClient) GetShow(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "show")
+}
+
+func (c *FakeClient) GetJobs(ctx context.Context) ([]int, error) {
+	return c.getList(ctx, "jobs")
+}
+
+// GetMaxItem returns the largest item ID.
+func (c *FakeClient) GetMaxItem(ctx context.Context) (int, error) {
+	if err := c.inject(ctx); err != nil {
+		return 0, err
+	}
+
+	maxID := 0
+	for id := range c.items {
+		maxID = max(maxID, id)
+	}
+
+	return maxID, nil
+}
+
+// GetUpdates returns the updates set with WithUpdates.
+func (c *FakeClient) GetUpdates(ctx context.Context) (*hn.Updates, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return &hn.Updates{Items: slices.Clone(c.updates.Items), Profiles: slices.Clone(c.updates.Profiles)}, nil
+}
+
+func (c *FakeClient) ClockSkew(ctx context.Context) (time.Duration, error) {
+	if err := c.inject(ctx); err != nil {
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+func (c *FakeClient) Now(ctx context.Context) (time.Time, time.Duration, error) {
+	if err := c.inject(ctx); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return c.clock.Now(), 0, nil
+}
+
+func (c *FakeClient) GetUser(ctx context.Context, username string) (*hn.User, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.users[username], nil
+}
+
+// GetUsers returns the users by username, with the ones that do not exist mapped to nil, in a single call.
+func (c *FakeClient) GetUsers(ctx context.Context, usernames []string) (map[string]*hn.User, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*hn.User, len(usernames))
+	for _, username := range usernames {
+		result[username] = c.users[username]
+	}
+
+	return result, nil
+}
+
+func (c *FakeClient) GetItems(ctx context.Context, ids []int) (hn.ItemSet, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make(hn.ItemSet, len(ids))
+	for _, id := range ids {
+		result[id] = c.item(id)
+	}
+
+	return result, nil
+}
+
+// GetItem returns the item with id, or an error wrapping hn.ErrItemNotFound if it does not exist, after the retries of
+// WithNullRetry. The retries wait in real time rather than by the clock of the client.
+func (c *FakeClient) GetItem(ctx context.Context, id int) (*hn.Item, error) {
+	delay, waited := c.retryDelay, time.Duration(0)
+
+	for {
+		if err := c.inject(ctx); err != nil {
+			return nil, err
+		}
+
+		if item, ok := c.items[id]; ok {
+			return item, nil
+		}
+
+		if delay <= 0 || waited >= c.retryFor {
+			return nil, fmt.Errorf("item %d has null body: %w", id, hn.ErrItemNotFound)
+		}
+
+		wait := min(delay, c.retryFor-waited)
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to retry item %d: %w", id, ctx.Err())
+		case <-timer.C:
+		}
+
+		waited += wait
+		delay *= 2
+	}
+}
+
+func (c *FakeClient) item(id int) *hn.Item {
+	if item, ok := c.items[id]; ok {
+		return item
+	}
+
+	var item hn.Item
+	item.ID = id
+	item.Type = hn.NullBody
+
+	return &item
+}
+
+// GetActive returns the items up to maxID created at or after activeAfter that are not dead or deleted, along with
+// their ancestors. Like the real client, ancestors that do not exist are included with a null body.
+func (c *FakeClient) GetActive(ctx context.Context, maxID int, activeAfter time.Time) (hn.ItemSet, error) {
+	all, _, err := c.GetActiveCapped(ctx, maxID, activeAfter, 0)
+
+	return all, err
+}
+
+// GetActiveCapped is GetActive with the items of each tree capped at maxTreeSize, keeping the newest active items.
+// Unlike the real client, which only learns the tree of an item as it retrieves its ancestors, the cap is exact. If
+// ctx ends during the injected latency, nothing was retrieved, so the partial result is empty.
+func (c *FakeClient) GetActiveCapped(
+	ctx context.Context, maxID int, activeAfter time.Time, maxTreeSize int,
+) (hn.ItemSet, hn.Truncated, error) {
+	if err := c.inject(ctx); err != nil {
+		if ctx.Err() != nil {
+			return hn.ItemSet{}, hn.Truncated{}, fmt.Errorf("%w: %w", hn.ErrPartialResult, ctx.Err())
+		}
+
+		return nil, nil, err
+	}
+
+	result := make(hn.ItemSet)
+	truncated := make(hn.Truncated)
+	sizes := make(map[int]int)
+
+	ids := slices.Sorted(maps.Keys(c.items))
+	slices.Reverse(ids)
+
+	for _, id := range ids {
+		item := c.items[id]
+		if id > maxID || item.Dead || item.Deleted || !hn.BoundaryInclusive.After(item.Time, activeAfter) {
+			continue
+		}
+
+		path := []*hn.Item{item}
+		for current := item; current.Parent != nil; {
+			current = c.item(*current.Parent)
+			path = append(path, current)
+		}
+
+		root := path[len(path)-1].ID
+		if _, ok := result[id]; !ok && maxTreeSize > 0 && sizes[root] >= maxTreeSize {
+			truncated[root]++
+			continue
+		}
+
+		for _, current := range path {
+			if _, ok := result[current.ID]; !ok {
+				result[current.ID] = current
+				sizes[root]++
+			}
+		}
+	}
+
+	return result, truncated, nil
+}
+
+func (c *FakeClient) SearchOrdered(
+	ctx context.Context,
+	ids []int,
+	acc func(id int, item *hn.Item) (bool, []int, error),
+) error {
+	if err := c.inject(ctx); err != nil {
+		return err
+	}
+
+	queue := slices.Clone(ids)
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		more, moreIDs, err := acc(id, c.item(id))
+		if err != nil || !more {
+			return err
+		}
+
+		queue = append(queue, moreIDs...)
+	}
+
+	return nil
+}
+
+// SearchUnordered visits items in the same order as SearchOrdered, which is one of the orders the real client allows.
+func (c *FakeClient) SearchUnordered(
+	ctx context.Context,
+	ids []int,
+	acc func(id int, item *hn.Item) (bool, []int, error),
+) error {
+	return c.SearchOrdered(ctx, ids, acc)
+}
+
+func (c *FakeClient) GetParents(ctx context.Context, items hn.ItemSet) (hn.ItemSet, error) {
+	var ids []int
+
+	for _, item := range items {
+		if item.Parent != nil {
+			ids = append(ids, *item.Parent)
+		}
+	}
+
+	parents, err := c.GetItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range parents {
+		if item.Type == hn.NullBody {
+			return nil, fmt.Errorf("parent %d has null body: %w", item.ID, hn.ErrItemNotFound)
+		}
+	}
+
+	return parents, nil
+}
+
+func (c *FakeClient) GetAncestors(ctx context.Context, items hn.ItemSet) (hn.ItemSet, error) {
+	if err := c.inject(ctx); err != nil {
+		return nil, err
+	}
+
+	result := make(hn.ItemSet, len(items))
+
+	for _, item := range items {
+		for current := c.item(item.ID); ; current = c.item(*current.Parent) {
+			result[current.ID] = current
+
+			if current.Parent == nil {
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (c *FakeClient) GetKids(ctx context.Context, items hn.ItemSet) (hn.ItemSet, error) {
+	var ids []int
+
+	for _, item := range items {
+		ids = append(ids, item.Kids...)
+	}
+
+	return c.GetItems(ctx, ids)
+}
+
+func (c *FakeClient) GetDescendants(ctx context.Context, items hn.ItemSet) (hn.ItemSet, error) {
+	result := make(hn.ItemSet, len(items))
+
+	err := c.SearchOrdered(ctx, items.IDs(), func(id int, item *hn.Item) (bool, []int, error) {
+		result[id] = item
+		return true, item.Kids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetTree returns the item with rootID along with all of its descendants, or an error wrapping hn.ErrItemNotFound if
+// the root does not exist.
+func (c *FakeClient) GetTree(ctx context.Context, rootID int) (hn.ItemSet, error) {
+	all, _, err := c.GetTreeCapped(ctx, rootID, 0, 0)
+
+	return all, err
+}
+
+// GetTreeCapped is GetTree with the descent capped as by the real client. The tree is descended breadth first, which
+// is one of the orders the real client allows, so maxItems keeps the items nearest the root.
+func (c *FakeClient) GetTreeCapped(
+	ctx context.Context, rootID int, maxDepth int, maxItems int,
+) (hn.ItemSet, bool, error) {
+	if err := c.inject(ctx); err != nil {
+		if ctx.Err() != nil {
+			return hn.ItemSet{}, false, fmt.Errorf("%w: %w", hn.ErrPartialResult, ctx.Err())
+		}
+
+		return nil, false, err
+	}
+
+	root := c.item(rootID)
+	if root.Type == hn.NullBody {
+		return nil, false, fmt.Errorf("root %d has null body: %w", rootID, hn.ErrItemNotFound)
+	}
+
+	all := hn.ItemSet{rootID: root}
+	depths := map[int]int{rootID: 0}
+	truncated := false
+
+	for queue := []*hn.Item{root}; len(queue) > 0; queue = queue[1:] {
+		item := queue[0]
+
+		depth := depths[item.ID] + 1
+		if len(item.Kids) > 0 && maxDepth > 0 && depth > maxDepth {
+			truncated = true
+			continue
+		}
+
+		for _, kid := range item.Kids {
+			if _, ok := depths[kid]; ok {
+				continue
+			}
+
+			if maxItems > 0 && len(depths) >= maxItems {
+				truncated = true
+				break
+			}
+
+			depths[kid] = depth
+			all[kid] = c.item(kid)
+			queue = append(queue, all[kid])
+		}
+	}
+
+	return all, truncated, nil
+}
+
+func (c *FakeClient) Close() error {
+	return nil
+}
@@ -0,0 +1,243 @@
+package hntest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestFakeClientMatchesClient(t *testing.T) {
+	t.Parallel()
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(testdata.Getter), hn.WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	fake, err := NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, api := range []hn.API{client, fake} {
+		ids, err := api.GetNew(t.Context())
+		if err != nil || !slices.Equal(ids, testdata.New) {
+			t.Fatalf("%T: unexpected new stories: %v", api, err)
+		}
+
+		maxItem, err := api.GetMaxItem(t.Context())
+		if err != nil || maxItem != testdata.MaxItem {
+			t.Fatalf("%T: expected max item %d, got %d: %v", api, testdata.MaxItem, maxItem, err)
+		}
+
+		updates, err := api.GetUpdates(t.Context())
+		if err != nil || len(updates.Items) != 0 || len(updates.Profiles) != 0 {
+			t.Fatalf("%T: expected no updates, got %v: %v", api, updates, err)
+		}
+	}
+
+	activeAfter := testdata.MaxTime.Add(-time.Hour)
+
+	expected, err := client.GetActive(t.Context(), testdata.MaxItem, activeAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := fake.GetActive(t.Context(), testdata.MaxItem, activeAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(actual.IDs(), expected.IDs()) {
+		t.Fatalf("expected %d active items, got %d", len(expected), len(actual))
+	}
+
+	items, err := fake.GetItems(t.Context(), []int{testdata.MaxItem + 1})
+	if err != nil || items[testdata.MaxItem+1].Type != hn.NullBody {
+		t.Fatalf("expected a null body for a missing item: %v", err)
+	}
+}
+
+func TestFakeClientInjection(t *testing.T) {
+	t.Parallel()
+
+	errCustom := errors.New("custom")
+
+	failing := NewFakeClient(nil, WithFailures(1, errCustom))
+
+	_, err := failing.GetTop(t.Context())
+	if !errors.Is(err, errCustom) {
+		t.Fatalf("expected the injected error, got %v", err)
+	}
+
+	if failing.Calls() != 1 {
+		t.Fatalf("expected one call, got %d", failing.Calls())
+	}
+
+	slow := NewFakeClient(nil, WithLatency(time.Hour))
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Millisecond)
+	defer cancel()
+
+	_, err = slow.GetMaxItem(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the latency to respect the context, got %v", err)
+	}
+}
+
+func TestFakeClientGetter(t *testing.T) {
+	t.Parallel()
+
+	fake, err := NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(fake.Getter()), hn.WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	items, err := client.GetItems(t.Context(), []int{testdata.MaxItem, testdata.MaxItem + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items[testdata.MaxItem].Type == hn.NullBody || items[testdata.MaxItem+1].Type != hn.NullBody {
+		t.Fatal("expected the latest item and a null body past it")
+	}
+
+	user, err := client.GetUser(t.Context(), testdata.UserID)
+	if err != nil || !slices.Equal(user.Submitted, testdata.UserSubmitted) {
+		t.Fatalf("unexpected user: %v", err)
+	}
+
+	if calls := fake.Calls(); calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	_, err = fake.Getter().Get(t.Context(), "nothing.json")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFakeClientGetItem(t *testing.T) {
+	t.Parallel()
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(testdata.Getter), hn.WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	fake, err := NewFakeClientFromTestdata(WithNullRetry(time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, api := range []hn.API{client, fake} {
+		item, err := api.GetItem(t.Context(), testdata.MaxItem)
+		if err != nil || item.ID != testdata.MaxItem {
+			t.Fatalf("%T: unexpected item: %v", api, err)
+		}
+
+		_, err = api.GetItem(t.Context(), testdata.MaxItem+1)
+		if !errors.Is(err, hn.ErrItemNotFound) {
+			t.Fatalf("%T: expected hn.ErrItemNotFound, got %v", api, err)
+		}
+	}
+
+	// the first call, then retries after 1, 2, 4, and the remaining 3 milliseconds
+	if calls := fake.Calls(); calls != 1+5 {
+		t.Fatalf("expected 6 calls, got %d", calls)
+	}
+}
+
+func TestFakeClientGetTree(t *testing.T) {
+	t.Parallel()
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(testdata.Getter), hn.WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	fake, err := NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rootID := range testdata.Top[:10] {
+		expected, err := client.GetTree(t.Context(), rootID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := fake.GetTree(t.Context(), rootID)
+		if err != nil || !slices.Equal(actual.IDs(), expected.IDs()) {
+			t.Fatalf("expected %d items under %d, got %d: %v", len(expected), rootID, len(actual), err)
+		}
+
+		expected, expectedTruncated, err := client.GetTreeCapped(t.Context(), rootID, 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, truncated, err := fake.GetTreeCapped(t.Context(), rootID, 1, 0)
+		if err != nil || truncated != expectedTruncated || !slices.Equal(actual.IDs(), expected.IDs()) {
+			t.Fatalf("expected %d items within a level of %d, got %d: %v", len(expected), rootID, len(actual), err)
+		}
+	}
+
+	_, err = fake.GetTree(t.Context(), testdata.MaxItem+1)
+	if !errors.Is(err, hn.ErrItemNotFound) {
+		t.Fatalf("expected hn.ErrItemNotFound, got %v", err)
+	}
+}
+
+func TestFakeClientGetUsers(t *testing.T) {
+	t.Parallel()
+
+	client, err := hn.NewClient(
+		t.Context(), hn.WithFileCachePath(""), hn.WithGetter(testdata.Getter), hn.WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	fake, err := NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, api := range []hn.API{client, fake} {
+		users, err := api.GetUsers(t.Context(), []string{testdata.UserID})
+		if err != nil || len(users) != 1 || !slices.Equal(users[testdata.UserID].Submitted, testdata.UserSubmitted) {
+			t.Fatalf("%T: unexpected users: %v", api, err)
+		}
+	}
+
+	// the testdata getter serves its user for every username, so only the fake has users that do not exist
+	users, err := fake.GetUsers(t.Context(), []string{testdata.UserID, "missing"})
+	if err != nil || len(users) != 2 || users["missing"] != nil {
+		t.Fatalf("expected a nil user for a missing username: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package hntest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// ErrNotFound is returned by the getter of a FakeClient for paths the HN API does not serve.
+var ErrNotFound = errors.New("not found")
+
+// Getter returns a getter serving the items, lists, users, and updates of c as the HN API would, for an hn.Client
+// created with hn.WithGetter to run against the fake, such as to try its ItemStreams. Each request counts as a call
+// and is subject to WithLatency and WithFailures.
+func (c *FakeClient) Getter() core.Getter[string, io.ReadCloser] {
+	return fakeGetter{c}
+}
+
+type fakeGetter struct {
+	c *FakeClient
+}
+
+//nolint:gochecknoglobals // constant
+var fakeListPaths = map[string]string{
+	"topstories.json":  "top",
+	"beststories.json": "best",
+	"newstories.json":  "new",
+	"askstories.json":  "ask",
+	"showstories.json": "show",
+	"jobstories.json":  "jobs",
+}
+
+func (g fakeGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	value, err := g.get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func (g fakeGetter) get(ctx context.Context, path string) (any, error) {
+	if list, ok := fakeListPaths[path]; ok {
+		return g.c.getList(ctx, list)
+	}
+
+	switch {
+	case path == "maxitem.json":
+		return g.c.GetMaxItem(ctx)
+	case path == "updates.json":
+		return g.c.GetUpdates(ctx)
+	case strings.HasPrefix(path, "user/"):
+		return g.c.GetUser(ctx, strings.TrimSuffix(strings.TrimPrefix(path, "user/"), ".json"))
+	case strings.HasPrefix(path, "item/"):
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "item/"), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+
+		if err = g.c.inject(ctx); err != nil {
+			return nil, err
+		}
+
+		// items that do not exist have a null body
+		item, ok := g.c.items[id]
+		if !ok {
+			return nil, nil //nolint:nilnil // marshaled as null
+		}
+
+		return item, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+}
@@ -4,26 +4,78 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 )
 
 var ErrItemNotFound = errors.New("item not found")
 
+var (
+	// ErrParentCycle is the cause of a TreeError for an item whose parents lead back to an item already passed.
+	ErrParentCycle = errors.New("parent cycle")
+	// ErrTreeTooDeep is the cause of a TreeError for an item more than MaxTreeDepth below its root.
+	ErrTreeTooDeep = errors.New("tree too deep")
+)
+
+// MaxTreeDepth bounds the depth of the trees that are traversed. The deepest threads on HN are a few hundred replies
+// deep, so anything deeper is corrupted data rather than a discussion.
+const MaxTreeDepth = 10_000
+
+// TreeError reports parent links that don't form a tree, so walking them would never end, such as from a corrupted
+// cache, with the ID of the item where the walk started.
+type TreeError struct {
+	Err error
+	ID  int
+}
+
+func (e *TreeError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.ID, e.Err)
+}
+
+func (e *TreeError) Unwrap() error {
+	return e.Err
+}
+
+// FindRoot follows the parents of item through ancestors to its root. It returns a TreeError if the parents form a
+// cycle or go deeper than MaxTreeDepth.
 func (item *Item) FindRoot(ancestors ItemSet) (*Item, error) {
 	current := item
 
-	for current.Parent != nil {
+	for depth := 0; current.Parent != nil; depth++ {
 		next, ok := ancestors[*current.Parent]
 		if !ok {
 			return nil, fmt.Errorf("parent %d not found: %w", *current.Parent, ErrItemNotFound)
 		}
 
+		// without a cycle, every step reaches another of the ancestors
+		if depth >= len(ancestors) || depth >= MaxTreeDepth {
+			return nil, item.treeError(ancestors)
+		}
+
 		current = next
 	}
 
 	return current, nil
 }
 
+// treeError walks the parents of item again, remembering where it has been, to tell a cycle from a chain deeper than
+// MaxTreeDepth.
+func (item *Item) treeError(ancestors ItemSet) error {
+	seen := make(map[int]struct{})
+
+	for current := item; current != nil && current.Parent != nil && len(seen) < MaxTreeDepth; {
+		if _, ok := seen[current.ID]; ok {
+			return &TreeError{ErrParentCycle, item.ID}
+		}
+
+		seen[current.ID] = struct{}{}
+		current = ancestors[*current.Parent]
+	}
+
+	return &TreeError{ErrTreeTooDeep, item.ID}
+}
+
 func (item *Item) FindChildren(items ItemSet) (ItemSet, error) {
 	results := make(ItemSet, len(item.Kids))
 
@@ -39,6 +91,43 @@ func (item *Item) FindChildren(items ItemSet) (ItemSet, error) {
 	return results, nil
 }
 
+// ChangedFields returns the JSON names of the fields that differ between item and other, in serialization order.
+func (item *Item) ChangedFields(other *Item) []string {
+	var changed []string
+
+	add := func(name string, equal bool) {
+		if !equal {
+			changed = append(changed, name)
+		}
+	}
+
+	add("by", item.By == other.By)
+	add("dead", item.Dead == other.Dead)
+	add("deleted", item.Deleted == other.Deleted)
+	add("descendants", item.Descendants == other.Descendants)
+	add("id", item.ID == other.ID)
+	add("kids", slices.Equal(item.Kids, other.Kids))
+	add("parent", equalIntP(item.Parent, other.Parent))
+	add("poll", equalIntP(item.Poll, other.Poll))
+	add("parts", slices.Equal(item.Parts, other.Parts))
+	add("score", item.Score == other.Score)
+	add("text", item.Text == other.Text)
+	add("time", item.Time == other.Time)
+	add("title", item.Title == other.Title)
+	add("type", item.Type == other.Type)
+	add("url", item.URL == other.URL)
+
+	return changed
+}
+
+func equalIntP(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
 func (items ItemSet) IDs() []int {
 	ids := make([]int, 0, len(items))
 
@@ -139,6 +228,34 @@ func (items ItemSet) GroupByParent() (map[int]ItemSet, ItemSet, error) {
 	return results, noParent, nil
 }
 
+// traceable returns the items that can be traced to their root through items, dropping those with a missing ancestor.
+func (items ItemSet) traceable() ItemSet {
+	known := make(map[int]bool, len(items))
+
+	var isTraceable func(item *Item) bool
+	isTraceable = func(item *Item) bool {
+		if v, ok := known[item.ID]; ok {
+			return v
+		}
+
+		// an item on a parent cycle is never traced to a root
+		known[item.ID] = false
+
+		v := item.Parent == nil
+		if !v {
+			if parent, ok := items[*item.Parent]; ok {
+				v = isTraceable(parent)
+			}
+		}
+
+		known[item.ID] = v
+
+		return v
+	}
+
+	return items.Filter(isTraceable)
+}
+
 func (items ItemSet) GroupByBy() map[string]ItemSet {
 	results := make(map[string]ItemSet)
 
@@ -180,10 +297,12 @@ func (items ItemSet) getParents(ctx context.Context, c *Client) (ItemSet, error)
 	return parents, nil
 }
 
+// getAncestors retrieves the ancestors of items with a search that requests each parent as soon as its child arrives.
+// The items themselves, already in hand, are not requested again.
 func (items ItemSet) getAncestors(ctx context.Context, c *Client) (ItemSet, error) {
-	result := make(ItemSet, len(items))
-	ids := items.IDs()
-	queuedAsParent := make(map[int]struct{})
+	result := maps.Clone(items)
+	ids := missingParents(items, result)
+	queuedAsParent := make(map[int]struct{}, len(ids))
 
 	for _, id := range ids {
 		queuedAsParent[id] = struct{}{}
@@ -191,17 +310,22 @@ func (items ItemSet) getAncestors(ctx context.Context, c *Client) (ItemSet, erro
 
 	moreIDs := make([]int, 1)
 
-	err := c.SearchUnordered(ctx, items.IDs(), func(id int, item *Item) (bool, []int, error) {
+	err := c.SearchUnordered(ctx, ids, func(id int, item *Item) (bool, []int, error) {
 		result[id] = item
 
 		if item.Parent == nil {
 			return true, nil, nil
 		}
 
-		_, ok := queuedAsParent[*item.Parent]
-		if !ok {
-			queuedAsParent[*item.Parent] = struct{}{}
-			moreIDs[0] = *item.Parent
+		parent := *item.Parent
+
+		if _, ok := result[parent]; ok {
+			return true, nil, nil
+		}
+
+		if _, ok := queuedAsParent[parent]; !ok {
+			queuedAsParent[parent] = struct{}{}
+			moreIDs[0] = parent
 
 			return true, moreIDs, nil
 		}
@@ -209,12 +333,37 @@ func (items ItemSet) getAncestors(ctx context.Context, c *Client) (ItemSet, erro
 		return true, nil, nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to retrieve ancestors: %w", err)
 	}
 
 	return result, nil
 }
 
+// missingParents returns the IDs of the parents of items that are not in known, each once.
+func missingParents(items ItemSet, known ItemSet) []int {
+	ids := make([]int, 0, len(items))
+	queued := make(map[int]struct{}, len(items))
+
+	for _, item := range items {
+		if item.Parent == nil {
+			continue
+		}
+
+		parent := *item.Parent
+
+		if _, ok := known[parent]; ok {
+			continue
+		}
+
+		if _, ok := queued[parent]; !ok {
+			queued[parent] = struct{}{}
+			ids = append(ids, parent)
+		}
+	}
+
+	return ids
+}
+
 func (items ItemSet) getKids(ctx context.Context, c *Client) (ItemSet, error) {
 	ids := make([]int, 0, len(items))
 
@@ -0,0 +1,290 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestChangedFields(t *testing.T) {
+	t.Parallel()
+
+	parent := 1
+	otherParent := 2
+
+	a := &Item{
+		Parent:      &parent,
+		Poll:        nil,
+		By:          "a",
+		Text:        "text",
+		Title:       "",
+		URL:         "",
+		Type:        Comment,
+		Kids:        []int{4, 5},
+		Parts:       nil,
+		Time:        10,
+		Descendants: 0,
+		ID:          3,
+		Score:       0,
+		Dead:        false,
+		Deleted:     false,
+	}
+
+	b := *a
+
+	diff := cmp.Diff([]string(nil), a.ChangedFields(&b))
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	b.Kids = []int{4, 5, 6}
+	b.Text = "edited"
+	b.Parent = &otherParent
+
+	diff = cmp.Diff([]string{"kids", "parent", "text"}, a.ChangedFields(&b))
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+
+	b = *a
+	b.Parent = nil
+	b.Dead = true
+
+	diff = cmp.Diff([]string{"dead", "parent"}, a.ChangedFields(&b))
+	if diff != "" {
+		t.Fatalf("(-want +got):\n%s", diff)
+	}
+}
+
+// newDeepCorpus returns chains of depth replies, each under a story, and width replies spread over the stories. Its
+// items are its leaves: the last reply of each chain and the spread replies.
+func newDeepCorpus(chains int, depth int, width int) (ItemSet, ItemSet) {
+	all := make(ItemSet)
+	leaves := make(ItemSet)
+	id := 0
+
+	add := func(parent *int) *Item {
+		id++
+
+		item := &Item{
+			Parent: parent, Poll: nil, By: "a", Text: "", Title: "", URL: "", Type: Comment, Kids: nil, Parts: nil,
+			Time: int64(id), Descendants: 0, ID: id, Score: 0, Dead: false, Deleted: false,
+		}
+		if parent == nil {
+			item.Type = Story
+		}
+
+		all[id] = item
+
+		return item
+	}
+
+	for range chains {
+		var parent *int
+
+		for range depth {
+			item := add(parent)
+			parent = &item.ID
+		}
+
+		leaves[id] = all[id]
+	}
+
+	for i := range width {
+		story := 1 + (i%chains)*depth
+		item := add(&story)
+		leaves[item.ID] = item
+	}
+
+	return all, leaves
+}
+
+// latencyGetter answers from a corpus after a delay, like the API.
+type latencyGetter struct {
+	corpus  *corpusGetter
+	latency time.Duration
+}
+
+func (g latencyGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	time.Sleep(g.latency)
+
+	return g.corpus.Get(ctx, path)
+}
+
+func TestGetAncestors(t *testing.T) {
+	t.Parallel()
+
+	all, leaves := newDeepCorpus(5, 30, 200)
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(&corpusGetter{all}),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ancestors, err := client.GetAncestors(t.Context(), leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(all.IDs(), ancestors.IDs()); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	// the items in hand are not requested again, and each ancestor is requested once
+	if requests := client.Stats().Requests; requests != int64(len(all)-len(leaves)) {
+		t.Fatalf("expected %d requests, got %d", len(all)-len(leaves), requests)
+	}
+}
+
+// BenchmarkGetAncestors retrieves deep chains with 1ms of latency per request. Skipping the items in hand takes it
+// from about 110ms to 55ms; requesting the parents in batched waves instead of as each child arrives did no better.
+func BenchmarkGetAncestors(b *testing.B) {
+	all, leaves := newDeepCorpus(20, 40, 2000)
+
+	for b.Loop() {
+		client, err := NewClient(
+			b.Context(),
+			WithFileCachePath(""),
+			WithGetter(latencyGetter{&corpusGetter{all}, time.Millisecond}),
+			WithClock(testdata.Clock))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_, err = client.GetAncestors(b.Context(), leaves)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_ = client.Close()
+	}
+}
+
+// parentGraph returns an item for each byte of data whose parent is selected by the byte: none, one of the items, or
+// one missing from the set.
+func parentGraph(data []byte) ItemSet {
+	const maxItems = 64
+
+	data = data[:min(len(data), maxItems)]
+	n := len(data)
+	items := make(ItemSet, n)
+
+	for i, b := range data {
+		item := &Item{
+			Parent: nil, Poll: nil, By: "a", Text: "", Title: "", URL: "", Type: Comment, Kids: nil, Parts: nil,
+			Time: int64(i), Descendants: 0, ID: i + 1, Score: 0, Dead: false, Deleted: false,
+		}
+
+		if parent := int(b) % (n + 2); parent != 0 {
+			item.Parent = &parent
+		}
+
+		items[item.ID] = item
+	}
+
+	return items
+}
+
+func FuzzFindRoot(f *testing.F) {
+	f.Add([]byte{0, 1, 2})
+	f.Add([]byte{2, 1})
+	f.Add([]byte{1})
+	f.Add([]byte{0, 3, 4, 2})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		items := parentGraph(data)
+
+		for _, item := range items {
+			// walk the parents remembering where it has been to know the expected outcome
+			var expectedErr error
+
+			seen := make(map[int]struct{})
+			current := item
+
+			for current.Parent != nil {
+				if _, ok := seen[current.ID]; ok {
+					expectedErr = ErrParentCycle
+					break
+				}
+
+				seen[current.ID] = struct{}{}
+
+				next, ok := items[*current.Parent]
+				if !ok {
+					expectedErr = ErrItemNotFound
+					break
+				}
+
+				current = next
+			}
+
+			root, err := item.FindRoot(items)
+
+			var treeErr *TreeError
+			if errors.Is(expectedErr, ErrParentCycle) && (!errors.As(err, &treeErr) || treeErr.ID != item.ID) {
+				t.Fatalf("expected a TreeError for item %d, got %v", item.ID, err)
+			}
+
+			if !errors.Is(err, expectedErr) || (err == nil && root != current) {
+				t.Fatalf("expected root %d and error %v for item %d, got %v and %v", current.ID, expectedErr, item.ID, root, err)
+			}
+		}
+
+		// GroupByRoot stops at the first failure, which may be a missing parent even when another item is on a cycle
+		_, err := items.GroupByRoot()
+		if (err != nil) != slices.ContainsFunc(slices.Collect(maps.Values(items)), func(item *Item) bool {
+			_, err := item.FindRoot(items)
+
+			return err != nil
+		}) {
+			t.Fatalf("expected GroupByRoot to fail exactly when an item cannot be traced, got %v", err)
+		}
+
+		_ = items.traceable()
+	})
+}
+
+func TestFindRootTooDeep(t *testing.T) {
+	t.Parallel()
+
+	items := make(ItemSet, MaxTreeDepth+2)
+
+	for id := 1; id <= MaxTreeDepth+2; id++ {
+		item := &Item{
+			Parent: nil, Poll: nil, By: "a", Text: "", Title: "", URL: "", Type: Comment, Kids: nil, Parts: nil,
+			Time: int64(id), Descendants: 0, ID: id, Score: 0, Dead: false, Deleted: false,
+		}
+
+		if id > 1 {
+			parent := id - 1
+			item.Parent = &parent
+		}
+
+		items[id] = item
+	}
+
+	_, err := items[MaxTreeDepth+1].FindRoot(items)
+	if err != nil {
+		t.Fatalf("expected a chain of MaxTreeDepth to be traced, got %v", err)
+	}
+
+	_, err = items[MaxTreeDepth+2].FindRoot(items)
+
+	var treeErr *TreeError
+	if !errors.As(err, &treeErr) || !errors.Is(err, ErrTreeTooDeep) || treeErr.ID != MaxTreeDepth+2 {
+		t.Fatalf("expected a TreeError for a chain deeper than MaxTreeDepth, got %v", err)
+	}
+}
@@ -4,7 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"golang.org/x/sync/errgroup"
 )
 
 type ItemStreamValue[TItem any] struct {
@@ -13,75 +21,401 @@ type ItemStreamValue[TItem any] struct {
 	ID   int
 }
 
-func wrapError[TItem any](err error) ItemStreamValue[TItem] {
+func wrapError[TItem any](id int, op string, err error) ItemStreamValue[TItem] {
 	var d TItem
-	return ItemStreamValue[TItem]{Item: d, Err: err, ID: 0}
+	return ItemStreamValue[TItem]{Item: d, Err: &StreamError{err, op, id}, ID: id}
+}
+
+// StreamError is the error for an item in an ItemStream: "enqueue" when the request channel is full, "read" or
+// "decode" when retrieving the item failed, "validate" when it was malformed (see WithValidation), or "send" when the
+// result channel is full. ID is 0 for "send".
+type StreamError struct {
+	Err error
+	Op  string
+	ID  int
+}
+
+func (e *StreamError) Error() string {
+	if e.ID == 0 {
+		return e.Op + ": " + e.Err.Error()
+	}
+
+	return e.Op + " " + strconv.Itoa(e.ID) + ": " + e.Err.Error()
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// Cause describes the innermost error along with the operation, so it is shared by items failing for the same reason.
+// HTTP failures are described by their status code since the error otherwise includes the path.
+func (e *StreamError) Cause() string {
+	var getterErr *core.GetterError
+	if errors.As(e.Err, &getterErr) {
+		return e.Op + ": status " + strconv.Itoa(getterErr.Code)
+	}
+
+	root := e.Err
+	for next := errors.Unwrap(root); next != nil; next = errors.Unwrap(root) {
+		root = next
+	}
+
+	return e.Op + ": " + root.Error()
 }
 
+// SearchError is returned by a search that failed. Err is the error not specific to an item, such as one returned by
+// the accumulator, and Items are the errors for the items that failed.
+type SearchError struct {
+	Err   error
+	Items []*StreamError
+}
+
+func (e *SearchError) Error() string {
+	var parts []string
+
+	if e.Err != nil {
+		parts = append(parts, e.Err.Error())
+	}
+
+	const maxIDsPerCause = 5
+
+	byCause := e.ByCause()
+
+	for _, cause := range slices.Sorted(maps.Keys(byCause)) {
+		ids := byCause[cause]
+		part := fmt.Sprintf("%s (%d items", cause, len(ids))
+
+		if ids[0] != 0 {
+			shown := make([]string, 0, maxIDsPerCause)
+			for _, id := range ids[:min(len(ids), maxIDsPerCause)] {
+				shown = append(shown, strconv.Itoa(id))
+			}
+
+			part += ": " + strings.Join(shown, ", ")
+			if len(ids) > maxIDsPerCause {
+				part += ", ..."
+			}
+		}
+
+		parts = append(parts, part+")")
+	}
+
+	return "search error: " + strings.Join(parts, "; ")
+}
+
+func (e *SearchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Items)+1)
+
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+
+	for _, item := range e.Items {
+		errs = append(errs, item)
+	}
+
+	return errs
+}
+
+// ByCause groups the IDs of the failed items by StreamError.Cause, in the order they failed.
+func (e *SearchError) ByCause() map[string][]int {
+	result := make(map[string][]int)
+
+	for _, item := range e.Items {
+		cause := item.Cause()
+		result[cause] = append(result[cause], item.ID)
+	}
+
+	return result
+}
+
+// ItemStream retrieves items with a bounded number in flight. An ItemStream is not safe for concurrent use: one
+// goroutine runs one search at a time. A stream from NewItemStream serves a single Get or search, after which it is
+// closed. A stream from AcquireStream serves any number of them in turn until it is released.
 type ItemStream[TItem any] struct {
 	IDs         chan<- int
 	Items       <-chan ItemStreamValue[TItem]
+	pool        *itemStreamPool[TItem]
+	control     itemStreamControl
 	maxInFlight int
+	reorder     ReorderPolicy
+}
+
+// ReorderPolicy is how far SearchOrdered may deliver results out of the order of the IDs. While an early ID is still
+// being retrieved, a strict search holds every later result, and once the IDs in flight reach the limit of the stream
+// it sends no more until the early one completes; allowing some skew keeps the later results and the requests moving.
+// BenchmarkReorderPolicy searches through occasional slow responses about six times as fast with BoundedSkew(16).
+// Searches such as listings whose output must keep its order should stay strict.
+type ReorderPolicy int
+
+const (
+	// StrictOrder delivers every result in the order of the IDs. It is the default.
+	StrictOrder ReorderPolicy = 0
+	// BestEffortOrder delivers every result as it completes. The IDs are still sent in order, so the results stay
+	// within the IDs in flight of their place.
+	BestEffortOrder ReorderPolicy = -1
+)
+
+// BoundedSkew delivers a result as it completes when at most n earlier IDs are still being retrieved, so results are
+// never more than n places ahead of their order. n of 0 or less is StrictOrder.
+func BoundedSkew(n int) ReorderPolicy {
+	return ReorderPolicy(max(n, 0))
+}
+
+func (p ReorderPolicy) maxSkew() int {
+	if p < 0 {
+		return math.MaxInt
+	}
+
+	return int(p)
+}
+
+// SetReorderPolicy sets the ReorderPolicy of the later ordered searches of the stream. A stream from AcquireStream
+// returns to StrictOrder when released.
+func (s *ItemStream[TItem]) SetReorderPolicy(p ReorderPolicy) {
+	s.reorder = p
+}
+
+// itemStreamControl directs the goroutine serving a pooled stream. starts switches it to the context of a new
+// acquisition, and flushes marks the end of a search, after which it sends errEndOfSearch once the last result of the
+// search is sent.
+type itemStreamControl struct {
+	starts  chan context.Context
+	flushes chan struct{}
 }
 
 var errRequestChannelFull = errors.New("request channel full")
 
 var errResultChannelFull = errors.New("result channel full (itemStreamMaxInFlight exceeded)")
 
+var errEndOfSearch = errors.New("end of search")
+
+// An ItemStream runs in three stages. Dispatch reads the IDs sent to the stream into batches, fetch retrieves each
+// batch with the bulk getter and sends the results as they complete, and reorder, run by SearchOrdered in the calling
+// goroutine, holds the results that complete early until those before them are done. Dispatch hands each batch to
+// fetch directly, since another goroutine between them would double the latency of small searches. The stages of a
+// stream run in an errgroup.Group, and the Items channel is closed once all of them have returned.
+
+// itemStreamBatch is a batch of IDs passed from the dispatch to the fetch stage, to be retrieved with ctx. A flush
+// batch ends a search of a pooled stream, after the rest of whose results errEndOfSearch is sent.
+type itemStreamBatch struct {
+	ctx   context.Context
+	ids   []int
+	flush bool
+}
+
 func newItemStream[TItem any](
 	ctx context.Context,
 	bulkItemGetter BulkStreamGetter[TItem],
 	maxInFlight int,
 ) *ItemStream[TItem] {
 	idCh := make(chan int, maxInFlight)
-	resultCh := make(chan ItemStreamValue[TItem], maxInFlight)
-
-	go func() {
-		defer close(resultCh)
-
-		errCh := make(chan error, 1)
-		var wg sync.WaitGroup
+	f := newItemStreamFetcher(bulkItemGetter, maxInFlight)
 
+	runItemStreamStages(f, func(fetch func(itemStreamBatch)) {
 		for {
 			ids, ok := greedyRead(idCh, 0)
 			if !ok {
-				break
+				return
 			}
 
-			for range ids {
-				wg.Add(1)
-			}
+			fetch(itemStreamBatch{ctx, ids, false})
+		}
+	})
 
-			r := bulkItemGetter.Get(ctx, ids, func(_ int, value ItemStreamValue[TItem]) {
-				defer wg.Done()
+	return &ItemStream[TItem]{idCh, f.resultCh, nil, itemStreamControl{nil, nil}, maxInFlight, StrictOrder}
+}
 
-				if !trySend(resultCh, value) {
-					_ = trySend(errCh, fmt.Errorf("failed to send result: %w", errResultChannelFull))
-				}
-			})
+// newPooledItemStream starts a stream that serves searches until its starts channel is closed. It waits for the
+// context of its first acquisition before serving anything.
+func newPooledItemStream[TItem any](pool *itemStreamPool[TItem]) *ItemStream[TItem] {
+	idCh := make(chan int, pool.maxInFlight)
+	f := newItemStreamFetcher(pool.getter, pool.maxInFlight)
+	control := itemStreamControl{make(chan context.Context), make(chan struct{})}
 
-			for _, id := range r {
-				resultCh <- wrapError[TItem](fmt.Errorf("failed to get %d: %w", id, errRequestChannelFull))
+	runItemStreamStages(f, func(fetch func(itemStreamBatch)) {
+		ctx, ok := <-control.starts
+		if !ok {
+			return
+		}
 
-				wg.Done()
+		for {
+			select {
+			case next, ok := <-control.starts:
+				if !ok {
+					return
+				}
+
+				ctx = next
+			case id := <-idCh:
+				fetch(itemStreamBatch{ctx, appendAvailable(idCh, []int{id}, 0), false})
+			case <-control.flushes:
+				// The search sent all of its IDs before flushing, so any still buffered belong to it.
+				fetch(itemStreamBatch{ctx, appendAvailable(idCh, nil, 0), true})
 			}
 		}
+	})
 
-		wg.Wait()
-		close(errCh)
+	return &ItemStream[TItem]{idCh, f.resultCh, pool, control, pool.maxInFlight, StrictOrder}
+}
 
-		var errs []error
-		for err := range errCh {
-			errs = append(errs, err)
-		}
+// runItemStreamStages runs the dispatch stage, which passes batches to the fetch stage of f until it returns, and
+// closes the results of f once the stages are done.
+func runItemStreamStages[TItem any](f *itemStreamFetcher[TItem], dispatch func(fetch func(itemStreamBatch))) {
+	var g errgroup.Group
 
-		err := errors.Join(errs...)
-		if err != nil {
-			resultCh <- wrapError[TItem](err)
-		}
+	g.Go(func() error {
+		dispatch(f.run)
+		f.finish()
+
+		return nil
+	})
+
+	go func() {
+		_ = g.Wait()
+
+		close(f.resultCh)
 	}()
+}
+
+// itemStreamFetcher retrieves the IDs sent to a stream and sends their results. A failure to send a result is
+// reported by finish once every retrieval has completed.
+type itemStreamFetcher[TItem any] struct {
+	getter   BulkStreamGetter[TItem]
+	resultCh chan ItemStreamValue[TItem]
+	errCh    chan error
+	wg       sync.WaitGroup
+}
+
+func newItemStreamFetcher[TItem any](getter BulkStreamGetter[TItem], maxInFlight int) *itemStreamFetcher[TItem] {
+	return &itemStreamFetcher[TItem]{
+		getter,
+		make(chan ItemStreamValue[TItem], maxInFlight),
+		make(chan error, 1),
+		sync.WaitGroup{},
+	}
+}
+
+// run is the fetch stage, retrieving a batch from the dispatch stage.
+func (f *itemStreamFetcher[TItem]) run(batch itemStreamBatch) {
+	if len(batch.ids) > 0 {
+		f.fetch(batch.ctx, batch.ids)
+	}
+
+	if batch.flush {
+		f.finish()
+		f.resultCh <- wrapError[TItem](0, "end", errEndOfSearch)
+	}
+}
+
+func (f *itemStreamFetcher[TItem]) fetch(ctx context.Context, ids []int) {
+	f.wg.Add(len(ids))
+
+	r := f.getter.Get(ctx, ids, func(_ int, value ItemStreamValue[TItem]) {
+		defer f.wg.Done()
+
+		if !trySend(f.resultCh, value) {
+			_ = trySend(f.errCh, errResultChannelFull)
+		}
+	})
+
+	for _, id := range r {
+		f.resultCh <- wrapError[TItem](id, "enqueue", errRequestChannelFull)
+
+		f.wg.Done()
+	}
+}
+
+func (f *itemStreamFetcher[TItem]) finish() {
+	f.wg.Wait()
+
+	select {
+	case err := <-f.errCh:
+		f.resultCh <- wrapError[TItem](0, "send", err)
+	default:
+	}
+}
+
+// itemStreamPool keeps released streams, up to maxIdle, so their goroutines and channels serve later acquisitions.
+type itemStreamPool[TItem any] struct {
+	getter      BulkStreamGetter[TItem]
+	idle        []*ItemStream[TItem]
+	maxIdle     int
+	maxInFlight int
+	mu          sync.Mutex
+	closed      bool
+}
 
-	return &ItemStream[TItem]{idCh, resultCh, maxInFlight}
+func newItemStreamPool[TItem any](
+	getter BulkStreamGetter[TItem],
+	maxInFlight int,
+	maxIdle int,
+) *itemStreamPool[TItem] {
+	return &itemStreamPool[TItem]{getter, nil, maxIdle, maxInFlight, sync.Mutex{}, false}
+}
+
+func (p *itemStreamPool[TItem]) acquire(ctx context.Context) *ItemStream[TItem] {
+	p.mu.Lock()
+
+	var s *ItemStream[TItem]
+	if n := len(p.idle); n > 0 {
+		s = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+	}
+
+	p.mu.Unlock()
+
+	if s == nil {
+		s = newPooledItemStream(p)
+	}
+
+	s.control.starts <- ctx
+
+	return s
+}
+
+func (p *itemStreamPool[TItem]) release(s *ItemStream[TItem]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || len(p.idle) >= p.maxIdle {
+		close(s.control.starts)
+		return
+	}
+
+	p.idle = append(p.idle, s)
+}
+
+// close stops the idle streams. Streams released afterward are stopped rather than kept.
+func (p *itemStreamPool[TItem]) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.idle {
+		close(s.control.starts)
+	}
+
+	p.idle = nil
+	p.closed = true
+}
+
+// Release returns a stream from AcquireStream to its client for reuse. The stream must not be used afterward. Release
+// does nothing for other streams.
+func (s *ItemStream[TItem]) Release() {
+	if s.pool != nil {
+		s.reorder = StrictOrder
+		s.pool.release(s)
+	}
+}
+
+// endSearch tells the stream that the current search has sent its last ID, so searchDrain reads to the end of it.
+func (s *ItemStream[TItem]) endSearch() {
+	if s.pool == nil {
+		close(s.IDs)
+		return
+	}
+
+	s.control.flushes <- struct{}{}
 }
 
 func (s *ItemStream[TItem]) MaxInFlight() int {
@@ -103,10 +437,13 @@ func (s *ItemStream[TItem]) Get(ids []int) (map[int]TItem, error) {
 }
 
 func (s *ItemStream[TItem]) SearchOrdered(ids []int, acc func(key int, value TItem) (bool, []int, error)) error {
-	all := make(map[int]ItemStreamValue[TItem], len(ids))
+	reorder := &itemStreamReorder[TItem]{
+		make(map[int]ItemStreamValue[TItem], len(ids)), nil, acc, s.reorder.maxSkew(),
+	}
 	maxReadAhead, idCh, resultCh := s.maxInFlight, s.IDs, s.Items
 
 	var outerErr error
+	var itemErrs []*StreamError
 
 	for outstanding := 0; len(ids) > 0; {
 		end := min(len(ids), outstanding+(maxReadAhead-outstanding))
@@ -117,7 +454,12 @@ func (s *ItemStream[TItem]) SearchOrdered(ids []int, acc func(key int, value TIt
 			break
 		}
 
-		ok, consumed, newIDs, err := searchOrderedBatch(all, ids, items, acc)
+		itemErrs = appendItemErrors(itemErrs, items)
+		if len(itemErrs) > 0 {
+			break
+		}
+
+		ok, remaining, delivered, newIDs, err := reorder.accept(ids, outstanding, items)
 		if err != nil {
 			outerErr = fmt.Errorf("failed to search: %w", err)
 			break
@@ -127,21 +469,22 @@ func (s *ItemStream[TItem]) SearchOrdered(ids []int, acc func(key int, value TIt
 			break
 		}
 
-		ids = ids[consumed:]
-		outstanding -= consumed
+		ids = remaining
+		outstanding -= delivered
 
 		ids = append(ids, newIDs...)
 	}
 
-	close(idCh)
+	s.endSearch()
 
-	return searchDrain(outerErr, resultCh)
+	return searchDrain(outerErr, itemErrs, resultCh)
 }
 
 func (s *ItemStream[TItem]) SearchUnordered(ids []int, acc func(key int, value TItem) (bool, []int, error)) error {
 	maxReadAhead, idCh, resultCh := s.maxInFlight, s.IDs, s.Items
 
 	var outerErr error
+	var itemErrs []*StreamError
 
 	for outstanding := 0; len(ids) > 0 || outstanding > 0; {
 		sent := trySendSlice(idCh, ids[:min(len(ids), maxReadAhead-outstanding)])
@@ -160,7 +503,9 @@ func (s *ItemStream[TItem]) SearchUnordered(ids []int, acc func(key int, value T
 			item := items[i]
 
 			if item.Err != nil {
-				err = item.Err
+				itemErrs = appendItemErrors(itemErrs, items[i:])
+				ok = false
+
 				break
 			}
 
@@ -180,63 +525,121 @@ func (s *ItemStream[TItem]) SearchUnordered(ids []int, acc func(key int, value T
 		outstanding -= len(items)
 	}
 
-	close(idCh)
+	s.endSearch()
 
-	return searchDrain(outerErr, resultCh)
+	return searchDrain(outerErr, itemErrs, resultCh)
 }
 
-func searchDrain[TItem any](err error, resultCh <-chan ItemStreamValue[TItem]) error {
-	var errs []error
-
+// searchDrain drains the results after a search stops, returning a SearchError if the search or any item failed.
+func searchDrain[TItem any](err error, itemErrs []*StreamError, resultCh <-chan ItemStreamValue[TItem]) error {
 	for itemOrError := range resultCh {
-		if itemOrError.Err != nil {
-			errs = append(errs, itemOrError.Err)
+		if errors.Is(itemOrError.Err, errEndOfSearch) {
+			break
 		}
+
+		itemErrs = appendItemErrors(itemErrs, []ItemStreamValue[TItem]{itemOrError})
 	}
 
-	err = errors.Join(err, errors.Join(errs...))
-	if err != nil {
-		return fmt.Errorf("search error: %w", err)
+	if err == nil && len(itemErrs) == 0 {
+		return nil
+	}
+
+	return &SearchError{err, itemErrs}
+}
+
+// appendItemErrors appends the errors of the items, as StreamErrors if they are not already.
+func appendItemErrors[TItem any](itemErrs []*StreamError, items []ItemStreamValue[TItem]) []*StreamError {
+	for _, item := range items {
+		if item.Err == nil {
+			continue
+		}
+
+		var streamErr *StreamError
+		if !errors.As(item.Err, &streamErr) {
+			streamErr = &StreamError{item.Err, "get", item.ID}
+		}
+
+		itemErrs = append(itemErrs, streamErr)
 	}
 
-	return nil
+	return itemErrs
 }
 
+// Advanced returns the limit on items in flight and the channels of the stream. Closing the IDs channel ends the
+// stream, so the channels of a stream from AcquireStream must not be used directly.
 func (s *ItemStream[TItem]) Advanced() (int, chan<- int, <-chan ItemStreamValue[TItem]) {
 	return s.maxInFlight, s.IDs, s.Items
 }
 
-func searchOrderedBatch[TItem any](
-	all map[int]ItemStreamValue[TItem],
-	ids []int,
-	items []ItemStreamValue[TItem],
-	acc func(key int, value TItem) (bool, []int, error),
-) (bool, int, []int, error) {
+// itemStreamReorder is the reorder stage of SearchOrdered. It holds the results that complete ahead of those before
+// them, passing them to the accumulator in the order of the IDs once at most maxSkew earlier IDs are still pending.
+// The later results of an ID passed more than once wait in repeats, so each of its places gets one of them.
+type itemStreamReorder[TItem any] struct {
+	pending map[int]ItemStreamValue[TItem]
+	repeats map[int][]ItemStreamValue[TItem]
+	acc     func(key int, value TItem) (bool, []int, error)
+	maxSkew int
+}
+
+// accept holds the completed items and passes those now deliverable among the first outstanding of ids, the IDs
+// sent, to the accumulator. It returns whether to keep going, the IDs not yet delivered in order, how many were
+// delivered, and the IDs the accumulator added. The IDs not delivered are ids resliced from the first one skipped
+// unless a later one was delivered, which only BoundedSkew and BestEffortOrder allow, and a new slice otherwise.
+func (r *itemStreamReorder[TItem]) accept(
+	ids []int, outstanding int, items []ItemStreamValue[TItem],
+) (bool, []int, int, []int, error) {
 	for _, item := range items {
-		if item.Err != nil {
-			return false, 0, nil, fmt.Errorf("failed to accumulate item: %w", item.Err)
+		if _, ok := r.pending[item.ID]; !ok {
+			r.pending[item.ID] = item
+			continue
+		}
+
+		if r.repeats == nil {
+			r.repeats = make(map[int][]ItemStreamValue[TItem])
 		}
 
-		all[item.ID] = item
+		r.repeats[item.ID] = append(r.repeats[item.ID], item)
 	}
 
-	consumed := 0
-	keepGoing := true
+	// skipped is only built once an ID is delivered past one still pending
+	var skipped []int
 	var allNewIDs []int
 
-	for ; keepGoing && consumed < len(ids); consumed++ {
-		id := ids[consumed]
+	keepGoing := true
+	delivered := 0
+	skips := 0
+	firstSkip := 0
+	i := 0
+
+	for ; keepGoing && i < outstanding && skips <= r.maxSkew; i++ {
+		id := ids[i]
 
-		item, ok := all[id]
+		item, ok := r.pending[id]
 		if !ok {
-			break
+			if skips == 0 {
+				firstSkip = i
+			}
+
+			skips++
+
+			if skipped != nil {
+				skipped = append(skipped, id)
+			}
+
+			continue
 		}
 
-		delete(all, id)
+		if skips > 0 && skipped == nil {
+			skipped = slices.Clone(ids[firstSkip:i])
+		}
+
+		r.next(id)
+
+		delivered++
 
-		ok, newIDs, err := acc(item.ID, item.Item)
+		ok, newIDs, err := r.acc(item.ID, item.Item)
 		if err != nil {
-			return false, consumed, nil, fmt.Errorf("failed to accumulate item: %w", err)
+			return false, nil, delivered, nil, fmt.Errorf("failed to accumulate item: %w", err)
 		}
 
 		keepGoing = ok
@@ -244,37 +647,59 @@ func searchOrderedBatch[TItem any](
 		allNewIDs = append(allNewIDs, newIDs...)
 	}
 
-	return keepGoing, consumed, allNewIDs, nil
+	switch {
+	case skips == 0:
+		return keepGoing, ids[i:], delivered, allNewIDs, nil
+	case skipped == nil:
+		return keepGoing, ids[firstSkip:], delivered, allNewIDs, nil
+	default:
+		return keepGoing, append(skipped, ids[i:]...), delivered, allNewIDs, nil
+	}
 }
 
-func greedyRead[T any](from <-chan T, maxRead int) ([]T, bool) {
-	var id T
-	var ok bool
+// next replaces the pending result of id with its next repeat, if any.
+func (r *itemStreamReorder[TItem]) next(id int) {
+	repeats := r.repeats[id]
+	if len(repeats) == 0 {
+		delete(r.pending, id)
+		return
+	}
+
+	r.pending[id] = repeats[0]
+
+	if len(repeats) == 1 {
+		delete(r.repeats, id)
+	} else {
+		r.repeats[id] = repeats[1:]
+	}
+}
 
-	id, ok = <-from
+func greedyRead[T any](from <-chan T, maxRead int) ([]T, bool) {
+	v, ok := <-from
 	if !ok {
 		return nil, false
 	}
 
-	var result []T
-	result = append(result, id)
+	return appendAvailable(from, []T{v}, maxRead), true
+}
 
-	more := true
-	for more && (maxRead == 0 || len(result) < maxRead) {
+// appendAvailable appends the values that can be read from from without blocking, up to maxRead in total if it is
+// not 0.
+func appendAvailable[T any](from <-chan T, result []T, maxRead int) []T {
+	for maxRead == 0 || len(result) < maxRead {
 		select {
-		case id, ok = <-from:
+		case v, ok := <-from:
 			if !ok {
-				more = false
-				break
+				return result
 			}
 
-			result = append(result, id)
+			result = append(result, v)
 		default:
-			more = false
+			return result
 		}
 	}
 
-	return result, true
+	return result
 }
 
 func trySendSlice[T any](to chan<- T, v []T) int {
@@ -0,0 +1,583 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestSearchErrorByCause(t *testing.T) {
+	t.Parallel()
+
+	var spec core.FailureSpec
+	spec.ServerErrorRate = 1
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(core.NewFlakyGetter(testdata.Getter, spec)),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := testdata.New[:3]
+
+	_, err = client.GetItems(t.Context(), ids)
+
+	var searchErr *SearchError
+	if !errors.As(err, &searchErr) {
+		t.Fatalf("expected a SearchError, got %v", err)
+	}
+
+	byCause := searchErr.ByCause()
+
+	failed := byCause["read: status 500"]
+	if len(byCause) != 1 || len(failed) != len(ids) {
+		t.Fatalf("expected every item to fail with status 500, got %v", byCause)
+	}
+
+	slices.Sort(failed)
+
+	expected := slices.Sorted(slices.Values(ids))
+	if diff := cmp.Diff(expected, failed); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Op != "read" || !slices.Contains(ids, streamErr.ID) {
+		t.Fatalf("expected a StreamError for one of the items, got %v", err)
+	}
+
+	if !strings.HasPrefix(err.Error(), "search error: read: status 500 (3 items: ") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestAcquireStream(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := testdata.New[:10]
+
+	expected, err := client.GetItems(t.Context(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := client.AcquireStream(t.Context())
+
+	for range 3 {
+		items, err := stream.Get(ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(expected, ItemSet(items)); diff != "" {
+			t.Fatalf("diff: %s", diff)
+		}
+	}
+
+	// A search that stops early leaves items in flight, which must not leak into the next search.
+	err = stream.SearchOrdered(ids, func(_ int, _ *Item) (bool, []int, error) { return false, nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []int
+
+	err = stream.SearchOrdered(ids, func(id int, _ *Item) (bool, []int, error) {
+		seen = append(seen, id)
+		return true, nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(ids, seen); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	stream.Release()
+
+	if reused := client.AcquireStream(t.Context()); reused != stream {
+		t.Fatal("expected the released stream to be reused")
+	}
+
+	err = client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.Release()
+
+	_, _, items := stream.Advanced()
+	for range items {
+		t.Fatal("expected no results from a stream released after Close")
+	}
+}
+
+func TestAcquireStreamErrors(t *testing.T) {
+	t.Parallel()
+
+	var spec core.FailureSpec
+	spec.ServerErrorRate = 1
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(core.NewFlakyGetter(testdata.Getter, spec)),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	stream := client.AcquireStream(t.Context())
+	defer stream.Release()
+
+	for range 2 {
+		_, err = stream.Get(testdata.New[:3])
+
+		var searchErr *SearchError
+		if !errors.As(err, &searchErr) || len(searchErr.Items) != 3 {
+			t.Fatalf("expected a SearchError for 3 items, got %v", err)
+		}
+	}
+}
+
+func BenchmarkItemStream(b *testing.B) {
+	client, err := NewClient(
+		b.Context(),
+		WithFileCachePath(""),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := testdata.New[:5]
+
+	_, err = client.GetItems(b.Context(), ids)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("NewItemStream", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			_, err := client.Advanced().NewItemStream(b.Context()).Get(ids)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AcquireStream", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			stream := client.AcquireStream(b.Context())
+
+			_, err := stream.Get(ids)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			stream.Release()
+		}
+	})
+}
+
+func TestPooledItemStream(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	// a window of the scan including items missing from the corpus, which are null
+	ids := make([]int, 0, 500)
+	for id := testdata.MaxItem + 10; id > testdata.MaxItem-490; id-- {
+		ids = append(ids, id)
+	}
+
+	expected, err := client.GetItems(t.Context(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []int
+
+	retained := make(ItemSet, len(ids))
+
+	stream := client.Advanced().NewPooledItemStream(t.Context())
+
+	err = stream.SearchOrdered(ids, func(id int, item *Item) (bool, []int, error) {
+		order = append(order, id)
+		retained[id] = item.Clone()
+
+		return true, nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(order, ids) {
+		t.Fatal("expected the items in order")
+	}
+
+	for id, item := range expected {
+		if len(item.Kids) == 0 {
+			item.Kids = nil
+		}
+
+		if diff := cmp.Diff(item, retained[id]); diff != "" {
+			t.Fatalf("item %d (-want +got):\n%s", id, diff)
+		}
+	}
+}
+
+// BenchmarkPooledItemStream compares scanning the corpus for stories with a typed ItemStream and a PooledItemStream.
+func BenchmarkPooledItemStream(b *testing.B) {
+	client, err := NewClient(
+		b.Context(),
+		WithFileCachePath(""),
+		WithCacheFor(0),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	ids := make([]int, 0, testdata.MaxItem-testdata.MinItem+1)
+	for id := testdata.MaxItem; id >= testdata.MinItem; id-- {
+		ids = append(ids, id)
+	}
+
+	stories := 0
+	filter := func(_ int, item *Item) (bool, []int, error) {
+		if item.Type == Story {
+			stories++
+		}
+
+		return true, nil, nil
+	}
+
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			err := client.Advanced().NewItemStream(b.Context()).SearchOrdered(ids, filter)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for b.Loop() {
+			err := client.Advanced().NewPooledItemStream(b.Context()).SearchOrdered(ids, filter)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// stragglerGetter delays every every-th item, like the occasional slow response of the API.
+type stragglerGetter struct {
+	every int
+	delay time.Duration
+}
+
+func (g stragglerGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, itemPathPrefix), jsonSuffix))
+	if err == nil && id%g.every == 0 {
+		time.Sleep(g.delay)
+	}
+
+	return testdata.Getter.Get(ctx, path)
+}
+
+func newStragglerClient(tb testing.TB, getter stragglerGetter) *Client {
+	tb.Helper()
+
+	client, err := NewClient(
+		tb.Context(),
+		WithFileCachePath(""),
+		WithCacheFor(0),
+		WithMaxConnections(8),
+		WithGetter(getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestReorderPolicy(t *testing.T) {
+	t.Parallel()
+
+	client := newStragglerClient(t, stragglerGetter{25, 10 * time.Millisecond})
+
+	ids := make([]int, 0, 300)
+	for id := testdata.MaxItem; len(ids) < cap(ids); id-- {
+		ids = append(ids, id)
+	}
+
+	maxInFlight := client.Advanced().NewItemStream(t.Context()).MaxInFlight()
+
+	for _, test := range []struct {
+		policy  ReorderPolicy
+		maxSkew int
+	}{
+		{StrictOrder, 0},
+		{BoundedSkew(4), 4},
+		{BestEffortOrder, maxInFlight - 1},
+	} {
+		stream := client.Advanced().NewItemStream(t.Context())
+		stream.SetReorderPolicy(test.policy)
+
+		// the skew of a result is the count of earlier IDs not yet delivered
+		delivered := make(map[int]bool, len(ids))
+		position := make(map[int]int, len(ids))
+
+		for i, id := range ids {
+			position[id] = i
+		}
+
+		worst := 0
+		next := 0
+
+		err := stream.SearchOrdered(ids, func(id int, _ *Item) (bool, []int, error) {
+			skew := 0
+			for _, earlier := range ids[next:position[id]] {
+				if !delivered[earlier] {
+					skew++
+				}
+			}
+
+			worst = max(worst, skew)
+
+			delivered[id] = true
+			for next < len(ids) && delivered[ids[next]] {
+				next++
+			}
+
+			return true, nil, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(delivered) != len(ids) || worst > test.maxSkew {
+			t.Fatalf("policy %d: expected %d items with a skew of at most %d, got %d with %d",
+				test.policy, len(ids), test.maxSkew, len(delivered), worst)
+		}
+
+		if test.policy != StrictOrder && worst == 0 {
+			t.Fatalf("policy %d: expected the stragglers to be passed", test.policy)
+		}
+	}
+}
+
+// TestReorderAccept checks the IDs left by the reorder stage, which under StrictOrder are the read-ahead window
+// resliced rather than copied while the first of them is still pending. It is not parallel, as AllocsPerRun requires.
+func TestReorderAccept(t *testing.T) {
+	ids := make([]int, 100)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+
+	acc := func(int, int) (bool, []int, error) { return true, nil, nil }
+	arrived := []ItemStreamValue[int]{{0, nil, 2}, {0, nil, 4}}
+
+	for _, test := range []struct {
+		remaining []int
+		maxSkew   int
+		delivered int
+	}{
+		{ids, 0, 0},
+		{append([]int{1, 3}, ids[4:]...), 4, 2},
+	} {
+		r := &itemStreamReorder[int]{make(map[int]ItemStreamValue[int]), nil, acc, test.maxSkew}
+
+		_, remaining, delivered, _, err := r.accept(ids, len(ids), arrived)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if delivered != test.delivered || !slices.Equal(remaining, test.remaining) {
+			t.Fatalf("skew %d: expected %d delivered leaving %v, got %d leaving %v",
+				test.maxSkew, test.delivered, test.remaining[:4], delivered, remaining[:min(4, len(remaining))])
+		}
+	}
+
+	r := &itemStreamReorder[int]{make(map[int]ItemStreamValue[int]), nil, acc, 0}
+
+	_, _, _, _, err := r.accept(ids, len(ids), arrived)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _, _, _, _ = r.accept(ids, len(ids), nil)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations while the first ID is pending, got %v", allocs)
+	}
+}
+
+// BenchmarkReorderPolicy compares ordered searches through occasional slow responses with each ReorderPolicy.
+func BenchmarkReorderPolicy(b *testing.B) {
+	client := newStragglerClient(b, stragglerGetter{20, 2 * time.Millisecond})
+
+	ids := make([]int, 0, 1000)
+	for id := testdata.MaxItem; len(ids) < cap(ids); id-- {
+		ids = append(ids, id)
+	}
+
+	for _, bm := range []struct {
+		name   string
+		policy ReorderPolicy
+	}{
+		{"strict", StrictOrder},
+		{"skew-16", BoundedSkew(16)},
+		{"best-effort", BestEffortOrder},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for b.Loop() {
+				stream := client.Advanced().NewItemStream(b.Context())
+				stream.SetReorderPolicy(bm.policy)
+
+				err := stream.SearchOrdered(ids, func(int, *Item) (bool, []int, error) { return true, nil, nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestDuplicateIDs checks that an ID passed n times to a search reaches the accumulator n times, in its places for
+// SearchOrdered, through the whole pipeline with and without WithDedupe.
+func TestDuplicateIDs(t *testing.T) {
+	t.Parallel()
+
+	var ids []int
+
+	for id := testdata.MaxItem; id > testdata.MaxItem-100; id-- {
+		ids = append(ids, id, id, id+1, id)
+	}
+
+	expectedCounts := make(map[int]int)
+	for _, id := range ids {
+		expectedCounts[id]++
+	}
+
+	for _, dedupe := range []bool{false, true} {
+		t.Run("dedupe "+strconv.FormatBool(dedupe), func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient(
+				t.Context(), WithFileCachePath(""), WithGetter(testdata.Getter), WithClock(testdata.Clock),
+				WithDedupe(dedupe),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() { _ = client.Close() }()
+
+			var order []int
+
+			err = client.Advanced().NewItemStream(t.Context()).SearchOrdered(ids, func(id int, _ *Item) (bool, []int, error) {
+				order = append(order, id)
+				return true, nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(order, ids) {
+				t.Fatal("expected each duplicate in its place")
+			}
+
+			counts := make(map[int]int)
+
+			err = client.Advanced().NewItemStream(t.Context()).SearchUnordered(ids, func(id int, _ *Item) (bool, []int, error) {
+				counts[id]++
+				return true, nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(expectedCounts, counts); diff != "" {
+				t.Fatalf("unexpected counts (-want +got):\n%s", diff)
+			}
+
+			// each duplicate of a raw item has its own reader
+			counts = make(map[int]int)
+
+			err = client.Advanced().NewPooledItemStream(t.Context()).SearchUnordered(
+				ids, func(id int, item *Item) (bool, []int, error) {
+					if item.ID != id {
+						return false, nil, fmt.Errorf("item %d decoded as %d", id, item.ID)
+					}
+
+					counts[id]++
+
+					return true, nil, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(expectedCounts, counts); diff != "" {
+				t.Fatalf("unexpected raw counts (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package hn
+
+import (
+	"strconv"
+)
+
+const siteItemURL = "https://news.ycombinator.com/item?id="
+
+// ItemURL returns the permalink of the item with id on news.ycombinator.com.
+func ItemURL(id int) string {
+	return siteItemURL + strconv.Itoa(id)
+}
+
+// ContextURL returns a link to item within its thread: the page of its parent, anchored at the item so the site
+// scrolls to it with the replies around it visible. Items without a parent, such as stories, link to their own page.
+func ContextURL(item *Item) string {
+	if item.Parent == nil {
+		return ItemURL(item.ID)
+	}
+
+	return ItemURL(*item.Parent) + "#" + strconv.Itoa(item.ID)
+}
@@ -3,9 +3,13 @@ package hn
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
+	"sync"
+	"unicode/utf8"
 )
 
 func (u *User) Marshal() ([]byte, error) {
@@ -22,11 +26,11 @@ func (u *User) Marshal() ([]byte, error) {
 func (u *User) WriteJSON(w io.Writer) error {
 	pw := startObject(w)
 
-	writeJSONProperty(&pw, "\"about\":", u.About, isDefault[string], writeJSONString[string])
-	writeJSONProperty(&pw, "\"created\":", u.Created, isDefault[int64], writeJSONInt[int64])
-	writeJSONProperty(&pw, "\"id\":", u.ID, isDefault[string], writeJSONString[string])
-	writeJSONProperty(&pw, "\"karma\":", u.Karma, isDefault[int], writeJSONInt[int])
-	writeJSONProperty(&pw, "\"submitted\":", u.Submitted, isEmptySlice[int], writeJSONIntSlice[int])
+	writeJSONProperty(pw, "\"about\":", u.About, isDefault[string], appendJSONString[string])
+	writeJSONProperty(pw, "\"created\":", u.Created, isDefault[int64], appendJSONInt[int64])
+	writeJSONProperty(pw, "\"id\":", u.ID, isDefault[string], appendJSONString[string])
+	writeJSONProperty(pw, "\"karma\":", u.Karma, isDefault[int], appendJSONInt[int])
+	writeJSONProperty(pw, "\"submitted\":", u.Submitted, isEmptySlice[int], appendJSONIntSlice[int])
 
 	return pw.closeObject()
 }
@@ -53,46 +57,154 @@ func (item *Item) WriteJSON(w io.Writer) error {
 
 	pw := startObject(w)
 
-	writeJSONProperty(&pw, "\"by\":", item.By, isDefault[string], writeJSONString[string])
-	writeJSONProperty(&pw, "\"dead\":", item.Dead, isDefault[bool], writeJSONBool)
-	writeJSONProperty(&pw, "\"deleted\":", item.Deleted, isDefault[bool], writeJSONBool)
-	writeJSONProperty(&pw, "\"descendants\":", item.Descendants, descendantsSkip, writeJSONInt[int])
-	writeJSONProperty(&pw, "\"id\":", item.ID, isDefault[int], writeJSONInt[int])
-	writeJSONProperty(&pw, "\"kids\":", item.Kids, isEmptySlice[int], writeJSONIntSlice[int])
-	writeJSONProperty(&pw, "\"parent\":", item.Parent, isDefault[*int], writeJSONIntP[int])
-	writeJSONProperty(&pw, "\"poll\":", item.Poll, isDefault[*int], writeJSONIntP[int])
-	writeJSONProperty(&pw, "\"parts\":", item.Parts, isEmptySlice[int], writeJSONIntSlice[int])
-	writeJSONProperty(&pw, "\"score\":", item.Score, isDefault[int], writeJSONInt[int])
-	writeJSONProperty(&pw, "\"text\":", item.Text, isDefault[string], writeJSONString[string])
-	writeJSONProperty(&pw, "\"time\":", item.Time, isDefault[int64], writeJSONInt[int64])
-	writeJSONProperty(&pw, "\"title\":", item.Title, isDefault[string], writeJSONString[string])
-	writeJSONProperty(&pw, "\"type\":", item.Type, isDefault[ItemType], writeJSONString[ItemType])
-	writeJSONProperty(&pw, "\"url\":", item.URL, isDefault[string], writeJSONString[string])
+	writeJSONProperty(pw, "\"by\":", item.By, isDefault[string], appendJSONString[string])
+	writeJSONProperty(pw, "\"dead\":", item.Dead, isDefault[bool], appendJSONBool)
+	writeJSONProperty(pw, "\"deleted\":", item.Deleted, isDefault[bool], appendJSONBool)
+	writeJSONProperty(pw, "\"descendants\":", item.Descendants, descendantsSkip, appendJSONInt[int])
+	writeJSONProperty(pw, "\"id\":", item.ID, isDefault[int], appendJSONInt[int])
+	writeJSONProperty(pw, "\"kids\":", item.Kids, isEmptySlice[int], appendJSONIntSlice[int])
+	writeJSONProperty(pw, "\"parent\":", item.Parent, isDefault[*int], appendJSONIntP[int])
+	writeJSONProperty(pw, "\"poll\":", item.Poll, isDefault[*int], appendJSONIntP[int])
+	writeJSONProperty(pw, "\"parts\":", item.Parts, isEmptySlice[int], appendJSONIntSlice[int])
+	writeJSONProperty(pw, "\"score\":", item.Score, isDefault[int], appendJSONInt[int])
+	writeJSONProperty(pw, "\"text\":", item.Text, isDefault[string], appendJSONString[string])
+	writeJSONProperty(pw, "\"time\":", item.Time, isDefault[int64], appendJSONInt[int64])
+	writeJSONProperty(pw, "\"title\":", item.Title, isDefault[string], appendJSONString[string])
+	writeJSONProperty(pw, "\"type\":", item.Type, isDefault[ItemType], appendJSONString[ItemType])
+	writeJSONProperty(pw, "\"url\":", item.URL, isDefault[string], appendJSONString[string])
 
 	return pw.closeObject()
 }
 
+// ItemFields are the names of the fields accepted by WriteJSONFields, in the order written by WriteJSON.
+var ItemFields = []string{ //nolint:gochecknoglobals // constant
+	"by", "dead", "deleted", "descendants", "id", "kids", "parent", "poll", "parts", "score", "text", "time", "title",
+	"type", "url",
+}
+
+// ItemLinkFields are the names of the links to an item on news.ycombinator.com that WriteJSONFields also accepts:
+// its ItemURL as "permalink" and its ContextURL as "context".
+var ItemLinkFields = []string{"permalink", "context"} //nolint:gochecknoglobals // constant
+
+// JSONProperty is an extra property for WriteJSONFields with a value that is already encoded as JSON.
+type JSONProperty struct {
+	Name  string
+	Value json.RawMessage
+}
+
+//nolint:gochecknoglobals // constant
+var itemFieldPrefixes = func() map[string]string {
+	prefixes := make(map[string]string, len(ItemFields)+len(ItemLinkFields))
+	for _, field := range append(slices.Clone(ItemFields), ItemLinkFields...) {
+		prefixes[field] = "\"" + field + "\":"
+	}
+
+	return prefixes
+}()
+
+var errUnknownItemField = errors.New("unknown item field")
+
+// WriteJSONFields writes an object with the named fields of item, in the order given, followed by the extra
+// properties. Unlike WriteJSON, every named field is written even if it has its default value, so projected output
+// has the same keys in every object. Absent parent and poll fields and empty slices are written as null.
+func (item *Item) WriteJSONFields(w io.Writer, fields []string, extra ...JSONProperty) error {
+	pw := startObject(w)
+
+	for _, field := range fields {
+		prefix := itemFieldPrefixes[field]
+
+		switch field {
+		case "by":
+			writeJSONProperty(pw, prefix, item.By, neverSkip[string], appendJSONString[string])
+		case "dead":
+			writeJSONProperty(pw, prefix, item.Dead, neverSkip[bool], appendJSONBool)
+		case "deleted":
+			writeJSONProperty(pw, prefix, item.Deleted, neverSkip[bool], appendJSONBool)
+		case "descendants":
+			writeJSONProperty(pw, prefix, item.Descendants, neverSkip[int], appendJSONInt[int])
+		case "id":
+			writeJSONProperty(pw, prefix, item.ID, neverSkip[int], appendJSONInt[int])
+		case "kids":
+			writeJSONProperty(pw, prefix, item.Kids, neverSkip[[]int], appendJSONIntSliceOrNull[int])
+		case "parent":
+			writeJSONProperty(pw, prefix, item.Parent, neverSkip[*int], appendJSONIntPOrNull[int])
+		case "poll":
+			writeJSONProperty(pw, prefix, item.Poll, neverSkip[*int], appendJSONIntPOrNull[int])
+		case "parts":
+			writeJSONProperty(pw, prefix, item.Parts, neverSkip[[]int], appendJSONIntSliceOrNull[int])
+		case "score":
+			writeJSONProperty(pw, prefix, item.Score, neverSkip[int], appendJSONInt[int])
+		case "text":
+			writeJSONProperty(pw, prefix, item.Text, neverSkip[string], appendJSONString[string])
+		case "time":
+			writeJSONProperty(pw, prefix, item.Time, neverSkip[int64], appendJSONInt[int64])
+		case "title":
+			writeJSONProperty(pw, prefix, item.Title, neverSkip[string], appendJSONString[string])
+		case "type":
+			writeJSONProperty(pw, prefix, item.Type, neverSkip[ItemType], appendJSONString[ItemType])
+		case "url":
+			writeJSONProperty(pw, prefix, item.URL, neverSkip[string], appendJSONString[string])
+		case "permalink":
+			writeJSONProperty(pw, prefix, ItemURL(item.ID), neverSkip[string], appendJSONString[string])
+		case "context":
+			writeJSONProperty(pw, prefix, ContextURL(item), neverSkip[string], appendJSONString[string])
+		default:
+			pw.release()
+			return fmt.Errorf("%w: %q", errUnknownItemField, field)
+		}
+	}
+
+	for _, p := range extra {
+		writeJSONProperty(pw, "", p, neverSkip[JSONProperty], appendJSONProperty)
+	}
+
+	return pw.closeObject()
+}
+
+// objectWriter builds an object in a buffer and writes it to inner with a single Write when closed. objectWriters
+// are pooled so the buffer is reused across objects.
 type objectWriter struct {
-	err   error
 	inner io.Writer
 	d     string
+	buf   []byte
 }
 
-func startObject(w io.Writer) objectWriter {
-	err := writeByte(w, '{')
+//nolint:gochecknoglobals // pool
+var objectWriterPool = sync.Pool{New: func() any { return &objectWriter{nil, "", nil} }}
+
+func startObject(w io.Writer) *objectWriter {
+	pw := objectWriterPool.Get().(*objectWriter) //nolint:forcetypeassert // pool of one type
+	pw.inner = w
+	pw.buf = append(pw.buf[:0], '{')
+	pw.d = ""
+
+	return pw
+}
+
+func (pw *objectWriter) closeObject() error {
+	pw.buf = append(pw.buf, '}')
+
+	_, err := pw.inner.Write(pw.buf)
+
+	pw.release()
+
 	if err != nil {
-		return objectWriter{err, nil, ""}
+		return wrapWriterError(err)
 	}
 
-	return objectWriter{nil, w, ""}
+	return nil
 }
 
-func (pw objectWriter) closeObject() error {
-	if pw.err != nil {
-		return pw.err
+// release returns pw to the pool, dropping unusually large buffers so one huge item does not pin its memory.
+func (pw *objectWriter) release() {
+	const maxPooledBuffer = 64 * 1024
+
+	if cap(pw.buf) > maxPooledBuffer {
+		pw.buf = nil
 	}
 
-	return writeByte(pw.inner, '}')
+	pw.inner = nil
+	objectWriterPool.Put(pw)
 }
 
 func writeJSONProperty[T any](
@@ -100,34 +212,19 @@ func writeJSONProperty[T any](
 	prefix string,
 	v T,
 	skip func(T) bool,
-	write func(io.Writer, T) error,
+	appendValue func([]byte, T) []byte,
 ) {
-	if pw.err != nil || skip(v) {
-		return
-	}
-
-	_, err := io.WriteString(pw.inner, pw.d)
-	if err != nil {
-		pw.err = wrapWriterError(err)
+	if skip(v) {
 		return
 	}
 
+	pw.buf = append(pw.buf, pw.d...)
 	pw.d = ","
-
-	_, err = io.WriteString(pw.inner, prefix)
-	if err != nil {
-		pw.err = wrapWriterError(err)
-		return
-	}
-
-	err = write(pw.inner, v)
-	if err != nil {
-		pw.err = err
-		return
-	}
+	pw.buf = append(pw.buf, prefix...)
+	pw.buf = appendValue(pw.buf, v)
 }
 
-func neverSkip[T comparable](_ T) bool {
+func neverSkip[T any](_ T) bool {
 	return false
 }
 
@@ -140,110 +237,130 @@ func isEmptySlice[T comparable](v []T) bool {
 	return len(v) == 0
 }
 
-type noNewlineWriter struct {
-	inner io.Writer
-}
-
-func (w noNewlineWriter) Write(p []byte) (int, error) {
-	if len(p) > 0 && p[len(p)-1] == '\n' {
-		p = p[:len(p)-1]
-	}
-
-	return w.inner.Write(p)
-}
+// appendJSONString appends v as a JSON string, escaped as by encoding/json with HTML escaping disabled.
+func appendJSONString[T ~string](b []byte, v T) []byte {
+	const hex = "0123456789abcdef"
+
+	s := string(v)
+
+	b = append(b, '"')
+	start := 0
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+
+			b = append(b, s[start:i]...)
+
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+
+			i++
+			start = i
+
+			continue
+		}
 
-func writeJSONString[T any](w io.Writer, v T) error {
-	enc := json.NewEncoder(noNewlineWriter{w})
-	enc.SetEscapeHTML(false)
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			i += size
+			continue
+		}
 
-	err := enc.Encode(v)
-	if err != nil {
-		return wrapWriterError(err)
+		i += size
+		start = i
 	}
 
-	return nil
-}
+	b = append(b, s[start:]...)
 
-func writeJSONBool(w io.Writer, v bool) error {
-	if v {
-		_, err := io.WriteString(w, "true")
-		if err != nil {
-			return wrapWriterError(err)
-		}
-	} else {
-		_, err := io.WriteString(w, "false")
-		if err != nil {
-			return wrapWriterError(err)
-		}
-	}
+	return append(b, '"')
+}
 
-	return nil
+func appendJSONBool(b []byte, v bool) []byte {
+	return strconv.AppendBool(b, v)
 }
 
 type Integer interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32
 }
 
-func writeJSONIntP[T Integer](w io.Writer, v *T) error {
-	return writeJSONInt(w, *v)
+func appendJSONIntP[T Integer](b []byte, v *T) []byte {
+	return appendJSONInt(b, *v)
 }
 
-func writeJSONInt[T Integer](w io.Writer, v T) error {
-	const base10 = 10
+func appendJSONIntPOrNull[T Integer](b []byte, v *T) []byte {
+	if v == nil {
+		return append(b, "null"...)
+	}
 
-	var buf [20]byte
-	b := strconv.AppendInt(buf[:0], int64(v), base10)
+	return appendJSONInt(b, *v)
+}
 
-	_, err := w.Write(b)
-	if err != nil {
-		return wrapWriterError(err)
-	}
+func appendJSONInt[T Integer](b []byte, v T) []byte {
+	const base10 = 10
 
-	return nil
+	return strconv.AppendInt(b, int64(v), base10)
 }
 
 // requires non-empty int slice.
-func writeJSONIntSlice[T Integer](w io.Writer, v []T) error {
-	err := writeByte(w, '[')
-	if err != nil {
-		return err
-	}
+func appendJSONIntSlice[T Integer](b []byte, v []T) []byte {
+	b = append(b, '[')
+	b = appendJSONInt(b, v[0])
 
-	err = writeJSONInt(w, v[0])
-	if err != nil {
-		return err
+	for _, vv := range v[1:] {
+		b = append(b, ',')
+		b = appendJSONInt(b, vv)
 	}
 
-	delim := []byte{','}
-	for _, vv := range v[1:] {
-		_, err = w.Write(delim)
-		if err != nil {
-			return wrapWriterError(err)
-		}
+	return append(b, ']')
+}
 
-		err = writeJSONInt(w, vv)
-		if err != nil {
-			return err
-		}
+func appendJSONIntSliceOrNull[T Integer](b []byte, v []T) []byte {
+	if len(v) == 0 {
+		return append(b, "null"...)
 	}
 
-	err = writeByte(w, ']')
-	if err != nil {
-		return err
+	return appendJSONIntSlice(b, v)
+}
+
+func appendJSONProperty(b []byte, p JSONProperty) []byte {
+	b = appendJSONString(b, p.Name)
+	b = append(b, ':')
+
+	if len(p.Value) == 0 {
+		return append(b, "null"...)
 	}
 
-	return nil
+	return append(b, p.Value...)
 }
 
 func wrapWriterError(err error) error {
 	return fmt.Errorf("failed writing JSON to writer: %w", err)
 }
-
-func writeByte(w io.Writer, b byte) error {
-	_, err := w.Write([]byte{b})
-	if err != nil {
-		return wrapWriterError(err)
-	}
-
-	return nil
-}
@@ -0,0 +1,165 @@
+package hn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func loadTestItems(tb testing.TB) ([]*Item, [][]byte) {
+	tb.Helper()
+
+	var items []*Item
+
+	var raws [][]byte
+
+	for i := testdata.MaxItem; i > testdata.MinItem; i-- {
+		reader, err := testdata.Getter.Get(tb.Context(), "item/"+strconv.Itoa(i))
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		var item Item
+
+		err = json.Unmarshal(raw, &item)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		items = append(items, &item)
+		raws = append(raws, raw)
+	}
+
+	return items, raws
+}
+
+func TestItemWriteJSON(t *testing.T) {
+	t.Parallel()
+
+	items, raws := loadTestItems(t)
+
+	for i, item := range items {
+		actual, err := item.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Equal(actual, raws[i]) {
+			continue
+		}
+
+		// the API escapes some control characters in uppercase hex, so fall back to comparing values
+		var roundTrip Item
+
+		err = json.Unmarshal(actual, &roundTrip)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(item, &roundTrip); diff != "" {
+			t.Fatalf("item %d: %s", item.ID, diff)
+		}
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []string{
+		"", "plain", `quote " backslash \ slash /`, "<b>&amp;</b>", "tab\tnewline\ncr\rbell\a\b\f\x00\x1f\x7f",
+		"caf\u00e9 \U0001F600", "\u2028\u2029", "bad \xff utf8 \xe2\x82",
+	} {
+		var expected bytes.Buffer
+
+		enc := json.NewEncoder(&expected)
+		enc.SetEscapeHTML(false)
+
+		err := enc.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := string(appendJSONString(nil, v))
+		if actual != strings.TrimSuffix(expected.String(), "\n") {
+			t.Errorf("expected %s, got %s", expected.String(), actual)
+		}
+	}
+}
+
+func TestItemWriteJSONFields(t *testing.T) {
+	t.Parallel()
+
+	parent := 1
+	item := &Item{&parent, nil, "a", "", "T", "", Comment, nil, nil, 2, 0, 3, 0, false, false}
+
+	var buf bytes.Buffer
+
+	err := item.WriteJSONFields(&buf, []string{"id", "title", "text", "parent", "poll", "kids", "score"},
+		JSONProperty{"changed", json.RawMessage(`["title"]`)}, JSONProperty{"old", nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id":3,"title":"T","text":"","parent":1,"poll":null,"kids":null,"score":0,"changed":["title"],` +
+		`"old":null}`
+	if buf.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, buf.String())
+	}
+
+	buf.Reset()
+
+	err = item.WriteJSONFields(&buf, ItemLinkFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `{"permalink":"https://news.ycombinator.com/item?id=3",` +
+		`"context":"https://news.ycombinator.com/item?id=1#3"}`
+	if buf.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, buf.String())
+	}
+
+	err = item.WriteJSONFields(&buf, []string{"nope"})
+	if !errors.Is(err, errUnknownItemField) {
+		t.Fatalf("expected unknown field, got %v", err)
+	}
+}
+
+func BenchmarkItemJSON(b *testing.B) {
+	items, _ := loadTestItems(b)
+	fields := []string{"id", "by", "time", "title", "text", "score"}
+
+	for _, bench := range []struct {
+		write func(w io.Writer, item *Item) error
+		name  string
+	}{
+		{func(w io.Writer, item *Item) error { return item.WriteJSON(w) }, "WriteJSON"},
+		{func(w io.Writer, item *Item) error { return item.WriteJSONFields(w, fields) }, "WriteJSONFields"},
+		{func(w io.Writer, item *Item) error { return json.NewEncoder(w).Encode(item) }, "encoding/json"},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				for _, item := range items {
+					err := bench.write(io.Discard, item)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
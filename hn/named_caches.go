@@ -0,0 +1,114 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultFileCacheName is the file name of the unnamed file cache.
+const DefaultFileCacheName = "hn.db"
+
+var (
+	errInvalidCacheName = errors.New("invalid cache name")
+	cacheNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`) //nolint:gochecknoglobals // constant
+)
+
+// NamedFileCachePath returns the path of the file cache with the given name in dir, hn-<name>.db, so separate
+// workflows can keep separate caches. The empty name is the default cache, hn.db. Names may only contain letters,
+// digits, '-', and '_'.
+func NamedFileCachePath(dir string, name string) (string, error) {
+	if name == "" {
+		return filepath.Join(dir, DefaultFileCacheName), nil
+	}
+
+	if !cacheNamePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: %q may only contain letters, digits, '-', and '_'", errInvalidCacheName, name)
+	}
+
+	return filepath.Join(dir, "hn-"+name+".db"), nil
+}
+
+// NamedFileCache is a file cache found by NamedFileCaches.
+type NamedFileCache struct {
+	// Name is the name of the cache, empty for the default cache.
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Size is the size in bytes of the database and its write-ahead log.
+	Size int64 `json:"size"`
+}
+
+// NamedFileCaches returns the default and named file caches in dir, the default first and the rest by name.
+func NamedFileCaches(dir string) ([]NamedFileCache, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var caches []NamedFileCache
+
+	for _, entry := range entries {
+		name, ok := fileCacheName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		size, err := FileCacheSize(path)
+		if err != nil {
+			return nil, err
+		}
+
+		caches = append(caches, NamedFileCache{name, path, size})
+	}
+
+	slices.SortFunc(caches, func(a, b NamedFileCache) int { return strings.Compare(a.Name, b.Name) })
+
+	return caches, nil
+}
+
+// fileCacheName returns the cache name for a file name produced by NamedFileCachePath.
+func fileCacheName(fileName string) (string, bool) {
+	if fileName == DefaultFileCacheName {
+		return "", true
+	}
+
+	name, ok := strings.CutPrefix(fileName, "hn-")
+	if !ok {
+		return "", false
+	}
+
+	name, ok = strings.CutSuffix(name, ".db")
+
+	return name, ok && cacheNamePattern.MatchString(name)
+}
+
+// FileCacheSize returns the size in bytes of the file cache database at path and its write-ahead log.
+func FileCacheSize(path string) (int64, error) {
+	var total int64
+
+	for _, p := range []string{path, path + "-wal"} {
+		stat, err := os.Stat(p)
+		if errors.Is(err, fs.ErrNotExist) && p != path {
+			continue
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat cache: %w", err)
+		}
+
+		total += stat.Size()
+	}
+
+	return total, nil
+}
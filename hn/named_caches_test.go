@@ -0,0 +1,53 @@
+package hn
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNamedFileCaches(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for _, name := range []string{"hn.db", "hn-b.db", "hn-b.db-wal", "hn-a.db", "hn-.db", "other.db", "hn-c.txt"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	caches, err := NamedFileCaches(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []NamedFileCache{
+		{"", filepath.Join(dir, "hn.db"), 4},
+		{"a", filepath.Join(dir, "hn-a.db"), 4},
+		{"b", filepath.Join(dir, "hn-b.db"), 8},
+	}
+
+	if len(caches) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, caches)
+	}
+
+	for i := range expected {
+		if caches[i] != expected[i] {
+			t.Fatalf("expected %+v, got %+v", expected, caches)
+		}
+
+		path, err := NamedFileCachePath(dir, expected[i].Name)
+		if err != nil || path != expected[i].Path {
+			t.Fatalf("expected %s for %q, got %s: %v", expected[i].Path, expected[i].Name, path, err)
+		}
+	}
+
+	for _, name := range []string{"../a", "a/b", "a.db", " "} {
+		_, err = NamedFileCachePath(dir, name)
+		if err == nil {
+			t.Fatalf("expected an error for %q", name)
+		}
+	}
+}
@@ -10,14 +10,17 @@ import (
 	"net/http"
 	"os"
 	"path"
-	"sync"
+	"runtime/debug"
 	"time"
 
 	"github.com/jasonthorsness/unlurker/hn/core"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const BaseURL = "https://hacker-news.firebaseio.com/v0/"
 
+const tracerName = "github.com/jasonthorsness/unlurker/hn"
+
 // NewClient creates a new client.
 // The default client with no options (client := hn.NewClient()) is suitable for most tasks.
 // Options include WithMaxConnections, WithCacheFor, WithFileCachePath, WithLogger
@@ -53,6 +56,128 @@ func WithFileCachePath(value string) Option {
 	}}
 }
 
+// WithFileCacheHistory keeps previous versions of changed items in the file cache (see ItemFileCache.EnableHistory).
+func WithFileCacheHistory(value bool) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCacheHistory = value
+	}}
+}
+
+// WithFileCachePutBatchSize sets the most items written to the file cache in one statement.
+func WithFileCachePutBatchSize(value int) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.BatchSize = value
+	}}
+}
+
+// WithFileCachePutChannelDepth sets the number of batches queued for the file cache before puts are dropped, or
+// block with WithFileCacheBlockingPuts. Dropped puts are counted in Client.Stats.
+func WithFileCachePutChannelDepth(value int) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.ChannelDepth = value
+	}}
+}
+
+// WithFileCacheFlushInterval waits up to value for a batch of file cache puts to fill before writing it.
+func WithFileCacheFlushInterval(value time.Duration) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.FlushInterval = value
+	}}
+}
+
+// WithFileCacheBlockingPuts makes retrievals wait for room in the file cache put queue instead of dropping puts.
+func WithFileCacheBlockingPuts(value bool) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.Block = value
+	}}
+}
+
+// WithFileCacheCheckpointInterval truncates the file cache write-ahead log after puts at most once per value.
+// With 0, the log is only truncated for WithFileCacheCheckpointWALSize and on Close.
+func WithFileCacheCheckpointInterval(value time.Duration) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.CheckpointInterval = value
+	}}
+}
+
+// WithFileCacheCheckpointWALSize truncates the file cache write-ahead log after a put once it reaches value bytes.
+// With 0, the size is not checked.
+func WithFileCacheCheckpointWALSize(value int64) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCachePutOptions.CheckpointWALSize = value
+	}}
+}
+
+// WithFileCacheCorruptHandler is called when the file cache is found to be corrupt while creating the client. The
+// corrupt file is moved aside to movedTo and a new cache is created in its place, so the client still works.
+func WithFileCacheCorruptHandler(value func(movedTo string, err error)) Option {
+	return Option{func(co *clientOptions) {
+		co.fileCacheCorruptHandler = value
+	}}
+}
+
+// WithWorkerPanicHandler recovers the panics of the work run on the worker pool of the client, passing each to value
+// as an error wrapping core.ErrWorkerPanic rather than terminating the program, so one malformed item can't take down
+// a long-running process. A panic while decoding an item also fails the item with a "decode" StreamError wrapping
+// the same error, so the searches waiting on it finish. By default a panic terminates the program.
+func WithWorkerPanicHandler(value func(error)) Option {
+	return Option{func(co *clientOptions) {
+		co.workerPanicHandler = value
+	}}
+}
+
+// WithRequestTimeout fails each API request, including reading its body, with an error wrapping
+// core.ErrRequestTimeout after value, so a stuck response fails as the ItemStreamValue.Err of its item rather than
+// holding a worker until the context of the whole scan is done. Set a different timeout for the requests of one call
+// with core.WithRequestTimeout on its context; a request shared by concurrent calls keeps the timeout of the first.
+// With 0, the default, requests have no timeout of their own.
+func WithRequestTimeout(value time.Duration) Option {
+	return Option{func(co *clientOptions) {
+		co.requestTimeout = value
+	}}
+}
+
+// WithNormalizedNow corrects Client.Now for local clock skew against the time of the latest item.
+func WithNormalizedNow(value bool) Option {
+	return Option{func(co *clientOptions) {
+		co.normalizeNow = value
+	}}
+}
+
+// WithInterning interns the By and Type of decoded items (see Item.Intern), which saves memory when holding many
+// items with little text, such as for analytics over the full history. See CompactItem for larger savings.
+func WithInterning(value bool) Option {
+	return Option{func(co *clientOptions) {
+		co.intern = value
+	}}
+}
+
+// WithNullRetry makes Client.GetItem retrieve an item again while the API returns a null body for it, as it does for
+// a little while for very new items, waiting delay before the first retry and twice as long before each next one until
+// timeout has passed. A delay of 0, the default, disables the retries.
+func WithNullRetry(delay time.Duration, timeout time.Duration) Option {
+	return Option{func(co *clientOptions) {
+		co.nullRetry = nullRetry{delay, timeout}
+	}}
+}
+
+// WithValidation checks the items as they are decoded, repairing or rejecting those the API returns malformed (see
+// ValidationLevel). It applies to the items of GetItems and ItemStreams but not of raw or pooled streams.
+func WithValidation(level ValidationLevel) Option {
+	return Option{func(co *clientOptions) {
+		co.validation = level
+	}}
+}
+
+// WithDedupe makes the item getters pass each distinct ID of a batch down the pipeline once and fan its item out to
+// every time the ID was passed, rather than have single flight share one request between the duplicates. Either way
+// an ID passed n times to a search reaches its accumulator n times, in its places for SearchOrdered.
+func WithDedupe(value bool) Option {
+	return Option{func(co *clientOptions) {
+		co.dedupe = value
+	}}
+}
+
 func WithGetter(getter core.Getter[string, io.ReadCloser]) Option {
 	return Option{func(co *clientOptions) {
 		co.getter = getter
@@ -65,6 +190,22 @@ func WithClock(clock core.Clock) Option {
 	}}
 }
 
+// WithRateLimiter makes every API request wait for value, such as to shape bandwidth with a core.RateSchedule.
+// Requests served from the caches do not wait.
+func WithRateLimiter(value *core.RateLimiter) Option {
+	return Option{func(co *clientOptions) {
+		co.rateLimiter = value
+	}}
+}
+
+// WithTracerProvider records OpenTelemetry spans for API requests ("hn.get"), worker pool enqueues
+// ("hn.worker_pool"), and file cache lookups ("hn.file_cache") as children of the spans in the request contexts.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return Option{func(co *clientOptions) {
+		co.tracerProvider = tp
+	}}
+}
+
 func NewCustomClient(
 	resourceGetter ResourceGetter,
 	bulkItemGetter BulkStreamGetter[*Item],
@@ -72,27 +213,55 @@ func NewCustomClient(
 	itemStreamMaxInFlight int,
 	closers []io.Closer,
 ) *Client {
+	const maxIdleItemStreams = 16
+
 	return &Client{
 		resourceGetter,
 		bulkItemGetter,
 		bulkRawItemGetter,
+		core.NewClock(),
+		nil,
+		nil,
+		newItemStreamPool(bulkItemGetter, itemStreamMaxInFlight, maxIdleItemStreams),
+		nil,
+		nil,
 		closers,
 		itemStreamMaxInFlight,
+		nullRetry{0, 0},
+		false,
 	}
 }
 
 type clientOptions struct {
-	fileCacheErrorHandler func(error)
-	getter                core.Getter[string, io.ReadCloser]
-	clock                 core.Clock
-	fileCachePath         string
-	maxConnections        int
-	cacheFor              time.Duration
+	fileCacheErrorHandler   func(error)
+	fileCacheCorruptHandler func(string, error)
+	workerPanicHandler      func(error)
+	getter                  core.Getter[string, io.ReadCloser]
+	clock                   core.Clock
+	tracerProvider          trace.TracerProvider
+	rateLimiter             *core.RateLimiter
+	source                  Source
+	fileCachePath           string
+	fileCachePutOptions     core.BulkItemFileCachePutOptions
+	maxConnections          int
+	cacheFor                time.Duration
+	requestTimeout          time.Duration
+	nullRetry               nullRetry
+	validation              ValidationLevel
+	fileCacheHistory        bool
+	normalizeNow            bool
+	intern                  bool
+	dedupe                  bool
 }
 
 const (
-	DefaultMaxConnections = 100
-	DefaultCacheFor       = 1 * time.Minute
+	DefaultMaxConnections              = 100
+	DefaultCacheFor                    = 1 * time.Minute
+	DefaultFileCachePutBatchSize       = 100
+	DefaultFileCachePutChannelDepth    = 10
+	DefaultFileCachePutFlushInterval   = 0
+	DefaultFileCacheCheckpointInterval = 5 * time.Minute
+	DefaultFileCacheCheckpointWALSize  = 64 * 1024 * 1024
 )
 
 var ErrFileCachePutChannelFull = errors.New("file cache put channel full")
@@ -104,12 +273,31 @@ func getDefaultClientOptions() clientOptions {
 	}
 
 	return clientOptions{
-		maxConnections:        DefaultMaxConnections,
-		cacheFor:              DefaultCacheFor,
-		fileCachePath:         path.Join(cacheDir, "hn.db"),
-		fileCacheErrorHandler: nil,
-		getter:                nil,
-		clock:                 nil,
+		maxConnections: DefaultMaxConnections,
+		cacheFor:       DefaultCacheFor,
+		requestTimeout: 0,
+		fileCachePath:  path.Join(cacheDir, DefaultFileCacheName),
+		fileCachePutOptions: core.BulkItemFileCachePutOptions{
+			BatchSize:          DefaultFileCachePutBatchSize,
+			ChannelDepth:       DefaultFileCachePutChannelDepth,
+			FlushInterval:      DefaultFileCachePutFlushInterval,
+			Block:              false,
+			CheckpointInterval: DefaultFileCacheCheckpointInterval,
+			CheckpointWALSize:  DefaultFileCacheCheckpointWALSize,
+		},
+		fileCacheErrorHandler:   nil,
+		fileCacheCorruptHandler: nil,
+		workerPanicHandler:      nil,
+		getter:                  nil,
+		clock:                   nil,
+		tracerProvider:          nil,
+		rateLimiter:             nil,
+		source:                  HackerNews,
+		nullRetry:               nullRetry{0, 0},
+		validation:              ValidationOff,
+		fileCacheHistory:        false,
+		normalizeNow:            false,
+		dedupe:                  false,
 	}
 }
 
@@ -126,6 +314,10 @@ func (co clientOptions) buildClient(ctx context.Context) (*Client, error) {
 		dco.fileCacheErrorHandler = func(error) {}
 	}
 
+	if dco.fileCacheCorruptHandler == nil {
+		dco.fileCacheCorruptHandler = func(string, error) {}
+	}
+
 	if dco.getter == nil {
 		transport := &http.Transport{
 			MaxIdleConns:        co.maxConnections,
@@ -138,9 +330,11 @@ func (co clientOptions) buildClient(ctx context.Context) (*Client, error) {
 			Transport: transport,
 		}
 
-		dco.getter = core.NewBaseGetter(httpClient, BaseURL)
+		dco.getter = core.NewBaseGetter(httpClient, co.source.BaseURL)
 	}
 
+	dco.getter = newSourceGetter(dco.getter, co.source)
+
 	return dco.buildClientInternal(ctx)
 }
 
@@ -167,30 +361,78 @@ func (co clientOptions) buildClientInternal(ctx context.Context) (_ *Client, err
 	numWorkers := co.maxConnections
 	workerPoolChannelCapacity := numWorkers * workerPoolWorkChannelCapacityPerWorker
 	itemStreamMaxInFlight := numWorkers * itemStreamMaxInFlightPerWorker
-	fileCachePutBatchSize := 100
 
-	rg := core.NewResourceGetter(co.getter, core.NewMapCache[string, any](co.clock, 1*time.Minute))
+	counters := &clientCounters{}
+
+	getter := core.NewCountingGetter(core.NewTimeoutGetter(co.getter, co.requestTimeout), &counters.requests)
+	if co.rateLimiter != nil {
+		getter = core.NewRateLimitedGetter(getter, co.rateLimiter)
+	}
+
+	traceBulk := func(inner core.BulkGetter[int, io.ReadCloser], _ string) core.BulkGetter[int, io.ReadCloser] {
+		return inner
+	}
+
+	if co.tracerProvider != nil {
+		tracer := co.tracerProvider.Tracer(tracerName)
+		getter = core.NewTracingGetter(getter, tracer)
+		traceBulk = func(inner core.BulkGetter[int, io.ReadCloser], name string) core.BulkGetter[int, io.ReadCloser] {
+			return core.NewBulkTracingGetter(inner, tracer, name)
+		}
+	}
 
-	wp := core.NewWorkerPool(numWorkers, workerPoolChannelCapacity)
+	rg := core.NewResourceGetter(getter, core.NewMapCache[string, any](co.clock, 1*time.Minute))
+
+	wp := core.NewWorkerPool(numWorkers, workerPoolChannelCapacity, co.workerPanicHandler)
 	closers = append(closers, wp)
 
-	inner := core.NewBulkItemGetter(wp, co.getter)
+	inner := core.NewBulkCountingGetter(
+		traceBulk(core.NewBulkItemGetter(wp, getter), "hn.worker_pool"),
+		&counters.network)
+
+	var fcg *core.BulkItemFileCacheGetter
 
 	if co.fileCachePath != "" {
-		cache, err := core.NewItemFileCache(ctx, co.clock, co.fileCachePath, "")
+		cache, err := co.openFileCache(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create item file cache: %w", err)
+			return nil, err
+		}
+
+		if co.fileCacheHistory {
+			err = cache.EnableHistory(ctx)
+			if err != nil {
+				return nil, errors.Join(fmt.Errorf("failed to enable item file cache history: %w", err), cache.Close())
+			}
 		}
 
 		errorHandler := co.fileCacheErrorHandler
 		putChannelFull := func() { errorHandler(ErrFileCachePutChannelFull) }
 		putError := func(err error) { errorHandler(err) }
-		fcg := core.NewBulkItemFileCacheGetter(ctx, inner, cache, fileCachePutBatchSize, putChannelFull, putError)
-		inner = fcg
+		fcg = core.NewBulkItemFileCacheGetter(ctx, inner, cache, co.fileCachePutOptions, putChannelFull, putError)
+		inner = core.NewBulkCountingGetter(traceBulk(fcg, "hn.file_cache"), &counters.fileCacheLookups)
 		closers = append([]io.Closer{fcg, cache}, closers...)
 	}
 
-	outer := core.NewBulkTransformGetter(inner, unmarshalItemStreamValue)
+	// single-flight at the byte layer so the typed and raw pipelines share in-flight requests
+	shared := core.NewBulkSingleFlightGetter(core.NewBulkTransformGetter(inner, readItemStreamValue), nil, nil)
+
+	unmarshal := unmarshalItemStreamValue
+	if co.intern || co.validation != ValidationOff {
+		unmarshal = func(id int, value ItemStreamValue[[]byte]) ItemStreamValue[*Item] {
+			result := validateItemStreamValue(co.validation, value.Item, unmarshalItemStreamValue(id, value))
+			if co.intern && result.Item != nil {
+				result.Item.Intern()
+			}
+
+			return result
+		}
+	}
+
+	if co.workerPanicHandler != nil {
+		unmarshal = recoverUnmarshal(unmarshal, co.workerPanicHandler)
+	}
+
+	outer := core.NewBulkTransformGetter(shared, unmarshal)
 
 	var mapCache *core.MapCache[int, ItemStreamValue[*Item]]
 	var shouldCache func(int, ItemStreamValue[*Item]) bool
@@ -204,31 +446,98 @@ func (co clientOptions) buildClientInternal(ctx context.Context) (_ *Client, err
 
 	outer = core.NewBulkSingleFlightGetter(outer, mapCache, shouldCache)
 
-	pool := &sync.Pool{New: func() any { return &bytes.Buffer{} }}
-	raw := core.NewBulkTransformGetter(inner, func(id int, reader io.ReadCloser) ItemStreamValue[io.ReadCloser] {
-		defer func() { _ = reader.Close() }()
-
-		buffer := pool.Get().(*bytes.Buffer) //nolint:forcetypeassert // typed pool
-
-		buffer.Reset()
+	var rawShared core.BulkGetter[int, ItemStreamValue[[]byte]] = shared
+	if co.dedupe {
+		outer = core.NewBulkDedupeGetter(outer)
+		// below the transform, so each duplicate gets its own reader
+		rawShared = core.NewBulkDedupeGetter(rawShared)
+	}
 
-		_, err := buffer.ReadFrom(reader)
-		if err != nil {
-			return wrapError[io.ReadCloser](err)
+	raw := core.NewBulkTransformGetter(rawShared, func(
+		id int,
+		value ItemStreamValue[[]byte],
+	) ItemStreamValue[io.ReadCloser] {
+		if value.Err != nil {
+			return ItemStreamValue[io.ReadCloser]{ID: id, Item: nil, Err: value.Err}
 		}
 
-		return ItemStreamValue[io.ReadCloser]{ID: id, Item: core.NewReadCloserWithPooledBuffer(pool, buffer), Err: nil}
+		return ItemStreamValue[io.ReadCloser]{ID: id, Item: io.NopCloser(bytes.NewReader(value.Item)), Err: nil}
 	})
 
+	outer = core.NewBulkCountingGetter(outer, &counters.items)
+	raw = core.NewBulkCountingGetter(raw, &counters.items)
+
 	c := NewCustomClient(rg, outer, raw, itemStreamMaxInFlight, closers)
+	c.clock = co.clock
+	c.normalizeNow = co.normalizeNow
+	c.nullRetry = co.nullRetry
+	c.fileCache = fcg
+	c.counters = counters
+	c.getter = getter
+	c.bulkUserGetter = core.NewBulkUserGetter(wp, getter)
 
 	return c, nil
 }
 
-func unmarshalItemStreamValue(id int, reader io.ReadCloser) ItemStreamValue[*Item] {
-	item, err := unmarshalItem(id, reader)
+// openFileCache opens the file cache, replacing it with a new one if it is corrupt.
+func (co clientOptions) openFileCache(ctx context.Context) (*core.ItemFileCache, error) {
+	cache, err := core.NewItemFileCache(ctx, co.clock, co.fileCachePath, "")
+	if core.IsItemFileCacheCorrupt(err) {
+		movedTo, moveErr := core.MoveItemFileCacheAside(co.fileCachePath, co.clock.Now())
+		if moveErr != nil {
+			return nil, errors.Join(fmt.Errorf("failed to create item file cache: %w", err), moveErr)
+		}
+
+		co.fileCacheCorruptHandler(movedTo, err)
+
+		cache, err = core.NewItemFileCache(ctx, co.clock, co.fileCachePath, "")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create item file cache: %w", err)
+	}
+
+	return cache, nil
+}
+
+func readItemStreamValue(id int, reader io.ReadCloser) ItemStreamValue[[]byte] {
+	defer func() { _ = reader.Close() }()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return wrapError[[]byte](id, "read", err)
+	}
+
+	return ItemStreamValue[[]byte]{ID: id, Item: body, Err: nil}
+}
+
+// recoverUnmarshal fails the item when unmarshal panics and reports the panic to panicHandler.
+func recoverUnmarshal(
+	unmarshal func(int, ItemStreamValue[[]byte]) ItemStreamValue[*Item],
+	panicHandler func(error),
+) func(int, ItemStreamValue[[]byte]) ItemStreamValue[*Item] {
+	return func(id int, value ItemStreamValue[[]byte]) (result ItemStreamValue[*Item]) {
+		defer func() {
+			v := recover()
+			if v != nil {
+				err := fmt.Errorf("%w: %v\n%s", core.ErrWorkerPanic, v, debug.Stack())
+				panicHandler(err)
+				result = wrapError[*Item](id, "decode", err)
+			}
+		}()
+
+		return unmarshal(id, value)
+	}
+}
+
+func unmarshalItemStreamValue(id int, value ItemStreamValue[[]byte]) ItemStreamValue[*Item] {
+	if value.Err != nil {
+		return ItemStreamValue[*Item]{ID: id, Item: nil, Err: value.Err}
+	}
+
+	item, err := unmarshalItem(id, io.NopCloser(bytes.NewReader(value.Item)))
 	if err != nil {
-		return ItemStreamValue[*Item]{ID: id, Item: nil, Err: err}
+		return wrapError[*Item](id, "decode", err)
 	}
 
 	return ItemStreamValue[*Item]{ID: id, Item: item, Err: nil}
@@ -277,7 +586,7 @@ const (
 )
 
 type ResourceType interface {
-	int | []int | *User
+	int | []int | *User | *Updates
 }
 
 func getResource[T ResourceType](ctx context.Context, resourceGetter ResourceGetter, path string) (T, error) {
@@ -0,0 +1,142 @@
+package hn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// PooledItemStream is an item stream for scans that look at each item once, such as to filter them by type. It
+// decodes every item into a recycled Item from a pool rather than a new one, which spares the garbage collector
+// millions of short-lived allocations: BenchmarkPooledItemStream allocates about half the bytes of a typed ItemStream
+// over the same scan.
+//
+// The item passed to an accumulator belongs to the stream and is reused once the accumulator returns, so neither it
+// nor its Kids and Parts may be retained: keep a Clone instead. Its strings and its Parent and Poll are not reused and
+// may be kept. Kids and Parts are nil rather than empty when the item has none.
+//
+// Items from a PooledItemStream bypass the map cache of the client, which would otherwise hold recycled items. Like
+// an ItemStream from NewItemStream, it serves a single search and is not safe for concurrent use.
+type PooledItemStream struct {
+	raw *ItemStream[io.ReadCloser]
+}
+
+// NewPooledItemStream returns a PooledItemStream reading through the raw item pipeline of the client.
+func (c AdvancedClient) NewPooledItemStream(ctx context.Context) *PooledItemStream {
+	return &PooledItemStream{c.NewRawItemStream(ctx)}
+}
+
+func (s *PooledItemStream) MaxInFlight() int {
+	return s.raw.MaxInFlight()
+}
+
+// SetReorderPolicy sets the ReorderPolicy of the later ordered searches of the stream.
+func (s *PooledItemStream) SetReorderPolicy(p ReorderPolicy) {
+	s.raw.SetReorderPolicy(p)
+}
+
+// SearchOrdered is ItemStream.SearchOrdered with items decoded into the pool.
+func (s *PooledItemStream) SearchOrdered(ids []int, acc func(key int, value *Item) (bool, []int, error)) error {
+	return s.raw.SearchOrdered(ids, pooledAccumulator(acc))
+}
+
+// SearchUnordered is ItemStream.SearchUnordered with items decoded into the pool.
+func (s *PooledItemStream) SearchUnordered(ids []int, acc func(key int, value *Item) (bool, []int, error)) error {
+	return s.raw.SearchUnordered(ids, pooledAccumulator(acc))
+}
+
+type pooledItem struct {
+	body  bytes.Buffer
+	kids  []int
+	parts []int
+	item  Item
+}
+
+//nolint:gochecknoglobals // pool
+var itemPool = sync.Pool{New: func() any { return new(pooledItem) }}
+
+func pooledAccumulator(
+	acc func(key int, value *Item) (bool, []int, error),
+) func(key int, value io.ReadCloser) (bool, []int, error) {
+	return func(key int, value io.ReadCloser) (bool, []int, error) {
+		p, _ := itemPool.Get().(*pooledItem)
+		defer itemPool.Put(p)
+
+		err := p.decode(key, value)
+		if err != nil {
+			return false, nil, &StreamError{err, "decode", key}
+		}
+
+		return acc(key, &p.item)
+	}
+}
+
+// decode reads the item from reader into p.item, reusing the body buffer and the arrays of Kids and Parts. Like
+// unmarshalItem, a null body becomes an item of NullBody.
+func (p *pooledItem) decode(id int, reader io.ReadCloser) (err error) {
+	defer func() { _ = reader.Close() }()
+
+	p.body.Reset()
+
+	_, err = p.body.ReadFrom(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read item: %w", err)
+	}
+
+	// the decoder keeps the values of fields missing from the body, so start from an empty item
+	p.item = Item{
+		nil, nil, "", "", "", "", NullBody, p.kids[:0], p.parts[:0], 0, 0, 0, 0, false, false,
+	}
+
+	body := p.body.Bytes()
+
+	if !bytes.Equal(bytes.TrimSpace(body), []byte("null")) {
+		err = json.Unmarshal(body, &p.item)
+		if err != nil {
+			return fmt.Errorf("failed to deserialize item: %w", err)
+		}
+	} else {
+		p.item.ID = id
+	}
+
+	// keep the grown arrays for the next item
+	p.kids, p.parts = p.item.Kids, p.item.Parts
+
+	if len(p.item.Kids) == 0 {
+		p.item.Kids = nil
+	}
+
+	if len(p.item.Parts) == 0 {
+		p.item.Parts = nil
+	}
+
+	if p.item.ID != id {
+		return fmt.Errorf("resource id does not match body id: %d: %w", id, errContract)
+	}
+
+	return nil
+}
+
+// Clone returns a copy of item that shares nothing mutable with it, such as to retain an item from a
+// PooledItemStream.
+func (item *Item) Clone() *Item {
+	clone := *item
+	clone.Kids = slices.Clone(item.Kids)
+	clone.Parts = slices.Clone(item.Parts)
+
+	if item.Parent != nil {
+		parent := *item.Parent
+		clone.Parent = &parent
+	}
+
+	if item.Poll != nil {
+		poll := *item.Poll
+		clone.Poll = &poll
+	}
+
+	return &clone
+}
@@ -0,0 +1,91 @@
+package hn
+
+import (
+	"sync"
+	"time"
+)
+
+// ScanStats describes the scans of GetActive and GetActiveCapped by a Client, summed over every scan.
+type ScanStats struct {
+	// Items counts the items requested while scanning and where they came from. It is measured from the counts of the
+	// whole Client, so it includes any other retrievals made while a scan was running.
+	Items ItemStats
+	// Elapsed is the time spent scanning.
+	Elapsed time.Duration
+	// Scans counts the scans.
+	Scans int64
+	// Examined counts the items retrieved and checked by the scans, including probes and ancestors.
+	Examined int64
+	// Requests counts the requests made to the API while scanning.
+	Requests int64
+	// OldestID is the smallest ID retrieved by any scan, or 0 if there were none.
+	OldestID int
+}
+
+// CacheHitRate returns the share of the items requested while scanning that were answered by the in-memory or file
+// cache, or 0 if none were requested.
+func (s ScanStats) CacheHitRate() float64 {
+	if s.Items.Requested == 0 {
+		return 0
+	}
+
+	return float64(s.Items.Memory+s.Items.FileCache) / float64(s.Items.Requested)
+}
+
+// scanCounters sums the ScanStats of a Client.
+type scanCounters struct {
+	stats ScanStats
+	mu    sync.Mutex
+}
+
+// activeScan measures a single scan. Its methods are not safe for concurrent use, but the callbacks of a search are
+// never concurrent.
+type activeScan struct {
+	start    time.Time
+	client   *Client
+	items    ItemStats
+	examined int64
+	requests int64
+	oldestID int
+}
+
+// startScan starts measuring a scan, which is added to the stats of c by end.
+func (c *Client) startScan() *activeScan {
+	items, requests := c.itemStats()
+
+	return &activeScan{time.Now(), c, items, 0, requests, 0}
+}
+
+// examine counts the retrieved item with id.
+func (s *activeScan) examine(id int) {
+	s.examined++
+
+	if s.oldestID == 0 || id < s.oldestID {
+		s.oldestID = id
+	}
+}
+
+func (s *activeScan) end() {
+	if s.client.counters == nil {
+		return
+	}
+
+	items, requests := s.client.itemStats()
+	scans := &s.client.counters.scans
+
+	scans.mu.Lock()
+	defer scans.mu.Unlock()
+
+	scans.stats.Items.Requested += items.Requested - s.items.Requested
+	scans.stats.Items.Memory += items.Memory - s.items.Memory
+	scans.stats.Items.FileCache += items.FileCache - s.items.FileCache
+	scans.stats.Items.Network += items.Network - s.items.Network
+	scans.stats.Elapsed += time.Since(s.start)
+	scans.stats.Scans++
+	scans.stats.Examined += s.examined
+	scans.stats.Requests += requests - s.requests
+
+	if s.oldestID != 0 && (scans.stats.OldestID == 0 || s.oldestID < scans.stats.OldestID) {
+		scans.stats.OldestID = s.oldestID
+	}
+}
@@ -0,0 +1,135 @@
+package hn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// Source is an API serving HN-like data. The client requests the paths of the HN API, which a Source maps to its own,
+// translating the responses to the JSON of the HN API so the caches and everything above them work unchanged.
+type Source struct {
+	// Paths maps the HN API paths to those of the source. The item and user paths are keyed as "item/{id}.json" and
+	// "user/{id}.json", and "{id}" in a Template is replaced with the item ID or username. A nil Paths requests every
+	// path unchanged. Paths missing from a non-nil map are not supported by the source.
+	Paths map[string]SourcePath
+	// Name selects the source with --source and names its default cache file.
+	Name string
+	// BaseURL is prepended to the paths of the source.
+	BaseURL string
+}
+
+// SourcePath is how a Source serves one path of the HN API.
+type SourcePath struct {
+	// Translate converts a response of the source to the JSON of the HN API. nil keeps the response unchanged.
+	Translate func(body []byte) ([]byte, error)
+	// Template is the path of the source relative to BaseURL.
+	Template string
+	// NotFound replaces a 404 response, since the HN API returns null for missing items rather than an error. nil
+	// keeps the error.
+	NotFound []byte
+}
+
+// HackerNews is the HN API itself.
+var HackerNews = Source{nil, "hn", BaseURL} //nolint:gochecknoglobals // constant
+
+// Sources are the sources selectable by name with SourceByName.
+var Sources = []Source{HackerNews, Algolia} //nolint:gochecknoglobals // constant
+
+// ErrUnsupportedBySource is returned for requests a Source has no path for, such as the best list from Algolia.
+var ErrUnsupportedBySource = errors.New("not supported by source")
+
+var errUnknownSource = errors.New("unknown source")
+
+// SourceByName returns the source in Sources with the given name.
+func SourceByName(name string) (Source, error) {
+	for _, source := range Sources {
+		if source.Name == name {
+			return source, nil
+		}
+	}
+
+	return HackerNews, fmt.Errorf("%w %q, expected one of %s", errUnknownSource, name, strings.Join(SourceNames(), ", "))
+}
+
+// SourceNames returns the names of the Sources.
+func SourceNames() []string {
+	names := make([]string, len(Sources))
+	for i, source := range Sources {
+		names[i] = source.Name
+	}
+
+	return names
+}
+
+// WithSource requests items, lists, and users from source instead of the HN API. The source also applies to a getter
+// from WithGetter, which then serves the paths of the source.
+func WithSource(source Source) Option {
+	return Option{func(co *clientOptions) {
+		co.source = source
+	}}
+}
+
+func newSourceGetter(inner core.Getter[string, io.ReadCloser], source Source) core.Getter[string, io.ReadCloser] {
+	if source.Paths == nil {
+		return inner
+	}
+
+	return &sourceGetter{inner, source}
+}
+
+type sourceGetter struct {
+	inner  core.Getter[string, io.ReadCloser]
+	source Source
+}
+
+func (g *sourceGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	key, id := path, ""
+
+	for _, prefix := range []string{itemPathPrefix, userPathPrefix} {
+		if strings.HasPrefix(path, prefix) && strings.HasSuffix(path, jsonSuffix) {
+			key, id = prefix+"{id}"+jsonSuffix, strings.TrimSuffix(strings.TrimPrefix(path, prefix), jsonSuffix)
+			break
+		}
+	}
+
+	sp, ok := g.source.Paths[key]
+	if !ok || core.IsEventStream(ctx) {
+		return nil, fmt.Errorf("%w: %s does not provide %s", ErrUnsupportedBySource, g.source.Name, path)
+	}
+
+	reader, err := g.inner.Get(ctx, strings.ReplaceAll(sp.Template, "{id}", url.PathEscape(id)))
+	if err != nil {
+		var getterErr *core.GetterError
+		if sp.NotFound != nil && errors.As(err, &getterErr) && getterErr.Code == http.StatusNotFound {
+			return io.NopCloser(bytes.NewReader(sp.NotFound)), nil
+		}
+
+		return nil, fmt.Errorf("failed to get %s from %s: %w", path, g.source.Name, err)
+	}
+
+	if sp.Translate == nil {
+		return reader, nil
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from %s: %w", path, g.source.Name, err)
+	}
+
+	body, err = sp.Translate(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate %s from %s: %w", path, g.source.Name, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
@@ -0,0 +1,179 @@
+package hn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// AlgoliaBaseURL is the base URL of the HN Search API by Algolia (https://hn.algolia.com/api).
+const AlgoliaBaseURL = "https://hn.algolia.com/api/v1/"
+
+// Algolia is the HN Search API, a mirror of HN with its own paths and JSON. Only some lists map to its searches:
+// top is the front page, and new, ask, show, and jobs are the latest of each. There is no best list, items omit dead
+// replies, and kids are in the order of the search index rather than HN's ranking. Users have no submitted list.
+//
+//nolint:gochecknoglobals // constant
+var Algolia = Source{
+	map[string]SourcePath{
+		"item/{id}.json":   {translateAlgoliaItem, "items/{id}", []byte("null")},
+		"user/{id}.json":   {translateAlgoliaUser, "users/{id}", []byte("null")},
+		"maxitem.json":     {translateAlgoliaMaxItem, "search_by_date?tags=(story,comment,poll,job)&hitsPerPage=1", nil},
+		"topstories.json":  {translateAlgoliaList, "search?tags=front_page&hitsPerPage=500", nil},
+		"newstories.json":  {translateAlgoliaList, "search_by_date?tags=story&hitsPerPage=500", nil},
+		"askstories.json":  {translateAlgoliaList, "search_by_date?tags=ask_hn&hitsPerPage=200", nil},
+		"showstories.json": {translateAlgoliaList, "search_by_date?tags=show_hn&hitsPerPage=200", nil},
+		"jobstories.json":  {translateAlgoliaList, "search_by_date?tags=job&hitsPerPage=200", nil},
+	},
+	"algolia",
+	AlgoliaBaseURL,
+}
+
+type algoliaItem struct {
+	ParentID  *int          `json:"parent_id"`
+	Author    string        `json:"author"`
+	Title     string        `json:"title"`
+	URL       string        `json:"url"`
+	Text      string        `json:"text"`
+	Type      ItemType      `json:"type"`
+	Children  []algoliaItem `json:"children"`
+	Options   []algoliaItem `json:"options"`
+	CreatedAt int64         `json:"created_at_i"`
+	Points    int           `json:"points"`
+	ID        int           `json:"id"`
+}
+
+// countDescendants counts the replies under the item, as the HN API's descendants does for stories and polls.
+func (a *algoliaItem) countDescendants() int {
+	n := len(a.Children)
+	for i := range a.Children {
+		n += a.Children[i].countDescendants()
+	}
+
+	return n
+}
+
+func translateAlgoliaItem(body []byte) ([]byte, error) {
+	var a *algoliaItem
+
+	err := json.Unmarshal(body, &a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+
+	if a == nil {
+		return body, nil
+	}
+
+	item := Item{
+		nil, nil, a.Author, a.Text, a.Title, a.URL, a.Type, nil, nil, a.CreatedAt, 0, a.ID, a.Points, false, false,
+	}
+
+	// a poll option's parent is its poll, which the HN API gives as poll rather than parent
+	if a.Type == PollOption {
+		item.Poll = a.ParentID
+	} else {
+		item.Parent = a.ParentID
+	}
+
+	for i := range a.Children {
+		item.Kids = append(item.Kids, a.Children[i].ID)
+	}
+
+	for i := range a.Options {
+		item.Parts = append(item.Parts, a.Options[i].ID)
+	}
+
+	if a.Type == Story || a.Type == Poll {
+		item.Descendants = a.countDescendants()
+	}
+
+	return item.Marshal()
+}
+
+func translateAlgoliaUser(body []byte) ([]byte, error) {
+	var a *struct {
+		About     string `json:"about"`
+		Username  string `json:"username"`
+		CreatedAt int64  `json:"created_at_i"`
+		Karma     int    `json:"karma"`
+	}
+
+	err := json.Unmarshal(body, &a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+
+	if a == nil {
+		return body, nil
+	}
+
+	user := User{a.About, a.Username, nil, a.CreatedAt, a.Karma}
+
+	return user.Marshal()
+}
+
+type algoliaSearch struct {
+	Hits []struct {
+		ObjectID string `json:"objectID"`
+	} `json:"hits"`
+}
+
+func (s *algoliaSearch) ids() ([]int, error) {
+	ids := make([]int, len(s.Hits))
+
+	for i, hit := range s.Hits {
+		id, err := strconv.Atoi(hit.ObjectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse objectID: %w", err)
+		}
+
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+func translateAlgoliaList(body []byte) ([]byte, error) {
+	var s algoliaSearch
+
+	err := json.Unmarshal(body, &s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search: %w", err)
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal list: %w", err)
+	}
+
+	return body, nil
+}
+
+var errNoAlgoliaHits = errors.New("search returned no hits")
+
+func translateAlgoliaMaxItem(body []byte) ([]byte, error) {
+	var s algoliaSearch
+
+	err := json.Unmarshal(body, &s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search: %w", err)
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, errNoAlgoliaHits
+	}
+
+	return []byte(strconv.Itoa(ids[0])), nil
+}
@@ -0,0 +1,129 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// algoliaGetter serves responses in the format of the Algolia API, failing like it does for missing paths.
+type algoliaGetter struct {
+	data map[string]string
+}
+
+func (g *algoliaGetter) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	s, ok := g.data[path]
+	if !ok {
+		return nil, &core.GetterError{Path: path, Code: 404}
+	}
+
+	return io.NopCloser(strings.NewReader(s)), nil
+}
+
+func TestAlgoliaSource(t *testing.T) {
+	t.Parallel()
+
+	getter := &algoliaGetter{map[string]string{
+		"items/1": `{"id":1,"created_at_i":100,"type":"story","author":"pg","title":"Y Combinator",` +
+			`"url":"http://ycombinator.com","text":null,"points":57,"parent_id":null,"options":[],"children":[` +
+			`{"id":2,"created_at_i":200,"type":"comment","author":"a","text":"<p>hi","points":null,"parent_id":1,` +
+			`"children":[{"id":3,"created_at_i":300,"type":"comment","author":"b","parent_id":2,"children":[]}]},` +
+			`{"id":4,"created_at_i":400,"type":"comment","author":"c","parent_id":1,"children":[]}]}`,
+		"items/2": `{"id":2,"created_at_i":200,"type":"comment","author":"a","text":"<p>hi","parent_id":1,` +
+			`"children":[{"id":3,"created_at_i":300,"type":"comment","author":"b","parent_id":2,"children":[]}]}`,
+		"users/pg": `{"username":"pg","about":"Bug fixer.","karma":155111,"created_at_i":1160418092}`,
+		"search_by_date?tags=(story,comment,poll,job)&hitsPerPage=1": `{"hits":[{"objectID":"4"}]}`,
+		"search?tags=front_page&hitsPerPage=500":                     `{"hits":[{"objectID":"1"},{"objectID":"5"}]}`,
+	}}
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithSource(Algolia),
+		WithGetter(getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	items, err := client.GetItems(t.Context(), []int{1, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := 1
+	expected := &Item{
+		nil, nil, "pg", "", "Y Combinator", "http://ycombinator.com", Story, []int{2, 4}, nil, 100, 3, 1, 57, false, false,
+	}
+
+	if diff := cmp.Diff(expected, items[1]); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	if items[9].Type != NullBody {
+		t.Fatalf("expected a missing item to be null, got %v", items[9])
+	}
+
+	user, err := client.GetUser(t.Context(), "pg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(&User{"Bug fixer.", "pg", nil, 1160418092, 155111}, user); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	maxID, err := client.GetMaxItem(t.Context())
+	if err != nil || maxID != 4 {
+		t.Fatalf("expected max item 4, got %d, %v", maxID, err)
+	}
+
+	top, err := client.GetTop(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]int{1, 5}, top); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	_, err = client.GetBest(t.Context())
+	if !errors.Is(err, ErrUnsupportedBySource) {
+		t.Fatalf("expected the best list to be unsupported, got %v", err)
+	}
+
+	replies, err := client.GetItems(t.Context(), []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := replies[2]
+	if reply.Parent == nil || *reply.Parent != parent || reply.Descendants != 0 || !slices.Equal(reply.Kids, []int{3}) {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+}
+
+func TestSourceByName(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range Sources {
+		found, err := SourceByName(source.Name)
+		if err != nil || found.BaseURL != source.BaseURL {
+			t.Fatalf("expected %s, got %v, %v", source.Name, found, err)
+		}
+	}
+
+	_, err := SourceByName("lobsters")
+	if !errors.Is(err, errUnknownSource) {
+		t.Fatalf("expected unknown source, got %v", err)
+	}
+}
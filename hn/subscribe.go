@@ -0,0 +1,144 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// ChangedSet is a batch of changes sent by Client.SubscribeUpdates for one poll of the updates. Items and Profiles
+// are those newly listed since the previous successful poll, or the whole list for the first. When a poll fails,
+// Err is set and there are no changes; the subscription backs off and keeps polling.
+type ChangedSet struct {
+	Time     time.Time
+	Err      error
+	Items    []int
+	Profiles []string
+}
+
+// MaxSubscribeBackoff is the longest SubscribeUpdates waits between polls while they fail.
+const MaxSubscribeBackoff = 5 * time.Minute
+
+var errInvalidInterval = errors.New("interval must be positive")
+
+// resourceRefresher is implemented by resource getters that can bypass their cache, as core.ResourceGetter does.
+type resourceRefresher interface {
+	Refresh(ctx context.Context, path string, result any) error
+}
+
+// SubscribeUpdates polls the updates every interval and sends what changed until ctx is canceled, when the channel
+// is closed. The polls bypass the map cache of the client, which would otherwise repeat the same list for its
+// lifetime. Polls that change nothing send nothing, and while polls fail the interval doubles up to
+// MaxSubscribeBackoff. The first poll is made before returning, so an error such as one wrapping
+// ErrUnsupportedBySource is returned directly. The updates list only the few hundred latest changes, so an item that
+// changes again while still listed is not sent again, and a consumer slower than the list rolls over misses changes.
+func (c *Client) SubscribeUpdates(ctx context.Context, interval time.Duration) (<-chan ChangedSet, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidInterval, interval)
+	}
+
+	updates, err := c.refreshUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan ChangedSet, 1)
+	ch <- ChangedSet{c.clock.Now(), nil, updates.Items, updates.Profiles}
+
+	go c.pollUpdates(ctx, interval, updates, ch)
+
+	return ch, nil
+}
+
+func (c *Client) pollUpdates(ctx context.Context, interval time.Duration, previous *Updates, ch chan<- ChangedSet) {
+	defer close(ch)
+
+	delay := interval
+
+	for {
+		if core.Sleep(ctx, c.clock, delay) != nil {
+			return
+		}
+
+		updates, err := c.refreshUpdates(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			delay = min(delay*2, max(MaxSubscribeBackoff, interval))
+
+			if !sendChangedSet(ctx, ch, ChangedSet{c.clock.Now(), err, nil, nil}) {
+				return
+			}
+
+			continue
+		}
+
+		delay = interval
+		changed := ChangedSet{
+			c.clock.Now(), nil, newlyListed(previous.Items, updates.Items),
+			newlyListed(previous.Profiles, updates.Profiles),
+		}
+		previous = updates
+
+		if len(changed.Items) == 0 && len(changed.Profiles) == 0 {
+			continue
+		}
+
+		if !sendChangedSet(ctx, ch, changed) {
+			return
+		}
+	}
+}
+
+func (c *Client) refreshUpdates(ctx context.Context) (*Updates, error) {
+	refresher, ok := c.resourceGetter.(resourceRefresher)
+	if !ok {
+		return c.GetUpdates(ctx)
+	}
+
+	var result *Updates
+
+	err := refresher.Refresh(ctx, "updates.json", &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path updates.json: %w", err)
+	}
+
+	if result == nil {
+		result = &Updates{nil, nil}
+	}
+
+	return result, nil
+}
+
+func sendChangedSet(ctx context.Context, ch chan<- ChangedSet, changed ChangedSet) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- changed:
+		return true
+	}
+}
+
+// newlyListed returns the values of current not in previous, in the order of current.
+func newlyListed[T comparable](previous []T, current []T) []T {
+	seen := make(map[T]struct{}, len(previous))
+	for _, v := range previous {
+		seen[v] = struct{}{}
+	}
+
+	var result []T
+
+	for _, v := range current {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,127 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// sequenceGetter serves the updates in turn, repeating the last, with an empty body standing for a failed poll.
+type sequenceGetter struct {
+	updates []string
+	mu      sync.Mutex
+}
+
+func (g *sequenceGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "updates.json" {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	body := g.updates[0]
+	if len(g.updates) > 1 {
+		g.updates = g.updates[1:]
+	}
+
+	if body == "" {
+		return nil, &core.GetterError{Path: path, Code: 503}
+	}
+
+	return io.NopCloser(strings.NewReader(body)), nil
+}
+
+func TestSubscribeUpdates(t *testing.T) {
+	t.Parallel()
+
+	getter := &sequenceGetter{[]string{
+		`{"items":[3,2,1],"profiles":["a"]}`,
+		`{"items":[3,2,1],"profiles":["a"]}`,
+		`{"items":[5,4,3,2],"profiles":["a"]}`,
+		``,
+		`{"items":[6,5,4],"profiles":["b","a"]}`,
+	}, sync.Mutex{}}
+
+	// the waits go through the clock, so the minutes between polls take no real time
+	clock := &sleepClock{testdata.MaxTime, nil, sync.Mutex{}}
+
+	client, err := NewClient(t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	// served from the map cache by GetUpdates, but not by the subscription
+	_, err = client.GetUpdates(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	ch, err := client.SubscribeUpdates(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type change struct {
+		Items    []int
+		Profiles []string
+		After    time.Duration
+		Failed   bool
+	}
+
+	// the failed poll doubles the wait before the next
+	expected := []change{
+		{[]int{3, 2, 1}, []string{"a"}, 0, false},
+		{[]int{5, 4}, nil, time.Minute, false},
+		{nil, nil, 2 * time.Minute, true},
+		{[]int{6}, []string{"b"}, 4 * time.Minute, false},
+	}
+
+	var got []change
+
+	for changed := range ch {
+		var getterErr *core.GetterError
+
+		failed := errors.As(changed.Err, &getterErr) && getterErr.Code == 503
+		if changed.Err != nil && !failed {
+			t.Fatalf("unexpected error %v", changed.Err)
+		}
+
+		got = append(got, change{changed.Items, changed.Profiles, changed.Time.Sub(testdata.MaxTime), failed})
+		if len(got) == len(expected) {
+			cancel()
+		}
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected changes (-want +got):\n%s", diff)
+	}
+
+	_, err = client.SubscribeUpdates(t.Context(), 0)
+	if err == nil {
+		t.Fatal("expected an error for a zero interval")
+	}
+}
+
+func TestNewlyListed(t *testing.T) {
+	t.Parallel()
+
+	got := newlyListed([]int{3, 2, 1}, []int{5, 3, 4, 5, 2})
+	if !slices.Equal(got, []int{5, 4}) {
+		t.Fatalf("expected [5 4], got %v", got)
+	}
+}
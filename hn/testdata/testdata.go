@@ -18,6 +18,7 @@ import (
 	"io"
 	"log"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +33,12 @@ var topStoriesJSON []byte
 
 var bestStoriesJSON []byte
 
+var askStoriesJSON []byte
+
+var showStoriesJSON []byte
+
+var jobStoriesJSON []byte
+
 var userJSON []byte
 
 //go:embed items.json.gz
@@ -61,6 +68,12 @@ var Top []int
 
 var Best []int
 
+var Ask []int
+
+var Show []int
+
+var Jobs []int
+
 func init() {
 	err := initItems()
 	if err != nil {
@@ -104,6 +117,7 @@ func initItems() error {
 
 		var temp struct {
 			By   string `json:"by"`
+			Type string `json:"type"`
 			ID   int    `json:"id"`
 			Time int64  `json:"time"`
 		}
@@ -113,6 +127,10 @@ func initItems() error {
 		}
 
 		byUser[temp.By] = append(byUser[temp.By], temp.ID)
+
+		if temp.Type == "job" {
+			Jobs = append(Jobs, temp.ID)
+		}
 		if len(byUser[temp.By]) > len(byUser[maxBy]) {
 			maxBy = temp.By
 		}
@@ -188,6 +206,43 @@ func initLists() error {
 		return fmt.Errorf("failed to marshal best stories: %w", err)
 	}
 
+	// set Ask and Show to the items in New that would be on those lists, with Tell HN on Ask as on the site
+	for _, id := range New {
+		var temp struct {
+			Title string `json:"title"`
+		}
+
+		err = json.Unmarshal(items[id], &temp)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(temp.Title, "Ask HN"), strings.HasPrefix(temp.Title, "Tell HN"):
+			Ask = append(Ask, id)
+		case strings.HasPrefix(temp.Title, "Show HN"):
+			Show = append(Show, id)
+		}
+	}
+
+	askStoriesJSON, err = json.Marshal(Ask)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ask stories: %w", err)
+	}
+
+	showStoriesJSON, err = json.Marshal(Show)
+	if err != nil {
+		return fmt.Errorf("failed to marshal show stories: %w", err)
+	}
+
+	// set Jobs to every job, newest first as on the site
+	slices.SortFunc(Jobs, func(a, b int) int { return b - a })
+
+	jobStoriesJSON, err = json.Marshal(Jobs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job stories: %w", err)
+	}
+
 	return nil
 }
 
@@ -205,8 +260,17 @@ func (g *getter) Get(_ context.Context, key string) (io.ReadCloser, error) {
 		return io.NopCloser(bytes.NewReader(topStoriesJSON)), nil
 	case "beststories.json":
 		return io.NopCloser(bytes.NewReader(bestStoriesJSON)), nil
+	case "askstories.json":
+		return io.NopCloser(bytes.NewReader(askStoriesJSON)), nil
+	case "showstories.json":
+		return io.NopCloser(bytes.NewReader(showStoriesJSON)), nil
+	case "jobstories.json":
+		return io.NopCloser(bytes.NewReader(jobStoriesJSON)), nil
 	case "maxitem.json":
 		return io.NopCloser(bytes.NewReader(maxItemJSON)), nil
+	case "updates.json":
+		// the corpus is a snapshot, so nothing changes
+		return io.NopCloser(strings.NewReader(`{"items":[],"profiles":[]}`)), nil
 	default:
 		if strings.HasPrefix(key, "user/") {
 			return io.NopCloser(bytes.NewReader(userJSON)), nil
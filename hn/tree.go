@@ -0,0 +1,76 @@
+package hn
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetTree returns the item with rootID along with all of its descendants, such as a whole discussion under a story or
+// the subthread under a comment. It returns an error wrapping ErrItemNotFound if the API returns a null body for the
+// root.
+func (c *Client) GetTree(ctx context.Context, rootID int) (ItemSet, error) {
+	all, _, err := c.GetTreeCapped(ctx, rootID, 0, 0)
+
+	return all, err
+}
+
+// GetTreeCapped is GetTree with the descent stopped maxDepth levels below the root and after maxItems items, either
+// uncapped if 0 or less, and reports whether either cap left out items. Every item returned is the root or a kid of
+// another item returned. If ctx is done before the tree is complete, the items retrieved so far are returned with an
+// error wrapping ErrPartialResult.
+func (c *Client) GetTreeCapped(
+	ctx context.Context,
+	rootID int,
+	maxDepth int,
+	maxItems int,
+) (ItemSet, bool, error) {
+	all := make(ItemSet)
+	depths := map[int]int{rootID: 0}
+	truncated := false
+
+	err := c.SearchUnordered(ctx, []int{rootID}, func(id int, item *Item) (bool, []int, error) {
+		all[id] = item
+
+		if len(item.Kids) == 0 {
+			return true, nil, nil
+		}
+
+		depth := depths[id] + 1
+		if maxDepth > 0 && depth > maxDepth {
+			truncated = true
+			return true, nil, nil
+		}
+
+		kids := make([]int, 0, len(item.Kids))
+
+		for _, kid := range item.Kids {
+			// a kid listed twice or under two parents is retrieved once
+			if _, ok := depths[kid]; ok {
+				continue
+			}
+
+			if maxItems > 0 && len(depths) >= maxItems {
+				truncated = true
+				break
+			}
+
+			depths[kid] = depth
+			kids = append(kids, kid)
+		}
+
+		return true, kids, nil
+	})
+	if err != nil && ctx.Err() == nil {
+		return nil, false, err
+	}
+
+	if err != nil {
+		return all, truncated, fmt.Errorf("%w: %w", ErrPartialResult, ctx.Err())
+	}
+
+	if all[rootID].Type == NullBody {
+		return nil, false, fmt.Errorf("root %d has null body: %w", rootID, ErrItemNotFound)
+	}
+
+	return all, truncated, nil
+}
@@ -0,0 +1,78 @@
+package hn
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestGetTree(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(t.Context(), WithFileCachePath(""), WithGetter(testdata.Getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	stories, err := client.GetItems(t.Context(), testdata.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the story with the largest discussion in the corpus
+	root := slices.MaxFunc(slices.Collect(maps.Values(stories)), func(a, b *Item) int {
+		return a.Descendants - b.Descendants
+	})
+
+	expected, err := client.GetDescendants(t.Context(), ItemSet{root.ID: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expected) < 10 {
+		t.Fatalf("expected a larger tree under %d, got %d items", root.ID, len(expected))
+	}
+
+	all, err := client.GetTree(t.Context(), root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(all.IDs(), expected.IDs()) {
+		t.Fatalf("expected %d items, got %d", len(expected), len(all))
+	}
+
+	capped, truncated, err := client.GetTreeCapped(t.Context(), root.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !truncated || len(capped) != len(root.Kids)+1 {
+		t.Fatalf("expected the root and its %d kids, got %d items", len(root.Kids), len(capped))
+	}
+
+	capped, truncated, err = client.GetTreeCapped(t.Context(), root.ID, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !truncated || len(capped) != 5 {
+		t.Fatalf("expected 5 items, got %d", len(capped))
+	}
+
+	for _, item := range capped {
+		if item.ID != root.ID && capped[*item.Parent] == nil {
+			t.Fatalf("item %d is not traceable to the root", item.ID)
+		}
+	}
+
+	_, err = client.GetTree(t.Context(), testdata.MaxItem+1)
+	if !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("expected ErrItemNotFound, got %v", err)
+	}
+}
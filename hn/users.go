@@ -0,0 +1,113 @@
+package hn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// GetUsers returns the profiles of usernames by username, retrieving them concurrently on the worker pool of the
+// client like items rather than one request at a time as GetUser does, so looking up thousands of users such as the
+// commenters of a thread takes about as many round trips as there are in flight. Users that do not exist map to nil,
+// as GetUser returns. The profiles bypass the cache of GetUser. If any retrieval fails, the error joins the failures
+// along with the profiles retrieved.
+func (c *Client) GetUsers(ctx context.Context, usernames []string) (map[string]*User, error) {
+	result := make(map[string]*User, len(usernames))
+
+	if c.bulkUserGetter == nil {
+		for _, username := range usernames {
+			user, err := c.GetUser(ctx, username)
+			if err != nil {
+				return result, err
+			}
+
+			result[username] = user
+		}
+
+		return result, nil
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+
+	done := func(username string, user *User, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", username, err))
+			return
+		}
+
+		result[username] = user
+	}
+
+	// each slot is held by a user in flight, so the requests stay within the capacity of the worker pool
+	slots := make(chan struct{}, max(c.itemStreamMaxInFlight, 1))
+	seen := make(map[string]struct{}, len(usernames))
+
+	for _, username := range usernames {
+		if _, ok := seen[username]; ok {
+			continue
+		}
+
+		seen[username] = struct{}{}
+
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+
+		refused := c.bulkUserGetter.Get(ctx, []string{username}, func(username string, body io.ReadCloser) {
+			defer func() { <-slots }()
+			defer wg.Done()
+
+			user, err := unmarshalUser(body)
+			done(username, user, err)
+		})
+
+		if len(refused) > 0 {
+			<-slots
+
+			wg.Done()
+			done(username, nil, errRequestChannelFull)
+		}
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("failed to get users: %w", errors.Join(errs...))
+	}
+
+	return result, nil
+}
+
+func unmarshalUser(body io.ReadCloser) (_ *User, err error) {
+	defer func() { err = errors.Join(err, body.Close()) }()
+
+	var user *User
+
+	err = json.NewDecoder(body).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize user: %w", err)
+	}
+
+	return user, nil
+}
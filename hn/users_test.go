@@ -0,0 +1,78 @@
+package hn
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// usersGetter serves a profile after latency for every user named "user<n>", and a null body for others.
+type usersGetter struct {
+	requests atomic.Int64
+	latency  time.Duration
+}
+
+func (g *usersGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, "user/") {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	username, ok := strings.CutPrefix(strings.TrimSuffix(path, ".json"), "user/user")
+
+	g.requests.Add(1)
+	time.Sleep(g.latency)
+
+	if !ok {
+		return io.NopCloser(strings.NewReader("null")), nil
+	}
+
+	return io.NopCloser(strings.NewReader(`{"id":"user` + username + `","karma":` + username + `}`)), nil
+}
+
+func TestGetUsers(t *testing.T) {
+	t.Parallel()
+
+	const latency = 20 * time.Millisecond
+
+	getter := &usersGetter{atomic.Int64{}, latency}
+
+	client, err := NewClient(t.Context(), WithFileCachePath(""), WithGetter(getter), WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	var usernames []string
+	for i := range 100 {
+		usernames = append(usernames, "user"+strconv.Itoa(i))
+	}
+
+	usernames = append(usernames, "user7", "missing")
+
+	start := time.Now()
+
+	users, err := client.GetUsers(t.Context(), usernames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one request at a time would take 101 round trips
+	if elapsed := time.Since(start); elapsed > 20*latency {
+		t.Fatalf("expected the requests to run concurrently, took %s", elapsed)
+	}
+
+	if n := getter.requests.Load(); n != 101 {
+		t.Fatalf("expected 101 requests, got %d", n)
+	}
+
+	if len(users) != 101 || users["missing"] != nil || users["user42"].Karma != 42 {
+		t.Fatalf("unexpected users: %d", len(users))
+	}
+}
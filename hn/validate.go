@@ -0,0 +1,172 @@
+package hn
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationLevel is what the client does with the occasional item of the API that is malformed, such as one with a
+// parent newer than itself or a negative time (see WithValidation and Item.Repair).
+type ValidationLevel int
+
+const (
+	// ValidationOff returns items as the API returns them.
+	ValidationOff ValidationLevel = iota
+	// ValidationRepair repairs malformed items and returns them as if they were not.
+	ValidationRepair
+	// ValidationReject repairs malformed items but fails them with a *ValidationError, which lists the problems and
+	// carries the repaired item for consumers that would rather keep it.
+	ValidationReject
+)
+
+// ErrInvalidItem is wrapped by ValidationError.
+var ErrInvalidItem = errors.New("invalid item")
+
+// ValidationProblem is a problem found in an item: the JSON name of the field and what was wrong with it.
+type ValidationProblem struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError is the error for a malformed item with ValidationReject, found in ItemStreamValue.Err inside a
+// StreamError of "validate". Item is the repaired item.
+type ValidationError struct {
+	Item     *Item
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("invalid item ")
+	sb.WriteString(strconv.Itoa(e.Item.ID))
+
+	for i, p := range e.Problems {
+		if i == 0 {
+			sb.WriteString(": ")
+		} else {
+			sb.WriteString("; ")
+		}
+
+		sb.WriteString(p.Field)
+		sb.WriteString(" ")
+		sb.WriteString(p.Reason)
+	}
+
+	return sb.String()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidItem
+}
+
+// Repair fixes the problems of item that would otherwise mislead consumers and returns what it fixed, or nil for
+// a well-formed item. Since ids only grow, a parent or poll must be older than the item and its kids and parts newer:
+// a parent or poll that isn't is dropped, making the item a root, and so are such kids and parts, along with
+// duplicates. Negative times and descendant counts become 0 and invalid UTF-8 in the text becomes U+FFFD.
+func (item *Item) Repair() []ValidationProblem {
+	var problems []ValidationProblem
+
+	report := func(field string, reason string) {
+		problems = append(problems, ValidationProblem{field, reason})
+	}
+
+	if item.Parent != nil && (*item.Parent <= 0 || *item.Parent >= item.ID) {
+		report("parent", "is not older than the item")
+		item.Parent = nil
+	}
+
+	if item.Poll != nil && (*item.Poll <= 0 || *item.Poll >= item.ID) {
+		report("poll", "is not older than the item")
+		item.Poll = nil
+	}
+
+	if kids, ok := newerUnique(item.ID, item.Kids); !ok {
+		report("kids", "are not all unique and newer than the item")
+		item.Kids = kids
+	}
+
+	if parts, ok := newerUnique(item.ID, item.Parts); !ok {
+		report("parts", "are not all unique and newer than the item")
+		item.Parts = parts
+	}
+
+	if item.Time < 0 {
+		report("time", "is negative")
+		item.Time = 0
+	}
+
+	if item.Descendants < 0 {
+		report("descendants", "is negative")
+		item.Descendants = 0
+	}
+
+	for _, field := range []struct {
+		value *string
+		name  string
+	}{{&item.By, "by"}, {&item.Text, "text"}, {&item.Title, "title"}, {&item.URL, "url"}} {
+		if !utf8.ValidString(*field.value) {
+			report(field.name, "is not valid UTF-8")
+			*field.value = strings.ToValidUTF8(*field.value, string(utf8.RuneError))
+		}
+	}
+
+	return problems
+}
+
+// newerUnique returns ids without those not newer than id and the repeats, and whether there were none.
+func newerUnique(id int, ids []int) ([]int, bool) {
+	seen := make(map[int]struct{}, len(ids))
+	ok := true
+
+	for _, v := range ids {
+		_, repeated := seen[v]
+		seen[v] = struct{}{}
+
+		ok = ok && !repeated && v > id
+	}
+
+	if ok {
+		return ids, true
+	}
+
+	clear(seen)
+
+	return slices.DeleteFunc(slices.Clone(ids), func(v int) bool {
+		_, repeated := seen[v]
+		seen[v] = struct{}{}
+
+		return repeated || v <= id
+	}), false
+}
+
+// validateItemStreamValue applies level to a decoded item. The decoder has already replaced invalid UTF-8 in the
+// strings of the item with U+FFFD, so invalid UTF-8 is found in the body it was decoded from.
+func validateItemStreamValue(
+	level ValidationLevel,
+	body []byte,
+	value ItemStreamValue[*Item],
+) ItemStreamValue[*Item] {
+	if level == ValidationOff || value.Err != nil || value.Item.Type == NullBody {
+		return value
+	}
+
+	problems := value.Item.Repair()
+
+	if !utf8.Valid(body) {
+		problems = append(problems, ValidationProblem{"body", "is not valid UTF-8"})
+	}
+
+	if level == ValidationRepair || len(problems) == 0 {
+		return value
+	}
+
+	return ItemStreamValue[*Item]{
+		ID:   value.ID,
+		Item: nil,
+		Err:  &StreamError{&ValidationError{value.Item, problems}, "validate", value.ID},
+	}
+}
@@ -0,0 +1,159 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestItemRepair(t *testing.T) {
+	t.Parallel()
+
+	parent := func(v int) *int { return &v }
+
+	tests := []struct {
+		name     string
+		modify   func(item *Item)
+		expected func(item *Item)
+		fields   []string
+	}{
+		{"well-formed", func(*Item) {}, func(*Item) {}, nil},
+		{
+			"newer parent",
+			func(item *Item) { item.Parent = parent(11) },
+			func(item *Item) { item.Parent = nil },
+			[]string{"parent"},
+		},
+		{
+			"self poll",
+			func(item *Item) { item.Poll = parent(10) },
+			func(item *Item) { item.Poll = nil },
+			[]string{"poll"},
+		},
+		{
+			"old and repeated kids",
+			func(item *Item) { item.Kids = []int{13, 9, 12, 13, 10} },
+			func(item *Item) { item.Kids = []int{13, 12} },
+			[]string{"kids"},
+		},
+		{
+			"negative counts",
+			func(item *Item) { item.Time, item.Descendants = -1, -2 },
+			func(item *Item) { item.Time, item.Descendants = 0, 0 },
+			[]string{"time", "descendants"},
+		},
+		{
+			"invalid UTF-8",
+			func(item *Item) { item.Text, item.By = "a\xffb", "\xfe" },
+			func(item *Item) { item.Text, item.By = "a�b", "�" },
+			[]string{"by", "text"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			newItem := func() *Item {
+				var item Item
+				item.ID = 10
+				item.Parent = parent(5)
+				item.Type = Comment
+				item.Kids = []int{11, 12}
+				item.Time = 1_700_000_000
+				item.Text = "text"
+
+				return &item
+			}
+
+			item, expected := newItem(), newItem()
+			tt.modify(item)
+			tt.expected(expected)
+
+			problems := item.Repair()
+
+			var fields []string
+			for _, p := range problems {
+				fields = append(fields, p.Field)
+			}
+
+			if diff := cmp.Diff(tt.fields, fields); diff != "" {
+				t.Fatalf("unexpected problems (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(expected, item); diff != "" {
+				t.Fatalf("unexpected repair (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// malformedGetter serves item 10 with a newer parent and a negative time.
+type malformedGetter struct{}
+
+func (malformedGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	if path != "item/10.json" {
+		return testdata.Getter.Get(ctx, path)
+	}
+
+	return io.NopCloser(strings.NewReader(`{"id":10,"type":"comment","parent":11,"time":-1,"text":"a"}`)), nil
+}
+
+func TestWithValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		level  ValidationLevel
+		parent bool
+		reject bool
+	}{
+		{"off", ValidationOff, true, false},
+		{"repair", ValidationRepair, false, false},
+		{"reject", ValidationReject, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := NewClient(
+				t.Context(), WithFileCachePath(""), WithGetter(malformedGetter{}), WithClock(testdata.Clock),
+				WithValidation(tt.level),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() { _ = client.Close() }()
+
+			items, err := client.GetItems(t.Context(), []int{10})
+
+			if tt.reject {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || !errors.Is(err, ErrInvalidItem) {
+					t.Fatalf("expected a ValidationError, got %v", err)
+				}
+
+				if len(validationErr.Problems) != 2 || validationErr.Item.Parent != nil {
+					t.Fatalf("unexpected validation error %v", validationErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (items[10].Parent != nil) != tt.parent {
+				t.Fatalf("unexpected parent %v", items[10].Parent)
+			}
+		})
+	}
+}
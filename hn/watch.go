@@ -0,0 +1,112 @@
+package hn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+// WatchEvent is a change to a watched path as sent by the Firebase REST API. A "put" replaces the value at Path,
+// relative to the watched path, with Data, and a "patch" merges the children in Data into it. The first event is a
+// put of the whole value at "/", so for "maxitem.json" each put carries the new max item as Data, and for an item
+// the later events put its changed fields such as "/score" or "/kids".
+//
+// The last event sent before the channel is closed has Err set to why the stream ended, unless the context was
+// canceled: an error wrapping ErrWatchCanceled when the server canceled it, or ErrWatchEnded when it closed the
+// stream.
+type WatchEvent struct {
+	Err  error
+	Type string
+	Path string
+	Data json.RawMessage
+}
+
+var (
+	// ErrWatchCanceled is returned when the server cancels a watch, such as when the path stops being readable.
+	ErrWatchCanceled = errors.New("watch canceled by server")
+	// ErrWatchEnded is returned when the server closes the stream of a watch.
+	ErrWatchEnded = errors.New("watch ended by server")
+)
+
+var errNoGetter = errors.New("client has no getter")
+
+// Watch opens a server-sent event stream for path, such as "maxitem.json", "newstories.json", or "item/8863.json",
+// and sends its events until ctx is canceled or the stream ends, when the channel is closed. It stands in for polling:
+// the server pushes each change as it happens on the one connection. Keep-alive events are not sent. Opening the
+// stream is done before returning, so an error such as one wrapping ErrUnsupportedBySource for sources other than the
+// HN API is returned directly. The events bypass the caches.
+func (c *Client) Watch(ctx context.Context, path string) (<-chan WatchEvent, error) {
+	if c.getter == nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", path, errNoGetter)
+	}
+
+	reader, err := c.getter.Get(core.WithEventStream(ctx), path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	ch := make(chan WatchEvent, 1)
+
+	go func() {
+		defer close(ch)
+		defer func() { _ = reader.Close() }()
+
+		err := readWatchEvents(ctx, reader, ch)
+		if ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+		case ch <- WatchEvent{err, "", "", nil}:
+		}
+	}()
+
+	return ch, nil
+}
+
+// readWatchEvents sends the put and patch events from reader, returning why the stream ended.
+func readWatchEvents(ctx context.Context, reader io.Reader, ch chan<- WatchEvent) error {
+	var stop error
+
+	err := core.ReadEvents(reader, func(event core.Event) bool {
+		switch event.Type {
+		case "put", "patch":
+			var body struct {
+				Path string          `json:"path"`
+				Data json.RawMessage `json:"data"`
+			}
+
+			stop = json.Unmarshal(event.Data, &body)
+			if stop != nil {
+				stop = fmt.Errorf("failed to decode %s event: %w", event.Type, stop)
+				return false
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case ch <- WatchEvent{nil, event.Type, body.Path, body.Data}:
+				return true
+			}
+		case "cancel", "auth_revoked":
+			stop = fmt.Errorf("%w: %s %s", ErrWatchCanceled, event.Type, event.Data)
+			return false
+		default:
+			return true
+		}
+	})
+
+	switch {
+	case stop != nil:
+		return stop
+	case err != nil:
+		return err
+	default:
+		return ErrWatchEnded
+	}
+}
@@ -0,0 +1,123 @@
+package hn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+// firebaseHandler streams events like the Firebase REST API: the value of the path, a keep-alive, a change, and then
+// the events for the path, if any, before holding the stream open until the client goes away.
+func firebaseHandler(last map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "expected an event stream", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		_, _ = fmt.Fprint(w, "event: put\ndata: {\"path\":\"/\",\"data\":100}\n\n")
+		_, _ = fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+		_, _ = fmt.Fprint(w, "event: patch\ndata: {\"path\":\"/\",\"data\":{\"score\":2}}\n\n")
+
+		if event, ok := last[r.URL.Path]; ok {
+			_, _ = fmt.Fprint(w, event)
+			return
+		}
+
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(firebaseHandler(map[string]string{
+		"/ended.json":    "",
+		"/canceled.json": "event: cancel\ndata: permission denied\n\n",
+	}))
+	defer server.Close()
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithGetter(core.NewBaseGetter(server.Client(), server.URL+"/")),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	expected := []WatchEvent{
+		{nil, "put", "/", []byte("100")},
+		{nil, "patch", "/", []byte(`{"score":2}`)},
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	events, err := client.Watch(ctx, "maxitem.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []WatchEvent
+
+	for event := range events {
+		got = append(got, event)
+		if len(got) == len(expected) {
+			cancel()
+		}
+	}
+
+	if diff := cmp.Diff(expected, got, cmp.Comparer(errors.Is)); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	for path, expectedErr := range map[string]error{"ended.json": ErrWatchEnded, "canceled.json": ErrWatchCanceled} {
+		events, err := client.Watch(t.Context(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var last WatchEvent
+		for event := range events {
+			last = event
+		}
+
+		if !errors.Is(last.Err, expectedErr) {
+			t.Fatalf("%s: expected the last event to end with %v, got %v", path, expectedErr, last.Err)
+		}
+	}
+}
+
+func TestWatchUnsupported(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewClient(
+		t.Context(),
+		WithFileCachePath(""),
+		WithSource(Algolia),
+		WithGetter(testdata.Getter),
+		WithClock(testdata.Clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = client.Close() }()
+
+	_, err = client.Watch(t.Context(), "maxitem.json")
+	if !errors.Is(err, ErrUnsupportedBySource) {
+		t.Fatalf("expected watching Algolia to be unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package unl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// ActiveSnapshot is a result of GetActive kept so an identical run shortly after can reuse it: the active roots in
+// order and the items of their trees.
+type ActiveSnapshot struct {
+	Created time.Time
+	Items   hn.ItemSet
+	Roots   []int
+}
+
+// NewActiveSnapshot keeps the trees of the roots returned by GetActive, dropping the other items it retrieved.
+func NewActiveSnapshot(roots []*hn.Item, allByParent map[int]hn.ItemSet, created time.Time) *ActiveSnapshot {
+	snapshot := &ActiveSnapshot{created, make(hn.ItemSet), make([]int, len(roots))}
+
+	for i, root := range roots {
+		snapshot.Roots[i] = root.ID
+
+		for _, item := range FlattenTree(root, allByParent) {
+			snapshot.Items[item.ID] = item.Item
+		}
+	}
+
+	return snapshot
+}
+
+// Result returns the snapshot as GetActive returns it.
+func (a *ActiveSnapshot) Result() ([]*hn.Item, map[int]hn.ItemSet, error) {
+	roots := make([]*hn.Item, len(a.Roots))
+	for i, id := range a.Roots {
+		roots[i] = a.Items[id]
+	}
+
+	allByParent, _, err := a.Items.GroupByParent()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get group by parent: %w", err)
+	}
+
+	return roots, allByParent, nil
+}
+
+// Changed reports whether an item of the snapshot has changed since it was taken. The API lists recently changed
+// items for a while after each change, so listed items in the snapshot are retrieved again through client and
+// compared. A new reply changes the kids of its parent, so it is found the same way. Sources without updates report
+// no change, leaving the snapshot valid until it expires.
+func (a *ActiveSnapshot) Changed(ctx context.Context, client hn.API) (bool, error) {
+	updates, err := client.GetUpdates(ctx)
+	if errors.Is(err, hn.ErrUnsupportedBySource) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get updates: %w", err)
+	}
+
+	var ids []int
+
+	for _, id := range updates.Items {
+		if _, ok := a.Items[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	items, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return false, fmt.Errorf("failed to get updated items: %w", err)
+	}
+
+	for _, id := range ids {
+		before, err := a.Items[id].Marshal()
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal item: %w", err)
+		}
+
+		after, err := items[id].Marshal()
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal item: %w", err)
+		}
+
+		if !bytes.Equal(before, after) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SaveActiveSnapshot replaces the snapshot saved under key, usually a description of the parameters of the run, and
+// removes the snapshots of other keys created before expiredBefore.
+func (s *Store) SaveActiveSnapshot(
+	ctx context.Context, key string, snapshot *ActiveSnapshot, expiredBefore time.Time,
+) (err error) {
+	roots, err := json.Marshal(snapshot.Roots)
+	if err != nil {
+		return fmt.Errorf("failed to marshal roots: %w", err)
+	}
+
+	var items bytes.Buffer
+
+	for _, item := range snapshot.Items {
+		err = item.WriteJSON(&items)
+		if err != nil {
+			return fmt.Errorf("failed to write item: %w", err)
+		}
+
+		items.WriteByte('\n')
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM active_snapshot WHERE created < ?", expiredBefore.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to remove expired snapshots: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT OR REPLACE INTO active_snapshot(key, created, roots, items) VALUES (?, ?, ?, ?)",
+		key, snapshot.Created.Unix(), string(roots), items.String())
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ActiveSnapshot returns the snapshot saved under key if it was created at or after createdAfter, or nil.
+func (s *Store) ActiveSnapshot(ctx context.Context, key string, createdAfter time.Time) (*ActiveSnapshot, error) {
+	var (
+		created int64
+		roots   string
+		items   []byte
+	)
+
+	err := s.db.QueryRowContext(
+		ctx, "SELECT created, roots, items FROM active_snapshot WHERE key = ? AND created >= ?",
+		key, createdAfter.Unix()).Scan(&created, &roots, &items)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil //nolint:nilnil // no snapshot is not an error
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("snapshot scan: %w", err)
+	}
+
+	snapshot := &ActiveSnapshot{time.Unix(created, 0), make(hn.ItemSet), nil}
+
+	err = json.Unmarshal([]byte(roots), &snapshot.Roots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal roots: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(items))
+	scanner.Buffer(nil, len(items)+1)
+
+	for scanner.Scan() {
+		var item hn.Item
+
+		err = json.Unmarshal(scanner.Bytes(), &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+		}
+
+		snapshot.Items[item.ID] = &item
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan items: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// ClearActiveSnapshots removes every snapshot, for changes that affect every result such as muting a thread.
+func (s *Store) ClearActiveSnapshots(ctx context.Context) error {
+	return s.execContext(ctx, "DELETE FROM active_snapshot")
+}
@@ -0,0 +1,105 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Bookmark is a saved item with optional tags and a note. The JSON form is used for export and import.
+type Bookmark struct {
+	Note    string   `json:"note,omitempty"`
+	Tags    []string `json:"tags"`
+	ID      int      `json:"id"`
+	SavedAt int64    `json:"saved"`
+}
+
+// SaveBookmark stores b. Saving an item again adds its tags to the existing ones, replaces the note if b has one,
+// and keeps the original SavedAt.
+func (s *Store) SaveBookmark(ctx context.Context, b Bookmark) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO bookmark(ID, note, saved) VALUES (?, ?, ?) "+
+			"ON CONFLICT(ID) DO UPDATE SET note = CASE WHEN excluded.note = '' THEN note ELSE excluded.note END",
+		b.ID, b.Note, b.SavedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert bookmark: %w", err)
+	}
+
+	for _, tag := range b.Tags {
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO bookmark_tag(ID, tag) VALUES (?, ?)", b.ID, tag)
+		if err != nil {
+			return fmt.Errorf("failed to insert bookmark tag: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveBookmark deletes the bookmark for id and its tags, if any.
+func (s *Store) RemoveBookmark(ctx context.Context, id int) error {
+	return errors.Join(
+		s.execContext(ctx, "DELETE FROM bookmark_tag WHERE ID = ?", id),
+		s.execContext(ctx, "DELETE FROM bookmark WHERE ID = ?", id))
+}
+
+// Bookmarks returns the bookmarks with tag, or all bookmarks if tag is empty, most recently saved first.
+func (s *Store) Bookmarks(ctx context.Context, tag string) (_ []Bookmark, err error) {
+	rows, err := s.queryContext(
+		ctx,
+		"SELECT b.ID, b.note, b.saved, t.tag FROM bookmark b LEFT JOIN bookmark_tag t ON t.ID = b.ID "+
+			"WHERE ? = '' OR b.ID IN (SELECT ID FROM bookmark_tag WHERE tag = ?) "+
+			"ORDER BY b.saved DESC, b.ID DESC, t.tag",
+		tag, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	var result []Bookmark
+
+	for rows.Next() {
+		var b Bookmark
+		var t sql.NullString
+
+		err = rows.Scan(&b.ID, &b.Note, &b.SavedAt, &t)
+		if err != nil {
+			return nil, fmt.Errorf("bookmark scan: %w", err)
+		}
+
+		if len(result) == 0 || result[len(result)-1].ID != b.ID {
+			b.Tags = []string{}
+			result = append(result, b)
+		}
+
+		if t.Valid {
+			last := &result[len(result)-1]
+			last.Tags = append(last.Tags, t.String)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("bookmark rows err: %w", err)
+	}
+
+	return result, nil
+}
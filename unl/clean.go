@@ -0,0 +1,440 @@
+package unl
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CleanOptions selects how CleanText converts HN item HTML to plain text. The zero value produces a single line of
+// text, as shown by PrettyCleanText.
+type CleanOptions struct {
+	// ParagraphBreaks separates paragraphs with a blank line instead of a space.
+	ParagraphBreaks bool
+	// CodeFences keeps the contents of code blocks verbatim between ``` lines instead of collapsing them.
+	CodeFences bool
+	// StripURLs removes links and bare URLs instead of keeping their targets.
+	StripURLs bool
+	// Lowercase lowercases the text outside code blocks.
+	Lowercase bool
+}
+
+const (
+	codeBlockStart = "<pre><code>"
+	codeBlockEnd   = "</code></pre>"
+	// maxEntityLength bounds the search for the end of a character reference.
+	maxEntityLength = 32
+)
+
+// PrettyCleanText converts HN item HTML to a single line of plain text.
+func PrettyCleanText(v string) string {
+	return CleanText(v, CleanOptions{false, false, false, false})
+}
+
+// CleanText converts HN item HTML to plain text according to opts. Tags and character references are handled in a
+// single pass, so text that only looks like a tag once unescaped, such as "&lt;p&gt;", is kept as written.
+func CleanText(v string, opts CleanOptions) string {
+	c := cleaner{strings.Builder{}, nil, opts, separatorNone}
+	c.sb.Grow(len(v))
+
+	for i := 0; i < len(v); {
+		b := v[i]
+
+		switch {
+		case b == '<':
+			i += c.tag(v[i:])
+		case b == '&':
+			i += decodeEntity(v[i:], c.rune)
+		case b < utf8.RuneSelf:
+			c.rune(rune(b))
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(v[i:])
+			c.rune(r)
+			i += size
+		}
+	}
+
+	c.separate(separatorNone)
+
+	return c.sb.String()
+}
+
+// separator is the whitespace owed between two pieces of output. A larger separator absorbs a smaller one.
+type separator int
+
+const (
+	separatorNone separator = iota
+	separatorSpace
+	separatorParagraph
+	separatorBlock
+)
+
+// tagSeparators maps the tags removed from text to whether they separate paragraphs.
+//
+//nolint:gochecknoglobals // constant
+var tagSeparators = []struct {
+	tag       string
+	paragraph bool
+}{
+	{"<p>", true},
+	{"</p>", true},
+	{"<b>", false},
+	{"</b>", false},
+	{"<i>", false},
+	{"</i>", false},
+	{"<pre>", true},
+	{"</pre>", true},
+	{"<code>", false},
+	{"</code>", false},
+}
+
+// commonEntities are decoded without html.UnescapeString, which allocates.
+//
+//nolint:gochecknoglobals // constant
+var commonEntities = []struct {
+	name string
+	r    rune
+}{
+	{"&quot;", '"'},
+	{"&amp;", '&'},
+	{"&lt;", '<'},
+	{"&gt;", '>'},
+}
+
+// cleaner accumulates cleaned text. Runes are buffered into the current word so StripURLs can drop URLs within it,
+// and separators are deferred so runs of them collapse and none are written at either end.
+type cleaner struct {
+	sb      strings.Builder
+	word    []byte
+	opts    CleanOptions
+	pending separator
+}
+
+func (c *cleaner) rune(r rune) {
+	switch {
+	case r <= ' ':
+		c.separate(separatorSpace)
+	case r < utf8.RuneSelf:
+		if c.opts.Lowercase && 'A' <= r && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+
+		c.word = append(c.word, byte(r))
+	case unicode.IsPrint(r) && !unicode.IsSpace(r):
+		if c.opts.Lowercase {
+			r = unicode.ToLower(r)
+		}
+
+		c.word = utf8.AppendRune(c.word, r)
+	default:
+		c.separate(separatorSpace)
+	}
+}
+
+func (c *cleaner) codeRune(r rune) {
+	switch {
+	case r == '\n':
+		c.word = append(c.word, '\n')
+	case r < ' ':
+		c.word = append(c.word, ' ')
+	case r < utf8.RuneSelf:
+		c.word = append(c.word, byte(r))
+	case unicode.IsPrint(r):
+		c.word = utf8.AppendRune(c.word, r)
+	default:
+		c.word = append(c.word, ' ')
+	}
+}
+
+// separate ends the current word and owes at least s before the next output.
+func (c *cleaner) separate(s separator) {
+	word := c.word
+	if c.opts.StripURLs {
+		word = word[:bareURLStart(word)]
+	}
+
+	if len(word) > 0 {
+		c.begin()
+		c.sb.Write(word)
+	}
+
+	c.word = c.word[:0]
+	c.pending = max(c.pending, s)
+}
+
+// begin writes the owed separator unless nothing has been written yet.
+func (c *cleaner) begin() {
+	if c.sb.Len() > 0 {
+		switch c.pending {
+		case separatorNone:
+		case separatorSpace:
+			c.sb.WriteByte(' ')
+		case separatorParagraph:
+			c.sb.WriteString("\n\n")
+		case separatorBlock:
+			if c.opts.ParagraphBreaks {
+				c.sb.WriteString("\n\n")
+			} else {
+				c.sb.WriteByte('\n')
+			}
+		}
+	}
+
+	c.pending = separatorNone
+}
+
+// tag handles the markup at the start of v, which starts with '<', and returns the number of bytes consumed.
+func (c *cleaner) tag(v string) int {
+	if c.opts.CodeFences && strings.HasPrefix(v, codeBlockStart) {
+		return c.codeBlock(v)
+	}
+
+	if n := c.link(v); n > 0 {
+		return n
+	}
+
+	for _, t := range tagSeparators {
+		if strings.HasPrefix(v, t.tag) {
+			if t.paragraph && c.opts.ParagraphBreaks {
+				c.separate(separatorParagraph)
+			} else {
+				c.separate(separatorSpace)
+			}
+
+			return len(t.tag)
+		}
+	}
+
+	c.rune('<')
+
+	return 1
+}
+
+// link handles an anchor like <a href="target" rel="nofollow">text</a>, replacing it with its target as a word, or
+// with nothing if StripURLs is set. It returns 0 if v does not start with a complete anchor.
+func (c *cleaner) link(v string) int {
+	const hrefPrefix = `href="`
+
+	if len(v) < len("<a ") || (v[1] != 'a' && v[1] != 'A') || !isASCIISpace(v[2]) {
+		return 0
+	}
+
+	rest := strings.TrimLeft(v[2:], " \t\n\f\r")
+	if len(rest) < len(hrefPrefix) || !strings.EqualFold(rest[:len(hrefPrefix)], hrefPrefix) {
+		return 0
+	}
+
+	target, rest, ok := strings.Cut(rest[len(hrefPrefix):], `"`)
+	if !ok {
+		return 0
+	}
+
+	end := strings.IndexByte(rest, '>')
+	if end < 0 || (end > 0 && rest[end-1] != '"') {
+		return 0
+	}
+
+	rest = rest[end+1:]
+
+	end = indexFold(rest, "</a>")
+	if end < 0 {
+		return 0
+	}
+
+	rest = rest[end+len("</a>"):]
+
+	c.separate(separatorSpace)
+
+	if !c.opts.StripURLs {
+		for i := 0; i < len(target); {
+			if target[i] == '&' {
+				i += decodeEntity(target[i:], c.rune)
+				continue
+			}
+
+			r, size := utf8.DecodeRuneInString(target[i:])
+			c.rune(r)
+			i += size
+		}
+
+		c.separate(separatorSpace)
+	}
+
+	return len(v) - len(rest)
+}
+
+// codeBlock writes the code block at the start of v between ``` lines, keeping its newlines and indentation, and
+// returns the number of bytes consumed. A block without an end runs to the end of v.
+func (c *cleaner) codeBlock(v string) int {
+	code, _, found := strings.Cut(v[len(codeBlockStart):], codeBlockEnd)
+
+	n := len(v)
+	if found {
+		n = len(codeBlockStart) + len(code) + len(codeBlockEnd)
+	}
+
+	c.separate(separatorBlock)
+	c.begin()
+
+	for i := 0; i < len(code); {
+		if code[i] == '&' {
+			i += decodeEntity(code[i:], c.codeRune)
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(code[i:])
+		c.codeRune(r)
+		i += size
+	}
+
+	c.sb.WriteString("```\n")
+	c.sb.Write(bytes.TrimRight(c.word, " \n"))
+	c.sb.WriteString("\n```")
+
+	c.word = c.word[:0]
+	c.pending = separatorBlock
+
+	return n
+}
+
+// decodeEntity decodes the character reference at the start of v, which starts with '&', passing the result to emit,
+// and returns the number of bytes consumed. An '&' that does not start a reference is passed through as written.
+func decodeEntity(v string, emit func(rune)) int {
+	if r, n := numericEntity(v); n > 0 {
+		emit(r)
+		return n
+	}
+
+	for _, e := range commonEntities {
+		if strings.HasPrefix(v, e.name) {
+			emit(e.r)
+			return len(e.name)
+		}
+	}
+
+	n := 1
+	for n < len(v) && n < maxEntityLength && isEntityByte(v[n]) {
+		n++
+	}
+
+	if n < len(v) && v[n] == ';' {
+		n++
+	}
+
+	decoded := html.UnescapeString(v[:n])
+	if decoded == v[:n] {
+		emit('&')
+		return 1
+	}
+
+	for _, r := range decoded {
+		emit(r)
+	}
+
+	return n
+}
+
+// numericEntity decodes a terminated numeric character reference like "&#x27;" at the start of v. It returns 0 for
+// anything else, including references that html.UnescapeString replaces rather than decodes.
+func numericEntity(v string) (rune, int) {
+	const maxDigits = 7
+
+	if len(v) < len("&#0;") || v[1] != '#' {
+		return 0, 0
+	}
+
+	base := rune(10)
+	i := 2
+
+	if v[i] == 'x' || v[i] == 'X' {
+		base = 16
+		i++
+	}
+
+	var r rune
+
+	start := i
+
+	for ; i < len(v) && i-start < maxDigits; i++ {
+		d := hexDigit(v[i])
+		if d < 0 || d >= base {
+			break
+		}
+
+		r = r*base + d
+	}
+
+	if i == start || i >= len(v) || v[i] != ';' {
+		return 0, 0
+	}
+
+	if (r < ' ' || r >= 0x7f) && (r < 0xa0 || r > unicode.MaxRune || (r >= 0xd800 && r <= 0xdfff)) {
+		return 0, 0
+	}
+
+	return r, i + 1
+}
+
+func hexDigit(b byte) rune {
+	switch {
+	case '0' <= b && b <= '9':
+		return rune(b - '0')
+	case 'a' <= b && b <= 'f':
+		return rune(b-'a') + 10
+	case 'A' <= b && b <= 'F':
+		return rune(b-'A') + 10
+	default:
+		return -1
+	}
+}
+
+// bareURLStart returns the index of the first http or https URL in word that starts at a word boundary, or len(word).
+func bareURLStart(word []byte) int {
+	for i := 0; i+len("http://") <= len(word); i++ {
+		if i > 0 && isWordByte(word[i-1]) {
+			continue
+		}
+
+		rest := word[i:]
+		if !bytes.EqualFold(rest[:len("http")], []byte("http")) {
+			continue
+		}
+
+		rest = rest[len("http"):]
+		if len(rest) > 0 && (rest[0] == 's' || rest[0] == 'S') {
+			rest = rest[1:]
+		}
+
+		if bytes.HasPrefix(rest, []byte("://")) && len(rest) > len("://") {
+			return i
+		}
+	}
+
+	return len(word)
+}
+
+// indexFold returns the index of the first case-insensitive match of the ASCII string sub in v, or -1.
+func indexFold(v string, sub string) int {
+	for i := 0; i+len(sub) <= len(v); i++ {
+		if strings.EqualFold(v[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isASCIISpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+func isEntityByte(b byte) bool {
+	return b == '#' || isWordByte(b)
+}
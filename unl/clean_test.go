@@ -0,0 +1,104 @@
+package unl
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestCleanText(t *testing.T) {
+	t.Parallel()
+
+	const text = "Try this:<p>It&#x27;s <i>fast</i>    see " +
+		`<a href="https:&#x2F;&#x2F;example.com&#x2F;a" rel="nofollow">https:&#x2F;&#x2F;example.com&#x2F;a</a>` +
+		"<pre><code>  if a &lt; b {\n    return\n  }\n</code></pre>Or http://example.org now."
+
+	tests := []struct {
+		name     string
+		expected string
+		opts     CleanOptions
+	}{
+		{
+			"default",
+			"Try this: It's fast see https://example.com/a if a < b { return } Or http://example.org now.",
+			CleanOptions{false, false, false, false},
+		},
+		{
+			"paragraphs",
+			"Try this:\n\nIt's fast see https://example.com/a\n\nif a < b { return }\n\nOr http://example.org now.",
+			CleanOptions{true, false, false, false},
+		},
+		{
+			"code",
+			"Try this: It's fast see https://example.com/a\n```\n  if a < b {\n    return\n  }\n```\n" +
+				"Or http://example.org now.",
+			CleanOptions{false, true, false, false},
+		},
+		{
+			"strip urls and lowercase",
+			"try this: it's fast see if a < b { return } or now.",
+			CleanOptions{false, false, true, true},
+		},
+		{
+			"all",
+			"try this:\n\nit's fast see\n\n```\n  if a < b {\n    return\n  }\n```\n\nor now.",
+			CleanOptions{true, true, true, true},
+		},
+	}
+
+	for _, test := range tests {
+		if actual := CleanText(text, test.opts); actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+	}
+
+	if actual := PrettyCleanText(text); actual != tests[0].expected {
+		t.Errorf("expected PrettyCleanText to match the default, got %q", actual)
+	}
+
+	const escaped = "&lt;p&gt;AT&T&amp;&copy;&#128512;&#0;<A HREF=\"x\">x</A><a href=\"unclosed\">"
+	if actual := PrettyCleanText(escaped); actual != "<p>AT&T&©😀� x <a href=\"unclosed\">" {
+		t.Errorf("unexpected cleaning of references and malformed tags: %q", actual)
+	}
+}
+
+func BenchmarkCleanText(b *testing.B) {
+	var texts []string
+
+	for i := testdata.MaxItem; i > testdata.MinItem; i-- {
+		reader, err := testdata.Getter.Get(b.Context(), "item/"+strconv.Itoa(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var item hn.Item
+
+		err = json.NewDecoder(reader).Decode(&item)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		texts = append(texts, item.Title, item.Text)
+	}
+
+	for _, bench := range []struct {
+		name string
+		opts CleanOptions
+	}{
+		{"default", CleanOptions{false, false, false, false}},
+		{"all", CleanOptions{true, true, true, true}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for b.Loop() {
+				for _, text := range texts {
+					_ = CleanText(text, bench.opts)
+				}
+			}
+		})
+	}
+}
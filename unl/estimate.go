@@ -0,0 +1,208 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+const (
+	defaultListDeltaStories   = 60
+	defaultListDeltaRetention = 24 * time.Hour
+)
+
+// ListDelta estimates activity from the growth of the descendants and score of the first Stories stories of the top
+// and new lists since the metrics recorded in the Store by earlier runs, which are kept for Retention. It retrieves
+// only the stories, an order of magnitude fewer requests than IDScan, but finds nothing under older stories on its
+// first run and returns no replies, so options that need them, such as WithMinActivity and WithOrphans, are ignored.
+// Each new comment has an author, so the growth is an upper bound on the new contributors checked against MinBy; it
+// also counts replies that were since removed and misses discussions under stories no longer listed.
+type ListDelta struct {
+	Stories   int
+	Retention time.Duration
+}
+
+func (ListDelta) Name() string {
+	return "descendants"
+}
+
+func (l ListDelta) Find(ctx context.Context, client hn.API, req ActivityRequest) (Activity, error) {
+	if req.Store == nil {
+		return Activity{}, fmt.Errorf("%w: %s", errStrategyStore, l.Name())
+	}
+
+	stories, err := GetRecentStories(ctx, client, l.Stories)
+	if err != nil {
+		return Activity{}, err
+	}
+
+	baseline, err := req.Store.StoryMetrics(ctx, req.ActiveAfter)
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get story metrics: %w", err)
+	}
+
+	growth := estimateActive(stories, baseline, req)
+
+	err = req.Store.SaveStoryMetrics(ctx, stories, req.Now, req.Now.Add(-l.Retention))
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to save story metrics: %w", err)
+	}
+
+	roots := make(hn.ItemSet, len(growth))
+	for id, e := range growth {
+		roots[id] = e.Item
+	}
+
+	return Activity{roots, map[int]hn.ItemSet{}, growth}, nil
+}
+
+// StoryMetrics records the descendants and score of a story when it was seen.
+type StoryMetrics struct {
+	ID          int
+	Descendants int
+	Score       int
+	Seen        int64
+}
+
+// Estimate is a story found active by ListDelta, with the growth of its descendants and score since Since.
+type Estimate struct {
+	Item     *hn.Item
+	Since    time.Time
+	Comments int
+	Points   int
+}
+
+// GetRecentStories retrieves the first count stories of the top and new lists, where almost all discussion happens.
+func GetRecentStories(ctx context.Context, client hn.API, count int) (hn.ItemSet, error) {
+	top, err := client.GetTop(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top stories: %w", err)
+	}
+
+	latest, err := client.GetNew(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new stories: %w", err)
+	}
+
+	ids := slices.Concat(top[:min(count, len(top))], latest[:min(count, len(latest))])
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	stories, err := client.GetItems(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stories: %w", err)
+	}
+
+	return stories, nil
+}
+
+// estimateActive returns the stories whose descendants grew by at least req.MinBy since their metrics in baseline.
+// A story created after req.ActiveAfter is compared with no comments, and a story with no metrics in baseline is
+// otherwise skipped.
+func estimateActive(stories hn.ItemSet, baseline map[int]StoryMetrics, req ActivityRequest) map[int]Estimate {
+	o := req.options()
+	estimates := make(map[int]Estimate)
+
+	for _, story := range stories {
+		_, muted := o.muted[story.ID]
+		if muted || story.Dead || story.Deleted || !o.eligible(story, req.EffectiveTimes, req.AgedAfter) {
+			continue
+		}
+
+		base, ok := baseline[story.ID]
+		if o.boundary.After(story.Time, req.ActiveAfter) {
+			// a submission starts with one point and no comments
+			base, ok = StoryMetrics{story.ID, 0, 1, story.Time}, true
+		}
+
+		if !ok || story.Descendants-base.Descendants < max(req.MinBy, 1) {
+			continue
+		}
+
+		estimates[story.ID] = Estimate{
+			story, time.Unix(base.Seen, 0), story.Descendants - base.Descendants, story.Score - base.Score,
+		}
+	}
+
+	return estimates
+}
+
+// SaveStoryMetrics records the descendants and score of the stories as seen at seen and removes the metrics seen
+// before expiredBefore.
+func (s *Store) SaveStoryMetrics(
+	ctx context.Context, stories hn.ItemSet, seen time.Time, expiredBefore time.Time,
+) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	for _, story := range stories {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT OR REPLACE INTO story_metrics(ID, seen, descendants, score) VALUES (?, ?, ?, ?)",
+			story.ID, seen.Unix(), story.Descendants, story.Score)
+		if err != nil {
+			return fmt.Errorf("failed to insert story metrics: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM story_metrics WHERE seen < ?", expiredBefore.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired story metrics: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// StoryMetrics returns the baseline for ListDelta: for each recorded story, its latest metrics seen at or
+// before at, or its earliest if all were seen after, which covers less of the window.
+func (s *Store) StoryMetrics(ctx context.Context, at time.Time) (_ map[int]StoryMetrics, err error) {
+	rows, err := s.queryContext(
+		ctx,
+		`SELECT ID, descendants, score, seen FROM story_metrics m WHERE seen = COALESCE(
+		  (SELECT MAX(seen) FROM story_metrics WHERE ID = m.ID AND seen <= ?),
+		  (SELECT MIN(seen) FROM story_metrics WHERE ID = m.ID))`,
+		at.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	result := make(map[int]StoryMetrics)
+
+	for rows.Next() {
+		var m StoryMetrics
+
+		err = rows.Scan(&m.ID, &m.Descendants, &m.Score, &m.Seen)
+		if err != nil {
+			return nil, fmt.Errorf("story metrics scan: %w", err)
+		}
+
+		result[m.ID] = m
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("story metrics rows err: %w", err)
+	}
+
+	return result, nil
+}
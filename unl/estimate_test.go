@@ -0,0 +1,135 @@
+package unl
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestEstimateActive(t *testing.T) {
+	t.Parallel()
+
+	story := func(id int, created int64, descendants int, score int) *hn.Item {
+		var item hn.Item
+		item.ID = id
+		item.Type = hn.Story
+		item.Time = created
+		item.Descendants = descendants
+		item.Score = score
+
+		return &item
+	}
+
+	stories := hn.ItemSet{
+		1: story(1, 1000, 40, 100), // grew by 10
+		2: story(2, 1000, 12, 30),  // grew by 2
+		3: story(3, 5000, 4, 9),    // created within the window
+		4: story(4, 1000, 50, 90),  // no baseline
+		5: story(5, 100, 30, 60),   // too old
+		6: story(6, 1000, 80, 200), // muted
+	}
+	stories[2].Dead = true
+
+	baseline := map[int]StoryMetrics{
+		1: {1, 30, 70, 3000},
+		2: {2, 10, 20, 3000},
+		5: {5, 0, 1, 3000},
+		6: {6, 0, 1, 3000},
+	}
+
+	req := ActivityRequest{
+		time.Time{}, time.Unix(4000, 0), time.Unix(500, 0), nil, nil, []Option{WithMuted(6)}, 3,
+	}
+
+	expected := map[int]Estimate{
+		1: {stories[1], time.Unix(3000, 0), 10, 30},
+		3: {stories[3], time.Unix(5000, 0), 4, 8},
+	}
+	if diff := cmp.Diff(expected, estimateActive(stories, baseline, req)); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+}
+
+func TestListDelta(t *testing.T) {
+	t.Parallel()
+
+	client, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	now := testdata.MaxTime
+	req := ActivityRequest{now, now.Add(-time.Hour), now.Add(-24 * time.Hour), nil, nil, nil, 1}
+	strategy := ListDelta{10, time.Hour}
+
+	_, err = strategy.Find(t.Context(), client, req)
+	if !errors.Is(err, errStrategyStore) {
+		t.Fatalf("expected an error without a store, got %v", err)
+	}
+
+	req.Store = store
+
+	// the first run finds only the stories created within the window, with all of their comments
+	items, activity, err := FindActive(t.Context(), client, strategy, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) == 0 || len(activity.ByParent) != 0 {
+		t.Fatalf("expected estimated stories and no replies, got %d stories", len(items))
+	}
+
+	for i, item := range items {
+		e := activity.Growth[item.ID]
+		if e.Item != item || e.Comments != item.Descendants || !e.Since.Equal(time.Unix(item.Time, 0)) ||
+			(i > 0 && item.Time > items[i-1].Time) {
+			t.Fatalf("unexpected estimate %+v for %+v", e, item)
+		}
+	}
+
+	// the recorded metrics are from after the window, so they are the baseline of a second run and nothing changed
+	req.Now = now.Add(time.Minute)
+	req.ActiveAfter = now.Add(time.Second)
+
+	items, _, err = FindActive(t.Context(), client, strategy, req, 0)
+	if err != nil || len(items) != 0 {
+		t.Fatalf("expected no growth since the first run, got %d stories: %v", len(items), err)
+	}
+}
+
+func TestGetRecentStories(t *testing.T) {
+	t.Parallel()
+
+	client, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := client.GetTop(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stories, err := GetRecentStories(t.Context(), client, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stories) < 5 || len(stories) > 10 || stories[top[0]] == nil {
+		t.Fatalf("expected the first 5 of each list, got %d stories", len(stories))
+	}
+}
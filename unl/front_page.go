@@ -0,0 +1,582 @@
+package unl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"golang.org/x/net/html"
+	"golang.org/x/sync/singleflight"
+)
+
+// FrontPageURL is the page scraped for apparent times and display metadata.
+const FrontPageURL = "https://news.ycombinator.com"
+
+// FrontPageEntry is an article as displayed on the front page.
+type FrontPageEntry struct {
+	Title  string
+	Domain string
+	ID     int
+	Rank   int
+	Points int
+	// Comments is the displayed comment count, which excludes dead and flagged comments unlike descendants.
+	Comments int
+	// Time is the creation time of the article and ApparentTime the time implied by its displayed age.
+	// They differ for second-chance articles.
+	Time         int64
+	ApparentTime int64
+}
+
+// Diverges returns the JSON names of the fields of item that disagree with the front page: "score" for points and
+// "descendants" for comments. Descendants often exceed the displayed count because of dead and flagged comments.
+func (e *FrontPageEntry) Diverges(item *hn.Item) []string {
+	var fields []string
+
+	if item.Score != e.Points {
+		fields = append(fields, "score")
+	}
+
+	if item.Descendants != e.Comments {
+		fields = append(fields, "descendants")
+	}
+
+	return fields
+}
+
+// FrontPage holds the articles on the front page in rank order.
+// If Partial is true the markup could only be parsed by the fallback parser so only IDs and times are set.
+type FrontPage struct {
+	Entries []FrontPageEntry
+	Partial bool
+}
+
+// Times returns the apparent times of the articles for use with GetActive.
+func (fp *FrontPage) Times() EffectiveTimes {
+	m := make(EffectiveTimes, len(fp.Entries))
+
+	for _, e := range fp.Entries {
+		m[e.ID] = e.ApparentTime
+	}
+
+	return m
+}
+
+// ByID returns the entries keyed by article ID.
+func (fp *FrontPage) ByID() map[int]*FrontPageEntry {
+	m := make(map[int]*FrontPageEntry, len(fp.Entries))
+
+	for i := range fp.Entries {
+		m[fp.Entries[i].ID] = &fp.Entries[i]
+	}
+
+	return m
+}
+
+// FrontPageClient retrieves and parses the front page, sharing concurrent requests and caching the displayed ages
+// briefly so each caller computes apparent times relative to its own now.
+type FrontPageClient struct {
+	httpClient *http.Client
+	cache      atomic.Pointer[frontPageCacheEntry]
+	group      singleflight.Group
+	url        string
+	cacheFor   time.Duration
+}
+
+type frontPageCacheEntry struct {
+	ts      time.Time
+	entries []agedFrontPageEntry
+	partial bool
+}
+
+// NewFrontPageClient creates a FrontPageClient. A nil httpClient uses http.DefaultClient.
+func NewFrontPageClient(httpClient *http.Client, url string, cacheFor time.Duration) *FrontPageClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &FrontPageClient{httpClient, atomic.Pointer[frontPageCacheEntry]{}, singleflight.Group{}, url, cacheFor}
+}
+
+var defaultFrontPageClient = NewFrontPageClient(nil, FrontPageURL, time.Minute) //nolint:gochecknoglobals // shared
+
+// frontPageFetchTimeout bounds a shared fetch, which runs on until it completes even if every caller waiting for it
+// gives up.
+const frontPageFetchTimeout = 30 * time.Second
+
+var (
+	errStatusNotOK                = errors.New("status not ok")
+	errUnexpectedSingleflightType = errors.New("unexpected type from singleflight")
+)
+
+// FetchFrontPageTimes retrieves the current apparent times of articles on HN's front page
+// for detecting second-chance articles (articles pulled from the second-chance pool).
+func FetchFrontPageTimes(ctx context.Context, now time.Time) (EffectiveTimes, error) {
+	fp, err := defaultFrontPageClient.Get(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return fp.Times(), nil
+}
+
+// FetchFrontPage retrieves the current front page using a shared default FrontPageClient.
+func FetchFrontPage(ctx context.Context, now time.Time) (*FrontPage, error) {
+	return defaultFrontPageClient.Get(ctx, now)
+}
+
+// Get returns the front page, with apparent times computed relative to now.
+func (c *FrontPageClient) Get(ctx context.Context, now time.Time) (*FrontPage, error) {
+	entry := c.cache.Load()
+	if entry == nil || time.Since(entry.ts) >= c.cacheFor {
+		var err error
+
+		entry, err = c.fetchShared(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return apparentFrontPage(entry.entries, entry.partial, now), nil
+}
+
+// fetchShared joins the fetch in flight or starts one detached from ctx, so a caller giving up does not fail the
+// others waiting for it.
+func (c *FrontPageClient) fetchShared(ctx context.Context) (*frontPageCacheEntry, error) {
+	ch := c.group.DoChan("frontpage", func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), frontPageFetchTimeout)
+		defer cancel()
+
+		return c.fetch(fetchCtx)
+	})
+
+	var result singleflight.Result
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("singleflight frontpage failed: %w", ctx.Err())
+	case result = <-ch:
+	}
+
+	if result.Err != nil {
+		return nil, fmt.Errorf("singleflight frontpage failed: %w", result.Err)
+	}
+
+	entry, ok := result.Val.(*frontPageCacheEntry)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnexpectedSingleflightType, result.Val)
+	}
+
+	return entry, nil
+}
+
+func (c *FrontPageClient) fetch(ctx context.Context) (interface{}, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s", errStatusNotOK, res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	entries, partial, err := parseFrontPageAges(body)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &frontPageCacheEntry{time.Now(), entries, partial}
+	c.cache.Store(entry)
+
+	return entry, nil
+}
+
+// ErrNoFrontPageEntries means the front page was parsed without finding any articles,
+// which almost certainly means the markup changed.
+var ErrNoFrontPageEntries = errors.New("no front page entries found")
+
+// FrontPageParseError is returned when a front page parser fails.
+type FrontPageParseError struct {
+	Err    error
+	Parser string
+	ID     int
+}
+
+func (e *FrontPageParseError) Error() string {
+	if e.ID != 0 {
+		return fmt.Sprintf("front page %s parser: item %d: %v", e.Parser, e.ID, e.Err)
+	}
+
+	return fmt.Sprintf("front page %s parser: %v", e.Parser, e.Err)
+}
+
+func (e *FrontPageParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseFrontPage parses the HTML of HN's front page, with apparent times computed relative to now.
+// It uses an HTML tokenizer and falls back to a regular expression for just the times if that fails.
+func ParseFrontPage(body []byte, now time.Time) (*FrontPage, error) {
+	entries, partial, err := parseFrontPageAges(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return apparentFrontPage(entries, partial, now), nil
+}
+
+// agedFrontPageEntry is an article with its displayed age, which is accurate to within gap.
+type agedFrontPageEntry struct {
+	FrontPageEntry
+	age time.Duration
+	gap time.Duration
+}
+
+func parseFrontPageAges(body []byte) ([]agedFrontPageEntry, bool, error) {
+	partial := false
+
+	entries, err := parseFrontPageHTML(body)
+	if err != nil {
+		var fallbackErr error
+
+		entries, fallbackErr = parseFrontPageRegex(body)
+		if fallbackErr != nil {
+			return nil, false, errors.Join(err, fallbackErr)
+		}
+
+		partial = true
+	}
+
+	aged := make([]agedFrontPageEntry, 0, len(entries))
+
+	for _, e := range entries {
+		age, gap, err := parseAge(e.age)
+		if err != nil {
+			return nil, false, &FrontPageParseError{err, "age", e.ID}
+		}
+
+		aged = append(aged, agedFrontPageEntry{e.FrontPageEntry, age, gap})
+	}
+
+	return aged, partial, nil
+}
+
+func apparentFrontPage(entries []agedFrontPageEntry, partial bool, now time.Time) *FrontPage {
+	fp := &FrontPage{make([]FrontPageEntry, 0, len(entries)), partial}
+
+	for _, e := range entries {
+		e.ApparentTime = e.Time
+
+		diff := now.Sub(time.Unix(e.Time, 0)) - e.age
+		if diff > e.gap {
+			e.ApparentTime = now.Add(-e.age).Unix()
+		}
+
+		fp.Entries = append(fp.Entries, e.FrontPageEntry)
+	}
+
+	return fp
+}
+
+// ParseFrontPageTimes extracts the apparent times of the articles in the HTML of HN's front page.
+func ParseFrontPageTimes(body []byte, now time.Time) (EffectiveTimes, error) {
+	fp, err := ParseFrontPage(body, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return fp.Times(), nil
+}
+
+type parsedFrontPageEntry struct {
+	age string
+	FrontPageEntry
+}
+
+var (
+	errMissingItemLink = errors.New("age without item link")
+	errUnexpectedHref  = errors.New("unexpected item link")
+	errUnexpectedTitle = errors.New("unexpected age title")
+	errUnexpectedText  = errors.New("unexpected text")
+)
+
+// parseFrontPageHTML walks the rows of the front page. Each article is a <tr class="athing"> with the rank, title,
+// and domain, followed by a row with the score, the age as <span class="age" title="... unix"><a>X ago</a></span>,
+// and the comments link.
+func parseFrontPageHTML(body []byte) ([]parsedFrontPageEntry, error) {
+	const parser = "html"
+
+	var (
+		entries       []parsedFrontPageEntry
+		row           FrontPageEntry
+		inTitleLine   bool
+		awaitingLink  bool
+		awaitingTitle int64
+	)
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return nil, &FrontPageParseError{z.Err(), parser, 0}
+			}
+
+			if len(entries) == 0 {
+				return nil, &FrontPageParseError{ErrNoFrontPageEntries, parser, 0}
+			}
+
+			return entries, nil
+		}
+
+		if tt != html.StartTagToken {
+			continue
+		}
+
+		token := z.Token()
+
+		var err error
+
+		switch {
+		case token.Data == "tr" && hasClass(token, "athing"):
+			row = FrontPageEntry{"", "", 0, 0, 0, 0, 0, 0}
+
+			row.ID, err = strconv.Atoi(attr(token, "id"))
+			if err != nil {
+				err = fmt.Errorf("%w: row id %q", errUnexpectedText, attr(token, "id"))
+			}
+		case token.Data == "span" && hasClass(token, "rank"):
+			row.Rank, err = parseLeadingInt(readText(z, "span"))
+		case token.Data == "span" && hasClass(token, "titleline"):
+			inTitleLine = true
+		case token.Data == "a" && inTitleLine:
+			row.Title = readText(z, "a")
+			inTitleLine = false
+		case token.Data == "span" && hasClass(token, "sitestr"):
+			row.Domain = readText(z, "span")
+		case token.Data == "span" && hasClass(token, "score"):
+			row.Points, err = parseLeadingInt(readText(z, "span"))
+		case token.Data == "span" && hasClass(token, "age"):
+			awaitingTitle, err = parseAgeTitle(attr(token, "title"))
+			awaitingLink = true
+		case token.Data == "a" && awaitingLink:
+			awaitingLink = false
+
+			entries, err = appendAgeLink(entries, row, token, readText(z, "a"), awaitingTitle)
+		case token.Data == "a" && len(entries) > 0:
+			last := &entries[len(entries)-1]
+
+			id, linkErr := parseItemHref(attr(token, "href"))
+			if linkErr == nil && id == last.ID {
+				last.Comments, err = parseComments(readText(z, "a"))
+			}
+		}
+
+		if err != nil {
+			id := row.ID
+			if len(entries) > 0 {
+				id = max(id, entries[len(entries)-1].ID)
+			}
+
+			return nil, &FrontPageParseError{err, parser, id}
+		}
+	}
+}
+
+func appendAgeLink(
+	entries []parsedFrontPageEntry,
+	row FrontPageEntry,
+	token html.Token,
+	text string,
+	ts int64,
+) ([]parsedFrontPageEntry, error) {
+	id, err := parseItemHref(attr(token, "href"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errMissingItemLink, err)
+	}
+
+	age, ok := strings.CutSuffix(strings.TrimSpace(text), " ago")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnexpectedAgeFormat, text)
+	}
+
+	// an age without a preceding row is kept with just its time
+	if row.ID != id {
+		row = FrontPageEntry{"", "", id, 0, 0, 0, 0, 0}
+	}
+
+	row.Time = ts
+
+	return append(entries, parsedFrontPageEntry{age, row}), nil
+}
+
+// readText returns the text up to the end of the current element, which must not contain another of the same tag.
+func readText(z *html.Tokenizer, tag string) string {
+	var sb strings.Builder
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return sb.String()
+		case html.TextToken:
+			sb.Write(z.Text())
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == tag {
+				return strings.Join(strings.Fields(sb.String()), " ")
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.CommentToken, html.DoctypeToken:
+		}
+	}
+}
+
+func parseLeadingInt(v string) (int, error) {
+	fields := strings.Fields(v)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedText, v)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSuffix(fields[0], "."))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedText, v)
+	}
+
+	return n, nil
+}
+
+// parseComments parses comment links like "12 comments", "1 comment", or "discuss".
+func parseComments(v string) (int, error) {
+	if v == "discuss" {
+		return 0, nil
+	}
+
+	return parseLeadingInt(v)
+}
+
+func hasClass(token html.Token, class string) bool {
+	return slices.Contains(strings.Fields(attr(token, "class")), class)
+}
+
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// parseAgeTitle parses the unix time from age titles like "2025-04-18T12:00:00 1744977600".
+func parseAgeTitle(v string) (int64, error) {
+	fields := strings.Fields(v)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedTitle, v)
+	}
+
+	ts, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedTitle, v)
+	}
+
+	return ts, nil
+}
+
+func parseItemHref(v string) (int, error) {
+	idText, ok := strings.CutPrefix(v, "item?id=")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedHref, v)
+	}
+
+	idText, _, _ = strings.Cut(idText, "&")
+
+	id, err := strconv.Atoi(idText)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedHref, v)
+	}
+
+	return id, nil
+}
+
+var frontPageAgeExtractor = regexp.MustCompile(
+	`<span class="age" title="[^"]+\s+(\d+)"><a href="item\?id=(\d+)">([^<]+) ago</a></span>`)
+
+func parseFrontPageRegex(body []byte) ([]parsedFrontPageEntry, error) {
+	const parser = "regex"
+
+	matches := frontPageAgeExtractor.FindAllSubmatch(body, -1)
+	if len(matches) == 0 {
+		return nil, &FrontPageParseError{ErrNoFrontPageEntries, parser, 0}
+	}
+
+	entries := make([]parsedFrontPageEntry, 0, len(matches))
+
+	for _, match := range matches {
+		ts, err := strconv.ParseInt(string(match[1]), 10, 64)
+		if err != nil {
+			return nil, &FrontPageParseError{fmt.Errorf("failed to parse time: %w", err), parser, 0}
+		}
+
+		id, err := strconv.Atoi(string(match[2]))
+		if err != nil {
+			return nil, &FrontPageParseError{fmt.Errorf("failed to parse id: %w", err), parser, 0}
+		}
+
+		entries = append(entries, parsedFrontPageEntry{string(match[3]), FrontPageEntry{"", "", id, 0, 0, 0, ts, 0}})
+	}
+
+	return entries, nil
+}
+
+var errUnexpectedAgeFormat = errors.New("unexpected age format")
+
+var relativeAgeRegex = regexp.MustCompile(
+	`^\s*(\d+)\s+(hour|hours|minute|minutes|day|days)\s*$`)
+
+func parseAge(s string) (time.Duration, time.Duration, error) {
+	m := relativeAgeRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("%w: %q", errUnexpectedAgeFormat, s)
+	}
+
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse age: %w", err)
+	}
+
+	const oneDayDuration = 24 * time.Hour
+
+	switch m[2] {
+	case "minute", "minutes":
+		return time.Duration(n) * time.Minute, 1 * time.Hour, nil
+	case "hour", "hours":
+		return time.Duration(n) * time.Hour, 2 * time.Hour, nil
+	case "day", "days":
+		return time.Duration(n) * oneDayDuration, oneDayDuration, nil
+	default:
+		return 0, 0, fmt.Errorf("%w: %q", errUnexpectedAgeFormat, m[2])
+	}
+}
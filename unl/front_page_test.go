@@ -0,0 +1,257 @@
+package unl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+func TestParseFrontPageTimes(t *testing.T) {
+	t.Parallel()
+
+	// fixtures are saved with ages relative to this time; 43732506 is a second-chance article
+	now := time.Unix(1745021807, 0)
+
+	expected := EffectiveTimes{
+		43732988: 1745021104,
+		43732741: 1745018352,
+		43732506: now.Add(-20 * time.Minute).Unix(),
+		43732047: 1745011924,
+		43731746: 1745009418,
+	}
+
+	tests := []struct {
+		fixture string
+		parser  string
+	}{
+		{"frontpage.html", ""},
+		// the regex requires the class before the title
+		{"frontpage_reordered.html", "regex"},
+		// the tokenizer rejects an age without a timestamp
+		{"frontpage_extra_age.html", "html"},
+	}
+
+	for _, test := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", test.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		times, err := ParseFrontPageTimes(body, now)
+		if err != nil {
+			t.Fatalf("%s: %v", test.fixture, err)
+		}
+
+		if !maps.Equal(times, expected) {
+			t.Fatalf("%s: expected %v, got %v", test.fixture, expected, times)
+		}
+
+		if test.parser == "" {
+			continue
+		}
+
+		var failing func([]byte) ([]parsedFrontPageEntry, error)
+		if test.parser == "html" {
+			failing = parseFrontPageHTML
+		} else {
+			failing = parseFrontPageRegex
+		}
+
+		_, err = failing(body)
+
+		var parseErr *FrontPageParseError
+		if !errors.As(err, &parseErr) || parseErr.Parser != test.parser {
+			t.Fatalf("%s: expected the %s parser to fail, got %v", test.fixture, test.parser, err)
+		}
+	}
+}
+
+func TestParseFrontPageTimes_Changed(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "frontpage_changed.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ParseFrontPageTimes(body, time.Unix(1745021807, 0))
+	if !errors.Is(err, ErrNoFrontPageEntries) {
+		t.Fatalf("expected no entries error, got %v", err)
+	}
+
+	var parseErr *FrontPageParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a FrontPageParseError, got %T", err)
+	}
+}
+
+func TestParseFrontPage(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1745021807, 0)
+
+	expected := []FrontPageEntry{
+		{
+			"Yale sells up to $6B of its PE portfolio amid federal funding challenge", "secondariesinvestor.com",
+			43732988, 1, 22, 0, 1745021104, 1745021104,
+		},
+		{
+			"UI tip: maybe don't round percentages to 0% or 100%", "evanhahn.com",
+			43732741, 2, 35, 12, 1745018352, 1745018352,
+		},
+		{
+			"OpenAI's new reasoning AI models hallucinate more", "techcrunch.com",
+			43732506, 3, 32, 23, 1745016192, now.Add(-20 * time.Minute).Unix(),
+		},
+		{
+			"I passionately hate hype, especially the AI hype", "unixdigest.com",
+			43732047, 4, 26, 4, 1745011924, 1745011924,
+		},
+		{
+			"Hextraction, a free and open source board game", "playhextraction.com",
+			43731746, 5, 28, 5, 1745009418, 1745009418,
+		},
+	}
+
+	for _, fixture := range []string{"frontpage.html", "frontpage_reordered.html"} {
+		body, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fp, err := ParseFrontPage(body, now)
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+
+		if fp.Partial || !slices.Equal(fp.Entries, expected) {
+			t.Fatalf("%s: expected %v, got %v (partial %v)", fixture, expected, fp.Entries, fp.Partial)
+		}
+
+		entry := fp.ByID()[43732506]
+		if entry.Rank != 3 {
+			t.Fatalf("%s: expected rank 3 by ID", fixture)
+		}
+
+		var item hn.Item
+		item.Score = 32
+		item.Descendants = 25
+
+		if !slices.Equal(entry.Diverges(&item), []string{"descendants"}) {
+			t.Fatalf("%s: expected only descendants to diverge", fixture)
+		}
+	}
+
+	body, err := os.ReadFile(filepath.Join("testdata", "frontpage_extra_age.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := ParseFrontPage(body, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fp.Partial || fp.Entries[0].Title != "" || fp.Entries[0].ID != 43732988 {
+		t.Fatalf("expected only IDs and times from the fallback parser, got %v", fp.Entries)
+	}
+}
+
+func TestFrontPageClient(t *testing.T) {
+	t.Parallel()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "frontpage.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests atomic.Int64
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		started <- struct{}{}
+
+		select {
+		case <-release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		var resp http.Response
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		return &resp, nil
+	})}
+
+	client := NewFrontPageClient(httpClient, FrontPageURL, time.Hour)
+	now := time.Unix(1745021807, 0)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	first := make(chan error, 1)
+
+	go func() {
+		_, err := client.Get(ctx, now)
+		first <- err
+	}()
+
+	<-started
+
+	second := make(chan *FrontPage, 1)
+
+	go func() {
+		fp, err := client.Get(t.Context(), now.Add(time.Hour))
+		if err != nil {
+			t.Error(err)
+		}
+
+		second <- fp
+	}()
+
+	// the caller that started the fetch gives up without failing the other
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the first caller to be canceled, got %v", err)
+	}
+
+	close(release)
+
+	later := <-second
+	if later == nil {
+		t.FailNow()
+	}
+
+	// the cached ages give each caller apparent times relative to its own now
+	fp, err := client.Get(t.Context(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const secondChance = 43732506
+
+	if actual := fp.Times()[secondChance]; actual != now.Add(-20*time.Minute).Unix() {
+		t.Fatalf("expected an apparent time 20 minutes before now, got %d", actual)
+	}
+
+	if actual := later.Times()[secondChance]; actual != now.Add(40*time.Minute).Unix() {
+		t.Fatalf("expected an apparent time 20 minutes before the later now, got %d", actual)
+	}
+
+	if requests.Load() != 1 {
+		t.Fatalf("expected 1 shared request, got %d", requests.Load())
+	}
+}
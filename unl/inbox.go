@@ -0,0 +1,135 @@
+package unl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+var errUnknownUser = errors.New("unknown user")
+
+// Reply is a reply by someone else to an item submitted by User.
+type Reply struct {
+	Item *hn.Item
+	User string
+}
+
+// GetReplies returns the replies to the most recent submitted items of each user, newest first. Only the direct
+// replies to each user's latest submitted items are checked, and replies by the user themselves are skipped.
+func GetReplies(ctx context.Context, client hn.API, users []string, submitted int) ([]Reply, error) {
+	var replies []Reply
+
+	for _, username := range users {
+		user, err := client.GetUser(ctx, username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %w", username, err)
+		}
+
+		if user == nil {
+			return nil, fmt.Errorf("%w: %s", errUnknownUser, username)
+		}
+
+		ids := user.Submitted
+		if submitted > 0 && len(ids) > submitted {
+			ids = ids[:submitted]
+		}
+
+		items, err := client.GetItems(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get items submitted by %s: %w", username, err)
+		}
+
+		kids, err := client.GetKids(ctx, items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get replies to %s: %w", username, err)
+		}
+
+		for _, kid := range kids {
+			if kid.Type == hn.NullBody || kid.Dead || kid.Deleted || kid.By == username {
+				continue
+			}
+
+			replies = append(replies, Reply{kid, username})
+		}
+	}
+
+	sort.Slice(replies, func(i, j int) bool {
+		a, b := replies[i].Item, replies[j].Item
+		if a.Time == b.Time {
+			return a.ID > b.ID
+		}
+
+		return a.Time > b.Time
+	})
+
+	return replies, nil
+}
+
+// UnseenReplies returns the replies not yet marked seen for their user, in the same order.
+func (s *Store) UnseenReplies(ctx context.Context, replies []Reply) ([]Reply, error) {
+	var unseen []Reply
+
+	for _, reply := range replies {
+		seen, err := s.replySeen(ctx, reply)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen {
+			unseen = append(unseen, reply)
+		}
+	}
+
+	return unseen, nil
+}
+
+func (s *Store) replySeen(ctx context.Context, reply Reply) (_ bool, err error) {
+	rows, err := s.queryContext(
+		ctx, "SELECT 1 FROM inbox_seen WHERE user = ? AND ID = ?", reply.User, reply.Item.ID)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() { err = errors.Join(err, rows.Close()) }()
+
+	seen := rows.Next()
+
+	err = rows.Err()
+	if err != nil {
+		return false, fmt.Errorf("inbox seen rows err: %w", err)
+	}
+
+	return seen, nil
+}
+
+// MarkRepliesSeen records the replies as seen for their user, so later UnseenReplies calls skip them.
+func (s *Store) MarkRepliesSeen(ctx context.Context, replies []Reply) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	for _, reply := range replies {
+		_, err = tx.ExecContext(
+			ctx, "INSERT OR IGNORE INTO inbox_seen(user, ID) VALUES (?, ?)", reply.User, reply.Item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert inbox seen: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package unl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+)
+
+func TestGetReplies(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":1,"kids":[4,5]},
+		{"id":2,"type":"comment","by":"a","time":2,"kids":[6]},
+		{"id":3,"type":"comment","by":"b","time":3,"kids":[7]},
+		{"id":4,"type":"comment","by":"x","parent":1,"time":10},
+		{"id":5,"type":"comment","by":"a","parent":1,"time":11},
+		{"id":6,"type":"comment","parent":2,"time":12,"deleted":true},
+		{"id":7,"type":"comment","by":"a","parent":3,"time":20}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	var a, b hn.User
+	a.ID, a.Submitted = "a", []int{2, 1}
+	b.ID, b.Submitted = "b", []int{3}
+
+	client := hntest.NewFakeClient(all, hntest.WithUsers(&a, &b))
+
+	replies, err := GetReplies(t.Context(), client, []string{"a", "b"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a's own reply and the deleted reply are skipped, and a's reply to b counts for b
+	expected := []Reply{{all[7], "b"}, {all[4], "a"}}
+	if diff := cmp.Diff(expected, replies); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	replies, err = GetReplies(t.Context(), client, []string{"a"}, 1)
+	if err != nil || len(replies) != 0 {
+		t.Fatalf("expected only the latest submitted item to be checked, got %v: %v", replies, err)
+	}
+
+	_, err = GetReplies(t.Context(), client, []string{"c"}, 0)
+	if err == nil {
+		t.Fatal("expected error for an unknown user")
+	}
+}
@@ -0,0 +1,58 @@
+package unl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var errUnexpectedItemRef = errors.New("expected an item ID or news.ycombinator.com item URL")
+
+// ParseItemRef returns the ID of the item v refers to: an ID, or a site URL as pasted from a browser, such as
+// "https://news.ycombinator.com/item?id=123", with or without the scheme, or the relative "item?id=123". The
+// "context" link of a comment points at its place in the thread with an anchor like "item?id=123#456", so a numeric
+// anchor takes precedence as the ID of the comment. Reply links ("reply?id=456") refer to the comment replied to.
+func ParseItemRef(v string) (int, error) {
+	v = strings.TrimSpace(v)
+
+	if id, err := strconv.Atoi(v); err == nil {
+		if id <= 0 {
+			return 0, fmt.Errorf("%w: %q", errUnexpectedItemRef, v)
+		}
+
+		return id, nil
+	}
+
+	// a URL copied without its scheme would otherwise parse as a path starting with the host
+	if lower := strings.ToLower(v); strings.HasPrefix(lower, "news.ycombinator.com/") ||
+		strings.HasPrefix(lower, "www.news.ycombinator.com/") {
+		v = "https://" + v
+	}
+
+	u, err := url.Parse(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedItemRef, v)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	page := strings.TrimPrefix(u.Path, "/")
+
+	if (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") ||
+		(host != "" && host != "news.ycombinator.com") ||
+		(page != "item" && page != "reply") {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedItemRef, v)
+	}
+
+	if anchor, err := strconv.Atoi(u.Fragment); err == nil && anchor > 0 {
+		return anchor, nil
+	}
+
+	id, err := strconv.Atoi(u.Query().Get("id"))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: %q", errUnexpectedItemRef, v)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,65 @@
+package unl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+func TestParseItemRef(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		v        string
+		expected int
+	}{
+		{"43740065", 43740065},
+		{" 43740065\n", 43740065},
+		{"https://news.ycombinator.com/item?id=43740065", 43740065},
+		{"http://www.news.ycombinator.com/item?id=43740065", 43740065},
+		{"news.ycombinator.com/item?id=43740065", 43740065},
+		{"//news.ycombinator.com/item?id=43740065", 43740065},
+		{"item?id=43740065", 43740065},
+		{"/item?id=43740065&p=2", 43740065},
+		{"https://news.ycombinator.com/item?id=43740065#43740647", 43740647},
+		{"https://news.ycombinator.com/item?id=43740065#up_43740647", 43740065},
+		{"https://news.ycombinator.com/reply?id=43740647&goto=item%3Fid%3D43740065", 43740647},
+		{"https://news.ycombinator.com/user?id=pg", 0},
+		{"https://example.com/item?id=43740065", 0},
+		{"ftp://news.ycombinator.com/item?id=43740065", 0},
+		{"https://news.ycombinator.com/item?id=abc", 0},
+		{"https://news.ycombinator.com/item?id=-1", 0},
+		{"0", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		id, err := ParseItemRef(test.v)
+		if test.expected == 0 {
+			if !errors.Is(err, errUnexpectedItemRef) {
+				t.Errorf("%q: expected an error, got %d, %v", test.v, id, err)
+			}
+
+			continue
+		}
+
+		if err != nil || id != test.expected {
+			t.Errorf("%q: expected %d, got %d, %v", test.v, test.expected, id, err)
+		}
+	}
+
+	// the links written by the exporters resolve back to their items
+	parent := 43740065
+
+	var comment hn.Item
+	comment.Parent = &parent
+	comment.ID = 43740647
+
+	for _, link := range []string{hn.ItemURL(comment.ID), hn.ContextURL(&comment)} {
+		id, err := ParseItemRef(link)
+		if err != nil || id != comment.ID {
+			t.Fatalf("expected %s to resolve to %d, got %d, %v", link, comment.ID, id, err)
+		}
+	}
+}
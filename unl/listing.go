@@ -0,0 +1,213 @@
+package unl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+	"golang.org/x/net/html"
+)
+
+// SiteURL is the base URL of the site listings, which are addressed by path such as "newest" or "ask".
+const SiteURL = FrontPageURL + "/"
+
+// Listings are the site listings supported by ListingClient. Unlike the API lists these page past 500 stories.
+var Listings = []string{ //nolint:gochecknoglobals // constant
+	"news", "newest", "front", "best", "active", "ask", "show", "shownew", "jobs", "pool",
+}
+
+// ErrUnknownListing is returned for a listing that is not one of Listings.
+var ErrUnknownListing = errors.New("unknown listing")
+
+var (
+	errUnexpectedMoreLink = errors.New("unexpected more link")
+	errInvalidProxy       = errors.New("invalid proxy")
+)
+
+// ListingClient scrapes the ordered IDs of site listings by following their "More" links.
+type ListingClient struct {
+	getter core.Getter[string, io.ReadCloser]
+}
+
+// NewListingClient creates a ListingClient that fetches site paths from getter.
+// A nil getter fetches from SiteURL with http.DefaultClient.
+func NewListingClient(getter core.Getter[string, io.ReadCloser]) *ListingClient {
+	if getter == nil {
+		getter = core.NewBaseGetter(http.DefaultClient, SiteURL)
+	}
+
+	return &ListingClient{getter}
+}
+
+// GetIDs returns the IDs on up to pages pages of listing in site order. Pages are fetched one at a time since each
+// page links to the next; IDs that shift onto a later page while paging are only returned once.
+func (c *ListingClient) GetIDs(ctx context.Context, listing string, pages int) ([]int, error) {
+	if !slices.Contains(Listings, listing) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownListing, listing)
+	}
+
+	var ids []int
+	seen := make(map[int]struct{})
+	path := listing
+
+	for page := 0; page < pages && path != ""; page++ {
+		body, err := c.fetch(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageIDs []int
+
+		pageIDs, path, err = ParseListingPage(listing, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s page %d: %w", listing, page+1, err)
+		}
+
+		for _, id := range pageIDs {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+func (c *ListingClient) fetch(ctx context.Context, path string) ([]byte, error) {
+	r, err := c.getter.Get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", path, err)
+	}
+
+	defer func() { _ = r.Close() }()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return body, nil
+}
+
+// NewSiteHTTPClient returns an HTTP client for scraping the site through an HTTP or SOCKS5 proxy URL.
+// An empty proxy returns http.DefaultClient, which uses the proxy from the environment if any.
+func NewSiteHTTPClient(proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return http.DefaultClient, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" || !slices.Contains([]string{"http", "https", "socks5"}, u.Scheme) {
+		return nil, fmt.Errorf("%w: %q", errInvalidProxy, proxy)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // standard library type
+	transport.Proxy = http.ProxyURL(u)
+
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
+
+// NewPoliteGetter wraps getter so requests are made one at a time, each starting delay plus a random duration up to
+// jitter after the previous one completed. Use it for the site, which throttles clients that request too quickly.
+func NewPoliteGetter(
+	getter core.Getter[string, io.ReadCloser],
+	delay time.Duration,
+	jitter time.Duration,
+) core.Getter[string, io.ReadCloser] {
+	return &politeGetter{time.Time{}, getter, sync.Mutex{}, delay, jitter}
+}
+
+type politeGetter struct {
+	last   time.Time
+	inner  core.Getter[string, io.ReadCloser]
+	mu     sync.Mutex
+	delay  time.Duration
+	jitter time.Duration
+}
+
+func (g *politeGetter) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.last.IsZero() {
+		wait := g.delay
+		if g.jitter > 0 {
+			wait += rand.N(g.jitter) //nolint:gosec // G404 jitter needs no cryptographic randomness
+		}
+
+		timer := time.NewTimer(time.Until(g.last.Add(wait)))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to wait for %s: %w", path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	defer func() { g.last = time.Now() }()
+
+	return g.inner.Get(ctx, path)
+}
+
+// ParseListingPage returns the IDs of the <tr class="athing"> rows of a listing page in order along with the path of
+// the next page from its "More" link, or "" if it is the last page. Relative links like "?p=2" resolve to listing.
+func ParseListingPage(listing string, body []byte) ([]int, string, error) {
+	var ids []int
+	var next string
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if !errors.Is(z.Err(), io.EOF) {
+				return nil, "", fmt.Errorf("failed to tokenize: %w", z.Err())
+			}
+
+			return ids, next, nil
+		}
+
+		if tt != html.StartTagToken {
+			continue
+		}
+
+		token := z.Token()
+
+		switch {
+		case token.Data == "tr" && hasClass(token, "athing"):
+			id, err := strconv.Atoi(attr(token, "id"))
+			if err != nil || id <= 0 {
+				return nil, "", fmt.Errorf("%w: row id %q", errUnexpectedText, attr(token, "id"))
+			}
+
+			ids = append(ids, id)
+		case token.Data == "a" && hasClass(token, "morelink"):
+			href := attr(token, "href")
+
+			switch {
+			case strings.HasPrefix(href, "?"):
+				next = listing + href
+			case href == "" || strings.Contains(href, ":") || strings.HasPrefix(href, "/"):
+				return nil, "", fmt.Errorf("%w: %q", errUnexpectedMoreLink, href)
+			default:
+				next = href
+			}
+		}
+	}
+}
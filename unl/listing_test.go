@@ -0,0 +1,134 @@
+package unl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/core"
+)
+
+type pathGetter map[string]string
+
+func (g pathGetter) Get(_ context.Context, path string) (io.ReadCloser, error) {
+	v, ok := g[path]
+	if !ok {
+		return nil, &core.GetterError{Path: path, Code: http.StatusNotFound}
+	}
+
+	return io.NopCloser(strings.NewReader(v)), nil
+}
+
+func TestListingClient(t *testing.T) {
+	t.Parallel()
+
+	page1, err := os.ReadFile(filepath.Join("testdata", "frontpage.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 43731746 shifted down from the first page between requests
+	getter := pathGetter{
+		"news": string(page1),
+		"news?p=2": `<table><tr class='athing submission' id='43731746'></tr>` +
+			`<tr class='athing submission' id='43731500'></tr>` +
+			`<tr><td><a href='news?p=3' class='morelink' rel='next'>More</a></td></tr></table>`,
+		"news?p=3": `<table><tr class='athing submission' id='43731000'></tr></table>`,
+	}
+
+	client := NewListingClient(getter)
+
+	ids, err := client.GetIDs(t.Context(), "news", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{43732988, 43732741, 43732506, 43732047, 43731746, 43731500, 43731000}
+	if !slices.Equal(ids, expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+
+	ids, err = client.GetIDs(t.Context(), "news", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(ids, expected[:5]) {
+		t.Fatalf("expected only the first page, got %v", ids)
+	}
+
+	_, err = client.GetIDs(t.Context(), "item", 1)
+	if !errors.Is(err, ErrUnknownListing) {
+		t.Fatalf("expected unknown listing, got %v", err)
+	}
+}
+
+func TestParseListingPage(t *testing.T) {
+	t.Parallel()
+
+	ids, next, err := ParseListingPage("newest",
+		[]byte(`<tr class="athing" id="3"></tr><tr class="athing" id="2"></tr>`+
+			`<a href="newest?next=2&amp;n=31" class="morelink">More</a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(ids, []int{3, 2}) || next != "newest?next=2&n=31" {
+		t.Fatalf("unexpected page %v %q", ids, next)
+	}
+
+	_, _, err = ParseListingPage("news", []byte(`<a href="https://example.com/" class="morelink">More</a>`))
+	if !errors.Is(err, errUnexpectedMoreLink) {
+		t.Fatalf("expected unexpected more link, got %v", err)
+	}
+}
+
+func TestPoliteGetter(t *testing.T) {
+	t.Parallel()
+
+	const delay = 20 * time.Millisecond
+
+	getter := NewPoliteGetter(pathGetter{"news": ""}, delay, delay)
+
+	start := time.Now()
+
+	for range 3 {
+		r, err := getter.Get(t.Context(), "news")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = r.Close()
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < 2*delay {
+		t.Fatalf("expected at least %s between three requests, took %s", 2*delay, elapsed)
+	}
+}
+
+func TestNewSiteHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	client, err := NewSiteHTTPClient("")
+	if err != nil || client != http.DefaultClient {
+		t.Fatalf("expected the default client without a proxy, got %v", err)
+	}
+
+	_, err = NewSiteHTTPClient("socks5://127.0.0.1:1080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewSiteHTTPClient("127.0.0.1:1080")
+	if !errors.Is(err, errInvalidProxy) {
+		t.Fatalf("expected invalid proxy, got %v", err)
+	}
+}
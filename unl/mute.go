@@ -0,0 +1,83 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Mute records the items as muted, to be hidden with WithMuted. Muting an item again keeps the first time. Snapshots
+// are cleared since they may include the muted threads.
+func (s *Store) Mute(ctx context.Context, ids []int, mutedAt time.Time) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	for _, id := range ids {
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO mute(ID, muted) VALUES (?, ?)", id, mutedAt.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to insert mute: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM active_snapshot")
+	if err != nil {
+		return fmt.Errorf("failed to clear snapshots: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Muted returns the muted item IDs, most recently muted first.
+func (s *Store) Muted(ctx context.Context) (_ []int, err error) {
+	rows, err := s.queryContext(ctx, "SELECT ID FROM mute ORDER BY muted DESC, ID DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	var result []int
+
+	for rows.Next() {
+		var id int
+
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("mute scan: %w", err)
+		}
+
+		result = append(result, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("mute rows err: %w", err)
+	}
+
+	return result, nil
+}
+
+// ClearMuted unmutes all items, clearing snapshots that left them out.
+func (s *Store) ClearMuted(ctx context.Context) error {
+	err := s.execContext(ctx, "DELETE FROM mute")
+	if err != nil {
+		return err
+	}
+
+	return s.ClearActiveSnapshots(ctx)
+}
@@ -0,0 +1,105 @@
+package unl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"strings"
+)
+
+const (
+	PagerAuto   = "auto"
+	PagerAlways = "always"
+	PagerNever  = "never"
+)
+
+// ErrInvalidPagerMode is returned for a pager mode other than PagerAuto, PagerAlways, and PagerNever.
+var ErrInvalidPagerMode = errors.New("invalid pager mode")
+
+// Pager pipes output through $PAGER like git does: when it is taller than the terminal with PagerAuto, or whenever
+// stdout is a terminal with PagerAlways. Without a terminal, height is 0 and output is written directly.
+type Pager struct {
+	getenv func(string) string
+	out    io.Writer
+	mode   string
+	height int
+}
+
+func NewPager(mode string, height int, getenv func(string) string, out io.Writer) (*Pager, error) {
+	if mode != PagerAuto && mode != PagerAlways && mode != PagerNever {
+		return nil, fmt.Errorf("%w: must be %s, %s, or %s", ErrInvalidPagerMode, PagerAuto, PagerAlways, PagerNever)
+	}
+
+	return &Pager{getenv, out, mode, height}, nil
+}
+
+// command returns the pager command from $PAGER, defaulting to less, or nil if $PAGER is "cat". Arguments are split
+// on spaces rather than run through a shell.
+func (p *Pager) command() []string {
+	fields := strings.Fields(p.getenv("PAGER"))
+	if len(fields) == 0 {
+		return []string{"less"}
+	}
+
+	if fields[0] == "cat" {
+		return nil
+	}
+
+	return fields
+}
+
+// Write writes the output of wt, through the pager if it should be paged.
+func (p *Pager) Write(ctx context.Context, wt io.WriterTo) error {
+	if p.mode == PagerNever || p.height <= 0 {
+		return writeTo(p.out, wt)
+	}
+
+	var buf bytes.Buffer
+
+	_, err := wt.WriteTo(&buf)
+	if err != nil {
+		return fmt.Errorf("failed to write to writer: %w", err)
+	}
+
+	command := p.command()
+	if command == nil || (p.mode == PagerAuto && bytes.Count(buf.Bytes(), []byte("\n")) < p.height) {
+		return writeTo(p.out, &buf)
+	}
+
+	cmd := osexec.CommandContext(ctx, command[0], command[1:]...) //nolint:gosec // G204 the user's own $PAGER
+	cmd.Stdin = &buf
+	cmd.Stdout = p.out
+	cmd.Stderr = os.Stderr
+
+	// like git, keep color (R), exit if the output fits after all (F), and leave it on the screen (X)
+	cmd.Env = os.Environ()
+	if p.getenv("LESS") == "" {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+
+	err = cmd.Run()
+
+	var execErr *osexec.Error
+	if errors.As(err, &execErr) {
+		return writeTo(p.out, &buf)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to run pager %s: %w", command[0], err)
+	}
+
+	return nil
+}
+
+func writeTo(w io.Writer, wt io.WriterTo) error {
+	_, err := wt.WriteTo(w)
+	if err != nil {
+		return fmt.Errorf("failed to write to writer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package unl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPager("sometimes", 0, nil, nil)
+	if !errors.Is(err, ErrInvalidPagerMode) {
+		t.Fatalf("expected invalid pager mode, got %v", err)
+	}
+
+	write := func(mode string, height int, pagerEnv string, text string) string {
+		var buf bytes.Buffer
+
+		env := map[string]string{"PAGER": pagerEnv}
+
+		p, err := NewPager(mode, height, func(key string) string { return env[key] }, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = p.Write(t.Context(), strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return buf.String()
+	}
+
+	tests := []struct {
+		mode     string
+		pager    string
+		text     string
+		expected string
+		height   int
+	}{
+		{PagerAuto, "tr a-z A-Z", "a\nb\n", "A\nB\n", 2},
+		{PagerAuto, "tr a-z A-Z", "a\n", "a\n", 2},
+		{PagerAuto, "tr a-z A-Z", "a\nb\n", "a\nb\n", 0},
+		{PagerAlways, "tr a-z A-Z", "a\n", "A\n", 2},
+		{PagerNever, "tr a-z A-Z", "a\nb\n", "a\nb\n", 2},
+		{PagerAlways, "cat", "a\n", "a\n", 2},
+		{PagerAlways, "unl-missing-pager", "a\n", "a\n", 2},
+	}
+
+	for _, test := range tests {
+		actual := write(test.mode, test.height, test.pager, test.text)
+		if actual != test.expected {
+			t.Errorf("%s with %q and height %d: expected %q, got %q",
+				test.mode, test.pager, test.height, test.expected, actual)
+		}
+	}
+}
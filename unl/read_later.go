@@ -0,0 +1,230 @@
+package unl
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ReadLater saves links to a read-later service.
+type ReadLater interface {
+	Add(ctx context.Context, link string, title string) error
+}
+
+// ReadLaterServices are the services supported by NewReadLater.
+var ReadLaterServices = []string{"pocket", "instapaper", "omnivore"} //nolint:gochecknoglobals // constant
+
+var (
+	errUnknownReadLater      = errors.New("unknown read-later service")
+	errMissingReadLaterCreds = errors.New("missing read-later credentials")
+	errReadLaterStatus       = errors.New("unexpected read-later response")
+	errReadLaterSave         = errors.New("read-later service failed to save")
+)
+
+// NewReadLater creates the named service with credentials from getenv:
+//   - pocket: POCKET_CONSUMER_KEY and POCKET_ACCESS_TOKEN
+//   - instapaper: INSTAPAPER_USERNAME and INSTAPAPER_PASSWORD
+//   - omnivore: OMNIVORE_API_KEY
+//
+// A nil httpClient uses http.DefaultClient.
+func NewReadLater(name string, httpClient *http.Client, getenv func(string) string) (ReadLater, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	env := func(keys ...string) ([]string, error) {
+		values := make([]string, len(keys))
+
+		for i, key := range keys {
+			values[i] = getenv(key)
+			if values[i] == "" {
+				return nil, fmt.Errorf("%w: %s requires %s", errMissingReadLaterCreds, name, strings.Join(keys, " and "))
+			}
+		}
+
+		return values, nil
+	}
+
+	switch name {
+	case "pocket":
+		v, err := env("POCKET_CONSUMER_KEY", "POCKET_ACCESS_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+
+		return &pocket{httpClient, "https://getpocket.com/v3/add", v[0], v[1]}, nil
+	case "instapaper":
+		v, err := env("INSTAPAPER_USERNAME", "INSTAPAPER_PASSWORD")
+		if err != nil {
+			return nil, err
+		}
+
+		return &instapaper{httpClient, "https://www.instapaper.com/api/add", v[0], v[1]}, nil
+	case "omnivore":
+		v, err := env("OMNIVORE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+
+		return &omnivore{httpClient, "https://api-prod.omnivore.app/api/graphql", v[0]}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownReadLater, name)
+	}
+}
+
+type pocket struct {
+	httpClient  *http.Client
+	endpoint    string
+	consumerKey string
+	accessToken string
+}
+
+func (p *pocket) Add(ctx context.Context, link string, title string) error {
+	body, err := json.Marshal(map[string]string{
+		"url": link, "title": title, "consumer_key": p.consumerKey, "access_token": p.accessToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Accept", "application/json")
+
+	return doReadLater(p.httpClient, req, nil)
+}
+
+type instapaper struct {
+	httpClient *http.Client
+	endpoint   string
+	username   string
+	password   string
+}
+
+func (p *instapaper) Add(ctx context.Context, link string, title string) error {
+	form := url.Values{"url": {link}, "title": {title}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.username, p.password)
+
+	return doReadLater(p.httpClient, req, nil)
+}
+
+type omnivore struct {
+	httpClient *http.Client
+	endpoint   string
+	apiKey     string
+}
+
+const omnivoreSaveURL = `mutation SaveUrl($input: SaveUrlInput!) {
+  saveUrl(input: $input) { ... on SaveSuccess { url } ... on SaveError { errorCodes message } }
+}`
+
+// omnivoreResponse is the GraphQL response to omnivoreSaveURL, which fails with a 200 status and either a SaveError
+// result or top-level errors.
+type omnivoreResponse struct {
+	Data struct {
+		SaveURL struct {
+			Message    string   `json:"message"`
+			ErrorCodes []string `json:"errorCodes"`
+		} `json:"saveUrl"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (p *omnivore) Add(ctx context.Context, link string, _ string) error {
+	body, err := json.Marshal(map[string]any{
+		"query": omnivoreSaveURL,
+		"variables": map[string]any{
+			"input": map[string]string{"url": link, "source": "api", "clientRequestId": newRequestID()},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", p.apiKey)
+
+	var result omnivoreResponse
+
+	err = doReadLater(p.httpClient, req, &result)
+	if err != nil {
+		return err
+	}
+
+	saveURL := result.Data.SaveURL
+	if len(saveURL.ErrorCodes) > 0 {
+		return fmt.Errorf("%w: %s: %s", errReadLaterSave, strings.Join(saveURL.ErrorCodes, ", "), saveURL.Message)
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%w: %s", errReadLaterSave, result.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// newRequestID returns a random version 4 UUID.
+func newRequestID() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// doReadLater executes req and checks the status, decoding a successful JSON response into result unless it is nil.
+func doReadLater(httpClient *http.Client, req *http.Request, result any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return fmt.Errorf("%w: %s returned %d", errReadLaterStatus, req.URL.Host, resp.StatusCode)
+	}
+
+	if result == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("%w: %s returned invalid JSON: %w", errReadLaterStatus, req.URL.Host, err)
+	}
+
+	return nil
+}
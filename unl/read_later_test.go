@@ -0,0 +1,122 @@
+package unl
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReadLater(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		"POCKET_CONSUMER_KEY": "key",
+		"POCKET_ACCESS_TOKEN": "token",
+		"INSTAPAPER_USERNAME": "user",
+		"INSTAPAPER_PASSWORD": "pass",
+		"OMNIVORE_API_KEY":    "api",
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		contains string
+	}{
+		{"pocket", "getpocket.com", `"access_token":"token"`},
+		{"instapaper", "www.instapaper.com", "url=https%3A%2F%2Fexample.com"},
+		{"omnivore", "api-prod.omnivore.app", `"url":"https://example.com"`},
+	}
+
+	for _, test := range tests {
+		var body string
+
+		status := http.StatusOK
+		httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != test.host {
+				t.Errorf("%s: unexpected host %s", test.name, req.URL.Host)
+			}
+
+			b, _ := io.ReadAll(req.Body)
+			body = string(b)
+
+			var resp http.Response
+			resp.StatusCode = status
+			resp.Body = io.NopCloser(strings.NewReader("{}"))
+
+			return &resp, nil
+		})}
+
+		readLater, err := NewReadLater(test.name, httpClient, func(key string) string { return env[key] })
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = readLater.Add(t.Context(), "https://example.com", "Example")
+		if err != nil || !strings.Contains(body, test.contains) {
+			t.Fatalf("%s: expected a request containing %s, got %q: %v", test.name, test.contains, body, err)
+		}
+
+		status = http.StatusForbidden
+
+		err = readLater.Add(t.Context(), "https://example.com", "Example")
+		if !errors.Is(err, errReadLaterStatus) {
+			t.Fatalf("%s: expected a status error, got %v", test.name, err)
+		}
+
+		_, err = NewReadLater(test.name, httpClient, func(string) string { return "" })
+		if !errors.Is(err, errMissingReadLaterCreds) {
+			t.Fatalf("%s: expected missing credentials, got %v", test.name, err)
+		}
+	}
+
+	_, err := NewReadLater("evernote", nil, func(key string) string { return env[key] })
+	if !errors.Is(err, errUnknownReadLater) {
+		t.Fatalf("expected unknown service, got %v", err)
+	}
+}
+
+func TestReadLaterOmnivoreSaveError(t *testing.T) {
+	t.Parallel()
+
+	response := `{"data":{"saveUrl":{"errorCodes":["UNAUTHORIZED"],"message":"invalid API key"}}}`
+	httpClient := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		var resp http.Response
+		resp.StatusCode = http.StatusOK
+		resp.Body = io.NopCloser(strings.NewReader(response))
+
+		return &resp, nil
+	})}
+
+	readLater, err := NewReadLater("omnivore", httpClient, func(string) string { return "api" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GraphQL reports failures with a 200 status
+	err = readLater.Add(t.Context(), "https://example.com", "Example")
+	if !errors.Is(err, errReadLaterSave) || !strings.Contains(err.Error(), "UNAUTHORIZED") {
+		t.Fatalf("expected a save error, got %v", err)
+	}
+
+	response = `{"errors":[{"message":"bad request"}]}`
+
+	err = readLater.Add(t.Context(), "https://example.com", "Example")
+	if !errors.Is(err, errReadLaterSave) {
+		t.Fatalf("expected a save error for GraphQL errors, got %v", err)
+	}
+
+	response = `{"data":{"saveUrl":{"url":"https://omnivore.app/me/example"}}}`
+
+	err = readLater.Add(t.Context(), "https://example.com", "Example")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
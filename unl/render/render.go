@@ -0,0 +1,511 @@
+// Package render renders HN threads as the aligned text shown by unl: a column of links, authors, and ages followed
+// by an indented tree of titles and text. Tools that render with the same options produce identical text.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl"
+)
+
+// Style holds the glyphs of the output. The tree itself is drawn with ASCII in every style.
+type Style struct {
+	// SecondChance is written above items whose time was adjusted for the second-chance pool.
+	SecondChance string
+	// Ellipsis ends text truncated to fit the width.
+	Ellipsis string
+	// ActiveMarker starts the text of active items, so they stand out without relying on color. It is omitted
+	// when every line is active, as in lists where the highlight carries no information.
+	ActiveMarker string
+	// NewMarker starts the text of items that appeared after Options.NewAfter.
+	NewMarker string
+	// Orphaned is written above threads whose root is a reply, shown because the story above it is dead or deleted.
+	Orphaned string
+	// Truncated is written above threads cut short by Options.Truncated, formatted with the count of omitted items.
+	Truncated string
+}
+
+// Theme holds the ANSI escape sequences that color each part of a line. Each replaces the color before it, so
+// sequences should set the foreground rather than add attributes.
+type Theme struct {
+	// Link colors the link and author, and the preview text of self-posts.
+	Link string
+	// Active colors the ages of active items.
+	Active string
+	// Inactive colors the ages of inactive items.
+	Inactive string
+	// Tree colors the tree and the markers written above items.
+	Tree string
+	// Root colors the titles of roots.
+	Root string
+	// Text colors the text of replies.
+	Text string
+	// New colors the marker of items that appeared after Options.NewAfter.
+	New string
+}
+
+const (
+	colorDarkBlue   = "\033[34m"
+	colorDarkGray   = "\033[90m"
+	colorLightBlue  = "\033[94m"
+	colorLightGreen = "\033[92m"
+	colorYellow     = "\033[93m"
+	colorReset      = "\033[0m"
+)
+
+// DefaultMaxByWidth fits every username allowed by HN for new accounts.
+const DefaultMaxByWidth = 15
+
+//nolint:gochecknoglobals // constant
+var (
+	UnicodeStyle = Style{
+		"↙ time adjusted for second-chance", unl.PrettyEllipsis, "", "★ ", "↙ reply under a dead or deleted root",
+		"↙ %d more active replies not retrieved",
+	}
+	ASCIIStyle = Style{
+		"[SECOND-CHANCE] time adjusted below", "...", "[ACTIVE] ", "[NEW] ", "[ORPHANED] reply under a dead or deleted root",
+		"[TRUNCATED] %d more active replies not retrieved",
+	}
+
+	// DefaultTheme is the theme of unl: green titles and blue ages, bright for active items, and yellow new markers.
+	DefaultTheme = Theme{
+		colorReset, colorLightBlue, colorDarkBlue, colorDarkGray, colorLightGreen, colorReset, colorYellow,
+	}
+)
+
+// Options selects how a TreeRenderer renders items.
+type Options struct {
+	// Now is the time ages are measured from.
+	Now time.Time
+	// ActiveAfter is the time after which items are active: highlighted, and with their text shown even in replies.
+	ActiveAfter time.Time
+	// NewAfter is the time after which items are new, such as since the previous refresh, and marked as such. The
+	// zero time marks nothing.
+	NewAfter time.Time
+	// Theme colors the output, or nil for no color.
+	Theme *Theme
+	// EffectiveTimes replaces the times of items adjusted for the second-chance pool, which are marked.
+	EffectiveTimes unl.EffectiveTimes
+	// Ranks prefixes the titles of roots with their rank, like "#3".
+	Ranks map[int]int
+	// Truncated marks the roots of trees with active items omitted, as from unl.WithMaxTreeSize.
+	Truncated hn.Truncated
+	Style     Style
+	// MaxWidth truncates lines to fit a terminal of this width, or 0 to never truncate.
+	MaxWidth int
+	// MaxByWidth truncates longer authors in the middle so one long name does not widen the column for every line,
+	// or 0 to never truncate.
+	MaxByWidth int
+	ChildOrder unl.ChildOrder
+	// PreviewLines shows up to this many wrapped lines of the text of self-posts below their title.
+	PreviewLines int
+	// Boundary sets whether items created exactly at ActiveAfter or NewAfter are active or new.
+	Boundary hn.Boundary
+}
+
+// Thread is a root item and its descendants grouped by parent, as from hn.ItemSet.GroupByParent.
+type Thread struct {
+	Root     *hn.Item
+	ByParent map[int]hn.ItemSet
+	// Orphaned marks a Root that is a reply under a dead or deleted root, as returned with unl.WithOrphans. It is
+	// shown as a root rather than as the subthread of a live discussion.
+	Orphaned bool
+}
+
+// Line is a single active item shown without a tree, with Text in place of its title, like a reply in an inbox.
+type Line struct {
+	Time time.Time
+	By   string
+	Text string
+	ID   int
+}
+
+// TreeRenderer renders threads and lines. It holds no state between calls and is safe for concurrent use.
+type TreeRenderer struct {
+	theme Theme
+	opts  Options
+}
+
+func NewTreeRenderer(opts Options) *TreeRenderer {
+	// the empty sequences of the zero theme write no color
+	theme := Theme{"", "", "", "", "", "", ""}
+	if opts.Theme != nil {
+		theme = *opts.Theme
+	}
+
+	return &TreeRenderer{theme, opts}
+}
+
+// Render writes threads to w, aligning the columns of all of them. The text of replies is shown only for those that
+// are active or have active replies.
+func (r *TreeRenderer) Render(w io.Writer, threads ...Thread) error {
+	var lines []renderLine
+
+	for _, thread := range threads {
+		lines = r.appendTree(lines, thread)
+	}
+
+	return r.write(w, lines)
+}
+
+// RenderLines writes lines to w, aligning their columns.
+func (r *TreeRenderer) RenderLines(w io.Writer, lines ...Line) error {
+	ll := make([]renderLine, 0, len(lines))
+
+	for _, l := range lines {
+		age := unl.PrettyFormatDuration(r.opts.Now.Sub(l.Time))
+		ll = append(ll, renderLine{hn.ItemURL(l.ID), l.By, age, "", l.Text, "", false, true, false, false, false, 0})
+	}
+
+	return r.write(w, ll)
+}
+
+type renderLine struct {
+	link         string
+	by           string
+	age          string
+	indent       string
+	text         string
+	preview      string
+	root         bool
+	active       bool
+	secondChance bool
+	isNew        bool
+	orphaned     bool
+	truncated    int
+}
+
+func calculateIndent(items []*unl.ItemWithDepth) []string {
+	indent := make([]string, len(items))
+	lastDepth := 0
+	stack := make([]byte, 0, items[len(items)-1].Depth)
+	indent[0] = ""
+
+	for i := len(items) - 1; i > 0; i-- {
+		item := items[i]
+
+		if item.Depth < lastDepth {
+			stack = stack[:len(stack)-1]
+		} else {
+			if len(stack) > 0 && i < len(items)-1 {
+				stack[lastDepth-1] = '|'
+			}
+
+			for range item.Depth - lastDepth {
+				stack = append(stack, ' ')
+			}
+		}
+
+		indent[i] = string(stack)
+		lastDepth = item.Depth
+	}
+
+	return indent
+}
+
+func (r *TreeRenderer) appendTree(lines []renderLine, thread Thread) []renderLine {
+	flat := unl.FlattenTreeOrdered(thread.Root, thread.ByParent, r.opts.ChildOrder)
+	activeMap := unl.BuildActiveMap(flat, r.opts.ActiveAfter, r.opts.Boundary)
+	indent := calculateIndent(flat)
+
+	for i, item := range flat {
+		root := item.Parent == nil || (i == 0 && thread.Orphaned)
+		ae := activeMap[item.ID]
+		showText := root || ae != 0
+		active := (ae & unl.ActiveMapSelf) > 0
+
+		isSecondChance := r.opts.EffectiveTimes.Adjusted(item.Item)
+
+		lines = append(lines, r.itemLine(item.Item, root, showText, active, isSecondChance, indent[i]))
+	}
+
+	return lines
+}
+
+func (r *TreeRenderer) itemLine(
+	item *hn.Item, root bool, showText bool, isActive bool, isSecondChance bool, indent string,
+) renderLine {
+	link := hn.ItemURL(item.ID)
+	by := item.By
+
+	age := unl.PrettyFormatDuration(r.opts.Now.Sub(time.Unix(r.opts.EffectiveTimes.Get(item), 0)))
+	text := ""
+
+	if showText {
+		text = unl.PrettyFormatTitle(item, true)
+
+		rank, ok := r.opts.Ranks[item.ID]
+		if ok && item.Parent == nil {
+			text = "#" + strconv.Itoa(rank) + " " + text
+		}
+	}
+
+	// self-posts like Ask HN have both a title and text
+	preview := ""
+	if r.opts.PreviewLines > 0 && item.Parent == nil && item.Title != "" && !item.Dead && !item.Deleted {
+		preview = unl.PrettyCleanText(item.Text)
+	}
+
+	isNew := !r.opts.NewAfter.IsZero() && r.opts.Boundary.After(item.Time, r.opts.NewAfter)
+
+	orphaned := root && item.Parent != nil
+
+	truncated := 0
+	if root {
+		truncated = r.opts.Truncated[item.ID]
+	}
+
+	return renderLine{link, by, age, indent, text, preview, root, isActive, isSecondChance, isNew, orphaned, truncated}
+}
+
+func (r *TreeRenderer) write(w io.Writer, lines []renderLine) error {
+	maxByLength := 0
+	maxAgeLength := 0
+	allActive := true
+
+	for i := range lines {
+		lines[i].by = r.opts.Style.truncateMiddle(lines[i].by, r.opts.MaxByWidth)
+	}
+
+	for _, line := range lines {
+		maxByLength = max(utf8.RuneCountInString(line.by), maxByLength)
+		maxAgeLength = max(len(line.age), maxAgeLength)
+		allActive = allActive && line.active
+	}
+
+	activeMarker := r.opts.Style.ActiveMarker
+	if allActive {
+		activeMarker = ""
+	}
+
+	var buf bytes.Buffer
+
+	for i, line := range lines {
+		buf.Reset()
+
+		if line.root && i != 0 {
+			buf.WriteString("\n")
+		}
+
+		const spaceBetweenFields = 3
+		markerIndent := strings.Repeat(" ", len(line.link)+maxByLength+maxAgeLength+spaceBetweenFields)
+
+		if line.orphaned {
+			buf.WriteString(r.theme.Tree)
+			buf.WriteString(markerIndent)
+			buf.WriteString(r.opts.Style.Orphaned)
+			buf.WriteString("\n")
+		}
+
+		if line.truncated > 0 {
+			buf.WriteString(r.theme.Tree)
+			buf.WriteString(markerIndent)
+			_, _ = fmt.Fprintf(&buf, r.opts.Style.Truncated, line.truncated)
+			buf.WriteString("\n")
+		}
+
+		if line.secondChance {
+			buf.WriteString(r.theme.Tree)
+			buf.WriteString(markerIndent)
+			buf.WriteString(r.opts.Style.SecondChance)
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(r.theme.Link)
+
+		buf.WriteString(line.link)
+
+		printable := len(line.link)
+
+		printable += writeBy(&buf, &line, maxByLength)
+
+		printable += r.writeAge(&buf, &line, maxAgeLength)
+
+		printable += r.writeIndent(&buf, &line)
+
+		r.writeText(&buf, &line, activeMarker, printable)
+
+		buf.WriteString("\n")
+
+		r.writePreview(&buf, &line, printable)
+
+		_, err := buf.WriteTo(w)
+		if err != nil {
+			return fmt.Errorf("failed to write to writer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *TreeRenderer) writePreview(buf *bytes.Buffer, line *renderLine, printable int) {
+	if line.preview == "" {
+		return
+	}
+
+	// without a terminal there is no width to fit, so wrap at a readable width instead
+	const widthWithoutTerminal = 100
+
+	width := widthWithoutTerminal
+	if r.opts.MaxWidth > 0 {
+		width = max(1, r.opts.MaxWidth-printable)
+	}
+
+	buf.WriteString(r.theme.Link)
+
+	for _, text := range unl.PrettyWrapText(line.preview, width, r.opts.PreviewLines) {
+		for range printable {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(r.opts.Style.ellipsize(text))
+		buf.WriteString("\n")
+	}
+}
+
+func writeBy(buf *bytes.Buffer, line *renderLine, maxByLength int) int {
+	buf.WriteByte(' ')
+
+	for range maxByLength - utf8.RuneCountInString(line.by) {
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString(line.by)
+
+	return maxByLength + 1
+}
+
+func (r *TreeRenderer) writeAge(buf *bytes.Buffer, line *renderLine, maxAgeLength int) int {
+	if line.active {
+		buf.WriteString(r.theme.Active)
+	} else {
+		buf.WriteString(r.theme.Inactive)
+	}
+
+	buf.WriteByte(' ')
+
+	for range maxAgeLength - len(line.age) {
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString(line.age)
+
+	return maxAgeLength + 1
+}
+
+func (r *TreeRenderer) writeIndent(buf *bytes.Buffer, line *renderLine) int {
+	buf.WriteString(r.theme.Tree)
+
+	printable := len(line.indent) + 1
+
+	buf.WriteByte(' ')
+	buf.WriteString(line.indent)
+
+	if line.indent != "" {
+		buf.WriteString("\\")
+
+		printable++
+
+		if line.text != "" {
+			buf.WriteString("- ")
+
+			printable += 2
+		}
+	}
+
+	return printable
+}
+
+func (r *TreeRenderer) writeText(buf *bytes.Buffer, line *renderLine, activeMarker string, printable int) {
+	if line.isNew && line.text != "" {
+		buf.WriteString(r.theme.New)
+		buf.WriteString(r.opts.Style.NewMarker)
+
+		printable += utf8.RuneCountInString(r.opts.Style.NewMarker)
+	}
+
+	if line.root {
+		buf.WriteString(r.theme.Root)
+	} else {
+		buf.WriteString(r.theme.Text)
+	}
+
+	remaining := math.MaxInt
+
+	if r.opts.MaxWidth > 0 {
+		remaining = max(1, r.opts.MaxWidth-printable)
+	}
+
+	text := line.text
+	if line.active && text != "" {
+		text = activeMarker + text
+	}
+
+	written := 0
+
+	for _, c := range text {
+		if remaining == 0 {
+			r.opts.Style.writeEllipsis(buf, written)
+
+			break
+		}
+
+		buf.WriteRune(c)
+		written++
+		remaining--
+	}
+}
+
+// writeEllipsis replaces the last runes of the written text with the ellipsis so the line keeps its width. written
+// is the count of runes of text in buf that may be replaced.
+func (s *Style) writeEllipsis(buf *bytes.Buffer, written int) {
+	for range min(written, utf8.RuneCountInString(s.Ellipsis)) {
+		_, size := utf8.DecodeLastRune(buf.Bytes())
+		buf.Truncate(buf.Len() - size)
+	}
+
+	buf.WriteString(s.Ellipsis)
+}
+
+// truncateMiddle shortens text to width runes by replacing its middle with the ellipsis, keeping more of the start
+// than the end. A width of 0 never truncates.
+func (s *Style) truncateMiddle(text string, width int) string {
+	runes := []rune(text)
+	if width <= 0 || len(runes) <= width {
+		return text
+	}
+
+	keep := width - utf8.RuneCountInString(s.Ellipsis)
+	if keep <= 0 {
+		return string(runes[:width])
+	}
+
+	tail := keep / 2
+
+	return string(runes[:keep-tail]) + s.Ellipsis + string(runes[len(runes)-tail:])
+}
+
+// ellipsize replaces the ellipsis of a line from unl.PrettyWrapText with that of the style, keeping its width.
+func (s *Style) ellipsize(text string) string {
+	if s.Ellipsis == unl.PrettyEllipsis {
+		return text
+	}
+
+	trimmed, ok := strings.CutSuffix(text, unl.PrettyEllipsis)
+	if !ok {
+		return text
+	}
+
+	runes := []rune(trimmed)
+	runes = runes[:max(0, len(runes)-utf8.RuneCountInString(s.Ellipsis)+1)]
+
+	return string(runes) + s.Ellipsis
+}
@@ -0,0 +1,266 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/unl"
+)
+
+//nolint:gochecknoglobals // test flag
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// readThread returns the items of testdata/thread.json by ID and grouped by parent.
+func readThread(t *testing.T) (hn.ItemSet, map[int]hn.ItemSet) {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "thread.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []*hn.Item
+
+	err = json.Unmarshal(raw, &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	byParent, _, err := all.GroupByParent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return all, byParent
+}
+
+// testOptions returns options for the thread with item 10 adjusted for the second-chance pool.
+func testOptions(style Style, theme *Theme, maxWidth int, previewLines int) Options {
+	return Options{
+		Now:            time.Unix(7200, 0),
+		ActiveAfter:    time.Unix(4500, 0),
+		NewAfter:       time.Time{},
+		Theme:          theme,
+		EffectiveTimes: unl.EffectiveTimes{10: 4500},
+		Ranks:          map[int]int{1: 1, 10: 2},
+		Truncated:      nil,
+		Style:          style,
+		MaxWidth:       maxWidth,
+		MaxByWidth:     DefaultMaxByWidth,
+		ChildOrder:     unl.ChildOrderTime,
+		PreviewLines:   previewLines,
+		Boundary:       hn.BoundaryInclusive,
+	}
+}
+
+func checkGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		err := os.WriteFile(path, actual, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	expected, err := os.ReadFile(path) //nolint:gosec // G304 intended
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("output differs from %s (rerun with -update to accept):\n%s", path, actual)
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	all, byParent := readThread(t)
+	threads := []Thread{{all[1], byParent, false}, {all[10], byParent, false}}
+
+	// items after the previous refresh at 4000 are marked as new
+	newOpts := testOptions(UnicodeStyle, &DefaultTheme, 0, 0)
+	newOpts.NewAfter = time.Unix(4000, 0)
+
+	for name, opts := range map[string]Options{
+		"unicode":      testOptions(UnicodeStyle, nil, 0, 2),
+		"ascii_narrow": testOptions(ASCIIStyle, nil, 60, 2),
+		"color":        testOptions(UnicodeStyle, &DefaultTheme, 0, 1),
+		"new":          newOpts,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			err := NewTreeRenderer(opts).Render(&buf, threads...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGolden(t, name, buf.Bytes())
+		})
+	}
+}
+
+func TestRenderOrphaned(t *testing.T) {
+	t.Parallel()
+
+	all, byParent := readThread(t)
+
+	// the root of item 21 is deleted, so it is shown as a root and marked
+	for _, style := range []Style{UnicodeStyle, ASCIIStyle} {
+		var buf bytes.Buffer
+
+		err := NewTreeRenderer(testOptions(style, nil, 0, 0)).Render(&buf, Thread{all[21], byParent, true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(buf.String(), "\n")
+		if !strings.HasSuffix(lines[0], style.Orphaned) || !strings.Contains(lines[1], "Does anyone know") {
+			t.Fatalf("expected the orphaned marker above item 21, got:\n%s", buf.String())
+		}
+	}
+
+	// a subthread of a live root, as from unl open, is not marked
+	var buf bytes.Buffer
+
+	err := NewTreeRenderer(testOptions(UnicodeStyle, nil, 0, 0)).Render(&buf, Thread{all[2], byParent, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), UnicodeStyle.Orphaned) {
+		t.Fatalf("expected no orphaned marker, got:\n%s", buf.String())
+	}
+}
+
+func TestRenderTruncated(t *testing.T) {
+	t.Parallel()
+
+	all, byParent := readThread(t)
+
+	opts := testOptions(ASCIIStyle, nil, 0, 0)
+	opts.Truncated = hn.Truncated{10: 7}
+
+	var buf bytes.Buffer
+
+	err := NewTreeRenderer(opts).Render(&buf, Thread{all[1], byParent, false}, Thread{all[10], byParent, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the marker is above the truncated root only, after the thread before it
+	out := buf.String()
+	marker := strings.Index(out, "[TRUNCATED] 7 more active replies not retrieved")
+
+	if strings.Count(out, "[TRUNCATED]") != 1 || marker < strings.Index(out, hn.ItemURL(4)+" ") ||
+		marker > strings.Index(out, hn.ItemURL(10)+" ") {
+		t.Fatalf("expected a single truncation marker above item 10, got:\n%s", out)
+	}
+}
+
+func TestRenderLines(t *testing.T) {
+	t.Parallel()
+
+	all, _ := readThread(t)
+
+	var buf bytes.Buffer
+
+	err := NewTreeRenderer(testOptions(UnicodeStyle, nil, 0, 0)).RenderLines(&buf,
+		Line{time.Unix(all[3].Time, 0), all[3].By, "(to bob) " + unl.PrettyFormatTitle(all[3], false), all[3].ID},
+		Line{time.Unix(all[5].Time, 0), all[5].By, "(to alice) " + unl.PrettyFormatTitle(all[5], false), all[5].ID},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "lines", buf.Bytes())
+}
+
+func TestRenderStyle(t *testing.T) {
+	t.Parallel()
+
+	r := NewTreeRenderer(testOptions(ASCIIStyle, nil, 40, 0))
+	lines := []renderLine{
+		{"link", "a", "1m", "", "title title title title title title title", "", true, true, true, false, false, 0},
+		{"link", "b", "2h", " ", "reply", "", false, false, false, false, false, 0},
+	}
+
+	var buf bytes.Buffer
+
+	err := r.write(&buf, lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "          [SECOND-CHANCE] time adjusted below\n" +
+		"link a 1m [ACTIVE] title title title ...\n" +
+		"link b 2h  \\- reply\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestRenderMaxByWidth(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(UnicodeStyle, nil, 0, 0)
+	opts.MaxByWidth = 8
+
+	lines := []Line{
+		{time.Unix(5000, 0), "averyverylongusername", "first", 1},
+		{time.Unix(6000, 0), "short", "second", 2},
+		{time.Unix(6000, 0), "exactly8", "third", 3},
+	}
+
+	var buf bytes.Buffer
+
+	err := NewTreeRenderer(opts).RenderLines(&buf, lines...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the long name keeps its start and end and the column stays 8 wide
+	expected := hn.ItemURL(1) + " aver…ame 36m first\n" +
+		hn.ItemURL(2) + "    short 20m second\n" +
+		hn.ItemURL(3) + " exactly8 20m third\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	for _, c := range []struct {
+		text     string
+		expected string
+		width    int
+	}{
+		{"abcdefghij", "abcdefghij", 0},
+		{"abcdefghij", "abcdefghij", 10},
+		{"abcdefghij", "abc...hij", 9},
+		{"abcdefghij", "ab...j", 6},
+		{"abcdefghij", "ab", 2},
+	} {
+		actual := ASCIIStyle.truncateMiddle(c.text, c.width)
+		if actual != c.expected {
+			t.Fatalf("expected %q truncated to %d to be %q, got %q", c.text, c.width, c.expected, actual)
+		}
+	}
+}
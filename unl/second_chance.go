@@ -0,0 +1,94 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// SecondChance records an article seen on the front page with a newer apparent time than its API time,
+// meaning it was picked from the second-chance pool.
+type SecondChance struct {
+	ID           int
+	Time         int64
+	ApparentTime int64
+	DetectedAt   int64
+}
+
+// RecordSecondChances stores the items whose effective time is adjusted.
+// Only the first detection of each item is kept.
+func (s *Store) RecordSecondChances(
+	ctx context.Context,
+	items hn.ItemSet,
+	effectiveTimes EffectiveTimes,
+	detectedAt time.Time,
+) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	for _, item := range items {
+		if !effectiveTimes.Adjusted(item) {
+			continue
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT OR IGNORE INTO second_chance(ID, Time, apparent, detected) VALUES (?, ?, ?, ?)",
+			item.ID, item.Time, effectiveTimes.Get(item), detectedAt.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to insert second chance: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SecondChances returns the articles detected after since, most recently detected first.
+func (s *Store) SecondChances(ctx context.Context, since time.Time) (_ []SecondChance, err error) {
+	rows, err := s.queryContext(
+		ctx,
+		"SELECT ID, Time, apparent, detected FROM second_chance WHERE detected > ? ORDER BY detected DESC, ID DESC",
+		since.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	var result []SecondChance
+
+	for rows.Next() {
+		var sc SecondChance
+
+		err = rows.Scan(&sc.ID, &sc.Time, &sc.ApparentTime, &sc.DetectedAt)
+		if err != nil {
+			return nil, fmt.Errorf("second chance scan: %w", err)
+		}
+
+		result = append(result, sc)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("second chance rows err: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,206 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Store persists unl state, such as detected second-chance articles, alongside the item cache in the same SQLite file.
+// The caller must register the "sqlite3" driver, as for hn.NewClient.
+type Store struct {
+	db *sql.DB
+}
+
+var storeSchema = []string{ //nolint:gochecknoglobals // schema
+	`CREATE TABLE IF NOT EXISTS second_chance(
+	  ID INTEGER PRIMARY KEY,
+	  Time INTEGER NOT NULL,
+	  apparent INTEGER NOT NULL,
+	  detected INTEGER NOT NULL
+	)`,
+	"CREATE INDEX IF NOT EXISTS second_chance_detected ON second_chance (detected)",
+	`CREATE TABLE IF NOT EXISTS watch_thread(
+	  ID INTEGER PRIMARY KEY,
+	  position INTEGER NOT NULL,
+	  title TEXT NOT NULL,
+	  score INTEGER NOT NULL,
+	  comments INTEGER NOT NULL,
+	  active INTEGER NOT NULL,
+	  users INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS watch_refresh(
+	  ID INTEGER PRIMARY KEY CHECK (ID = 0),
+	  refreshed INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS inbox_seen(
+	  user TEXT NOT NULL,
+	  ID INTEGER NOT NULL,
+	  PRIMARY KEY (user, ID)
+	)`,
+	`CREATE TABLE IF NOT EXISTS bookmark(
+	  ID INTEGER PRIMARY KEY,
+	  note TEXT NOT NULL,
+	  saved INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS bookmark_tag(
+	  ID INTEGER NOT NULL,
+	  tag TEXT NOT NULL,
+	  PRIMARY KEY (ID, tag)
+	)`,
+	`CREATE TABLE IF NOT EXISTS mute(
+	  ID INTEGER PRIMARY KEY,
+	  muted INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS active_snapshot(
+	  key TEXT PRIMARY KEY,
+	  created INTEGER NOT NULL,
+	  roots TEXT NOT NULL,
+	  items TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS story_metrics(
+	  ID INTEGER NOT NULL,
+	  seen INTEGER NOT NULL,
+	  descendants INTEGER NOT NULL,
+	  score INTEGER NOT NULL,
+	  PRIMARY KEY (ID, seen)
+	)`,
+	"CREATE INDEX IF NOT EXISTS story_metrics_seen ON story_metrics (seen)",
+}
+
+func OpenStore(ctx context.Context, path string) (_ *Store, err error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, db.Close())
+		}
+	}()
+
+	s := &Store{db}
+
+	err = s.execContext(ctx, "PRAGMA journal_mode = WAL")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range storeSchema {
+		err = s.execContext(ctx, statement)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// storeStateTables are the tables of user state, such as bookmarks and mutes, rather than of data that can be
+// retrieved again.
+var storeStateTables = []string{ //nolint:gochecknoglobals // constant
+	"bookmark", "bookmark_tag", "mute", "inbox_seen", "watch_thread", "watch_refresh", "second_chance", "story_metrics",
+}
+
+// RecoverStore copies the state kept in the SQLite file at from into the store at path with Store.Recover. The error
+// tells the user what was left behind in from.
+func RecoverStore(ctx context.Context, path string, from string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf(
+				"bookmarks, notes, tags, mutes, and inbox and watch state may remain in %s; recover them with the "+
+					"sqlite3 .recover command: %w", from, err)
+		}
+	}()
+
+	store, err := OpenStore(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	defer func() { err = errors.Join(err, store.Close()) }()
+
+	_, err = store.Recover(ctx, from)
+
+	return err
+}
+
+// Recover copies the state kept in the SQLite file at from, such as a cache file that was moved aside as corrupt,
+// into the store, keeping the rows already in the store. It returns the tables copied; the others are in the error.
+func (s *Store) Recover(ctx context.Context, from string) (_ []string, err error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	defer func() { err = errors.Join(err, conn.Close()) }()
+
+	_, err = conn.ExecContext(ctx, "ATTACH DATABASE ? AS recovered", from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", from, err)
+	}
+
+	defer func() {
+		_, detachErr := conn.ExecContext(context.WithoutCancel(ctx), "DETACH DATABASE recovered")
+		if detachErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to detach %s: %w", from, detachErr))
+		}
+	}()
+
+	var copied []string
+	var errs []error
+
+	for _, table := range storeStateTables {
+		var n int
+
+		// a file from before the table was added has nothing to recover from it
+		copyErr := conn.QueryRowContext(
+			ctx, "SELECT COUNT(*) FROM recovered.sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+		if copyErr == nil && n == 0 {
+			continue
+		}
+
+		if copyErr == nil {
+			// the table names are constants, so formatting them into the statement is safe
+			_, copyErr = conn.ExecContext(ctx, "INSERT OR IGNORE INTO main."+table+" SELECT * FROM recovered."+table)
+		}
+
+		if copyErr != nil {
+			errs = append(errs, fmt.Errorf("failed to recover %s: %w", table, copyErr))
+			continue
+		}
+
+		copied = append(copied, table)
+	}
+
+	return copied, errors.Join(errs...)
+}
+
+func (s *Store) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close db: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) execContext(ctx context.Context, query string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("exec failed: %s %w", query, err)
+	}
+
+	return nil
+}
+
+func (s *Store) queryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %s %w", query, err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,410 @@
+package unl
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestStore_SecondChances(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	items := make(hn.ItemSet, 3)
+	for id := 1; id <= 3; id++ {
+		var item hn.Item
+		item.ID = id
+		item.Time = int64(id * 100)
+		items[id] = &item
+	}
+
+	times := EffectiveTimes{1: 1000, 2: 200, 3: 3000}
+
+	err = store.RecordSecondChances(t.Context(), items, times, time.Unix(5000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a later detection of the same article keeps the first
+	times[3] = 4000
+
+	err = store.RecordSecondChances(t.Context(), items, times, time.Unix(6000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	picks, err := store.SecondChances(t.Context(), time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SecondChance{{3, 300, 3000, 5000}, {1, 100, 1000, 5000}}
+	if len(picks) != len(expected) || picks[0] != expected[0] || picks[1] != expected[1] {
+		t.Fatalf("expected %v, got %v", expected, picks)
+	}
+
+	picks, err = store.SecondChances(t.Context(), time.Unix(5000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(picks) != 0 {
+		t.Fatalf("expected no picks after the detection time, got %v", picks)
+	}
+}
+
+func TestStore_WatchState(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	store, err := OpenStore(t.Context(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threads := make([]*ActiveThread, 0, 2)
+	for _, id := range []int{2, 1} {
+		var root hn.Item
+		root.ID = id
+		root.Title = "title"
+		root.Score = id * 10
+		threads = append(threads, &ActiveThread{&root, id, id + 1, id + 2})
+	}
+
+	err = errors.Join(store.SaveWatchState(t.Context(), threads), store.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the state survives reopening the store
+	store, err = OpenStore(t.Context(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	loaded, err := store.WatchState(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Diff(threads, loaded).Empty() || loaded[0].ID() != 2 {
+		t.Fatalf("expected the saved threads in order, got %v", loaded)
+	}
+
+	err = store.ResetWatchState(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err = store.WatchState(t.Context())
+	if err != nil || len(loaded) != 0 {
+		t.Fatalf("expected no threads after reset, got %v: %v", loaded, err)
+	}
+
+	refreshed, err := store.WatchRefreshed(t.Context())
+	if err != nil || !refreshed.IsZero() {
+		t.Fatalf("expected no refresh time, got %v: %v", refreshed, err)
+	}
+
+	for _, at := range []int64{100, 200} {
+		err = store.SaveWatchRefreshed(t.Context(), time.Unix(at, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	refreshed, err = store.WatchRefreshed(t.Context())
+	if err != nil || refreshed.Unix() != 200 {
+		t.Fatalf("expected the latest refresh time, got %v: %v", refreshed, err)
+	}
+
+	err = store.ResetWatchState(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err = store.WatchRefreshed(t.Context())
+	if err != nil || !refreshed.IsZero() {
+		t.Fatalf("expected no refresh time after reset, got %v: %v", refreshed, err)
+	}
+}
+
+func TestStore_InboxSeen(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	var item hn.Item
+	item.ID = 1
+
+	replies := []Reply{{&item, "a"}, {&item, "b"}}
+
+	err = store.MarkRepliesSeen(t.Context(), replies[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unseen, err := store.UnseenReplies(t.Context(), replies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(unseen) != 1 || unseen[0].User != "b" {
+		t.Fatalf("expected only the reply for b to be unseen, got %v", unseen)
+	}
+}
+
+func TestStore_Bookmarks(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	err = errors.Join(
+		store.SaveBookmark(t.Context(), Bookmark{"first", []string{"reading"}, 1, 100}),
+		store.SaveBookmark(t.Context(), Bookmark{"", nil, 2, 200}),
+		// saving again merges tags and keeps the note and saved time
+		store.SaveBookmark(t.Context(), Bookmark{"", []string{"later", "reading"}, 1, 300}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.Bookmarks(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Bookmark{{"", []string{}, 2, 200}, {"first", []string{"later", "reading"}, 1, 100}}
+	if diff := cmp.Diff(expected, all); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	tagged, err := store.Bookmarks(t.Context(), "later")
+	if err != nil || len(tagged) != 1 || tagged[0].ID != 1 {
+		t.Fatalf("expected only bookmark 1 tagged later, got %v: %v", tagged, err)
+	}
+
+	err = store.RemoveBookmark(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagged, err = store.Bookmarks(t.Context(), "reading")
+	if err != nil || len(tagged) != 0 {
+		t.Fatalf("expected no bookmarks after removal, got %v: %v", tagged, err)
+	}
+}
+
+func TestStore_Mute(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	err = errors.Join(
+		store.Mute(t.Context(), []int{1, 2}, time.Unix(100, 0)),
+		store.Mute(t.Context(), []int{3, 1}, time.Unix(200, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	muted, err := store.Muted(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]int{3, 2, 1}, muted); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	err = store.ClearMuted(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	muted, err = store.Muted(t.Context())
+	if err != nil || len(muted) != 0 {
+		t.Fatalf("expected no mutes after clear, got %v: %v", muted, err)
+	}
+}
+
+func TestStore_ActiveSnapshot(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	var items []*hn.Item
+
+	err = json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100,"kids":[2]},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"story","by":"c","time":300}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the tree of the active root is kept
+	snapshot := NewActiveSnapshot([]*hn.Item{all[1]}, allByParent, time.Unix(1000, 0))
+
+	err = store.SaveActiveSnapshot(t.Context(), "key", snapshot, time.Unix(940, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.ActiveSnapshot(t.Context(), "key", time.Unix(990, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(snapshot, loaded); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	roots, byParent, err := loaded.Result()
+	if err != nil || len(roots) != 1 || roots[0].ID != 1 || len(byParent[1]) != 1 {
+		t.Fatalf("unexpected result %v, %v: %v", roots, byParent, err)
+	}
+
+	for _, test := range []struct {
+		key          string
+		createdAfter int64
+	}{{"other", 990}, {"key", 1001}} {
+		missing, err := store.ActiveSnapshot(t.Context(), test.key, time.Unix(test.createdAfter, 0))
+		if err != nil || missing != nil {
+			t.Fatalf("expected no snapshot for %s after %d, got %v: %v", test.key, test.createdAfter, missing, err)
+		}
+	}
+
+	// a reply listed in the updates changes the kids of the root
+	edited := *all[1]
+	edited.Kids = []int{4, 2}
+
+	for _, test := range []struct {
+		item     *hn.Item
+		updates  []int
+		expected bool
+	}{
+		{all[1], nil, false},
+		{all[1], []int{1, 3}, false},
+		{&edited, []int{3}, false},
+		{&edited, []int{1}, true},
+	} {
+		client := hntest.NewFakeClient(
+			hn.ItemSet{1: test.item, 2: all[2]}, hntest.WithUpdates(&hn.Updates{Items: test.updates, Profiles: nil}))
+
+		changed, err := loaded.Changed(t.Context(), client)
+		if err != nil || changed != test.expected {
+			t.Fatalf("updates %v: expected changed %t, got %t: %v", test.updates, test.expected, changed, err)
+		}
+	}
+
+	err = store.Mute(t.Context(), []int{1}, time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err = store.ActiveSnapshot(t.Context(), "key", time.Unix(990, 0))
+	if err != nil || loaded != nil {
+		t.Fatalf("expected muting to clear snapshots, got %v: %v", loaded, err)
+	}
+}
+
+func TestStore_StoryMetrics(t *testing.T) {
+	t.Parallel()
+
+	store, err := OpenStore(t.Context(), filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = store.Close() }()
+
+	story := func(id int, descendants int, score int) hn.ItemSet {
+		var item hn.Item
+		item.ID = id
+		item.Descendants = descendants
+		item.Score = score
+
+		return hn.ItemSet{id: &item}
+	}
+
+	err = errors.Join(
+		store.SaveStoryMetrics(t.Context(), story(1, 0, 1), time.Unix(100, 0), time.Unix(0, 0)),
+		store.SaveStoryMetrics(t.Context(), story(1, 5, 8), time.Unix(200, 0), time.Unix(0, 0)),
+		store.SaveStoryMetrics(t.Context(), story(1, 9, 20), time.Unix(300, 0), time.Unix(0, 0)),
+		store.SaveStoryMetrics(t.Context(), story(2, 3, 4), time.Unix(300, 0), time.Unix(0, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the latest metrics seen by 250, or the earliest if all are later
+	baseline, err := store.StoryMetrics(t.Context(), time.Unix(250, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int]StoryMetrics{1: {1, 5, 8, 200}, 2: {2, 3, 4, 300}}
+	if diff := cmp.Diff(expected, baseline); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	err = store.SaveStoryMetrics(t.Context(), story(2, 4, 4), time.Unix(400, 0), time.Unix(250, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err = store.StoryMetrics(t.Context(), time.Unix(250, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[int]StoryMetrics{1: {1, 9, 20, 300}, 2: {2, 3, 4, 300}}
+	if diff := cmp.Diff(expected, baseline); diff != "" {
+		t.Fatalf("diff after expiry: %s", diff)
+	}
+}
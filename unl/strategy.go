@@ -0,0 +1,185 @@
+package unl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// ActivityStrategy finds the active discussions for FindActive, which ranks and limits them the same way for every
+// strategy, so how the items are retrieved can change or be tested on its own.
+type ActivityStrategy interface {
+	// Name selects the strategy with StrategyByName.
+	Name() string
+	// Find returns the roots active by req, in no particular order, with the items retrieved for them. A partial result
+	// is returned along with an error wrapping hn.ErrPartialResult.
+	Find(ctx context.Context, client hn.API, req ActivityRequest) (Activity, error)
+}
+
+// ActivityRequest holds the parameters of FindActive passed to every ActivityStrategy. A strategy ignores those it
+// has no use for.
+type ActivityRequest struct {
+	// Now is the current time, when a strategy records state for later runs.
+	Now time.Time
+	// ActiveAfter is the start of the window for activity.
+	ActiveAfter time.Time
+	// AgedAfter is the oldest a root may be.
+	AgedAfter time.Time
+	// EffectiveTimes adjusts the times of roots, such as second-chance articles.
+	EffectiveTimes EffectiveTimes
+	// Store keeps the state of strategies that compare with earlier runs. It may be nil for those that don't.
+	Store *Store
+	// Options configure the strategy as for GetActive.
+	Options []Option
+	// MinBy is the minimum count of contributors to the activity of a root.
+	MinBy int
+}
+
+func (r ActivityRequest) options() activeOptions {
+	var o activeOptions
+	for _, option := range r.Options {
+		option.apply(&o)
+	}
+
+	return o
+}
+
+// Activity is what an ActivityStrategy found.
+type Activity struct {
+	// Roots are the active roots.
+	Roots hn.ItemSet
+	// ByParent holds the items retrieved under the roots grouped by parent, which for some strategies is none.
+	ByParent map[int]hn.ItemSet
+	// Growth holds the change of each root estimated by a strategy that doesn't retrieve the replies, or nil.
+	Growth map[int]Estimate
+}
+
+// Strategies are the strategies selectable by name with StrategyByName. IDScan is first and the default.
+var Strategies = []ActivityStrategy{ //nolint:gochecknoglobals // registry
+	IDScan{},
+	ListDelta{defaultListDeltaStories, defaultListDeltaRetention},
+	UpdatesFeed{},
+}
+
+var (
+	errUnknownStrategy = errors.New("unknown strategy")
+	errStrategyStore   = errors.New("strategy needs a store")
+)
+
+// StrategyByName returns the strategy in Strategies with the given name.
+func StrategyByName(name string) (ActivityStrategy, error) {
+	for _, strategy := range Strategies {
+		if strategy.Name() == name {
+			return strategy, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w %q, expected one of %s", errUnknownStrategy, name, strings.Join(StrategyNames(), ", "))
+}
+
+// StrategyNames returns the names of the Strategies.
+func StrategyNames() []string {
+	names := make([]string, len(Strategies))
+	for i, strategy := range Strategies {
+		names[i] = strategy.Name()
+	}
+
+	return names
+}
+
+// FindActive returns the roots found active by strategy, most recent first and at most limit if it is positive,
+// along with everything the strategy found. A partial result is returned along with its error, as from the strategy.
+func FindActive(
+	ctx context.Context, client hn.API, strategy ActivityStrategy, req ActivityRequest, limit int,
+) ([]*hn.Item, Activity, error) {
+	activity, err := strategy.Find(ctx, client, req)
+	if err != nil && !errors.Is(err, hn.ErrPartialResult) {
+		return nil, Activity{}, err
+	}
+
+	items := sortItems(activity.Roots.OrderByTimeDesc(), req.EffectiveTimes)
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items, activity, err
+}
+
+// IDScan retrieves every item in the window by scanning down from the max item, as with hn.Client.GetActiveCapped.
+// It is exact but costs a request per item in the window.
+type IDScan struct{}
+
+func (IDScan) Name() string {
+	return "scan"
+}
+
+func (IDScan) Find(ctx context.Context, client hn.API, req ActivityRequest) (Activity, error) {
+	o := req.options()
+
+	all, allByRoot, err := getActiveByRoot(ctx, client, req.ActiveAfter, o)
+	if err != nil && !errors.Is(err, hn.ErrPartialResult) {
+		return Activity{}, err
+	}
+
+	return newActivity(all, allByRoot, req, o, err)
+}
+
+// UpdatesFeed retrieves only the items on the changed list of the API, which holds the few hundred items changed most
+// recently, and their ancestors. It costs a fraction of IDScan but misses the activity that has already scrolled off
+// the list, so it suits short windows. Sources without the list are not supported.
+type UpdatesFeed struct{}
+
+func (UpdatesFeed) Name() string {
+	return "updates"
+}
+
+func (UpdatesFeed) Find(ctx context.Context, client hn.API, req ActivityRequest) (Activity, error) {
+	o := req.options()
+
+	updates, err := client.GetUpdates(ctx)
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get updates: %w", err)
+	}
+
+	changed, err := client.GetItems(ctx, updates.Items)
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get updated items: %w", err)
+	}
+
+	active := activeItems(changed, req.ActiveAfter, o.boundary)
+
+	all, err := client.GetAncestors(ctx, active)
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get ancestors: %w", err)
+	}
+
+	if len(o.muted) > 0 {
+		all = withoutMuted(all, o.muted)
+	}
+
+	allByRoot, err := all.GroupByRoot()
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get group by root: %w", err)
+	}
+
+	return newActivity(all, allByRoot, req, o, nil)
+}
+
+// newActivity judges the trees retrieved by a strategy, returning partialErr along with the result.
+func newActivity(
+	all hn.ItemSet, allByRoot map[*hn.Item]hn.ItemSet, req ActivityRequest, o activeOptions, partialErr error,
+) (Activity, error) {
+	roots := getActiveRoots(allByRoot, req.EffectiveTimes, req.AgedAfter, req.ActiveAfter, req.MinBy, o)
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		return Activity{}, fmt.Errorf("failed to get group by parent: %w", err)
+	}
+
+	return Activity{roots, allByParent, nil}, partialErr
+}
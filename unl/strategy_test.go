@@ -0,0 +1,80 @@
+package unl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestStrategyByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range StrategyNames() {
+		strategy, err := StrategyByName(name)
+		if err != nil || strategy.Name() != name {
+			t.Fatalf("expected %s, got %v: %v", name, strategy, err)
+		}
+	}
+
+	_, err := StrategyByName("guess")
+	if err == nil {
+		t.Fatal("expected an error for an unknown strategy")
+	}
+}
+
+func TestUpdatesFeed(t *testing.T) {
+	t.Parallel()
+
+	fake, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := testdata.MaxTime
+	req := ActivityRequest{now, now.Add(-time.Hour), now.Add(-24 * time.Hour), nil, nil, nil, 2}
+
+	expected, scanned, err := FindActive(t.Context(), fake, IDScan{}, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []int
+	for _, items := range scanned.ByParent {
+		all = append(all, items.IDs()...)
+	}
+
+	// with every active item on the changed list, the feed finds what the scan does
+	fake, err = hntest.NewFakeClientFromTestdata(hntest.WithUpdates(&hn.Updates{Items: all, Profiles: nil}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, _, err := FindActive(t.Context(), fake, UpdatesFeed{}, req, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expected) == 0 || len(actual) != len(expected) {
+		t.Fatalf("expected %d roots, got %d", len(expected), len(actual))
+	}
+
+	for i := range expected {
+		if actual[i].ID != expected[i].ID {
+			t.Fatalf("expected root %d at %d, got %d", expected[i].ID, i, actual[i].ID)
+		}
+	}
+
+	// activity that has scrolled off the list is missed
+	fake, err = hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, _, err = FindActive(t.Context(), fake, UpdatesFeed{}, req, 0)
+	if err != nil || len(actual) != 0 {
+		t.Fatalf("expected nothing without updates, got %d roots: %v", len(actual), err)
+	}
+}
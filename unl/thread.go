@@ -0,0 +1,157 @@
+package unl
+
+import (
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// ActiveThread summarizes an active discussion for comparison across polls. A thread is identified by the ID of its
+// root, so it keeps its identity when the root's title, score, or effective time changes.
+type ActiveThread struct {
+	Root *hn.Item
+	// Comments counts the retrieved items under the root that are not dead or deleted.
+	Comments int
+	// Active counts the comments created after activeAfter.
+	Active int
+	// Users counts the unique authors of the active comments.
+	Users int
+}
+
+// ID returns the stable identity of the thread.
+func (t *ActiveThread) ID() int {
+	return t.Root.ID
+}
+
+// NewActiveThreads summarizes the roots returned by GetActive, in the same order, counting items after activeAfter
+// with boundary as active.
+func NewActiveThreads(
+	roots []*hn.Item,
+	allByParent map[int]hn.ItemSet,
+	activeAfter time.Time,
+	boundary hn.Boundary,
+) []*ActiveThread {
+	threads := make([]*ActiveThread, len(roots))
+
+	for i, root := range roots {
+		thread := &ActiveThread{root, 0, 0, 0}
+		users := make(map[string]struct{})
+
+		for _, item := range FlattenTree(root, allByParent)[1:] {
+			if item.Dead || item.Deleted {
+				continue
+			}
+
+			thread.Comments++
+
+			if boundary.After(item.Time, activeAfter) {
+				thread.Active++
+				users[item.By] = struct{}{}
+			}
+		}
+
+		thread.Users = len(users)
+		threads[i] = thread
+	}
+
+	return threads
+}
+
+// ThreadMetric names a property of an ActiveThread compared by Diff.
+type ThreadMetric string
+
+const (
+	ThreadMetricTitle    ThreadMetric = "title"
+	ThreadMetricScore    ThreadMetric = "score"
+	ThreadMetricComments ThreadMetric = "comments"
+	ThreadMetricActive   ThreadMetric = "active"
+	ThreadMetricUsers    ThreadMetric = "users"
+)
+
+// ThreadChange is a thread present in both polls with the metrics that differ, in ThreadMetric declaration order.
+type ThreadChange struct {
+	Prev    *ActiveThread
+	Next    *ActiveThread
+	Metrics []ThreadMetric
+}
+
+// ThreadDiff holds the differences between two polls of active threads.
+type ThreadDiff struct {
+	Added   []*ActiveThread
+	Removed []*ActiveThread
+	Changed []ThreadChange
+}
+
+// Empty returns true if the polls had the same threads with the same metrics.
+func (d ThreadDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares two polls of active threads by ID. Added and Changed are in the order of next and Removed is in the
+// order of prev. A change in order alone is not a difference. If an ID appears more than once in a poll, the first
+// occurrence is used.
+func Diff(prev []*ActiveThread, next []*ActiveThread) ThreadDiff {
+	var d ThreadDiff
+
+	prevByID := make(map[int]*ActiveThread, len(prev))
+	for _, thread := range prev {
+		if _, ok := prevByID[thread.ID()]; !ok {
+			prevByID[thread.ID()] = thread
+		}
+	}
+
+	nextIDs := make(map[int]struct{}, len(next))
+
+	for _, thread := range next {
+		if _, ok := nextIDs[thread.ID()]; ok {
+			continue
+		}
+
+		nextIDs[thread.ID()] = struct{}{}
+
+		old, ok := prevByID[thread.ID()]
+		if !ok {
+			d.Added = append(d.Added, thread)
+			continue
+		}
+
+		metrics := changedThreadMetrics(old, thread)
+		if len(metrics) > 0 {
+			d.Changed = append(d.Changed, ThreadChange{old, thread, metrics})
+		}
+	}
+
+	for _, thread := range prev {
+		if _, ok := nextIDs[thread.ID()]; !ok && prevByID[thread.ID()] == thread {
+			d.Removed = append(d.Removed, thread)
+		}
+	}
+
+	return d
+}
+
+func changedThreadMetrics(prev *ActiveThread, next *ActiveThread) []ThreadMetric {
+	var metrics []ThreadMetric
+
+	if prev.Root.Title != next.Root.Title {
+		metrics = append(metrics, ThreadMetricTitle)
+	}
+
+	if prev.Root.Score != next.Root.Score {
+		metrics = append(metrics, ThreadMetricScore)
+	}
+
+	if prev.Comments != next.Comments {
+		metrics = append(metrics, ThreadMetricComments)
+	}
+
+	if prev.Active != next.Active {
+		metrics = append(metrics, ThreadMetricActive)
+	}
+
+	if prev.Users != next.Users {
+		metrics = append(metrics, ThreadMetricUsers)
+	}
+
+	return metrics
+}
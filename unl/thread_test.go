@@ -0,0 +1,103 @@
+package unl
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+func TestNewActiveThreads(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"comment","by":"c","parent":2,"time":300},
+		{"id":4,"type":"comment","by":"c","parent":3,"time":400},
+		{"id":5,"type":"comment","by":"d","parent":1,"time":500,"dead":true}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threads := NewActiveThreads(items[:1], allByParent, time.Unix(250, 0), hn.BoundaryInclusive)
+
+	expected := []*ActiveThread{{items[0], 3, 2, 1}}
+	if diff := cmp.Diff(expected, threads); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	// item 2 is exactly at the boundary, within the same second as a limit with a fraction
+	for _, activeAfter := range []time.Time{time.Unix(200, 0), time.Unix(200, 500)} {
+		threads = NewActiveThreads(items[:1], allByParent, activeAfter, hn.BoundaryInclusive)
+
+		expected = []*ActiveThread{{items[0], 3, 3, 2}}
+		if diff := cmp.Diff(expected, threads); diff != "" {
+			t.Fatalf("inclusive at %v diff: %s", activeAfter, diff)
+		}
+
+		threads = NewActiveThreads(items[:1], allByParent, activeAfter, hn.BoundaryExclusive)
+
+		expected = []*ActiveThread{{items[0], 3, 2, 1}}
+		if diff := cmp.Diff(expected, threads); diff != "" {
+			t.Fatalf("exclusive at %v diff: %s", activeAfter, diff)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	root := func(id int, title string, score int) *hn.Item {
+		var item hn.Item
+		item.ID = id
+		item.Title = title
+		item.Score = score
+
+		return &item
+	}
+
+	kept := &ActiveThread{root(1, "kept", 10), 5, 2, 2}
+	removed := &ActiveThread{root(2, "removed", 1), 1, 1, 1}
+	changed := &ActiveThread{root(3, "changed", 1), 1, 1, 1}
+
+	prev := []*ActiveThread{kept, removed, changed}
+
+	// the same thread identity with a new title, score, and comment count; order changes alone are not reported
+	changedNext := &ActiveThread{root(3, "changed title", 5), 4, 1, 1}
+	added := &ActiveThread{root(4, "added", 1), 0, 0, 0}
+	keptNext := &ActiveThread{root(1, "kept", 10), 5, 2, 2}
+
+	d := Diff(prev, []*ActiveThread{added, changedNext, keptNext, added})
+
+	if !slices.Equal(d.Added, []*ActiveThread{added}) || !slices.Equal(d.Removed, []*ActiveThread{removed}) {
+		t.Fatalf("unexpected added %v or removed %v", d.Added, d.Removed)
+	}
+
+	expected := []ThreadChange{
+		{changed, changedNext, []ThreadMetric{ThreadMetricTitle, ThreadMetricScore, ThreadMetricComments}},
+	}
+	if diff := cmp.Diff(expected, d.Changed); diff != "" {
+		t.Fatalf("diff: %s", diff)
+	}
+
+	if d.Empty() || !Diff(prev, prev).Empty() || !Diff(nil, nil).Empty() {
+		t.Fatal("unexpected Empty result")
+	}
+}
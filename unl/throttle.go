@@ -0,0 +1,104 @@
+package unl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var errInvalidQuietHours = errors.New("invalid quiet hours")
+
+// QuietHours is a daily span of local time, from Start up to End as offsets from midnight. A span with an End before
+// its Start wraps past midnight.
+type QuietHours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseQuietHours parses a span like "22:00-07:00".
+func ParseQuietHours(v string) (QuietHours, error) {
+	start, end, ok := strings.Cut(v, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("%w: %s", errInvalidQuietHours, v)
+	}
+
+	var q QuietHours
+
+	for _, part := range []struct {
+		d *time.Duration
+		v string
+	}{{&q.Start, start}, {&q.End, end}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.v))
+		if err != nil {
+			return QuietHours{}, fmt.Errorf("%w: %s", errInvalidQuietHours, v)
+		}
+
+		*part.d = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if q.Start == q.End {
+		return QuietHours{}, fmt.Errorf("%w: %s is empty", errInvalidQuietHours, v)
+	}
+
+	return q, nil
+}
+
+// Contains returns true if t, in its own location, is within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+
+	return offset >= q.Start || offset < q.End
+}
+
+// Throttle limits notifications to outside quiet hours and to at most a number per hour. Notifications that are not
+// allowed are counted so the next allowed notification can summarize them. A Throttle is not safe for concurrent use.
+type Throttle struct {
+	quiet      *QuietHours
+	sent       []time.Time
+	maxPerHour int
+	suppressed int
+}
+
+// NewThrottle creates a Throttle. A nil quiet allows notifications at any time and a maxPerHour of 0 allows any
+// number.
+func NewThrottle(quiet *QuietHours, maxPerHour int) *Throttle {
+	return &Throttle{quiet, nil, maxPerHour, 0}
+}
+
+// Allow returns whether a notification at now may be sent. If it may, Allow also returns the number of notifications
+// suppressed since the last allowed one.
+func (t *Throttle) Allow(now time.Time) (bool, int) {
+	if t.quiet != nil && t.quiet.Contains(now) {
+		t.suppressed++
+		return false, 0
+	}
+
+	if t.maxPerHour > 0 {
+		hourAgo := now.Add(-time.Hour)
+
+		i := 0
+		for i < len(t.sent) && !t.sent[i].After(hourAgo) {
+			i++
+		}
+
+		t.sent = t.sent[i:]
+
+		if len(t.sent) >= t.maxPerHour {
+			t.suppressed++
+			return false, 0
+		}
+
+		t.sent = append(t.sent, now)
+	}
+
+	suppressed := t.suppressed
+	t.suppressed = 0
+
+	return true, suppressed
+}
@@ -0,0 +1,82 @@
+package unl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	t.Parallel()
+
+	q, err := ParseQuietHours("22:00-07:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Start != 22*time.Hour || q.End != 7*time.Hour+30*time.Minute {
+		t.Fatalf("unexpected %+v", q)
+	}
+
+	day := time.Date(2025, 4, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		offset   time.Duration
+		expected bool
+	}{
+		{21*time.Hour + 59*time.Minute, false},
+		{22 * time.Hour, true},
+		{3 * time.Hour, true},
+		{7*time.Hour + 29*time.Minute, true},
+		{7*time.Hour + 30*time.Minute, false},
+		{12 * time.Hour, false},
+	}
+
+	for _, test := range tests {
+		if actual := q.Contains(day.Add(test.offset)); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.offset, test.expected, actual)
+		}
+	}
+
+	for _, v := range []string{"22:00", "25:00-07:00", "07:00-07:00"} {
+		_, err = ParseQuietHours(v)
+		if err == nil {
+			t.Errorf("%s: expected error", v)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	quiet := QuietHours{22 * time.Hour, 23 * time.Hour}
+	throttle := NewThrottle(&quiet, 2)
+	start := time.Date(2025, 4, 20, 20, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		offset     time.Duration
+		allowed    bool
+		suppressed int
+	}{
+		{0, true, 0},
+		{time.Minute, true, 0},
+		{2 * time.Minute, false, 0}, // over the hourly limit
+		{3 * time.Minute, false, 0},
+		{61 * time.Minute, true, 2},              // the first has aged out, so the overflow is summarized
+		{2*time.Hour + 30*time.Minute, false, 0}, // quiet hours
+		{3 * time.Hour, true, 1},
+	}
+
+	for i, step := range steps {
+		allowed, suppressed := throttle.Allow(start.Add(step.offset))
+		if allowed != step.allowed || suppressed != step.suppressed {
+			t.Fatalf("step %d: expected %v %d, got %v %d", i, step.allowed, step.suppressed, allowed, suppressed)
+		}
+	}
+
+	unlimited := NewThrottle(nil, 0)
+	for range 100 {
+		if allowed, _ := unlimited.Allow(start); !allowed {
+			t.Fatal("expected no limit")
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package unl
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// CountActive counts the roots GetActive would return for each combination of the windows and minimum counts of
+// contributors, where counts[i][j] is for windows[i] and minBys[j]. Items are retrieved once for the longest window,
+// since the items active in a shorter window are a subset of them, so trying thresholds costs a single run.
+func CountActive(
+	ctx context.Context,
+	client hn.API,
+	effectiveTimes EffectiveTimes,
+	now time.Time,
+	agedAfter time.Time,
+	windows []time.Duration,
+	minBys []int,
+	options ...Option,
+) ([][]int, error) {
+	var o activeOptions
+	for _, option := range options {
+		option.apply(&o)
+	}
+
+	counts := make([][]int, len(windows))
+	for i := range counts {
+		counts[i] = make([]int, len(minBys))
+	}
+
+	if len(windows) == 0 {
+		return counts, nil
+	}
+
+	_, allByRoot, err := getActiveByRoot(ctx, client, now.Add(-slices.Max(windows)), o)
+	if err != nil {
+		return nil, err
+	}
+
+	for root, tree := range allByRoot {
+		for top, subtree := range o.candidates(root, tree, effectiveTimes, agedAfter) {
+			tuneCount(counts, top, subtree, now, windows, minBys, o)
+		}
+	}
+
+	return counts, nil
+}
+
+// tuneCount adds the tree of root to each combination of windows and minBys it is active for.
+func tuneCount(
+	counts [][]int, root *hn.Item, tree hn.ItemSet, now time.Time, windows []time.Duration, minBys []int, o activeOptions,
+) {
+	for i, window := range windows {
+		active := activeItems(tree, now.Add(-window), o.boundary)
+
+		users := len(active.GroupByBy())
+		if !o.enoughActivity(root, tree, active) {
+			continue
+		}
+
+		for j, minBy := range minBys {
+			if users >= minBy {
+				counts[i][j]++
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package unl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
+)
+
+func TestCountActive(t *testing.T) {
+	t.Parallel()
+
+	client, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := testdata.Clock.Now()
+	agedAfter := now.Add(-8 * time.Hour)
+	windows := []time.Duration{15 * time.Minute, time.Hour, 30 * time.Minute}
+	minBys := []int{1, 3, 5}
+	options := []Option{WithMinActivity(1, DepthWeightedActivity)}
+
+	counts, err := CountActive(t.Context(), client, nil, now, agedAfter, windows, minBys, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the counts match separate runs of GetActive for each combination
+	for i, window := range windows {
+		for j, minBy := range minBys {
+			items, _, err := GetActive(t.Context(), client, nil, now.Add(-window), agedAfter, minBy, 0, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if counts[i][j] != len(items) {
+				t.Errorf("window %s, min-by %d: expected %d, got %d", window, minBy, len(items), counts[i][j])
+			}
+		}
+	}
+
+	if counts[1][0] == 0 || counts[1][0] == counts[1][2] {
+		t.Fatalf("expected the thresholds to make a difference, got %v", counts)
+	}
+}
@@ -6,66 +6,280 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"html"
-	"io"
-	"net/http"
+	"maps"
 	"net/url"
-	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
-	"unicode"
-	"unicode/utf8"
 
 	"github.com/jasonthorsness/unlurker/hn"
-	"golang.org/x/sync/singleflight"
 )
 
+// Option configures GetActive beyond its positional parameters.
+type Option struct {
+	apply func(*activeOptions)
+}
+
+type activeOptions struct {
+	activity    ActivityFunc
+	muted       map[int]struct{}
+	listed      map[int]struct{}
+	truncated   hn.Truncated
+	rootTypes   []RootType
+	minActivity float64
+	maxTreeSize int
+	boundary    hn.Boundary
+	orphans     bool
+}
+
+// ActivityFunc scores the activity of a tree.
+// tree holds every retrieved item under root and active holds the subset active within the window.
+type ActivityFunc func(root *hn.Item, tree hn.ItemSet, active hn.ItemSet) float64
+
+// WithMinActivity requires active roots to also have an activity score of at least minActivity.
+func WithMinActivity(minActivity float64, activity ActivityFunc) Option {
+	return Option{func(o *activeOptions) {
+		o.activity = activity
+		o.minActivity = minActivity
+	}}
+}
+
+// WithBoundary sets whether items created exactly at activeAfter or agedAfter count as after them. The default is
+// hn.BoundaryInclusive; pass the same boundary to BuildActiveMap and NewActiveThreads to agree with GetActive.
+func WithBoundary(boundary hn.Boundary) Option {
+	return Option{func(o *activeOptions) {
+		o.boundary = boundary
+	}}
+}
+
+// WithOrphans keeps the active discussions under dead or deleted roots, which are otherwise skipped with their whole
+// tree. The highest live replies under such a root are returned in its place, each with its subtree and judged on
+// its own; their Parent is not nil, which marks them as orphaned.
+func WithOrphans() Option {
+	return Option{func(o *activeOptions) {
+		o.orphans = true
+	}}
+}
+
+// WithMaxTreeSize caps the items retrieved for each tree at about maxTreeSize, as with hn.Client.GetActiveCapped, so
+// a single enormous thread can't dominate the time to retrieve the rest. Roots of trees that were cut short are added
+// to truncated, if not nil, with the count of active items omitted. The default of 0 lifts the cap.
+func WithMaxTreeSize(maxTreeSize int, truncated hn.Truncated) Option {
+	return Option{func(o *activeOptions) {
+		o.maxTreeSize = maxTreeSize
+		o.truncated = truncated
+	}}
+}
+
+// WithRootTypes restricts active roots to the given types.
+func WithRootTypes(types ...RootType) Option {
+	return Option{func(o *activeOptions) {
+		o.rootTypes = types
+	}}
+}
+
+// WithListedRoots accepts the roots with the given IDs when WithRootTypes would otherwise exclude them, such as the
+// stories on the Ask HN list whose titles do not start with "Ask HN".
+func WithListedRoots(ids ...int) Option {
+	return Option{func(o *activeOptions) {
+		o.listed = make(map[int]struct{}, len(ids))
+		for _, id := range ids {
+			o.listed[id] = struct{}{}
+		}
+	}}
+}
+
+// WithMuted hides the items with the given IDs and all of their descendants, so a muted root never appears and a
+// muted comment no longer counts toward the activity of its thread.
+func WithMuted(ids ...int) Option {
+	return Option{func(o *activeOptions) {
+		o.muted = make(map[int]struct{}, len(ids))
+		for _, id := range ids {
+			o.muted[id] = struct{}{}
+		}
+	}}
+}
+
+// RootType classifies the root of a discussion. Stories are split into Ask HN and Show HN by title prefix.
+type RootType string
+
+const (
+	RootStory RootType = "story"
+	RootAsk   RootType = "ask"
+	RootShow  RootType = "show"
+	RootJob   RootType = "job"
+	RootPoll  RootType = "poll"
+)
+
+var errUnknownRootType = errors.New("unknown root type")
+
+// ParseRootType parses "story", "ask", "show", "job", or "poll" into a RootType.
+func ParseRootType(v string) (RootType, error) {
+	switch t := RootType(v); t {
+	case RootStory, RootAsk, RootShow, RootJob, RootPoll:
+		return t, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errUnknownRootType, v)
+	}
+}
+
+func ClassifyRoot(item *hn.Item) RootType {
+	switch {
+	case item.Type == hn.Job:
+		return RootJob
+	case item.Type == hn.Poll:
+		return RootPoll
+	case strings.HasPrefix(item.Title, "Ask HN"):
+		return RootAsk
+	case strings.HasPrefix(item.Title, "Show HN"):
+		return RootShow
+	default:
+		return RootStory
+	}
+}
+
+// DepthWeightedActivity counts each unique author once, weighted by 1/depth of their shallowest active item,
+// so a direct reply to the root counts 1 and a reply ten levels down counts 0.1.
+func DepthWeightedActivity(root *hn.Item, tree hn.ItemSet, active hn.ItemSet) float64 {
+	weights := make(map[string]float64, len(active))
+
+	for _, item := range active {
+		if item.ID == root.ID {
+			continue
+		}
+
+		depth := 1
+
+		for current := item; current.Parent != nil && *current.Parent != root.ID; depth++ {
+			parent, ok := tree[*current.Parent]
+			if !ok {
+				break
+			}
+
+			current = parent
+		}
+
+		weights[item.By] = max(weights[item.By], 1/float64(depth))
+	}
+
+	var score float64
+	for _, weight := range weights {
+		score += weight
+	}
+
+	return score
+}
+
+// GetActive returns the roots with activity after activeAfter from at least minBy contributors, most recent first,
+// along with the retrieved items grouped by parent. It is FindActive with IDScan. If ctx ends during retrieval, the
+// roots found among the items retrieved so far are returned along with an error wrapping hn.ErrPartialResult.
 func GetActive(
 	ctx context.Context,
-	client *hn.Client,
-	adjustedTimes map[int]int64,
+	client hn.API,
+	effectiveTimes EffectiveTimes,
 	activeAfter time.Time,
 	agedAfter time.Time,
 	minBy int,
 	limit int,
+	options ...Option,
 ) ([]*hn.Item, map[int]hn.ItemSet, error) {
+	req := ActivityRequest{time.Time{}, activeAfter, agedAfter, effectiveTimes, nil, options, minBy}
+
+	items, activity, err := FindActive(ctx, client, IDScan{}, req, limit)
+	if err != nil && !errors.Is(err, hn.ErrPartialResult) {
+		return nil, nil, err
+	}
+
+	return items, activity.ByParent, err
+}
+
+// getActiveByRoot retrieves the items active after activeAfter with their ancestors, grouped by root. A partial
+// result is returned along with its error, as from hn.Client.GetActiveCapped.
+func getActiveByRoot(
+	ctx context.Context, client hn.API, activeAfter time.Time, o activeOptions,
+) (hn.ItemSet, map[*hn.Item]hn.ItemSet, error) {
 	maxID, err := client.GetMaxItem(ctx)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get max item: %w", err)
 	}
 
-	all, err := client.GetActive(ctx, maxID, activeAfter)
-	if err != nil {
+	all, truncated, err := client.GetActiveCapped(ctx, maxID, o.boundary.Inclusive(activeAfter), o.maxTreeSize)
+	if err != nil && !errors.Is(err, hn.ErrPartialResult) {
 		return nil, nil, fmt.Errorf("failed to get active items: %w", err)
 	}
 
+	partialErr := err
+
+	if o.truncated != nil {
+		maps.Copy(o.truncated, truncated)
+	}
+
+	if len(o.muted) > 0 {
+		all = withoutMuted(all, o.muted)
+	}
+
 	allByRoot, err := all.GroupByRoot()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get group by root: %w", err)
 	}
 
-	activeRoots := getActiveRoots(allByRoot, adjustedTimes, agedAfter, activeAfter, minBy)
+	return all, allByRoot, partialErr
+}
+
+// withoutMuted removes the muted items and their descendants. Items are complete up to their roots, as returned by
+// GetActive, so removing whole subtrees leaves no orphans.
+func withoutMuted(all hn.ItemSet, muted map[int]struct{}) hn.ItemSet {
+	hidden := make(map[int]bool, len(all))
 
-	items := activeRoots.OrderByTimeDesc()
+	var isHidden func(item *hn.Item) bool
+	isHidden = func(item *hn.Item) bool {
+		if v, ok := hidden[item.ID]; ok {
+			return v
+		}
+
+		// an item on a parent cycle is left to GroupByRoot to report
+		hidden[item.ID] = false
 
-	items = sortItems(items, adjustedTimes)
+		_, v := muted[item.ID]
+		if !v && item.Parent != nil {
+			if parent, ok := all[*item.Parent]; ok {
+				v = isHidden(parent)
+			}
+		}
 
-	if limit > 0 && len(items) > limit {
-		items = items[:limit]
+		hidden[item.ID] = v
+
+		return v
 	}
 
-	allByParent, _, err := all.GroupByParent()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get group by root: %w", err)
+	return all.Filter(func(item *hn.Item) bool { return !isHidden(item) })
+}
+
+// EffectiveTimes holds the apparent times of items where they might differ from the API,
+// such as second-chance articles whose time is reset when they are pulled onto the front page.
+// A nil EffectiveTimes is valid and adjusts nothing.
+type EffectiveTimes map[int]int64
+
+// Get returns the effective time of the item.
+func (e EffectiveTimes) Get(item *hn.Item) int64 {
+	t, ok := e[item.ID]
+	if ok {
+		return t
 	}
 
-	return items, allByParent, nil
+	return item.Time
 }
 
-func sortItems(items []*hn.Item, adjustedTimes map[int]int64) []*hn.Item {
+// Adjusted returns true if the effective time of the item differs from its API time.
+func (e EffectiveTimes) Adjusted(item *hn.Item) bool {
+	t, ok := e[item.ID]
+
+	return ok && t != item.Time
+}
+
+func sortItems(items []*hn.Item, effectiveTimes EffectiveTimes) []*hn.Item {
 	type itemWithTime struct {
 		item *hn.Item
 		time int64
@@ -74,14 +288,7 @@ func sortItems(items []*hn.Item, adjustedTimes map[int]int64) []*hn.Item {
 	itemsWithTimes := make([]itemWithTime, len(items))
 
 	for i, item := range items {
-		effectiveTime := item.Time
-
-		adjusted, ok := adjustedTimes[item.ID]
-		if ok {
-			effectiveTime = adjusted
-		}
-
-		itemsWithTimes[i] = itemWithTime{item, effectiveTime}
+		itemsWithTimes[i] = itemWithTime{item, effectiveTimes.Get(item)}
 	}
 
 	sort.Slice(itemsWithTimes, func(i, j int) bool {
@@ -103,35 +310,95 @@ func sortItems(items []*hn.Item, adjustedTimes map[int]int64) []*hn.Item {
 
 func getActiveRoots(
 	allByRoot map[*hn.Item]hn.ItemSet,
-	adjustedTimes map[int]int64,
+	effectiveTimes EffectiveTimes,
 	agedAfter time.Time,
 	activeAfter time.Time,
 	minBy int,
+	o activeOptions,
 ) hn.ItemSet {
 	activeRoots := make(hn.ItemSet, len(allByRoot))
 
 	for root, tree := range allByRoot {
-		effectiveRootTime := root.Time
+		for top, subtree := range o.candidates(root, tree, effectiveTimes, agedAfter) {
+			active := activeItems(subtree, activeAfter, o.boundary)
+
+			if len(active.GroupByBy()) < minBy || !o.enoughActivity(top, subtree, active) {
+				continue
+			}
 
-		adjusted, ok := adjustedTimes[root.ID]
-		if ok {
-			effectiveRootTime = adjusted
+			activeRoots[top.ID] = top
 		}
+	}
 
-		if root.Dead || root.Deleted || !time.Unix(effectiveRootTime, 0).After(agedAfter) {
-			continue
+	return activeRoots
+}
+
+// candidates returns the trees under root that may be active, keyed by the item to show as their root: root itself
+// if it is eligible, the orphaned subtrees of a dead or deleted root with WithOrphans, or none.
+func (o *activeOptions) candidates(
+	root *hn.Item, tree hn.ItemSet, effectiveTimes EffectiveTimes, agedAfter time.Time,
+) map[*hn.Item]hn.ItemSet {
+	if !o.eligible(root, effectiveTimes, agedAfter) {
+		return nil
+	}
+
+	if !root.Dead && !root.Deleted {
+		return map[*hn.Item]hn.ItemSet{root: tree}
+	}
+
+	if !o.orphans {
+		return nil
+	}
+
+	byParent, _, _ := tree.GroupByParent()
+	orphans := make(map[*hn.Item]hn.ItemSet)
+
+	var visit func(parent *hn.Item)
+	visit = func(parent *hn.Item) {
+		for _, child := range byParent[parent.ID] {
+			if child.Dead || child.Deleted {
+				visit(child)
+				continue
+			}
+
+			subtree := make(hn.ItemSet)
+			for _, item := range FlattenTree(child, byParent) {
+				subtree[item.ID] = item.Item
+			}
+
+			orphans[child] = subtree
 		}
+	}
 
-		active := tree.Filter(func(item *hn.Item) bool {
-			return !item.Dead && !item.Deleted && time.Unix(item.Time, 0).After(activeAfter)
-		})
+	visit(root)
 
-		if len(active.GroupByBy()) >= minBy {
-			activeRoots[root.ID] = root
+	return orphans
+}
+
+// eligible reports whether the tree of root may be active by the root alone: it is newer than agedAfter and of an
+// included type. Dead and deleted roots are left to candidates.
+func (o *activeOptions) eligible(root *hn.Item, effectiveTimes EffectiveTimes, agedAfter time.Time) bool {
+	if !o.boundary.After(effectiveTimes.Get(root), agedAfter) {
+		return false
+	}
+
+	if o.rootTypes != nil && !slices.Contains(o.rootTypes, ClassifyRoot(root)) {
+		if _, ok := o.listed[root.ID]; !ok {
+			return false
 		}
 	}
 
-	return activeRoots
+	return true
+}
+
+func (o *activeOptions) enoughActivity(root *hn.Item, tree hn.ItemSet, active hn.ItemSet) bool {
+	return o.activity == nil || o.activity(root, tree, active) >= o.minActivity
+}
+
+func activeItems(tree hn.ItemSet, activeAfter time.Time, boundary hn.Boundary) hn.ItemSet {
+	return tree.Filter(func(item *hn.Item) bool {
+		return !item.Dead && !item.Deleted && boundary.After(item.Time, activeAfter)
+	})
 }
 
 type ItemWithDepth struct {
@@ -141,30 +408,165 @@ type ItemWithDepth struct {
 }
 
 type treeTraverser struct {
-	items []*ItemWithDepth
+	err     error
+	visited map[int]struct{}
+	items   []*ItemWithDepth
+	order   ChildOrder
+}
+
+// treeFrame is an item on the explicit stack of treeTraverser, with the children still to visit.
+type treeFrame struct {
+	self     *ItemWithDepth
+	children []*hn.Item
+}
+
+// ChildOrder determines the order of sibling items when flattening a tree.
+type ChildOrder int
+
+const (
+	// ChildOrderTime orders siblings newest first.
+	ChildOrderTime ChildOrder = iota
+	// ChildOrderKids orders siblings by their position in the parent's Kids, which is HN's ranking.
+	ChildOrderKids
+	// ChildOrderScore orders siblings by score, then newest first. The API only returns scores for stories and
+	// polls so comments fall back to time.
+	ChildOrderScore
+)
+
+var errUnknownChildOrder = errors.New("unknown child order")
+
+// ParseChildOrder parses "time", "kids", or "score" into a ChildOrder.
+func ParseChildOrder(v string) (ChildOrder, error) {
+	switch v {
+	case "time":
+		return ChildOrderTime, nil
+	case "kids":
+		return ChildOrderKids, nil
+	case "score":
+		return ChildOrderScore, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", errUnknownChildOrder, v)
+	}
+}
+
+func (o ChildOrder) String() string {
+	switch o {
+	case ChildOrderKids:
+		return "kids"
+	case ChildOrderScore:
+		return "score"
+	default:
+		return "time"
+	}
 }
 
 func FlattenTree(item *hn.Item, allByParent map[int]hn.ItemSet) []*ItemWithDepth {
-	tt := &treeTraverser{items: make([]*ItemWithDepth, 0, 1+len(allByParent[item.ID]))}
+	return FlattenTreeOrdered(item, allByParent, ChildOrderTime)
+}
 
-	tt.traverseTreeRecurse(item, allByParent, 0)
+// FlattenTreeOrdered returns item and the items under it in allByParent in thread order, with siblings in order.
+// Links that don't form a tree are cut rather than followed forever: an item reached again is skipped along with
+// what is under it, as are the items more than hn.MaxTreeDepth below item. FlattenTreeChecked reports them.
+func FlattenTreeOrdered(item *hn.Item, allByParent map[int]hn.ItemSet, order ChildOrder) []*ItemWithDepth {
+	items, _ := FlattenTreeChecked(item, allByParent, order)
+
+	return items
+}
+
+// FlattenTreeChecked is FlattenTreeOrdered that also returns an *hn.TreeError for the first link it had to cut, along
+// with the items it could flatten.
+func FlattenTreeChecked(item *hn.Item, allByParent map[int]hn.ItemSet, order ChildOrder) ([]*ItemWithDepth, error) {
+	tt := &treeTraverser{
+		nil, make(map[int]struct{}), make([]*ItemWithDepth, 0, 1+len(allByParent[item.ID])), order,
+	}
+
+	tt.traverse(item, allByParent)
+
+	return tt.items, tt.err
+}
+
+// traverse visits the tree in thread order with an explicit stack, so a deep thread costs heap rather than goroutine
+// stack. Each item's NormalizedTime becomes the earliest time in its subtree once its last child is done.
+func (tt *treeTraverser) traverse(item *hn.Item, allByParent map[int]hn.ItemSet) {
+	stack := []treeFrame{tt.visit(item, allByParent, 0)}
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+
+		if len(top.children) == 0 {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1].self
+				parent.NormalizedTime = min(parent.NormalizedTime, top.self.NormalizedTime)
+			}
+
+			continue
+		}
+
+		child := top.children[0]
+		top.children = top.children[1:]
+
+		if _, ok := tt.visited[child.ID]; ok {
+			tt.fail(hn.ErrParentCycle, child)
+			continue
+		}
+
+		if top.self.Depth+1 > hn.MaxTreeDepth {
+			tt.fail(hn.ErrTreeTooDeep, child)
+			continue
+		}
 
-	return tt.items
+		stack = append(stack, tt.visit(child, allByParent, top.self.Depth+1))
+	}
 }
 
-func (tt *treeTraverser) traverseTreeRecurse(item *hn.Item, allByParent map[int]hn.ItemSet, depth int) int64 {
+func (tt *treeTraverser) visit(item *hn.Item, allByParent map[int]hn.ItemSet, depth int) treeFrame {
 	self := &ItemWithDepth{item, item.Time, depth}
 
+	tt.visited[item.ID] = struct{}{}
 	tt.items = append(tt.items, self)
 
-	children := allByParent[item.ID]
+	return treeFrame{self, orderChildren(item, allByParent[item.ID], tt.order)}
+}
+
+func (tt *treeTraverser) fail(err error, item *hn.Item) {
+	if tt.err == nil {
+		tt.err = &hn.TreeError{Err: err, ID: item.ID}
+	}
+}
+
+func orderChildren(parent *hn.Item, children hn.ItemSet, order ChildOrder) []*hn.Item {
 	cc := children.OrderByTimeDesc()
 
-	for _, child := range cc {
-		self.NormalizedTime = min(self.NormalizedTime, tt.traverseTreeRecurse(child, allByParent, depth+1))
+	switch order {
+	case ChildOrderKids:
+		rank := make(map[int]int, len(parent.Kids))
+		for i, id := range parent.Kids {
+			rank[id] = i
+		}
+
+		// children missing from Kids (e.g. the parent is older than the children in the cache) go last
+		sort.SliceStable(cc, func(i, j int) bool {
+			ri, ok := rank[cc[i].ID]
+			if !ok {
+				ri = len(parent.Kids)
+			}
+
+			rj, ok := rank[cc[j].ID]
+			if !ok {
+				rj = len(parent.Kids)
+			}
+
+			return ri < rj
+		})
+	case ChildOrderScore:
+		sort.SliceStable(cc, func(i, j int) bool {
+			return cc[i].Score > cc[j].Score
+		})
+	case ChildOrderTime:
 	}
 
-	return self.NormalizedTime
+	return cc
 }
 
 type ActiveMapEntry uint8
@@ -175,11 +577,11 @@ const (
 	ActiveMapChild    = 2
 )
 
-func BuildActiveMap(flat []*ItemWithDepth, activeAfter time.Time) map[int]ActiveMapEntry {
+func BuildActiveMap(flat []*ItemWithDepth, activeAfter time.Time, boundary hn.Boundary) map[int]ActiveMapEntry {
 	activeMap := make(map[int]ActiveMapEntry, len(flat))
 
 	for _, item := range flat {
-		active := time.Unix(item.Time, 0).After(activeAfter) && !item.Dead && !item.Deleted
+		active := boundary.After(item.Time, activeAfter) && !item.Dead && !item.Deleted
 		if !active {
 			continue
 		}
@@ -230,69 +632,70 @@ func PrettyFormatURL(v string) string {
 	return host
 }
 
-var (
-	linkRegex = regexp.MustCompile(`(?i)<a\s+href="([^"]*)[^>]*">.*?</a>`)
-
-	//nolint:gochecknoglobals // excluded type
-	tagStripper = strings.NewReplacer(
-		"<p>", " ", "</p>", " ",
-		"<b>", " ", "</b>", " ",
-		"<i>", " ", "</i>", " ",
-		"<pre>", " ", "</pre>", " ",
-		"<code>", " ", "</code>", " ",
-	)
-)
+// PrettyWrapText wraps cleaned text into at most maxLines lines of at most width runes, breaking on spaces.
+// Words longer than width are split. If the text does not fit, the last line ends with an ellipsis.
+func PrettyWrapText(v string, width int, maxLines int) []string {
+	if width <= 0 || maxLines <= 0 {
+		return nil
+	}
 
-func PrettyCleanText(v string) string {
-	v = html.UnescapeString(v)
-	v = strings.Map(func(r rune) rune {
-		switch {
-		case r < ' ':
-			return ' '
-		case r < utf8.RuneSelf || (unicode.IsPrint(r) && !unicode.IsSpace(r)):
-			return r
-		default:
-			return ' '
-		}
-	}, v)
+	lines := make([]string, 0, maxLines)
+	line := make([]rune, 0, width)
 
-	v = tagStripper.Replace(v)
-	v = linkRegex.ReplaceAllString(v, " $1 ")
-	v = collapseSpaces(v)
+	flush := func() {
+		lines = append(lines, string(line))
+		line = line[:0]
+	}
 
-	return v
-}
+	for _, word := range strings.Fields(v) {
+		w := []rune(word)
 
-func collapseSpaces(v string) string {
-	sb := strings.Builder{}
-	sb.Grow(len(v))
+		for len(w) > 0 {
+			if len(line) > 0 && len(line)+1+len(w) > width {
+				flush()
+			}
 
-	inWord := false
+			if len(lines) == maxLines {
+				return ellipsizeLastLine(lines, width)
+			}
 
-	for _, r := range v {
-		switch {
-		case r == ' ':
-			if inWord {
-				sb.WriteByte(' ')
+			if len(line) > 0 {
+				line = append(line, ' ')
 			}
 
-			inWord = false
-		case r < utf8.RuneSelf:
-			sb.WriteByte(byte(r))
+			n := min(len(w), width-len(line))
+			line = append(line, w[:n]...)
+			w = w[n:]
 
-			inWord = true
-		default:
-			sb.WriteRune(r)
+			if len(w) > 0 {
+				flush()
+			}
+		}
+	}
 
-			inWord = true
+	if len(line) > 0 {
+		if len(lines) == maxLines {
+			return ellipsizeLastLine(lines, width)
 		}
+
+		flush()
 	}
 
-	v = sb.String()
+	return lines
+}
+
+// PrettyEllipsis ends text truncated by PrettyWrapText.
+const PrettyEllipsis = "…"
+
+func ellipsizeLastLine(lines []string, width int) []string {
+	last := []rune(lines[len(lines)-1])
+	if len(last) >= width {
+		last = last[:width-1]
+	}
 
-	v = strings.TrimSpace(v)
+	lines[len(lines)-1] = string(last) + PrettyEllipsis
 
-	return v
+	return lines
 }
 
 var smallDurations []string //nolint:gochecknoglobals // string cache
@@ -341,136 +744,3 @@ func prettyFormatMinutes(totalMinutes int) string {
 
 	return strconv.Itoa(hours) + padding + ms + "m"
 }
-
-// Second-chance article functionality
-
-var (
-	fetchCache            atomic.Value       //nolint:gochecknoglobals // cache for front page times
-	fetchGroup            singleflight.Group //nolint:gochecknoglobals // deduplication for front page requests
-	frontPageAgeExtractor = regexp.MustCompile(
-		`<span class="age" title="[^"]+\s+(\d+)"><a href="item\?id=(\d+)">([^<]+) ago</a></span>`)
-)
-
-type fetchCacheEntry struct {
-	data map[int]int64
-	ts   time.Time
-}
-
-var (
-	errStatusNotOK                = errors.New("status not ok")
-	errUnexpectedSingleflightType = errors.New("unexpected type from singleflight")
-)
-
-// FetchFrontPageTimes retrieves the current apparent times of articles on HN's front page
-// for detecting second-chance articles (articles pulled from the second-chance pool).
-func FetchFrontPageTimes(ctx context.Context, now time.Time) (map[int]int64, error) {
-	entry, ok := fetchCache.Load().(*fetchCacheEntry)
-	if ok {
-		if time.Since(entry.ts) < time.Minute {
-			return entry.data, nil
-		}
-	}
-
-	v, err, _ := fetchGroup.Do(
-		"frontpage",
-		func() (interface{}, error) { return fetchFrontPageTimesInner(ctx, now) })
-	if err != nil {
-		return nil, fmt.Errorf("singleflight frontpage failed: %w", err)
-	}
-
-	times, ok := v.(map[int]int64)
-	if !ok {
-		return nil, fmt.Errorf("%w: %T", errUnexpectedSingleflightType, v)
-	}
-
-	return times, nil
-}
-
-func fetchFrontPageTimesInner(ctx context.Context, now time.Time) (interface{}, error) {
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://news.ycombinator.com", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	res, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-
-	defer func() { _ = res.Body.Close() }()
-
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%w: %s", errStatusNotOK, res.Status)
-	}
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read body: %w", err)
-	}
-
-	matches := frontPageAgeExtractor.FindAllSubmatch(body, -1)
-	m := make(map[int]int64, len(matches))
-
-	for _, match := range matches {
-		ts, err := strconv.ParseInt(string(match[1]), 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse time: %w", err)
-		}
-
-		t := time.Unix(ts, 0)
-
-		id, err := strconv.Atoi(string(match[2]))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse id: %w", err)
-		}
-
-		age, gap, err := parseAge(string(match[3]))
-		if err != nil {
-			return nil, err
-		}
-
-		diff := now.Sub(t) - age
-		if diff > gap {
-			m[id] = now.Add(-age).Unix()
-		} else {
-			m[id] = ts
-		}
-	}
-
-	fetchCache.Store(&fetchCacheEntry{
-		data: m,
-		ts:   time.Now(),
-	})
-
-	return m, nil
-}
-
-var errUnexpectedAgeFormat = errors.New("unexpected age format")
-
-var relativeAgeRegex = regexp.MustCompile(
-	`^\s*(\d+)\s+(hour|hours|minute|minutes|day|days)\s*$`)
-
-func parseAge(s string) (time.Duration, time.Duration, error) {
-	m := relativeAgeRegex.FindStringSubmatch(s)
-	if m == nil {
-		return 0, 0, fmt.Errorf("%w: %q", errUnexpectedAgeFormat, s)
-	}
-
-	n, err := strconv.Atoi(m[1])
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to parse age: %w", err)
-	}
-
-	const oneDayDuration = 24 * time.Hour
-
-	switch m[2] {
-	case "minute", "minutes":
-		return time.Duration(n) * time.Minute, 1 * time.Hour, nil
-	case "hour", "hours":
-		return time.Duration(n) * time.Hour, 2 * time.Hour, nil
-	case "day", "days":
-		return time.Duration(n) * oneDayDuration, oneDayDuration, nil
-	default:
-		return 0, 0, fmt.Errorf("%w: %q", errUnexpectedAgeFormat, m[2])
-	}
-}
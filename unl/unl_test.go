@@ -1,13 +1,18 @@
 package unl
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/jasonthorsness/unlurker/hn"
-	"github.com/jasonthorsness/unlurker/testdata"
+	"github.com/jasonthorsness/unlurker/hn/hntest"
+	"github.com/jasonthorsness/unlurker/hn/testdata"
 )
 
 func BenchmarkPrettyFormatDuration(b *testing.B) {
@@ -46,3 +51,658 @@ func BenchmarkPrettyFormatTitle(b *testing.B) {
 		}
 	})
 }
+
+func TestFlattenTreeOrdered(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","time":100,"kids":[3,4,2]},
+		{"id":2,"type":"comment","parent":1,"time":300,"score":1},
+		{"id":3,"type":"comment","parent":1,"time":200,"score":3},
+		{"id":4,"type":"comment","parent":1,"time":400,"score":2},
+		{"id":5,"type":"comment","parent":1,"time":500}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expected []int
+		order    ChildOrder
+	}{
+		{[]int{1, 5, 4, 2, 3}, ChildOrderTime},
+		{[]int{1, 3, 4, 2, 5}, ChildOrderKids},
+		{[]int{1, 3, 4, 2, 5}, ChildOrderScore},
+	}
+
+	for _, test := range tests {
+		flat := FlattenTreeOrdered(items[0], allByParent, test.order)
+
+		ids := make([]int, len(flat))
+		for i, item := range flat {
+			ids[i] = item.ID
+		}
+
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.order, test.expected, ids)
+		}
+
+		order, err := ParseChildOrder(test.order.String())
+		if err != nil || order != test.order {
+			t.Errorf("%s: failed to round trip: %v", test.order, err)
+		}
+	}
+
+	_, err = ParseChildOrder("random")
+	if err == nil {
+		t.Fatal("expected error for unknown child order")
+	}
+}
+
+// deepThread returns a story with a single chain of depth replies under it, each a second after its parent, and the
+// replies grouped by parent.
+func deepThread(depth int) (*hn.Item, map[int]hn.ItemSet) {
+	all := make(hn.ItemSet, depth+1)
+
+	for id := 1; id <= depth+1; id++ {
+		var item hn.Item
+		item.ID = id
+		item.Time = int64(id)
+
+		if id > 1 {
+			parent := id - 1
+			item.Parent = &parent
+		}
+
+		all[id] = &item
+	}
+
+	allByParent, _, _ := all.GroupByParent()
+
+	return all[1], allByParent
+}
+
+func TestFlattenTreeDeep(t *testing.T) {
+	t.Parallel()
+
+	root, allByParent := deepThread(hn.MaxTreeDepth)
+
+	flat, err := FlattenTreeChecked(root, allByParent, ChildOrderTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(flat) != hn.MaxTreeDepth+1 {
+		t.Fatalf("expected %d items, got %d", hn.MaxTreeDepth+1, len(flat))
+	}
+
+	for i, item := range flat {
+		if item.ID != i+1 || item.Depth != i || item.NormalizedTime != item.Time {
+			t.Fatalf("expected item %d at depth %d, got item %d at depth %d with time %d",
+				i+1, i, item.ID, item.Depth, item.NormalizedTime)
+		}
+	}
+
+	root, allByParent = deepThread(hn.MaxTreeDepth + 1)
+
+	flat, err = FlattenTreeChecked(root, allByParent, ChildOrderTime)
+	if !errors.Is(err, hn.ErrTreeTooDeep) || len(flat) != hn.MaxTreeDepth+1 {
+		t.Fatalf("expected the reply beyond MaxTreeDepth to be cut, got %d items and %v", len(flat), err)
+	}
+}
+
+func BenchmarkFlattenTree(b *testing.B) {
+	// a wide thread with a long chain under every reply
+	const width, depth = 50, 200
+
+	all := make(hn.ItemSet, 1+width*depth)
+
+	var story hn.Item
+	story.ID = 1
+	all[1] = &story
+
+	for i := range width {
+		for j := range depth {
+			parent := 1
+			if j > 0 {
+				parent = 1 + i*depth + j
+			}
+
+			var item hn.Item
+			item.ID = 2 + i*depth + j
+			item.Time = int64(item.ID)
+			item.Parent = &parent
+			all[item.ID] = &item
+		}
+	}
+
+	allByParent, _, err := all.GroupByParent()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		_ = FlattenTree(&story, allByParent)
+	}
+}
+
+func FuzzFlattenTree(f *testing.F) {
+	f.Add([]byte{0, 1, 1, 2})
+	f.Add([]byte{2, 1})
+	f.Add([]byte{1})
+	f.Add([]byte{3, 1, 2, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const maxItems = 64
+
+		data = data[:min(len(data), maxItems)]
+		if len(data) == 0 {
+			return
+		}
+
+		// item i+1 has the parent data[i] modulo one past the last item, with 0 for none
+		all := make(hn.ItemSet, len(data))
+
+		for i, b := range data {
+			var item hn.Item
+			item.ID = i + 1
+			item.Time = int64(i)
+
+			if parent := int(b) % (len(data) + 1); parent != 0 {
+				item.Parent = &parent
+			}
+
+			all[item.ID] = &item
+		}
+
+		// the items under the first are those whose parents lead back to it, which loops only if it is on a cycle
+		under := 0
+		onCycle := false
+
+		for _, item := range all {
+			current := item
+
+			for range len(all) {
+				if current.ID == 1 {
+					under++
+					break
+				}
+
+				if current.Parent == nil {
+					break
+				}
+
+				current = all[*current.Parent]
+			}
+		}
+
+		for current, i := all[1], 0; current.Parent != nil && i < len(all); i++ {
+			current = all[*current.Parent]
+			if current.ID == 1 {
+				onCycle = true
+				break
+			}
+		}
+
+		allByParent, _, err := all.GroupByParent()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		flat, err := FlattenTreeChecked(all[1], allByParent, ChildOrderTime)
+
+		seen := make(map[int]struct{}, len(flat))
+		for _, item := range flat {
+			if _, ok := seen[item.ID]; ok {
+				t.Fatalf("item %d flattened twice", item.ID)
+			}
+
+			seen[item.ID] = struct{}{}
+		}
+
+		if len(flat) != under {
+			t.Fatalf("expected %d items, got %d", under, len(flat))
+		}
+
+		var treeErr *hn.TreeError
+		if onCycle != (errors.As(err, &treeErr) && errors.Is(err, hn.ErrParentCycle)) {
+			t.Fatalf("expected a cycle error %v, got %v", onCycle, err)
+		}
+	})
+}
+
+func TestDepthWeightedActivity(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"comment","by":"c","parent":2,"time":300},
+		{"id":4,"type":"comment","by":"c","parent":3,"time":400},
+		{"id":5,"type":"comment","by":"d","parent":4,"time":500},
+		{"id":6,"type":"comment","by":"b","parent":5,"time":600}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		tree[item.ID] = item
+	}
+
+	// b at depth 1, c at depth 2, d at depth 4; the root itself does not count
+	const expected = 1 + 1.0/2 + 1.0/4
+
+	score := DepthWeightedActivity(items[0], tree, tree)
+	if score != expected {
+		t.Fatalf("expected %v, got %v", expected, score)
+	}
+}
+
+func TestClassifyRoot(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		itemType hn.ItemType
+		title    string
+		expected RootType
+	}{
+		{hn.Story, "Ask HN: What are you working on?", RootAsk},
+		{hn.Story, "Show HN: A thing I made", RootShow},
+		{hn.Story, "An article about Show HN", RootStory},
+		{hn.Job, "Acme (YC S25) is hiring", RootJob},
+		{hn.Poll, "Ask HN: Poll: Tabs or spaces?", RootPoll},
+	}
+
+	for _, test := range tests {
+		var item hn.Item
+		item.Type = test.itemType
+		item.Title = test.title
+
+		actual := ClassifyRoot(&item)
+		if actual != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.title, test.expected, actual)
+		}
+
+		parsed, err := ParseRootType(string(actual))
+		if err != nil || parsed != actual {
+			t.Errorf("%s: failed to round trip: %v", actual, err)
+		}
+	}
+
+	_, err := ParseRootType("comment")
+	if err == nil {
+		t.Fatal("expected error for unknown root type")
+	}
+}
+
+func TestPrettyWrapText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text     string
+		expected []string
+		width    int
+		maxLines int
+	}{
+		{"the quick brown fox", []string{"the quick", "brown fox"}, 10, 2},
+		{"the quick brown fox jumps", []string{"the quick", "brown fox…"}, 10, 2},
+		{"the quick brown fox jumps", []string{"the quick brown fox jumps"}, 100, 1},
+		{"abcdefghijkl xyz", []string{"abcdef", "ghijkl", "xyz"}, 6, 3},
+		{"abcdefghijkl", []string{"abcd…"}, 5, 1},
+		{"", []string{}, 10, 2},
+		{"text", nil, 10, 0},
+	}
+
+	for _, test := range tests {
+		actual := PrettyWrapText(test.text, test.width, test.maxLines)
+		if !slices.Equal(actual, test.expected) {
+			t.Errorf("%q (%d, %d): expected %q, got %q", test.text, test.width, test.maxLines, test.expected, actual)
+		}
+	}
+}
+
+func TestEffectiveTimes(t *testing.T) {
+	t.Parallel()
+
+	var adjusted, unchanged, absent hn.Item
+	adjusted.ID, adjusted.Time = 1, 100
+	unchanged.ID, unchanged.Time = 2, 200
+	absent.ID, absent.Time = 3, 300
+
+	times := EffectiveTimes{1: 150, 2: 200}
+
+	if times.Get(&adjusted) != 150 || !times.Adjusted(&adjusted) {
+		t.Fatal("expected adjusted time for item 1")
+	}
+
+	if times.Get(&unchanged) != 200 || times.Adjusted(&unchanged) {
+		t.Fatal("expected unchanged time for item 2")
+	}
+
+	var none EffectiveTimes
+
+	if times.Get(&absent) != 300 || times.Adjusted(&absent) || none.Get(&absent) != 300 || none.Adjusted(&absent) {
+		t.Fatal("expected API time for item 3")
+	}
+}
+
+func TestWithMuted(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"comment","by":"c","parent":2,"time":300},
+		{"id":4,"type":"comment","by":"d","parent":1,"time":400}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	client := hntest.NewFakeClient(all)
+	activeAfter, agedAfter := time.Unix(150, 0), time.Unix(0, 0)
+
+	tests := []struct {
+		muted    []int
+		expected int
+	}{
+		{nil, 1},
+		// muting comment 2 also hides its reply 3, leaving only d active
+		{[]int{2}, 0},
+		{[]int{1}, 0},
+		{[]int{3}, 0},
+		{[]int{5}, 1},
+	}
+
+	for _, test := range tests {
+		roots, _, err := GetActive(t.Context(), client, nil, activeAfter, agedAfter, 3, 0, WithMuted(test.muted...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(roots) != test.expected {
+			t.Errorf("muted %v: expected %d roots, got %d", test.muted, test.expected, len(roots))
+		}
+	}
+
+	_, allByParent, err := GetActive(t.Context(), client, nil, activeAfter, agedAfter, 2, 0, WithMuted(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(allByParent[1]) != 1 || len(allByParent[2]) != 0 {
+		t.Fatalf("expected the muted subtree to be removed, got %v", allByParent)
+	}
+}
+
+func TestWithBoundary(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"story","by":"a","time":100},
+		{"id":4,"type":"comment","by":"b","parent":3,"time":201}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	client := hntest.NewFakeClient(all)
+
+	tests := []struct {
+		options     []Option
+		activeAfter time.Time
+		agedAfter   time.Time
+		expected    []int
+	}{
+		// comment 2 is exactly at the window so both roots are active by default
+		{nil, time.Unix(200, 0), time.Unix(0, 0), []int{3, 1}},
+		{nil, time.Unix(200, 500), time.Unix(0, 0), []int{3, 1}},
+		{[]Option{WithBoundary(hn.BoundaryExclusive)}, time.Unix(200, 0), time.Unix(0, 0), []int{3}},
+		{[]Option{WithBoundary(hn.BoundaryExclusive)}, time.Unix(200, 500), time.Unix(0, 0), []int{3}},
+		// the roots are exactly at the max age
+		{nil, time.Unix(150, 0), time.Unix(100, 0), []int{3, 1}},
+		{[]Option{WithBoundary(hn.BoundaryExclusive)}, time.Unix(150, 0), time.Unix(100, 0), []int{}},
+	}
+
+	for _, test := range tests {
+		roots, _, err := GetActive(t.Context(), client, nil, test.activeAfter, test.agedAfter, 1, 0, test.options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int, len(roots))
+		for i, root := range roots {
+			ids[i] = root.ID
+		}
+
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("after %v aged %v: expected roots %v, got %v", test.activeAfter, test.agedAfter, test.expected, ids)
+		}
+	}
+}
+
+func TestWithOrphans(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","time":100,"deleted":true},
+		{"id":2,"type":"comment","by":"a","parent":1,"time":200,"dead":true},
+		{"id":3,"type":"comment","by":"b","parent":2,"time":300},
+		{"id":4,"type":"comment","by":"c","parent":3,"time":400},
+		{"id":5,"type":"comment","by":"d","parent":4,"time":500},
+		{"id":6,"type":"comment","by":"e","parent":1,"time":600}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	client := hntest.NewFakeClient(all)
+	activeAfter, agedAfter := time.Unix(250, 0), time.Unix(0, 0)
+
+	roots, _, err := GetActive(t.Context(), client, nil, activeAfter, agedAfter, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roots) != 0 {
+		t.Fatalf("expected the deleted root to be skipped, got %v", roots)
+	}
+
+	// 3 is the highest live reply of its subthread; 6 has too few contributors
+	roots, allByParent, err := GetActive(t.Context(), client, nil, activeAfter, agedAfter, 2, 0, WithOrphans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roots) != 1 || roots[0].ID != 3 {
+		t.Fatalf("expected orphaned root 3, got %v", roots)
+	}
+
+	if flat := FlattenTree(roots[0], allByParent); len(flat) != 3 {
+		t.Fatalf("expected the subtree of 3, got %d items", len(flat))
+	}
+}
+
+func TestWithMaxTreeSize(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","time":100},
+		{"id":2,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":3,"type":"comment","by":"c","parent":1,"time":300},
+		{"id":4,"type":"comment","by":"d","parent":1,"time":400},
+		{"id":5,"type":"comment","by":"e","parent":1,"time":500},
+		{"id":6,"type":"comment","by":"f","parent":1,"time":600}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	client := hntest.NewFakeClient(all)
+	activeAfter, agedAfter := time.Unix(150, 0), time.Unix(0, 0)
+
+	truncated := make(hn.Truncated)
+
+	// the root and its two newest replies fill the tree
+	roots, allByParent, err := GetActive(
+		t.Context(), client, nil, activeAfter, agedAfter, 2, 0, WithMaxTreeSize(3, truncated))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roots) != 1 || len(allByParent[1]) != 2 || truncated[1] != 3 {
+		t.Fatalf("expected 2 of 5 replies with 3 truncated, got %v and %v", allByParent[1], truncated)
+	}
+
+	truncated = make(hn.Truncated)
+
+	_, allByParent, err = GetActive(
+		t.Context(), client, nil, activeAfter, agedAfter, 2, 0, WithMaxTreeSize(0, truncated))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(allByParent[1]) != 5 || len(truncated) != 0 {
+		t.Fatalf("expected every reply without a cap, got %v and %v", allByParent[1], truncated)
+	}
+}
+
+// partialClient answers GetActiveCapped with everything it finds but reports it as cut short by a deadline.
+type partialClient struct {
+	*hntest.FakeClient
+}
+
+func (c partialClient) GetActiveCapped(
+	ctx context.Context, maxID int, activeAfter time.Time, maxTreeSize int,
+) (hn.ItemSet, hn.Truncated, error) {
+	all, truncated, err := c.FakeClient.GetActiveCapped(ctx, maxID, activeAfter, maxTreeSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return all, truncated, fmt.Errorf("%w: %w", hn.ErrPartialResult, context.DeadlineExceeded)
+}
+
+func TestGetActivePartial(t *testing.T) {
+	t.Parallel()
+
+	fake, err := hntest.NewFakeClientFromTestdata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activeAfter, agedAfter := testdata.MaxTime.Add(-time.Hour), testdata.MaxTime.Add(-24*time.Hour)
+
+	expected, _, err := GetActive(t.Context(), fake, nil, activeAfter, agedAfter, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the roots found so far are returned along with the error
+	roots, allByParent, err := GetActive(t.Context(), partialClient{fake}, nil, activeAfter, agedAfter, 2, 0)
+	if !errors.Is(err, hn.ErrPartialResult) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a partial result, got %v", err)
+	}
+
+	if len(roots) != len(expected) || len(allByParent) == 0 {
+		t.Fatalf("expected %d roots, got %d", len(expected), len(roots))
+	}
+}
+
+func TestWithListedRoots(t *testing.T) {
+	t.Parallel()
+
+	var items []*hn.Item
+
+	err := json.Unmarshal([]byte(`[
+		{"id":1,"type":"story","by":"a","title":"Ask HN: Anything?","time":100},
+		{"id":2,"type":"story","by":"a","title":"Tell HN: Something","time":100},
+		{"id":3,"type":"story","by":"a","title":"Something else","time":100},
+		{"id":4,"type":"comment","by":"b","parent":1,"time":200},
+		{"id":5,"type":"comment","by":"b","parent":2,"time":200},
+		{"id":6,"type":"comment","by":"b","parent":3,"time":200}
+	]`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := make(hn.ItemSet, len(items))
+	for _, item := range items {
+		all[item.ID] = item
+	}
+
+	client := hntest.NewFakeClient(all)
+	activeAfter, agedAfter := time.Unix(150, 0), time.Unix(0, 0)
+
+	tests := []struct {
+		listed   []int
+		expected []int
+	}{
+		{nil, []int{1}},
+		{[]int{2}, []int{2, 1}},
+		{[]int{1, 2}, []int{2, 1}},
+	}
+
+	for _, test := range tests {
+		roots, _, err := GetActive(t.Context(), client, nil, activeAfter, agedAfter, 1, 0,
+			WithRootTypes(RootAsk), WithListedRoots(test.listed...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int, len(roots))
+		for i, root := range roots {
+			ids[i] = root.ID
+		}
+
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("listed %v: expected roots %v, got %v", test.listed, test.expected, ids)
+		}
+	}
+}
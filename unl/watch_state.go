@@ -0,0 +1,115 @@
+package unl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jasonthorsness/unlurker/hn"
+)
+
+// SaveWatchState replaces the threads last seen by watch mode, so a restarted watch reports only what changed since.
+func (s *Store) SaveWatchState(ctx context.Context, threads []*ActiveThread) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM watch_thread")
+	if err != nil {
+		return fmt.Errorf("failed to clear watch state: %w", err)
+	}
+
+	for i, thread := range threads {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT OR IGNORE INTO watch_thread(ID, position, title, score, comments, active, users) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?)",
+			thread.ID(), i, thread.Root.Title, thread.Root.Score, thread.Comments, thread.Active, thread.Users)
+		if err != nil {
+			return fmt.Errorf("failed to insert watch thread: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WatchState returns the threads saved by SaveWatchState in the same order. Only the ID, title, and score of each
+// root are kept, which is what Diff compares.
+func (s *Store) WatchState(ctx context.Context) (_ []*ActiveThread, err error) {
+	rows, err := s.queryContext(
+		ctx, "SELECT ID, title, score, comments, active, users FROM watch_thread ORDER BY position")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(rows *sql.Rows) { err = errors.Join(err, rows.Close()) }(rows)
+
+	var result []*ActiveThread
+
+	for rows.Next() {
+		var root hn.Item
+
+		thread := &ActiveThread{&root, 0, 0, 0}
+
+		err = rows.Scan(&root.ID, &root.Title, &root.Score, &thread.Comments, &thread.Active, &thread.Users)
+		if err != nil {
+			return nil, fmt.Errorf("watch thread scan: %w", err)
+		}
+
+		result = append(result, thread)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("watch thread rows err: %w", err)
+	}
+
+	return result, nil
+}
+
+// ResetWatchState forgets the saved threads and refresh time, so the next watch poll reports every thread as added
+// and marks nothing as new.
+func (s *Store) ResetWatchState(ctx context.Context) error {
+	err := s.execContext(ctx, "DELETE FROM watch_thread")
+	if err != nil {
+		return err
+	}
+
+	return s.execContext(ctx, "DELETE FROM watch_refresh")
+}
+
+// SaveWatchRefreshed records the time of the latest refresh of the watch view, so the next refresh, even by a
+// restarted watch, can mark the items that appeared since.
+func (s *Store) SaveWatchRefreshed(ctx context.Context, refreshed time.Time) error {
+	return s.execContext(ctx, "INSERT OR REPLACE INTO watch_refresh(ID, refreshed) VALUES (0, ?)", refreshed.Unix())
+}
+
+// WatchRefreshed returns the time saved by SaveWatchRefreshed, or the zero time if there is none.
+func (s *Store) WatchRefreshed(ctx context.Context) (time.Time, error) {
+	var refreshed int64
+
+	err := s.db.QueryRowContext(ctx, "SELECT refreshed FROM watch_refresh WHERE ID = 0").Scan(&refreshed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read watch refresh time: %w", err)
+	}
+
+	return time.Unix(refreshed, 0), nil
+}